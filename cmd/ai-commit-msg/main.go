@@ -2,27 +2,53 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/nycjay/ai-commit-msg/pkg/ai"
+	"github.com/nycjay/ai-commit-msg/pkg/clipboard"
+	"github.com/nycjay/ai-commit-msg/pkg/commit"
+	"github.com/nycjay/ai-commit-msg/pkg/completion"
 	"github.com/nycjay/ai-commit-msg/pkg/config"
 	"github.com/nycjay/ai-commit-msg/pkg/git"
 	"github.com/nycjay/ai-commit-msg/pkg/key"
+	"github.com/nycjay/ai-commit-msg/pkg/scan"
+	"github.com/nycjay/ai-commit-msg/pkg/setup"
+	"github.com/nycjay/ai-commit-msg/pkg/ui"
+	"github.com/nycjay/ai-commit-msg/pkg/usage"
 	"golang.org/x/term"
 )
 
 var version string
 
+// exitAborted is the exit code used when the user declines to commit the message,
+// so scripts can tell an abort (2) from success (0) and errors (1)
+const exitAborted = 2
+
+// maxUntrackedFiles bounds the untracked files listed with --include-untracked-context,
+// as enhanced context does for related files
+const maxUntrackedFiles = 10
+
+// exitInterrupted is the exit code used when Ctrl-C cancels a request, as a shell
+// would report for a process ended by SIGINT
+const exitInterrupted = 130
+
+// exit terminates the program; tests replace it to observe the exit code
+var exit = os.Exit
+
 func init() {
 	// If version is not set during build, use a default
 	if version == "" {
@@ -48,28 +74,71 @@ type Message struct {
 
 type Response struct {
 	Content []Content `json:"content"`
+	Usage   struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
 }
 
 type Content struct {
 	Text string `json:"text"`
 }
 
-type GitDiff struct {
-	StagedFiles     []string
-	Diff            string
-	Branch          string
-	JiraID          string // Field for Jira ID
-	JiraDescription string // Field for Jira description
-}
-
 // Global variables
 var executableDir string
 var cfg *config.Config
 
+// logOutput is where log messages are written: stderr, so stdout only carries the
+// message, or the file given with --log-file
+var logOutput io.Writer = os.Stderr
+
+// uiOutput is where progress lines, banners and questions are written. Like git, the
+// tool keeps them on stderr so the message on stdout can be piped.
+var uiOutput io.Writer = os.Stderr
+
+// resultOutput is the original stdout, where the generated message is written
+var resultOutput io.Writer = os.Stdout
+
+// testProviderName is the provider named after the test-provider subcommand, if any
+var testProviderName string
+
+// runArgs are the command-line arguments in effect, which with the rerun subcommand
+// start with the options of the last run
+var runArgs []string
+
+// generatedBy is the provider and model that generated the latest message, such as
+// anthropic/claude-3-haiku-20240307, for the --attribution trailer
+var generatedBy string
+
+// jiraLinks are the links to the Jira tickets of the changes, built from jira_base_url,
+// for the Jira trailers
+var jiraLinks []string
+
+// commitNote is the rationale the model wrote for the chosen message with --notes,
+// attached to the commit as a git note
+var commitNote string
+
+// jsonResult is the object printed to stdout in JSON mode
+type jsonResult struct {
+	Message     string   `json:"message"`
+	Provider    string   `json:"provider"`
+	Model       string   `json:"model"`
+	Branch      string   `json:"branch"`
+	JiraID      string   `json:"jira_id"`
+	JiraIDs     []string `json:"jira_ids,omitempty"`
+	JiraURLs    []string `json:"jira_urls,omitempty"`
+	IssueRef    string   `json:"issue_ref,omitempty"`
+	StagedFiles []string `json:"staged_files"`
+	ElapsedMs   int64    `json:"elapsed_ms"`
+	Committed   bool     `json:"committed"`
+	Candidates  []string `json:"candidates,omitempty"`
+	Rationale   string   `json:"rationale,omitempty"`
+}
+
 // log prints a message only if the current verbosity level is >= the required level
 func log(level config.VerbosityLevel, format string, args ...interface{}) {
 	if cfg.GetVerbosity() >= level {
-		fmt.Printf(format+"\n", args...)
+		fmt.Fprintf(logOutput, format+"\n", args...)
 	}
 }
 
@@ -86,34 +155,106 @@ func printHelp() {
 	fmt.Println("USAGE:")
 	fmt.Println("  ai-commit-msg [OPTIONS]")
 	fmt.Println("  ai-commit-msg init-prompts")
+	fmt.Println("  ai-commit-msg init-config [--force]")
+	fmt.Println("  ai-commit-msg pr [--since REF] [--gh]")
+	fmt.Println("  ai-commit-msg rerun [OPTIONS]")
 	fmt.Println("")
 	fmt.Println("OPTIONS:")
 	fmt.Println("  -k, --key             Anthropic API key (can also be set with ANTHROPIC_API_KEY environment variable")
 	fmt.Println("                        or stored in your system credential manager)")
+	fmt.Println("  --key-file FILE       Read the API key for the selected provider from FILE")
 	fmt.Println("  -j, --jira            Jira issue ID (e.g., GTBUG-123 or GTN-456) to include in the commit message")
 	fmt.Println("  -d, --jira-desc       Jira issue description to provide additional context for the commit message")
+	fmt.Println("  --jira-url URL        Jira site (e.g. https://company.atlassian.net) to link tickets to with a Jira trailer")
+	fmt.Println("  --no-jira             Leave Jira out: no Jira IDs from the branch or --jira, and no placeholders (or jira_enabled = false)")
+	fmt.Println("  -i, --issue           GitLab issue reference (e.g., #123 or group/project#123) to include in the commit message")
 	fmt.Println("  -s, --store-key       Store the provided API key in your system credential manager for future use")
 	fmt.Println("  -a, --auto            Automatically commit using the generated message without confirmation")
 	fmt.Println("  -v                    Enable verbose output (level 1)")
 	fmt.Println("  -vv                   Enable more verbose output (level 2)")
 	fmt.Println("  -vvv                  Enable debug output (level 3)")
+	fmt.Println("                        Each v counts, also in combined flags such as -vva")
   fmt.Println("  -c, --context N       Number of context lines to include in the diff (default: 3)")
   fmt.Println("  -cc                   Include more context lines (10)")
   fmt.Println("  -ccc                  Include maximum context with enhanced analysis (entire file + code structure)")
-	fmt.Println("  -p, --provider NAME   Specify LLM provider to use (anthropic, openai, gemini) (default: anthropic)")
+	fmt.Println("  -p, --provider NAME   Specify LLM provider to use (anthropic, openai, gemini, custom, bedrock) (default: anthropic)")
 	fmt.Println("  -m, --model MODEL     Specify model to use (provider-specific)")
+	fmt.Println("  --allow-unknown-model Use a model even if it is not in the provider's list of known models")
+	fmt.Println("  -L, --language LANG   Write the commit message in another language (e.g. es, ja, de)")
 
 	fmt.Println("  --system-prompt PATH  Specify a custom system prompt file path")
 	fmt.Println("  --user-prompt PATH    Specify a custom user prompt file path")
+	fmt.Println("  --prompt-dir DIR      Read custom prompt files from DIR instead of the config directory")
+	fmt.Println("  --style-examples N    Include the last N commit messages as style examples (default: 0)")
+	fmt.Println("  -N, --candidates N    Generate N candidate messages and choose one (default: 1)")
+	fmt.Println("  -S, --signoff         Add a Signed-off-by trailer from git config user.name/user.email")
+	fmt.Println("  --attribution         Add a trailer naming the model, e.g. AI-Generated-By: anthropic/claude-3-haiku-20240307")
+	fmt.Println("  --co-author \"N <E>\"   Add a Co-authored-by trailer for N <E> (repeatable; see also co_authors)")
+	fmt.Println("  --edit                Open the message in your editor and commit what you save, without asking")
+	fmt.Println("  --show-prompt         Print the system and user prompts exactly as they are sent")
+	fmt.Println("  --analyze             Suggest how to split the staged changes into several commits, without committing")
+	fmt.Println("  --show-prompt-only    Print the prompts and exit without calling the provider")
+	fmt.Println("  --body                Generate a subject line plus a bulleted body summarizing each area")
+	fmt.Println("  --include-untracked-context  List untracked files next to the changed files in the prompt")
+	fmt.Println("  --include-diff-context-from-unstaged  Also send the working-tree content of the staged files, unstaged edits included, as context")
+	fmt.Println("  --log-file PATH       Append log output to PATH instead of printing it to the terminal")
+	fmt.Println("  --stream              Stream the commit message to the terminal as it is generated")
+	fmt.Println("  --structured          Ask OpenAI and Gemini models for the message as JSON fields instead of text")
+	fmt.Println("  --timeout DURATION    Timeout for requests to the provider, e.g. 60s or 2m (default: 30s)")
+	fmt.Println("                        With --candidates, the deadline for the whole set, per batch of 4 requests")
+	fmt.Println("  --timeout-per-candidate DURATION  Time allowed for each candidate request with --candidates, in place of --timeout")
+	fmt.Println("  --candidates-deadline DURATION    Deadline for the whole set of candidates with --candidates")
+	fmt.Println("                        (default: the candidate timeout for each batch of 4 requests)")
+	fmt.Println("  --max-prompt-tokens N Warn before sending a prompt larger than about N tokens (default: 0, no limit)")
+	fmt.Println("  --max-diff-bytes N    Truncate diffs larger than N bytes (default: 100000, 0 for no limit)")
+	fmt.Println("  --max-subject-length N Warn about subject lines longer than N characters (default: 72, 0 for no limit)")
+	fmt.Println("  --context-file FILE   Send FILE as project context instead of a summary of the README")
+	fmt.Println("  --gpg-sign            Sign the commit with GPG (git's commit.gpgsign is followed automatically)")
+	fmt.Println("  --gpg-key KEYID       Sign the commit with GPG using KEYID")
+	fmt.Println("  --no-gpg-sign         Don't sign the commit, even with commit.gpgsign or sign_commits")
+	fmt.Println("  --strict              Ask the model again when the subject line is over the limit,")
+	fmt.Println("                        and stop instead of asking when the changes look like they contain secrets")
+	fmt.Println("  -A, --all             Stage all changes (git add -A) when nothing is staged")
+	fmt.Println("  --amend               Regenerate the message for the last commit and amend it")
+	fmt.Println("  --no-verify           Skip the pre-commit and commit-msg hooks when committing")
+	fmt.Println("  --commit-arg ARG      Pass ARG to git commit, e.g. --commit-arg --date=now (repeatable)")
+	fmt.Println("  --notes               Keep the message concise and attach the model's rationale as a git note")
+	fmt.Println("  --force               Ignore the message prepared by a merge, rebase or cherry-pick")
+	fmt.Println("  --stat-only           Send only the diff stat and file list, not the full diff")
+	fmt.Println("  --summarize-first     Summarize each file's diff first, then write the message from the summaries")
+	fmt.Println("  --offline             Write a basic message from the changed files, without calling a provider")
+	fmt.Println("  --no-emoji            Leave emoji out of the first-time setup messages")
+	fmt.Println("  --diff-file FILE      Read the diff from FILE instead of git (use - for stdin)")
+	fmt.Println("  --since REF           Summarize all commits since REF in one message (nothing is committed)")
+	fmt.Println("  --pr-description      Write a pull request description instead of a commit message")
+	fmt.Println("  --gh                  With pr or --pr-description, open the pull request with gh pr create")
+	fmt.Println("  -C, --clipboard       Copy the message to the clipboard instead of committing")
+	fmt.Println("  --template FILE       Have the model fill in a commit template (default: git's commit.template)")
+	fmt.Println("  --only PATHSPEC       Describe and commit only the staged files matching PATHSPEC (repeatable)")
+	fmt.Println("  -q, --quiet           Print only the message, or nothing when committing with -a")
+	fmt.Println("  --non-interactive     Never run the first-time setup; fail with instructions if no API key is found")
+	fmt.Println("  --json                Print the result as a JSON object on stdout (logs go to stderr, no prompt)")
+	fmt.Println("  --branch NAME         Branch name to use for the prompt and Jira ID detection")
+	fmt.Println("  -R, --repo DIR        Run git in DIR instead of the current directory")
+	fmt.Println("  --profile NAME        Apply the settings of a [profiles.NAME] table in the config")
 	fmt.Println("  --remember            Remember command-line options in config for future use")
 	fmt.Println("  -h, --help            Display this help information")
 	fmt.Println("  --version             Display version information")
 	fmt.Println("")
 	fmt.Println("SUBCOMMANDS:")
 	fmt.Println("  init-prompts           Initialize custom prompt files in your config directory")
+	fmt.Println("  init-config            Write a commented config.toml with every setting (--force to overwrite)")
+	fmt.Println("  pr                     Write a pull request title and description for the commits since the")
+	fmt.Println("                         default branch (or --since REF); add --gh to open it with gh")
+	fmt.Println("  rerun                 Generate again with the provider, model, context and Jira options of the last run")
 	fmt.Println("  show-config            Display the current configuration")
 	fmt.Println("  list-providers        List all supported AI providers")
 	fmt.Println("  list-models           List available models for providers")
+	fmt.Println("  usage                 Show the tokens used and estimated cost so far")
+	fmt.Println("  check-prompts         Check the placeholders in the user prompt files")
+	fmt.Println("  test-provider [NAME]  Send a tiny request to check the API key, model and network")
+	fmt.Println("  list-profiles         List the config profiles usable with --profile")
+	fmt.Println("  completion SHELL      Print a completion script for bash, zsh or fish")
 	fmt.Println("")
 	fmt.Println("SUBCOMMAND EXAMPLES:")
 	fmt.Println("  # List all providers")
@@ -125,6 +266,9 @@ func printHelp() {
 	fmt.Println("  # List models for a specific provider")
 	fmt.Println("  ai-commit-msg list-models anthropic")
 	fmt.Println("")
+	fmt.Println("  # Enable tab completion in bash (zsh and fish work the same way)")
+	fmt.Println("  source <(ai-commit-msg completion bash)")
+	fmt.Println("")
 	fmt.Println("CONFIGURATION:")
 	fmt.Println("  The tool stores configuration in:")
 	
@@ -142,6 +286,9 @@ func printHelp() {
 	fmt.Println("  - AI_COMMIT_MODEL_NAME=...  # Set Claude model")
 	fmt.Println("  - AI_COMMIT_SYSTEM_PROMPT_PATH=... # Custom system prompt file path")
 	fmt.Println("  - AI_COMMIT_USER_PROMPT_PATH=...   # Custom user prompt file path")
+	fmt.Println("  - AI_COMMIT_JIRA_PREFIXES=GTN,OPS  # Jira project prefixes (comma-separated)")
+	fmt.Println("  - AI_COMMIT_TIMEOUT=60s            # Request timeout for the provider")
+	fmt.Println("  - AI_COMMIT_LANGUAGE=es            # Language for commit messages")
 	fmt.Println("")
 	fmt.Println("CUSTOM PROMPTS:")
 	promptDir, err := cfg.GetPromptDirectory()
@@ -152,7 +299,10 @@ func printHelp() {
 	}
 	fmt.Println("  System prompt file: system_prompt.txt")
 	fmt.Println("  User prompt file: user_prompt.txt")
+	fmt.Println("  User prompts use placeholders such as {{.Branch}}, {{.Files}}, {{.Diff}} and {{.JiraID}}")
+	fmt.Println("  Check the placeholders in your user prompt files with: ai-commit-msg check-prompts")
 	fmt.Println("  You can also specify custom prompt file paths in the config file or command line")
+	fmt.Println("  A [templates] table in the config file picks a user prompt per change type, e.g. docs = \"/path/docs.txt\"")
 	fmt.Println("")
 	
 	fmt.Println("SETUP & API KEYS:")
@@ -174,7 +324,7 @@ func printHelp() {
 
 	keyManager := cfg.GetKeyManager()
 	fmt.Printf("  The API key is stored in the %s with:\n", keyManager.GetCredentialStoreName())
-	fmt.Println("    - Service name: " + key.KeychainService)
+	fmt.Println("    - Service name: " + keyManager.GetServiceName())
 	fmt.Println("    - Account name: " + key.KeychainAccount)
 	fmt.Println("")
 	fmt.Println("JIRA INTEGRATION:")
@@ -182,6 +332,8 @@ func printHelp() {
 	fmt.Println("  - Specify an ID directly: ai-commit-msg --jira GTBUG-123")
 	fmt.Println("  - Add a description: ai-commit-msg --jira GTBUG-123 --jira-desc \"Fix memory leak issue\"")
 	fmt.Println("  - If no ID is provided, the tool will try to extract it from the branch name or suggest a placeholder")
+	fmt.Println("  - Link to the ticket: ai-commit-msg --jira-url https://company.atlassian.net (or set jira_base_url)")
+	fmt.Println("  - Repositories without Jira: ai-commit-msg --no-jira (or set jira_enabled = false)")
 	fmt.Println("")
 	fmt.Println("EXAMPLES:")
 	fmt.Println("  # Generate a commit message (will prompt for API key if not found):")
@@ -199,6 +351,15 @@ func printHelp() {
 	fmt.Println("  # Generate and automatically commit:")
 	fmt.Println("  ai-commit-msg -a")
 	fmt.Println("")
+	fmt.Println("  # Generate a message for a diff produced elsewhere (e.g. in CI):")
+	fmt.Println("  git diff main...HEAD | ai-commit-msg --diff-file - --branch feature/GTN-123-ci")
+	fmt.Println("")
+	fmt.Println("  # Summarize a branch for a squash merge or pull request:")
+	fmt.Println("  ai-commit-msg --since main --pr-description")
+	fmt.Println("")
+	fmt.Println("  # Rewrite the message of the last commit:")
+	fmt.Println("  ai-commit-msg --amend")
+	fmt.Println("")
 	fmt.Println("  # Generate with different levels of verbosity:")
 	fmt.Println("  ai-commit-msg -v     # Basic verbose output")
 	fmt.Println("  ai-commit-msg -vv    # More detailed output")
@@ -226,11 +387,71 @@ func printHelp() {
 	fmt.Println("")
 }
 
+// isInteractive reports whether the user can be prompted: stdin is a terminal and
+// --non-interactive wasn't given
+func isInteractive() bool {
+	return !cfg.IsNonInteractive() && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// canPrompt reports whether the user can be asked a question during generation, which
+// --json and --quiet rule out as well
+func canPrompt() bool {
+	return isInteractive() && !cfg.IsJSONOutput() && !cfg.IsQuiet()
+}
+
+// reenterAPIKey explains that the provider rejected the API key and offers to enter a
+// new one, which is used for the rest of the run and optionally replaces the stored
+// key. It returns false if no new key was entered.
+func reenterAPIKey(providerName string, authErr error) bool {
+	if providerName == "" {
+		providerName = string(ai.ProviderAnthropic)
+	}
+	fmt.Fprintf(uiOutput, "\n%s rejected the API key: %v\n", strings.Title(providerName), authErr)
+	fmt.Fprintln(uiOutput, "The key may have been revoked, expired or mistyped.")
+	fmt.Fprint(uiOutput, "Would you like to enter a new API key? (y/n): ")
+	var response string
+	fmt.Scanln(&response)
+	if response = strings.ToLower(strings.TrimSpace(response)); response != "y" && response != "yes" {
+		return false
+	}
+
+	enteredKey, err := readPasswordFromTerminal("Paste your new API key here: ")
+	if err != nil {
+		fmt.Fprintf(uiOutput, "Error reading API key: %v\n", err)
+		return false
+	}
+	apiKey := strings.TrimSpace(enteredKey)
+	if apiKey == "" {
+		fmt.Fprintln(uiOutput, "No API key entered.")
+		return false
+	}
+	cfg.SetProviderKey(providerName, apiKey)
+
+	keyManager := cfg.GetKeyManager()
+	if keyManager.CredentialStoreAvailable() {
+		fmt.Fprintf(uiOutput, "Replace the %s API key stored in %s? (y/n): ", strings.Title(providerName), keyManager.GetCredentialStoreName())
+		fmt.Scanln(&response)
+		if response = strings.ToLower(strings.TrimSpace(response)); response == "y" || response == "yes" {
+			if err := cfg.StoreProviderAPIKey(providerName, apiKey); err != nil {
+				fmt.Fprintf(uiOutput, "Error storing API key: %v\n", err)
+			} else {
+				fmt.Fprintf(uiOutput, "✅ API key updated in %s.\n", keyManager.GetCredentialStoreName())
+			}
+		}
+	}
+
+	// A key from the environment is found before the stored one on the next run
+	if envVarName := strings.ToUpper(providerName) + "_API_KEY"; os.Getenv(envVarName) != "" {
+		fmt.Fprintf(uiOutput, "Note: %s is set and is used before the stored key, so update it as well.\n", envVarName)
+	}
+	return true
+}
+
 // readPasswordFromTerminal reads a password from the terminal without echoing it
 func readPasswordFromTerminal(prompt string) (string, error) {
-	fmt.Print(prompt)
+	fmt.Fprint(uiOutput, prompt)
 	passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Println() // Add a newline after the user presses Enter
+	fmt.Fprintln(uiOutput) // Add a newline after the user presses Enter
 	if err != nil {
 		return "", err
 	}
@@ -253,8 +474,9 @@ func findExecutableDir() (string, error) {
 	return cwd, nil
 }
 
-// readPromptFile reads a prompt file from the appropriate location and indicates if it's using a custom version
-func readPromptFile(filename string) (string, bool, string, error) {
+// readPromptFile reads a prompt file for the provider from the appropriate location and
+// indicates if it's using a custom version
+func readPromptFile(providerName, filename string) (string, bool, string, error) {
 	var customPath string
 	var promptSource string
 	var isCustom bool
@@ -266,15 +488,15 @@ func readPromptFile(filename string) (string, bool, string, error) {
 	} else if filename == "user_prompt.txt" && cfg.GetUserPromptPath() != "" {
 		customPath = cfg.GetUserPromptPath()
 		logVerbose("Using custom user prompt path: %s", customPath)
-	} else if filename == "enhanced_user_prompt.txt" && cfg.GetUserPromptPath() != "" {
-		// Check if a custom path was specified for the enhanced version too
+	} else if (filename == "enhanced_user_prompt.txt" || filename == "body_user_prompt.txt" || filename == "pr_description_prompt.txt") && cfg.GetUserPromptPath() != "" {
+		// Check if a custom path was specified for the enhanced, body or PR description version too
 		customPath = cfg.GetUserPromptPath()
-		customPath = strings.Replace(customPath, "user_prompt.txt", "enhanced_user_prompt.txt", 1)
+		customPath = strings.Replace(customPath, "user_prompt.txt", filename, 1)
 		if _, err := os.Stat(customPath); err != nil {
-			// If the enhanced version doesn't exist, don't use the custom path
+			// If this version doesn't exist, don't use the custom path
 			customPath = ""
 		} else {
-			logVerbose("Using custom enhanced user prompt path: %s", customPath)
+			logVerbose("Using custom %s path: %s", filename, customPath)
 		}
 	}
 
@@ -290,53 +512,42 @@ func readPromptFile(filename string) (string, bool, string, error) {
 		logVerbose("Failed to read custom prompt file: %v, falling back to default", err)
 	}
 
-	// Try reading from the user's config directory
-	promptDir, err := cfg.GetPromptDirectory()
-	if err == nil {
-		promptPath := filepath.Join(promptDir, filename)
-		logVerbose("Checking for prompt file in config directory: %s", promptPath)
-		
-		if _, err := os.Stat(promptPath); err == nil {
-			logVerbose("Reading prompt file from config directory: %s", promptPath)
-			content, err := os.ReadFile(promptPath)
-			if err == nil {
-				isCustom = true
-				promptSource = fmt.Sprintf("config directory: %s", promptPath)
-				return string(content), isCustom, promptSource, nil
-			}
-			logVerbose("Failed to read prompt file from config directory: %v, falling back to default", err)
-		}
-	}
-
-	// Fall back to the executable directory (default prompts)
-	promptsDir := filepath.Join(executableDir, "prompts")
-	promptPath := filepath.Join(promptsDir, filename)
-
-	logVerbose("Reading prompt file from executable directory: %s", promptPath)
-	content, err := os.ReadFile(promptPath)
+	// Search the prompt directories, where the provider's own prompts come first, and
+	// then the default prompts next to the executable
+	content, promptPath, err := cfg.ReadProviderPrompt(providerName, filename)
 	if err != nil {
-		return "", false, "", fmt.Errorf("failed to read prompt file from any location: %v", err)
+		return "", false, "", err
 	}
-
-	promptSource = fmt.Sprintf("default: %s", promptPath)
-	return string(content), false, promptSource, nil
+	if promptPath == filepath.Join(executableDir, "prompts", filename) {
+		logVerbose("Read prompt file from executable directory: %s", promptPath)
+		return content, false, fmt.Sprintf("default: %s", promptPath), nil
+	}
+	logVerbose("Read prompt file from prompt directory: %s", promptPath)
+	return content, true, fmt.Sprintf("prompt directory: %s", promptPath), nil
 }
 
-func parseArgs() (bool, bool, bool, bool, bool, bool, []string) {
+func parseArgs() (bool, bool, bool, bool, bool, bool, bool, bool, bool, bool, bool, []string, error) {
 	// Variables to store the extracted values
 	var unknownFlags []string
 	var isInitPrompts bool
+	var isInitConfig bool
+	var isPR bool
 	var isShowConfig bool
 	var isListProviders bool
 	var isListModels bool
+	var isUsage bool
+	var isCheckPrompts bool
+	var isTestProvider bool
+	var isListProfiles bool
 	var isVersion bool
+	var isRerun bool
 
 	// First, check for version flag
 	for _, arg := range os.Args[1:] {
 		if arg == "--version" {
 			isVersion = true
 			// Skip further parsing for version command
-			return false, false, false, false, false, true, unknownFlags
+			return false, false, false, false, false, false, false, false, false, false, true, unknownFlags, nil
 		}
 	}
 
@@ -344,13 +555,31 @@ func parseArgs() (bool, bool, bool, bool, bool, bool, []string) {
 	// Also check for various subcommands
 	for i, arg := range os.Args[1:] {
 		if arg == "-h" || arg == "--help" {
-			return true, false, false, false, false, false, unknownFlags
+			return true, false, false, false, false, false, false, false, false, false, false, unknownFlags, nil
 		} else if arg == "init-prompts" {
 			isInitPrompts = true
+		} else if arg == "init-config" {
+			isInitConfig = true
+		} else if arg == "pr" {
+			isPR = true
 		} else if arg == "show-config" {
 			isShowConfig = true
 		} else if arg == "list-providers" {
 			isListProviders = true
+		} else if arg == "usage" {
+			isUsage = true
+		} else if arg == "check-prompts" {
+			isCheckPrompts = true
+		} else if arg == "list-profiles" {
+			isListProfiles = true
+		} else if arg == "rerun" {
+			isRerun = true
+		} else if arg == "test-provider" {
+			// The provider to test is optional; the configured one is used otherwise
+			if i+2 < len(os.Args) && !strings.HasPrefix(os.Args[i+2], "-") {
+				testProviderName = strings.ToLower(os.Args[i+2])
+			}
+			isTestProvider = true
 		} else if arg == "list-models" {
 			// Check if there's a provider specified
 			if i+2 < len(os.Args) {
@@ -360,13 +589,90 @@ func parseArgs() (bool, bool, bool, bool, bool, bool, []string) {
 		}
 	}
 
+	// rerun repeats the options of the last run, before the ones given now so those win
+	runArgs = os.Args[1:]
+	if isRerun {
+		lastArgs, err := loadLastRun()
+		if errors.Is(err, config.ErrNoLastRun) {
+			fmt.Fprintln(uiOutput, "Error: there is no previous run to repeat; generate a message once first")
+			os.Exit(1)
+		} else if err != nil {
+			fmt.Fprintf(uiOutput, "Error reading the last run: %v\n", err)
+			os.Exit(1)
+		}
+		runArgs = append(lastArgs, runArgs...)
+	}
+
 	// Use the config package to parse arguments
-	unknownFlags, err := cfg.ParseCommandLineArgs(os.Args[1:])
+	unknownFlags, err := cfg.ParseCommandLineArgs(runArgs)
 	if err != nil {
-		fmt.Printf("Error parsing command line arguments: %v\n", err)
+		return false, false, false, false, false, false, false, false, false, false, false, nil, err
+	}
+
+	// The pr subcommand describes the commits on the current branch since the default
+	// branch, or since --since, as a pull request
+	if isPR {
+		cfg.SetPRDescription(true)
+		if cfg.GetSince() == "" {
+			base, err := git.GetDefaultBranch()
+			if err != nil {
+				fmt.Fprintf(uiOutput, "Error: %v\n", err)
+				fmt.Fprintln(uiOutput, "Use --since REF to choose the branch the pull request is opened against.")
+				os.Exit(1)
+			}
+			cfg.SetSince(base)
+		}
+	}
+	if cfg.IsGHEnabled() && (!cfg.IsPRDescriptionEnabled() || cfg.IsJSONOutput()) {
+		fmt.Println("Error: --gh only works with the pr subcommand or --pr-description, and not with --json")
+		os.Exit(1)
+	}
+	if cfg.IsGHEnabled() && cfg.IsClipboardEnabled() {
+		fmt.Println("Error: --gh and --clipboard can't be used together")
+		os.Exit(1)
+	}
+	if cfg.IsAnalyzeEnabled() && (cfg.IsPRDescriptionEnabled() || cfg.GetSince() != "" || cfg.IsAmendEnabled()) {
+		fmt.Println("Error: --analyze only works on staged changes, not with the pr subcommand, --since or --amend")
+		os.Exit(1)
+	}
+	if cfg.IsOfflineEnabled() && (cfg.IsSummarizeFirstEnabled() || cfg.IsAnalyzeEnabled()) {
+		fmt.Println("Error: --summarize-first and --analyze need a provider, so they can't be used with --offline")
+		os.Exit(1)
+	}
+
+	return false, isInitPrompts, isInitConfig, isShowConfig, isListProviders, isListModels, isUsage, isCheckPrompts, isTestProvider, isListProfiles, isVersion, unknownFlags, nil
+}
+
+// subcommands lists the subcommands offered by shell completion
+var subcommands = []string{"init-prompts", "init-config", "pr", "rerun", "show-config", "list-providers", "list-models", "usage", "check-prompts", "test-provider", "list-profiles", "completion"}
+
+// completionSpec describes the command line for the shell completion scripts
+func completionSpec() completion.Spec {
+	spec := completion.Spec{
+		Program:     "ai-commit-msg",
+		Subcommands: subcommands,
+		SingleFlags: config.SingleFlags(),
+		ParamFlags:  config.ParamFlags(),
+		FileFlags:   []string{"--system-prompt", "--user-prompt", "--prompt-dir", "--context-file", "--diff-file", "--log-file", "--template"},
 	}
+	for _, provider := range ai.GetAllProviders() {
+		spec.Providers = append(spec.Providers, provider.GetName())
+		spec.Models = append(spec.Models, provider.GetAvailableModels()...)
+	}
+	return spec
+}
 
-	return false, isInitPrompts, isShowConfig, isListProviders, isListModels, isVersion, unknownFlags
+// printCompletion prints the completion script for the given shell
+func printCompletion(shell string) error {
+	if shell == "" {
+		return fmt.Errorf("usage: ai-commit-msg completion [%s]", strings.Join(completion.Shells, "|"))
+	}
+	script, err := completion.Generate(shell, completionSpec())
+	if err != nil {
+		return err
+	}
+	fmt.Print(script)
+	return nil
 }
 
 // printProviderInfo prints details about available providers and models
@@ -379,6 +685,8 @@ func printProviderInfo(listProvidersOnly bool, specificProvider string) {
 		"anthropic": "Anthropic Claude",
 		"openai":    "OpenAI",
 		"gemini":    "Google Gemini",
+		"custom":    "OpenAI-compatible server (custom_base_url)",
+		"bedrock":   "Anthropic Claude on AWS Bedrock (AWS credentials)",
 	}
 
 	if listProvidersOnly || specificProvider == "" {
@@ -395,6 +703,8 @@ func printProviderInfo(listProvidersOnly bool, specificProvider string) {
 			"anthropic": &ai.AnthropicProvider{},
 			"openai":    &ai.OpenAIProvider{},
 			"gemini":    &ai.GeminiProvider{},
+			"custom":    ai.NewCustomProvider(),
+			"bedrock":   ai.NewBedrockProvider(),
 		}
 
 		fmt.Println("\nAvailable Models:")
@@ -453,6 +763,16 @@ func printConfigDetails() {
 		fmt.Printf("Config Directory: %s\n", configDir)
 	}
 
+	// Repository config file
+	if repoConfig := cfg.GetRepoConfigFile(); repoConfig != "" {
+		fmt.Printf("Repository Config: %s\n", repoConfig)
+	}
+
+	// Profile
+	if profile := cfg.GetProfile(); profile != "" {
+		fmt.Printf("Profile: %s\n", profile)
+	}
+
 	// Verbosity
 	verbosityNames := map[config.VerbosityLevel]string{
 		config.Silent:       "Silent",
@@ -475,9 +795,29 @@ func printConfigDetails() {
 
 	// Current Provider and Model
 	currentProvider := cfg.GetProvider()
-	currentModel := cfg.GetModelName()
+	currentModel := effectiveModelName(currentProvider)
 	fmt.Printf("Current Provider: %s\n", currentProvider)
 	fmt.Printf("Current Model: %s\n", currentModel)
+	if baseURL := cfg.GetCustomBaseURL(); baseURL != "" {
+		fmt.Printf("Custom Provider: %s (model: %s, API key required: %v)\n", baseURL, cfg.GetCustomModel(), cfg.IsCustomRequireKeyEnabled())
+	}
+	if region := cfg.GetBedrockRegion(); region != "" {
+		fmt.Printf("Bedrock Region: %s\n", region)
+	}
+
+	// Commit message language
+	language := cfg.GetLanguage()
+	if language == "" {
+		language = "English (default)"
+	} else {
+		language = fmt.Sprintf("%s (%s)", ai.LanguageName(language), language)
+	}
+	fmt.Printf("Language: %s\n", language)
+
+	// Post-generate hook
+	if hook := cfg.GetPostGenerateHook(); hook != "" {
+		fmt.Printf("Post-Generate Hook: %s\n", hook)
+	}
 
 	// System and User Prompt Paths
 	systemPromptPath := cfg.GetSystemPromptPath()
@@ -495,6 +835,12 @@ func printConfigDetails() {
 	} else {
 		fmt.Println("  User Prompt: Default")
 	}
+	if prefix := cfg.GetSystemPromptPrefix(); prefix != "" {
+		fmt.Printf("  System Prompt Prefix: %q\n", prefix)
+	}
+	if suffix := cfg.GetSystemPromptSuffix(); suffix != "" {
+		fmt.Printf("  System Prompt Suffix: %q\n", suffix)
+	}
 
 	// Flags
 	fmt.Println("\nFlags:")
@@ -575,6 +921,19 @@ func main() {
 		}
 	}
 
+	// The completion script goes to stdout, so print it before anything else can
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		shell := ""
+		if len(os.Args) > 2 {
+			shell = os.Args[2]
+		}
+		if err := printCompletion(shell); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Get the executable directory
 	var err error
 	executableDir, err = findExecutableDir()
@@ -583,14 +942,62 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize config
+	// With --repo every git command runs in that directory, which must be known before
+	// the repository config is looked up
+	if repoDir := config.RepoDirFromArgs(os.Args[1:]); repoDir != "" {
+		if err := git.SetDir(repoDir); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Initialize config, with the settings of the profile from --profile on top
 	cfg = config.GetInstance()
+	cfg.SetProfile(config.ProfileFromArgs(os.Args[1:]))
 	if err := cfg.LoadConfig(); err != nil {
+		if errors.Is(err, config.ErrUnknownProfile) {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 		fmt.Printf("Warning: Error loading config: %v\n", err)
 	}
 
+	// Set the executable directory in config for prompt loading, before any subcommand
+	// such as check-prompts reads the bundled prompts
+	cfg.SetExecutableDir(executableDir)
+
+	// Use the configured Jira prefixes for branch name extraction
+	git.SetJiraPrefixes(cfg.GetJiraPrefixes())
+	git.SetLargeFileThreshold(cfg.GetLargeFileThreshold())
+
 	// Parse command line arguments
-	isHelp, isInitPrompts, isShowConfig, isListProviders, isListModels, _, unknownFlags := parseArgs()
+	isHelp, isInitPrompts, isInitConfig, isShowConfig, isListProviders, isListModels, isUsage, isCheckPrompts, isTestProvider, isListProfiles, _, unknownFlags, err := parseArgs()
+	if err != nil {
+		fmt.Printf("Error parsing command line arguments: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Route the git package's progress messages through our logger
+	git.SetLogger(func(format string, args ...interface{}) {
+		log(config.Verbose, format, args...)
+	})
+
+	// Apply the request timeout to all providers
+	ai.SetRequestTimeout(cfg.GetRequestTimeout())
+	ai.SetCandidateTimeout(cfg.GetCandidateTimeout())
+	ai.SetCandidatesDeadline(cfg.GetCandidatesDeadline())
+	ai.SetUsageHandler(recordUsage)
+	ai.SetRateLimitHandler(func(provider string, limit ai.RateLimit) {
+		log(config.Verbose, "Rate limit (%s): %s", provider, limit)
+	})
+	if cfg.GetVerbosity() >= config.Debug {
+		// Keys are redacted from the logged URLs and headers
+		ai.SetDebugLogger(func(format string, args ...interface{}) {
+			log(config.Debug, format, args...)
+		})
+	}
+	ai.SetCustomProviderSettings(cfg.GetCustomBaseURL(), cfg.GetCustomModel(), cfg.IsCustomRequireKeyEnabled())
+	ai.SetBedrockRegion(cfg.GetBedrockRegion())
 
 	// Handle unknown flags
 	if len(unknownFlags) > 0 {
@@ -621,7 +1028,7 @@ func main() {
 		fmt.Printf("Prompt directory: %s\n", promptDir)
 		
 		// Copy prompt files
-		promptFiles := []string{"system_prompt.txt", "user_prompt.txt", "enhanced_user_prompt.txt"}
+		promptFiles := []string{"system_prompt.txt", "user_prompt.txt", "enhanced_user_prompt.txt", "body_user_prompt.txt", "pr_description_prompt.txt", "analyze_prompt.txt", "file_summary_prompt.txt"}
 		for _, file := range promptFiles {
 			if err := copyPromptFile(file); err != nil {
 				fmt.Printf("Error copying %s: %v\n", file, err)
@@ -635,6 +1042,18 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Handle init-config subcommand
+	if isInitConfig {
+		path, err := cfg.WriteStarterConfig(cfg.IsForceEnabled())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote a starter config with every setting and its default to %s\n", path)
+		fmt.Println("Uncomment and edit the settings you want to change.")
+		os.Exit(0)
+	}
+
 	// Handle show-config subcommand
 	if isShowConfig {
 		// Load config to ensure it's up-to-date before showing
@@ -664,17 +1083,68 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Handle usage subcommand
+	if isUsage {
+		if err := printUsageTotals(); err != nil {
+			fmt.Printf("Error reading usage log: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Handle check-prompts subcommand
+	if isCheckPrompts {
+		if !checkPrompts() {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Handle list-profiles subcommand
+	if isListProfiles {
+		printProfiles()
+		os.Exit(0)
+	}
+
+	// Handle test-provider subcommand
+	if isTestProvider {
+		if !testProvider(testProviderName) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Version handling has been moved to an earlier stage in main()
 
-	// Set the executable directory in config for prompt loading
-	cfg.SetExecutableDir(executableDir)
-	
 	// Handle help, list-providers and list-models right away - these don't need git or staged changes
 	if isHelp {
 		printHelp()
 		os.Exit(0)
 	}
 	
+	// In JSON mode stdout is reserved for the result object, so all other
+	// output (warnings, errors, git output) is sent to stderr
+	if cfg.IsJSONOutput() {
+		resultOutput = os.Stdout
+		os.Stdout = os.Stderr
+	} else if cfg.IsQuiet() {
+		// Quiet mode keeps stdout for the message alone; errors and warnings go to stderr
+		resultOutput = os.Stdout
+		os.Stdout = os.Stderr
+	}
+
+	// Send log output to a file so debug logs don't mix with the message on the terminal
+	if logFile := cfg.GetLogFile(); logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Printf("Error opening log file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		logOutput = file
+		fmt.Fprintf(logOutput, "===== ai-commit-msg v%s: %s =====\n", version, time.Now().Format(time.RFC3339))
+	}
+
 	// Save config if remember flag is enabled
 	if cfg.IsRememberFlagsEnabled() {
 		if err := cfg.SaveConfig(); err != nil {
@@ -696,10 +1166,26 @@ func main() {
 	log(config.Verbose, "Context lines: %d", cfg.GetContextLines())
 	log(config.Verbose, "Using provider: %s", cfg.GetProvider())
 
+	// Skip git operations for commands that don't need them
+	requiresGit := !isListProviders && !isListModels && !isHelp && !isInitPrompts && !isInitConfig
+
+	// Without git every step below fails with an opaque exec error, so check for it
+	// before the first-time setup. Storing a key works without git.
+	if requiresGit && !cfg.IsStoreKeyEnabled() {
+		gitVersion, err := git.EnsureAvailable()
+		if err != nil {
+			printGitInstallHelp(err)
+			os.Exit(1)
+		}
+		log(config.Debug, "Using git %s", gitVersion)
+	}
+
 	// Check Jira details
 	jiraID := cfg.GetJiraID()
 	jiraDesc := cfg.GetJiraDesc()
-	if jiraID != "" {
+	if !cfg.IsJiraEnabled() {
+		logVerbose("Jira is turned off: no Jira IDs are looked for or sent")
+	} else if jiraID != "" {
 		logVerbose("Using provided Jira ID: %s", jiraID)
 		if jiraDesc != "" {
 			logVerbose("Using provided Jira description: %s", jiraDesc)
@@ -709,6 +1195,15 @@ func main() {
 	// Get the keyManager for easier access
 	keyManager := cfg.GetKeyManager()
 
+	// A key from --key-file or the provider's api_key_command counts as configured.
+	// --offline never calls a provider, so it needs no key at all.
+	if cfg.IsOfflineEnabled() {
+		logVerbose("Offline: no provider will be called")
+	} else if err := cfg.LoadProviderKey(cfg.GetProvider()); err != nil {
+		fmt.Printf("Error reading API key: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Handle storing the key in credential store if requested
 	apiKey := cfg.GetAPIKey()
 	if cfg.IsStoreKeyEnabled() && apiKey != "" {
@@ -723,148 +1218,56 @@ func main() {
 			fmt.Printf("Error storing API key: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("API key stored successfully in %s.\n", keyManager.GetCredentialStoreName())
+		if !cfg.IsQuiet() {
+			fmt.Println(setup.Text(setup.MsgKeyStored, cfg.IsNoEmoji(), keyManager.GetCredentialStoreName()))
+		}
 		if !cfg.GetAutoCommit() {
 			// Exit if we're just storing the key and not committing
 			os.Exit(0)
 		}
 	}
 
-	// Get API key from various sources if not already set
+	// Get API key from various sources if not already set. Providers that don't
+	// need a key, such as Bedrock or a custom server without one, skip the
+	// first-time setup.
 	if apiKey == "" {
+		apiKey = cfg.GetProviderKey(cfg.GetProvider())
+	}
+	if apiKey == "" && !isKeyOptional(cfg.GetProvider()) && !cfg.IsShowPromptOnly() && !cfg.IsOfflineEnabled() {
 		logVerbose("No API key provided via --key flag, checking environment...")
 		
-		// First-time setup
-		fmt.Println("\n===== Welcome to AI Commit Message Generator =====")
-		fmt.Println("It looks like this is the first time you're using this tool.")
-		
-		// Provider selection
-		providers := []string{"Anthropic", "OpenAI", "Gemini"}
-		fmt.Println("\nLet's start by choosing your default AI provider:")
-		for i, provider := range providers {
-			fmt.Printf("%d. %s\n", i+1, provider)
-		}
-		
-		var providerChoice int
-		for {
-			fmt.Print("\nEnter the number of your preferred provider (1-3): ")
-			_, err := fmt.Scanf("%d", &providerChoice)
-			if err != nil || providerChoice < 1 || providerChoice > len(providers) {
-				fmt.Println("Invalid choice. Please enter a number between 1 and 3.")
-				continue
+		// The setup reads from the terminal, which would hang or loop in CI
+		if !isInteractive() {
+			providerName := cfg.GetProvider()
+			if providerName == "" {
+				providerName = string(ai.ProviderAnthropic)
 			}
-			break
+			fmt.Printf("Error: No API key found for %s, and the first-time setup needs an interactive terminal.\n", providerName)
+			fmt.Printf("Set the %s environment variable, pass --key or --key-file, or run ai-commit-msg once in a terminal.\n",
+				strings.ToUpper(providerName)+"_API_KEY")
+			os.Exit(1)
 		}
 		
-		selectedProvider := strings.ToLower(providers[providerChoice-1])
-		cfg.SetProvider(selectedProvider)
-		
-		// Provider-specific API key guidance
-		var apiKeyUrl string
-		switch selectedProvider {
-		case "anthropic":
-			apiKeyUrl = "https://console.anthropic.com/"
-		case "openai":
-			apiKeyUrl = "https://platform.openai.com/account/api-keys"
-		case "gemini":
-			apiKeyUrl = "https://makersuite.google.com/app/apikey"
+		// First-time setup
+		wizard := setup.Wizard{
+			In:         os.Stdin,
+			Out:        uiOutput,
+			NoEmoji:    cfg.IsNoEmoji(),
+			Quiet:      cfg.IsQuiet(),
+			Store:      keyManager,
+			StoreKey:   cfg.StoreProviderAPIKey,
+			ReadSecret: readPasswordFromTerminal,
 		}
-		
-		fmt.Printf("\nYou've selected %s as your default provider.\n", providers[providerChoice-1])
-		fmt.Println("")
-		fmt.Printf("To use this tool, you'll need an API key from %s.\n", providers[providerChoice-1])
-		fmt.Println("Getting an API key is quick and easy:")
-		fmt.Printf("1. Visit: %s\n", apiKeyUrl)
-		fmt.Println("2. Sign up or log in to your account")
-		fmt.Println("3. Navigate to the API keys section")
-		fmt.Println("4. Create a new API key")
-		fmt.Println("")
-		
-		// Read password without echoing it
-		enteredKey, err := readPasswordFromTerminal("Paste your API key here: ")
-		if err != nil {
-			fmt.Printf("Error reading API key: %v\n", err)
+		result, err := wizard.Run()
+		if errors.Is(err, setup.ErrCancelled) {
 			os.Exit(1)
-		}
-
-		apiKey = strings.TrimSpace(enteredKey)
-		if apiKey == "" {
-			fmt.Println("\nNo API key provided. The tool cannot proceed without an API key.")
-			fmt.Printf("If you're having trouble, visit %s to obtain a key.\n", apiKeyUrl)
+		} else if err != nil {
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
-
-		// Provider-specific key validation
-		var validationPattern string
-		var validationDescription string
-		switch selectedProvider {
-		case "anthropic":
-			validationPattern = `^sk-ant-|^sk-`
-			validationDescription = "Anthropic API keys typically start with 'sk-ant-' or 'sk-'"
-		case "openai":
-			validationPattern = `^sk-`
-			validationDescription = "OpenAI API keys typically start with 'sk-'"
-		case "gemini":
-			validationPattern = `.+`  // Gemini keys have less strict format requirements
-			validationDescription = "Gemini API keys"
-		}
-
-		matched, _ := regexp.MatchString(validationPattern, apiKey)
-		if !matched {
-			fmt.Printf("\nWarning: The provided %s API key format looks incorrect.\n", 
-				strings.Title(selectedProvider))
-			fmt.Println(validationDescription)
-			fmt.Println("- Should be at least 20 characters long")
-			fmt.Println("")
-			fmt.Print("Are you sure you want to continue with this key? (y/n): ")
-			var continueResponse string
-			fmt.Scanln(&continueResponse)
-			if strings.ToLower(continueResponse) != "y" && strings.ToLower(continueResponse) != "yes" {
-				fmt.Println("API key setup cancelled. Exiting.")
-				os.Exit(1)
-			}
-		}
-
-		// Update the API key in config
-		cfg.SetProviderKey(selectedProvider, apiKey)
-
-		// Ask if the user wants to store the key
-		if keyManager.CredentialStoreAvailable() {
-			fmt.Println("\n🔐 API Key Storage")
-			fmt.Println("You can securely store your API key in your system's credential manager.")
-			fmt.Println("This allows you to use the tool without re-entering the key each time.")
-			fmt.Println("")
-			fmt.Printf("Would you like to store your %s API key in %s? (y/n): ", 
-				strings.Title(selectedProvider), keyManager.GetCredentialStoreName())
-			
-			var storeKeyResponse string
-			fmt.Scanln(&storeKeyResponse)
-			storeKeyResponse = strings.TrimSpace(strings.ToLower(storeKeyResponse))
-
-			if storeKeyResponse == "y" || storeKeyResponse == "yes" {
-				if err := cfg.StoreProviderAPIKey(selectedProvider, apiKey); err != nil {
-					fmt.Printf("Error storing API key: %v\n", err)
-					fmt.Println("\nYou'll need to enter the API key manually each time you use the tool.")
-				} else {
-					fmt.Println("\n✅ Success!")
-					fmt.Printf("API key stored securely in %s.\n", keyManager.GetCredentialStoreName())
-					fmt.Println("You won't need to enter it again on this machine.")
-				}
-			} else {
-				fmt.Println("\n⚠️  API key will not be stored.")
-				fmt.Println("You'll need to enter the API key manually each time you use the tool.")
-				
-				envVarName := strings.ToUpper(selectedProvider) + "_API_KEY"
-				fmt.Printf("\nTip: You can also set the %s environment variable to avoid manual entry.\n", envVarName)
-				fmt.Printf("Example: export %s=your_%s_api_key_here\n", envVarName, selectedProvider)
-			}
-		} else {
-			fmt.Println("\n⚠️  No secure credential store available on your platform.")
-			
-			envVarName := strings.ToUpper(selectedProvider) + "_API_KEY"
-			fmt.Printf("Recommended alternative: Set the %s environment variable.\n", envVarName)
-			fmt.Printf("Example: export %s=your_%s_api_key_here\n", envVarName, selectedProvider)
-		}
+		apiKey = result.APIKey
+		cfg.SetProvider(result.Provider)
+		cfg.SetProviderKey(result.Provider, apiKey)
 
 		// Save the configuration to persist the provider selection
 		if err := cfg.SaveConfig(); err != nil {
@@ -874,11 +1277,8 @@ func main() {
 		logVerbose("API key provided via config, environment or command line")
 	}
 
-	// Skip git operations for commands that don't need them
-	requiresGit := !isListProviders && !isListModels && !isHelp && !isInitPrompts
-	
 	// Only proceed with git operations if we need them
-	var diffInfo GitDiff
+	var diffInfo git.GitDiff
 	if requiresGit {
 		// Get git diff information
 		logVerbose("Getting git diff information with context lines: %d", cfg.GetContextLines())
@@ -892,198 +1292,742 @@ func main() {
 				os.Exit(1)
 			}
 		}
+		jiraLinks = diffInfo.JiraURLs
 
 		// Only check for staged files if we're actually generating a commit message
-		// and not just storing an API key
-		if !cfg.IsStoreKeyEnabled() && 
+		// and not just storing an API key. A provided diff was already checked for content.
+		if !cfg.IsStoreKeyEnabled() && cfg.GetDiffFile() == "" &&
 		   (len(diffInfo.StagedFiles) == 0 || (len(diffInfo.StagedFiles) == 1 && diffInfo.StagedFiles[0] == "")) {
-			fmt.Println("No staged changes found. Stage your changes using 'git add'.")
+			fmt.Println("No staged changes found. Stage your changes using 'git add' or run with --all.")
 			os.Exit(1)
 		}
-		
-		// Generate commit message
-		providerName := cfg.GetProvider()
-		fmt.Printf("Generating commit message with %s...\n", strings.Title(providerName))
-		startTime := time.Now()
-		
-		// Use the multi-provider implementation if a provider is specified
-		var message string
-		
-		if providerName != "" && providerName != "anthropic" {
-			// Convert GitDiff to git.GitDiff for the multi-provider implementation
-			gitDiffInfo := git.GitDiff{
-				StagedFiles:     diffInfo.StagedFiles,
-				Diff:            diffInfo.Diff,
-				Branch:          diffInfo.Branch,
-				JiraID:          diffInfo.JiraID,
-				JiraDescription: diffInfo.JiraDescription,
+
+		logVerbose("Found %d staged files in branch '%s'", len(diffInfo.StagedFiles), diffInfo.Branch)
+		if cfg.GetVerbosity() >= config.MoreVerbose {
+			for i, file := range diffInfo.StagedFiles {
+				fmt.Fprintf(logOutput, "  %d: %s\n", i+1, file)
 			}
-			
-	// Read prompts for the multi-provider implementation
-			// Check if enhanced context is enabled
-			promptFileName := "system_prompt.txt"
-			systemPrompt, isCustomSystemPrompt, systemPromptSource, promptErr := readPromptFile(promptFileName)
-			if promptErr != nil {
-				fmt.Printf("Error reading system prompt: %v\n", promptErr)
+		}
+
+		// A context file describes the project better than the README summary
+		if projectContext, err := loadContextFile(); err != nil {
+			fmt.Printf("Error reading context file: %v\n", err)
+			os.Exit(1)
+		} else if projectContext != "" {
+			diffInfo.ProjectContext = projectContext
+		}
+
+		// Check for secrets before the changes leave the machine
+		if cfg.IsScanSecretsEnabled() && !cfg.IsOfflineEnabled() && !cfg.IsShowPromptOnly() {
+			checkForSecrets(diffInfo)
+		}
+
+		// With --summarize-first the files are summarized one by one and the message is
+		// written from the summaries, so even a huge diff fits the context window
+		if cfg.IsSummarizeFirstEnabled() {
+			ctx, stop := interruptible()
+			diffInfo, err = summarizeDiff(ctx, diffInfo)
+			stop()
+			if err != nil {
+				exitIfInterrupted(err)
+				fmt.Printf("Error summarizing the diff: %v\n", err)
 				os.Exit(1)
 			}
-			
-			promptFileName = "user_prompt.txt"
-			if cfg.IsEnhancedContextEnabled() {
-				promptFileName = "enhanced_user_prompt.txt"
-				logVerbose("Using enhanced user prompt template for %s", providerName)
+		}
+
+		// Estimate the prompt size and check it against --max-prompt-tokens
+		diffInfo = enforcePromptTokenLimit(diffInfo)
+
+		// Let the model fill in the commit template, if there is one
+		diffInfo.Template, err = loadCommitTemplate()
+		if err != nil {
+			fmt.Printf("Error reading commit template: %v\n", err)
+			os.Exit(1)
+		}
+		diffInfo.Notes = cfg.IsNotesEnabled()
+
+		if cfg.IsShowPromptEnabled() {
+			if err := showPrompts(diffInfo); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if cfg.IsShowPromptOnly() {
+				os.Exit(0)
+			}
+		}
+
+		// --analyze only advises how to split the staged changes; nothing is committed
+		if cfg.IsAnalyzeEnabled() {
+			ctx, stop := interruptible()
+			err := analyzeStagedChanges(ctx, diffInfo)
+			stop()
+			if err != nil {
+				exitIfInterrupted(err)
+				fmt.Printf("Error analyzing staged changes: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+		
+		// Generate commit message. This runs in a loop so the user can regenerate or
+		// switch models; the diff stays in memory so no extra git calls are needed.
+		providerName := cfg.GetProvider()
+		diffInfo.Structured = useStructuredOutput()
+		candidateCount := cfg.GetCandidates()
+		keyReentered := false
+	generate:
+		for {
+			if !cfg.IsQuiet() && cfg.IsOfflineEnabled() {
+				fmt.Fprintln(uiOutput, "Writing a basic commit message from the changed files (offline)...")
+			} else if !cfg.IsQuiet() {
+				fmt.Fprintf(uiOutput, "Generating commit message with %s...\n", strings.Title(providerName))
 			}
+			startTime := time.Now()
+			
+			// Use the multi-provider implementation if a provider is specified.
+			// Streaming and multiple candidates are only implemented on the providers,
+			// so they always take this path.
+			var message string
+			var candidates []string
+			var promptDiffInfo git.GitDiff
+			heuristic := false
+			ctx, stop := interruptible()
 			
-			userPrompt, isCustomUserPrompt, userPromptSource, promptErr := readPromptFile(promptFileName)
-			if promptErr != nil {
-				log(config.Verbose, "Enhanced prompt not found, falling back to standard prompt")
-				userPrompt, isCustomUserPrompt, userPromptSource, promptErr = readPromptFile("user_prompt.txt")
+			if cfg.IsOfflineEnabled() {
+				message, heuristic = heuristicMessage(diffInfo), true
+			} else if (providerName != "" && providerName != "anthropic") || isStreaming() || candidateCount > 1 || len(cfg.GetFallbackProviders()) > 0 {
+				// Copy the diff so the prompts can be attached for the multi-provider implementation
+				gitDiffInfo, promptErr := withPrompts(diffInfo)
 				if promptErr != nil {
-					fmt.Printf("Error reading user prompt: %v\n", promptErr)
+					fmt.Fprintf(uiOutput, "Error: %v\n", promptErr)
 					os.Exit(1)
 				}
+				
+				// Use the new multi-provider implementation
+				promptDiffInfo = gitDiffInfo
+				if candidateCount > 1 {
+					candidates, err = generateCandidatesMultiProvider(ctx, gitDiffInfo, candidateCount)
+					if err == nil {
+						message = candidates[0]
+					}
+				} else {
+					message, err = generateCommitMessageMultiProvider(ctx, gitDiffInfo)
+				}
+				
+				// The end of the fallback chain is a basic message from the changed files,
+				// which the user can still review or edit before committing
+				if ai.IsRetryable(err) && len(cfg.GetFallbackProviders()) > 0 {
+					log(config.Normal, "⚠️  Every provider failed (%v); writing a basic message from the changed files instead", err)
+					message, candidates, heuristic, err = heuristicMessage(diffInfo), nil, true, nil
+				}
+			} else {
+				// Use the original implementation for backward compatibility
+				message, err = generateCommitMessage(ctx, cfg.GetAPIKey(), effectiveModelName(providerName), diffInfo)
 			}
 			
-			// Warn if using custom prompts
-			if isCustomSystemPrompt {
-				log(config.Normal, "⚠️  Using custom system prompt from %s", systemPromptSource)
+			// A revoked or mistyped key can be replaced without starting over, once
+			if err != nil && ai.IsAuthError(err) && !keyReentered && canPrompt() {
+				stop()
+				if keyReentered = reenterAPIKey(providerName, err); keyReentered {
+					continue generate
+				}
 			}
-			if isCustomUserPrompt {
-				log(config.Normal, "⚠️  Using custom user prompt from %s", userPromptSource)
+			if err != nil {
+				exitIfInterrupted(err)
+				fmt.Printf("Error generating commit message: %v\n", err)
+				os.Exit(1)
 			}
+			logVerbose("Commit message generated in %.2f seconds", time.Since(startTime).Seconds())
+			saveLastRun()
 			
-			// If enhanced context is enabled, we need to provide the enhanced fields
-			if cfg.IsEnhancedContextEnabled() && promptFileName == "enhanced_user_prompt.txt" {
-				// Initialize empty maps to satisfy the enhanced template
-				gitDiffInfo.FileContents = make(map[string]string)
-				gitDiffInfo.FileTypes = make(map[string]string)
-				gitDiffInfo.CommitHistory = make(map[string][]string)
-				gitDiffInfo.FileSummaries = make(map[string]string)
-				gitDiffInfo.RelatedFiles = make([]string, 0)
-				gitDiffInfo.ProjectContext = "" // Empty project context
+			// Shorten or flag a subject line over max_subject_length
+			if heuristic {
+				generatedBy = ""
+			} else if len(candidates) == 0 {
+				message = checkSubjectLength(ctx, message, diffInfo, promptDiffInfo)
 			}
+			stop()
 			
-			gitDiffInfo.SystemPrompt = systemPrompt
-			gitDiffInfo.UserPrompt = userPrompt
+			// With --notes the model writes a rationale after each message for the git note
+			var candidateNotes []string
+			if cfg.IsNotesEnabled() {
+				if len(candidates) > 0 {
+					candidateNotes = splitRationales(candidates)
+					message, commitNote = candidates[0], candidateNotes[0]
+				} else {
+					message, commitNote = ai.SplitRationale(message)
+					if commitNote == "" {
+						log(config.Normal, "⚠️  The model didn't write a rationale, so no git note will be added")
+					}
+				}
+			}
 			
-			// Use the new multi-provider implementation
-			message, err = generateCommitMessageMultiProvider(gitDiffInfo)
-		} else {
-			// Use the original implementation for backward compatibility
-			message, err = generateCommitMessage(cfg.GetAPIKey(), cfg.GetModelName(), diffInfo)
-		}
-		
-		if err != nil {
-			fmt.Printf("Error generating commit message: %v\n", err)
-			os.Exit(1)
-		}
-		logVerbose("Commit message generated in %.2f seconds", time.Since(startTime).Seconds())
-		
-		// Display the suggested commit message
-		fmt.Println("\n" + strings.Repeat("=", 50))
-		fmt.Println("Suggested commit message:")
-		fmt.Println(strings.Repeat("=", 50))
-		fmt.Println(message)
-		fmt.Println(strings.Repeat("=", 50))
-
-		// Handle the commit
-		if cfg.GetAutoCommit() {
-			logVerbose("Auto-commit enabled, committing changes...")
-			err = commitWithMessage(message)
-			if err != nil {
-				fmt.Printf("Error committing changes: %v\n", err)
-				os.Exit(1)
+			// Let the user's hook rewrite the message before it is shown or committed
+			hookChanged := false
+			if cfg.GetPostGenerateHook() != "" {
+				for i, candidate := range candidates {
+					if candidates[i], err = runPostGenerateHook(candidate, diffInfo); err != nil {
+						fmt.Printf("Error running post-generate hook: %v\n", err)
+						os.Exit(1)
+					}
+				}
+				if len(candidates) > 0 {
+					hookChanged = candidates[0] != message
+					message = candidates[0]
+				} else {
+					hookedMessage, err := runPostGenerateHook(message, diffInfo)
+					if err != nil {
+						fmt.Printf("Error running post-generate hook: %v\n", err)
+						os.Exit(1)
+					}
+					hookChanged = hookedMessage != message
+					message = hookedMessage
+				}
+			}
+			
+			// Display the suggested commit message (a streamed message has already been printed,
+			// unless the hook changed it)
+			if !cfg.IsJSONOutput() && !cfg.IsQuiet() {
+				showMessage(message, candidates, isStreaming() && !hookChanged && !heuristic)
 			}
-		} else {
-			fmt.Print("Use this message? (y)es/(e)dit/(n)o: ")
-			var response string
-			fmt.Scanln(&response)
-			response = strings.ToLower(response)
 
-			if response == "y" || response == "yes" {
-				logVerbose("User selected 'yes', committing changes...")
+			// Handle the commit
+			if cfg.IsJSONOutput() {
+				// Never prompt in JSON mode; only commit when auto-commit is enabled
+				result := jsonResult{
+					Message:     message,
+					Provider:    providerName,
+					Model:       effectiveModelName(providerName),
+					Branch:      diffInfo.Branch,
+					JiraID:      diffInfo.JiraID,
+					JiraIDs:     diffInfo.JiraIDs,
+					JiraURLs:    diffInfo.JiraURLs,
+					IssueRef:    diffInfo.IssueRef,
+					StagedFiles: diffInfo.StagedFiles,
+					ElapsedMs:   time.Since(startTime).Milliseconds(),
+				}
+				if len(candidates) > 1 {
+					result.Candidates = candidates
+				}
+				if heuristic {
+					result.Provider, result.Model = "offline", ""
+				}
+				result.Rationale = commitNote
+				if cfg.GetAutoCommit() && !isMessageOnly() {
+					logVerbose("Auto-commit enabled, committing changes...")
+					if err := commitWithMessage(message); err != nil {
+						fmt.Printf("Error committing changes: %v\n", err)
+						os.Exit(1)
+					}
+					result.Committed = true
+				}
+				if err := printJSONResult(result); err != nil {
+					fmt.Printf("Error writing JSON output: %v\n", err)
+					os.Exit(1)
+				}
+			} else if cfg.IsClipboardEnabled() {
+				// Copying replaces committing (the first message if there are several)
+				copyToClipboard(message)
+			} else if cfg.IsQuiet() {
+				// Quiet mode never prompts: with auto-commit it commits without output,
+				// otherwise it prints only the message (the first one if there are several)
+				if cfg.GetAutoCommit() && !isMessageOnly() {
+					if err := commitWithMessage(message); err != nil {
+						fmt.Printf("Error committing changes: %v\n", err)
+						os.Exit(1)
+					}
+				} else if cfg.IsGHEnabled() {
+					if err := createPullRequest(message); err != nil {
+						fmt.Printf("Error creating pull request: %v\n", err)
+						os.Exit(1)
+					}
+				} else {
+					fmt.Fprintln(resultOutput, message)
+				}
+			} else if isMessageOnly() && cfg.IsGHEnabled() {
+				if err := createPullRequest(message); err != nil {
+					fmt.Printf("Error creating pull request: %v\n", err)
+					os.Exit(1)
+				}
+			} else if isMessageOnly() {
+				// A summary of existing commits or a PR description has nothing to commit
+				fmt.Fprintln(uiOutput, "Not committing; copy the message above where you need it.")
+			} else if cfg.GetAutoCommit() {
+				logVerbose("Auto-commit enabled, committing changes...")
 				err = commitWithMessage(message)
 				if err != nil {
 					fmt.Printf("Error committing changes: %v\n", err)
 					os.Exit(1)
 				}
-			} else if response == "e" || response == "edit" {
-				logVerbose("User selected 'edit', opening editor...")
+			} else if len(candidates) > 1 {
+				chooseCandidate(candidates, candidateNotes, promptDiffInfo, candidateCount)
+			} else if cfg.IsEditAlwaysEnabled() {
+				// Skip the question and commit whatever is saved; an empty buffer aborts
+				logVerbose("Edit-always enabled, opening editor...")
 				editedMessage, err := editMessage(message)
 				if err != nil {
 					fmt.Printf("Error editing message: %v\n", err)
 					os.Exit(1)
 				}
-				if editedMessage != "" {
-					logVerbose("User provided edited message, committing changes...")
-					err = commitWithMessage(editedMessage)
-					if err != nil {
-						fmt.Printf("Error committing changes: %v\n", err)
-						os.Exit(1)
-					}
-				} else {
-					fmt.Println("Commit aborted.")
+				if editedMessage == "" {
+					abortCommit()
+				} else if err := commitWithMessage(editedMessage); err != nil {
+					fmt.Printf("Error committing changes: %v\n", err)
+					os.Exit(1)
 				}
 			} else {
-				fmt.Println("Commit aborted.")
-				os.Exit(0)  // Exit the program after aborting
-				os.Exit(0)  // Exit the program after aborting
+				// Ask until the user commits, aborts or wants a new message; writing a new
+				// body for the user's subject comes back here with the combined message
+				choices := withKeyBindings(
+					ui.NewChoice(ui.ActionYes, "y"),
+					ui.NewChoice(ui.ActionEdit, "e"),
+					ui.NewChoice(ui.ActionBody, "b"),
+					ui.NewChoice(ui.ActionRegenerate, "r"),
+					ui.NewChoice(ui.ActionModel, "m"),
+					ui.NewChoice(ui.ActionCopy, "c"),
+					ui.NewChoice(ui.ActionNo, "n"),
+				)
+				if cfg.IsOfflineEnabled() {
+					// Writing a body, regenerating and switching models all need a provider
+					choices = withKeyBindings(
+						ui.NewChoice(ui.ActionYes, "y"),
+						ui.NewChoice(ui.ActionEdit, "e"),
+						ui.NewChoice(ui.ActionCopy, "c"),
+						ui.NewChoice(ui.ActionNo, "n"),
+					)
+				}
+				for {
+					fmt.Fprint(uiOutput, "Use this message? ")
+					choice := ui.Confirm(choices)
+
+					if choice.Action == ui.ActionRegenerate {
+						// Feedback on the message is sent with the next request, along with
+						// any given earlier, so the model knows what to change
+						feedback := ui.Ask("What should change? (press Enter to just try again): ")
+						if feedback != "" {
+							logVerbose("User selected 'regenerate' with feedback: %s", feedback)
+							diffInfo.Turns = ai.FeedbackTurns(diffInfo.Turns, message, feedback)
+						} else {
+							logVerbose("User selected 'regenerate', generating a new message...")
+						}
+						continue generate
+					} else if choice.Action == ui.ActionModel {
+						logVerbose("User selected 'model', listing available models...")
+						chooseModel(providerName)
+						continue generate
+					} else if choice.Action == ui.ActionCopy {
+						logVerbose("User selected 'copy', copying the message instead of committing...")
+						copyToClipboard(message)
+					} else if choice.Action == ui.ActionBody {
+						logVerbose("User selected 'body', opening editor for the subject line...")
+						subject, err := editMessage(ai.Subject(message))
+						if err != nil {
+							fmt.Printf("Error editing message: %v\n", err)
+							os.Exit(1)
+						}
+						if subject = ai.Subject(subject); subject == "" {
+							abortCommit()
+						}
+						
+						fmt.Fprintln(uiOutput, "Writing a body for your subject line...")
+						ctx, stop := interruptible()
+						bodyMessage, err := generateBody(ctx, subject, diffInfo, promptDiffInfo)
+						stop()
+						if err != nil {
+							fmt.Printf("Error generating commit message body: %v\n", err)
+							continue
+						}
+						message = bodyMessage
+						showMessage(message, nil, false)
+						continue
+					} else if choice.Action == ui.ActionYes {
+						logVerbose("User selected 'yes', committing changes...")
+						err = commitWithMessage(message)
+						if err != nil {
+							fmt.Printf("Error committing changes: %v\n", err)
+							os.Exit(1)
+						}
+					} else if choice.Action == ui.ActionEdit {
+						logVerbose("User selected 'edit', opening editor...")
+						editedMessage, err := editMessage(message)
+						if err != nil {
+							fmt.Printf("Error editing message: %v\n", err)
+							os.Exit(1)
+						}
+						if editedMessage != "" {
+							logVerbose("User provided edited message, committing changes...")
+							err = commitWithMessage(editedMessage)
+							if err != nil {
+								fmt.Printf("Error committing changes: %v\n", err)
+								os.Exit(1)
+							}
+						} else {
+							abortCommit()
+						}
+					} else {
+						abortCommit()
+					}
+					break
+				}
 			}
+			break
+		}
+	}
+}
+
+// interruptible returns a context that is cancelled when the user presses Ctrl-C, so
+// a request in flight ends cleanly. Call stop as soon as the requests finish: until
+// then Ctrl-C doesn't end the program, which would leave it stuck at a prompt.
+func interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// exitIfInterrupted exits with exitInterrupted when err comes from a request
+// cancelled with Ctrl-C
+func exitIfInterrupted(err error) {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(uiOutput, "\nCancelled")
+		exit(exitInterrupted)
+	}
+}
+
+// generateBody asks the model for a body to go with the user's subject line and returns
+// the subject, kept verbatim, followed by the body. promptDiffInfo carries the prompts
+// when the multi-provider implementation is in use.
+func generateBody(ctx context.Context, subject string, diffInfo, promptDiffInfo git.GitDiff) (string, error) {
+	// The body is a new request, not another try at the rejected messages, and it is
+	// asked for as text since the subject is the user's
+	diffInfo.Turns, promptDiffInfo.Turns = nil, nil
+	diffInfo.Structured, promptDiffInfo.Structured = false, false
+
+	var response string
+	var err error
+	if promptDiffInfo.UserPrompt != "" {
+		promptDiffInfo.Subject = subject
+		response, err = generateCommitMessageMultiProvider(ctx, promptDiffInfo)
+	} else {
+		diffInfo.Subject = subject
+		response, err = generateCommitMessage(ctx, cfg.GetAPIKey(), effectiveModelName(cfg.GetProvider()), diffInfo)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.IsNotesEnabled() {
+		var note string
+		if response, note = ai.SplitRationale(response); note != "" {
+			commitNote = note
 		}
 	}
+	return ai.ComposeWithSubject(subject, response), nil
+}
 
-	logVerbose("Found %d staged files in branch '%s'", len(diffInfo.StagedFiles), diffInfo.Branch)
-	if cfg.GetVerbosity() >= config.MoreVerbose {
-		for i, file := range diffInfo.StagedFiles {
-			fmt.Printf("  %d: %s\n", i+1, file)
+// checkSubjectLength enforces max_subject_length on a generated message. With --strict
+// the model is asked once more for a shorter subject line; otherwise, or when the new
+// subject is still too long, the user is warned so they can edit it.
+func checkSubjectLength(ctx context.Context, message string, diffInfo, promptDiffInfo git.GitDiff) string {
+	maxLength := cfg.GetMaxSubjectLength()
+	length := commit.CheckSubjectLength(message, maxLength)
+	if length == 0 {
+		return message
+	}
+
+	if !cfg.IsStrictEnabled() {
+		log(config.Normal, "⚠️  The subject line is %d characters, over the limit of %d; edit it or use --strict to ask for a shorter one", length, maxLength)
+		return message
+	}
+
+	log(config.Normal, "⚠️  The subject line is %d characters, over the limit of %d; asking for a shorter one...", length, maxLength)
+	var shorter string
+	var err error
+	if promptDiffInfo.UserPrompt != "" {
+		promptDiffInfo.SubjectLimit = maxLength
+		shorter, err = generateCommitMessageMultiProvider(ctx, promptDiffInfo)
+	} else {
+		diffInfo.SubjectLimit = maxLength
+		shorter, err = generateCommitMessage(ctx, cfg.GetAPIKey(), effectiveModelName(cfg.GetProvider()), diffInfo)
+	}
+	if err != nil {
+		log(config.Normal, "⚠️  Could not generate a shorter subject line: %v", err)
+		return message
+	}
+
+	if length = commit.CheckSubjectLength(shorter, maxLength); length > 0 {
+		log(config.Normal, "⚠️  The new subject line is still %d characters; edit it to shorten it", length)
+	}
+	return shorter
+}
+
+// getGitDiff collects the changes to describe along with their --stat summary. With
+// --stat-only the diff itself is dropped and the summary and file list stand in for it.
+func getGitDiff(jiraID string, jiraDesc string, contextLines int) (git.GitDiff, error) {
+	if !cfg.IsJiraEnabled() {
+		// --no-jira drops a Jira ID given with --jira as well
+		jiraID, jiraDesc = "", ""
+	}
+	diffInfo, err := collectGitDiff(jiraID, jiraDesc, contextLines)
+	if err != nil {
+		return diffInfo, err
+	}
+	diffInfo.NoJira = !cfg.IsJiraEnabled()
+	diffInfo.JiraURLs = jiraURLs(diffInfo)
+	// Guess the commit type from the whole diff, before it is truncated or summarized
+	if diffInfo.ChangeType = git.InferChangeType(diffInfo.Diff); diffInfo.ChangeType != "" {
+		log(config.Verbose, "Likely change type: %s", diffInfo.ChangeType)
+	}
+	diffInfo = describeChangesWithoutLines(diffInfo)
+	if cfg.IsSummarizeFirstEnabled() {
+		// Each file is cut to max_diff_bytes on its own when it is summarized
+		return diffInfo, nil
+	}
+	if !cfg.IsStatOnlyEnabled() {
+		return truncateDiff(diffInfo), nil
+	}
+
+	// With --stat-only the summary and file list stand in for the diff to keep costs down
+	if diffInfo.DiffStat == "" {
+		log(config.Normal, "Warning: No diff stat available; sending the full diff despite --stat-only")
+		return diffInfo, nil
+	}
+	log(config.Verbose, "Sending only the diff stat and file list (--stat-only)")
+	diffInfo.Diff = ""
+	diffInfo.FileContents = nil
+	diffInfo.WorkingTreeFiles = nil
+	return diffInfo, nil
+}
+
+// describeChangesWithoutLines spells out the changes of a diff that changes no lines,
+// such as a chmod, so the model has something real to describe instead of bare headers
+func describeChangesWithoutLines(diffInfo git.GitDiff) git.GitDiff {
+	if git.HasLineChanges(diffInfo.Diff) {
+		return diffInfo
+	}
+
+	var notes []string
+	for _, change := range diffInfo.FileChanges {
+		if note := change.Note(); note != "" {
+			notes = append(notes, "- "+note)
 		}
 	}
+	if len(notes) == 0 {
+		return diffInfo
+	}
+
+	log(config.Verbose, "The diff changes no lines; describing the %d file changes instead", len(notes))
+	description := "No lines were changed. The staged changes are:\n" + strings.Join(notes, "\n") + "\n"
+	if diff := strings.TrimSpace(diffInfo.Diff); diff != "" {
+		description = diff + "\n\n" + description
+	}
+	diffInfo.Diff = description
+	return diffInfo
+}
+
+// checkForSecrets scans the diff, and the file contents sent with it, for secrets such
+// as API keys and private keys. If any are found the user has to confirm sending them;
+// with --strict, or when there is no one to ask, the run stops instead.
+func checkForSecrets(diffInfo git.GitDiff) {
+	rules, err := scan.CompileRules(cfg.GetSecretPatterns())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	findings := scan.FindSecrets(diffInfo.Diff, rules...)
+	files := make([]string, 0, len(diffInfo.FileContents))
+	for file := range diffInfo.FileContents {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		findings = append(findings, scan.FindSecretsInFile(file, diffInfo.FileContents[file], rules...)...)
+	}
+	for _, file := range diffInfo.WorkingTreeFiles {
+		findings = append(findings, scan.FindSecretsInFile(file.Path, file.Content, rules...)...)
+	}
+	if len(findings) == 0 {
+		logVerbose("No secrets found in the changes")
+		return
+	}
+
+	fmt.Fprintln(uiOutput, "⚠️  These look like secrets, and would be sent to the provider:")
+	for _, finding := range findings {
+		fmt.Fprintf(uiOutput, "  %s\n", finding)
+	}
+	if cfg.IsStrictEnabled() || !canPrompt() {
+		fmt.Println("Error: not sending possible secrets. Remove them from the changes, or set scan_secrets = false if they are false positives.")
+		os.Exit(1)
+	}
+
+	fmt.Fprint(uiOutput, "Send the changes anyway? ")
+	if choice := ui.Confirm([]ui.Choice{ui.NewChoice(ui.ActionYes, "y"), ui.NewChoice(ui.ActionNo, "n")}); choice.Action != ui.ActionYes {
+		abortCommit()
+	}
+}
+
+// summarizeDiff replaces the diff with a one-line summary of each file, written by the
+// model from that file's diff alone using file_summary_prompt.txt. The message is then
+// written from the summaries, trading a request per file for a prompt that fits.
+func summarizeDiff(ctx context.Context, diffInfo git.GitDiff) (git.GitDiff, error) {
+	files := git.SplitDiff(diffInfo.Diff)
+	if len(files) == 0 {
+		log(config.Verbose, "Nothing to summarize; sending the diff as it is")
+		return diffInfo, nil
+	}
+
+	prompt, isCustom, source, err := readPromptFile(promptProvider(), "file_summary_prompt.txt")
+	if err != nil {
+		log(config.Verbose, "file_summary_prompt.txt not found, using the built-in file summary prompt")
+		prompt = ai.DefaultFileSummaryPrompt
+	} else if isCustom {
+		log(config.Normal, "⚠️  Using custom file summary prompt from %s", source)
+	}
+
+	providerName, provider, apiKey, modelName, err := configuredProvider()
+	if err != nil {
+		return diffInfo, err
+	}
+
+	maxBytes := cfg.GetMaxDiffBytes()
+	for i := range files {
+		files[i].Diff, _ = git.TruncateDiff(files[i].Diff, maxBytes)
+	}
+
+	log(config.Normal, "Summarizing %d files with %s before writing the message...", len(files), strings.Title(providerName))
+	startTime := time.Now()
+	summaries, err := ai.SummarizeFiles(ctx, provider, apiKey, modelName, files, prompt)
+	if err != nil {
+		return diffInfo, err
+	}
+	logVerbose("Summarized %d files in %.2f seconds", len(files), time.Since(startTime).Seconds())
+
+	diffInfo.Diff = ai.FormatFileSummaries(summaries)
+	diffInfo.FileContents = nil
+	diffInfo.WorkingTreeFiles = nil
+	return diffInfo, nil
+}
 
-	// We've already generated and handled the commit message above, 
-	// so we shouldn't reach this point. This redundant code has been removed
-	// to prevent the double commit message generation issue.
+// truncateDiff cuts a diff larger than max_diff_bytes down to the start of each file's
+// changes. The diff stat, sent separately, still covers every file.
+func truncateDiff(diffInfo git.GitDiff) git.GitDiff {
+	maxBytes := cfg.GetMaxDiffBytes()
+	truncated, changed := git.TruncateDiff(diffInfo.Diff, maxBytes)
+	if !changed {
+		return diffInfo
+	}
+	log(config.Normal, "⚠️  The diff is %d bytes, over the limit of %d; sending the start of each file's changes", len(diffInfo.Diff), maxBytes)
+	diffInfo.Diff = truncated
+	return diffInfo
 }
 
-func getGitDiff(jiraID string, jiraDesc string, contextLines int) (GitDiff, error) {
+// collectGitDiff reads the changes to describe from a diff file, a commit range or the index
+func collectGitDiff(jiraID string, jiraDesc string, contextLines int) (git.GitDiff, error) {
+	// A diff provided with --diff-file bypasses the git diff commands entirely
+	if diffFile := cfg.GetDiffFile(); diffFile != "" {
+		return getDiffFromFile(diffFile, jiraID, jiraDesc)
+	}
+
+	// A range of commits given with --since is summarized without looking at the index
+	if since := cfg.GetSince(); since != "" {
+		return getRangeDiff(since, jiraID, jiraDesc, contextLines)
+	}
+
+	// Offer to stage everything if the user forgot to 'git add'. Amend mode can
+	// legitimately have nothing staged, and storing a key doesn't need a diff.
+	if !cfg.IsAmendEnabled() && !cfg.IsStoreKeyEnabled() {
+		if err := offerToStageAll(); err != nil {
+			return git.GitDiff{}, err
+		}
+	}
+
+	// A merge, rebase or cherry-pick in progress has already prepared a message
+	draft := inProgressDraft()
+
 	// Check if enhanced context is enabled
-	if cfg.IsEnhancedContextEnabled() {
+	// Enhanced context reads all staged changes, so amend mode and --only use the regular path
+	if cfg.IsEnhancedContextEnabled() && !cfg.IsAmendEnabled() && len(cfg.GetOnly()) == 0 {
 		// Use the enhanced git context
 		log(config.Verbose, "Using enhanced git context")
 		enhancedDiff, err := git.GetEnhancedGitDiff(jiraID, jiraDesc, contextLines)
 		if err != nil {
-			return GitDiff{}, err
+			return git.GitDiff{}, err
 		}
 		
-		// Convert enhanced diff to regular diff
-		return GitDiff{
-			StagedFiles:     enhancedDiff.StagedFiles,
-			Diff:            enhancedDiff.Diff,
-			Branch:          enhancedDiff.Branch,
-			JiraID:          enhancedDiff.JiraID,
-			JiraDescription: enhancedDiff.JiraDescription,
-		}, nil
+		// Convert enhanced diff to regular diff, keeping the enhanced context for the prompt
+		diffInfo := enhancedDiff.ToGitDiff()
+		if branch := cfg.GetBranch(); branch != "" {
+			// The enhanced diff reads the current branch, but --branch takes precedence
+			// as it does for the regular diff
+			logVerbose("Using provided branch name: %s", branch)
+			diffInfo.Branch = branch
+			if jiraID == "" && detectsJira() {
+				diffInfo.JiraIDs = git.ExtractJiraIDsFromBranchName(branch)
+				diffInfo.JiraID = ""
+				if len(diffInfo.JiraIDs) > 0 {
+					diffInfo.JiraID = diffInfo.JiraIDs[0]
+				}
+			}
+		}
+		if jiraID == "" && !detectsJira() {
+			// The enhanced diff always looks for a Jira ID in the branch name
+			diffInfo.JiraID = ""
+			diffInfo.JiraIDs = nil
+		}
+		diffInfo.Draft = draft
+		diffInfo.DiffStat = diffStat("--cached")
+		diffInfo.FileChanges = fileChanges("--cached")
+		return addWorkingTreeFiles(addUntrackedFiles(addStyleExamples(addIssueRef(diffInfo)))), nil
 	}
 
 	// Regular git diff logic
-	var diffInfo GitDiff
+	var diffInfo git.GitDiff
 	diffInfo.JiraID = jiraID
 	diffInfo.JiraDescription = jiraDesc
+	diffInfo.Draft = draft
 
 	// Check if we're in a git repository
 	logVerbose("Checking if current directory is a git repository...")
-	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	cmd := git.Command("rev-parse", "--is-inside-work-tree")
 	if err := cmd.Run(); err != nil {
 		return diffInfo, fmt.Errorf("not in a git repository")
 	}
 
+	// --only restricts the diff to some of the staged files; amend mode describes the whole commit
+	only := cfg.GetOnly()
+	if len(only) > 0 && cfg.IsAmendEnabled() {
+		return diffInfo, fmt.Errorf("--only can't be combined with --amend")
+	}
+
+	// In amend mode the index is compared with the parent of the last commit, so the
+	// diff covers both the already-committed changes and anything staged since
+	diffArgs := []string{"diff", "--cached"}
+	if cfg.IsAmendEnabled() {
+		base, err := git.GetAmendBase()
+		if err != nil {
+			return diffInfo, err
+		}
+		log(config.Verbose, "Amend mode: diffing staged changes against %s", base)
+		diffArgs = append(diffArgs, base)
+	}
+
 	// Get list of staged files
 	log(config.Verbose, "Getting list of staged files...")
-	cmd = exec.Command("git", "diff", "--name-only", "--cached")
+	cmd = git.Command(withPathspec(append(diffArgs, "--name-only"), only)...)
 	output, err := cmd.Output()
 	if err != nil {
 		return diffInfo, err
 	}
 	diffInfo.StagedFiles = strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(only) > 0 {
+		if strings.TrimSpace(string(output)) == "" {
+			return diffInfo, fmt.Errorf("--only %s matches no staged files", strings.Join(only, " "))
+		}
+		log(config.Verbose, "Restricting the diff to %d staged files matching %s", len(diffInfo.StagedFiles), strings.Join(only, " "))
+		if err := checkOnlyStaged(only); err != nil {
+			return diffInfo, err
+		}
+	}
+	diffInfo.DiffStat = diffStat(withPathspec(diffArgs[1:], only)...)
+	diffInfo.FileChanges = fileChanges(withPathspec(diffArgs[1:], only)...)
 	
 	// Log file details at MoreVerbose level with clear formatting
 	if cfg.GetVerbosity() >= config.MoreVerbose {
@@ -1092,7 +2036,7 @@ func getGitDiff(jiraID string, jiraDesc string, contextLines int) (GitDiff, erro
 			log(config.MoreVerbose, "File #%d: %s", i+1, file)
 			
 			// Get file stats
-			statCmd := exec.Command("git", "diff", "--cached", "--stat", file)
+			statCmd := git.Command(append(diffArgs, "--stat", "--", file)...)
 			statOutput, statErr := statCmd.Output()
 			if statErr == nil {
 				log(config.MoreVerbose, "  Changes: %s", strings.TrimSpace(string(statOutput)))
@@ -1101,384 +2045,1776 @@ func getGitDiff(jiraID string, jiraDesc string, contextLines int) (GitDiff, erro
 			// For debug level, show more detailed file info
 			if cfg.GetVerbosity() >= config.Debug {
 				// Get file type
-				typeCmd := exec.Command("git", "check-attr", "diff", "--", file)
+				typeCmd := git.Command("check-attr", "diff", "--", file)
 				typeOutput, typeErr := typeCmd.Output()
 				if typeErr == nil {
 					log(config.Debug, "  Attributes: %s", strings.TrimSpace(string(typeOutput)))
 				}
 				
 				// Get file size
-				sizeCmd := exec.Command("git", "ls-files", "-s", file)
+				sizeCmd := git.Command("ls-files", "-s", file)
 				sizeOutput, sizeErr := sizeCmd.Output()
 				if sizeErr == nil {
 					log(config.Debug, "  Details: %s", strings.TrimSpace(string(sizeOutput)))
 				}
 			}
 		}
-		log(config.MoreVerbose, "=============================")
+		log(config.MoreVerbose, "=============================")
+	}
+
+	// Get the diff details with context
+	logVerbose("Getting diff details with context lines: %d...", contextLines)
+	args := append([]string{}, diffArgs...)
+	
+	// Handle different context levels
+	if contextLines >= 0 {
+		// Use the specified number of context lines
+		args = append(args, fmt.Sprintf("--unified=%d", contextLines))
+	}
+	
+	if contextLines < 0 && len(diffInfo.StagedFiles) > 0 && diffInfo.StagedFiles[0] != "" {
+		// For maximum context (-1), send the full content of each changed file along with the diff
+		logVerbose("Using maximum context (full file content)...")
+		fullDiff, err := git.BuildFullContextDiff(diffInfo.StagedFiles, withPathspec(diffArgs[1:], only)...)
+		if err != nil {
+			return diffInfo, err
+		}
+		diffInfo.Diff = fullDiff
+		log(config.MoreVerbose, "Full context diff length: %d bytes", len(diffInfo.Diff))
+	} else {
+		// Standard diff with specified context
+		cmd = git.Command(withPathspec(args, only)...)
+		output, err = cmd.Output()
+		if err != nil {
+			return diffInfo, err
+		}
+		diffInfo.Diff = string(output)
+		log(config.MoreVerbose, "Diff length: %d bytes", len(diffInfo.Diff))
+	}
+
+	// Get the branch info and return
+	return addWorkingTreeFiles(addUntrackedFiles(addStyleExamples(getBranchInfo(diffInfo)))), nil
+}
+
+// smallerContextLevels are the context line counts tried, in order, when a prompt is too large
+var smallerContextLevels = []int{10, 3, 1, 0}
+
+// enforcePromptTokenLimit logs the estimated prompt size and, when it exceeds
+// --max-prompt-tokens, asks whether to continue, retry with fewer context lines or abort.
+// In auto-commit and JSON modes it reduces the context without asking. A diff read from
+// a file and the file summaries of --summarize-first can only be sent as they are.
+func enforcePromptTokenLimit(diffInfo git.GitDiff) git.GitDiff {
+	estimate := ai.EstimatePromptTokens(diffInfo)
+	log(config.Verbose, "Estimated prompt size: ~%d tokens (excluding prompt templates)", estimate)
+
+	maxTokens := cfg.GetMaxPromptTokens()
+	if maxTokens <= 0 || estimate <= maxTokens {
+		return diffInfo
+	}
+
+	// A diff read from a file or stdin can't be regenerated with less context, and with
+	// --summarize-first a new diff would replace the summaries with the whole diff
+	canReduce := cfg.GetDiffFile() == "" && !cfg.IsSummarizeFirstEnabled()
+
+	fmt.Fprintf(uiOutput, "Warning: Estimated prompt size (~%d tokens) exceeds the limit of %d tokens.\n", estimate, maxTokens)
+	if !cfg.GetAutoCommit() && !cfg.IsJSONOutput() && !cfg.IsQuiet() {
+		if canReduce {
+			fmt.Fprint(uiOutput, "(c)ontinue anyway, (r)educe context, or (a)bort? ")
+		} else {
+			fmt.Fprint(uiOutput, "(c)ontinue anyway or (a)bort? ")
+		}
+		var response string
+		fmt.Scanln(&response)
+		response = strings.ToLower(strings.TrimSpace(response))
+
+		switch {
+		case response == "c" || response == "continue":
+			return diffInfo
+		case canReduce && (response == "r" || response == "reduce"):
+			// Fall through to the context reduction below
+		default:
+			abortCommit()
+		}
+	} else if !canReduce {
+		if cfg.IsSummarizeFirstEnabled() {
+			fmt.Fprintln(uiOutput, "Continuing with the file summaries.")
+		} else {
+			fmt.Fprintln(uiOutput, "Continuing with the provided diff.")
+		}
+		return diffInfo
+	}
+
+	// Retry with progressively fewer context lines until the prompt fits
+	contextLines := cfg.GetContextLines()
+	for _, lines := range smallerContextLevels {
+		if contextLines >= 0 && lines >= contextLines {
+			continue
+		}
+
+		log(config.Normal, "Retrying with %d context lines...", lines)
+		reduced, err := getGitDiff(cfg.GetJiraID(), cfg.GetJiraDesc(), lines)
+		if err != nil {
+			fmt.Printf("Error getting git diff: %v\n", err)
+			os.Exit(1)
+		}
+		// The project context from --context-file is added after the diff is collected,
+		// so the new diff doesn't have it
+		reduced.ProjectContext = diffInfo.ProjectContext
+		diffInfo = reduced
+		contextLines = lines
+
+		estimate = ai.EstimatePromptTokens(diffInfo)
+		log(config.Verbose, "Estimated prompt size: ~%d tokens (excluding prompt templates)", estimate)
+		if estimate <= maxTokens {
+			return diffInfo
+		}
+	}
+
+	fmt.Fprintf(uiOutput, "Warning: Prompt is still ~%d tokens with the smallest context; sending it anyway.\n", estimate)
+	return diffInfo
+}
+
+// offerToStageAll stages all changes when nothing is staged but the working tree has
+// unstaged changes, either automatically with --all or after asking the user
+func offerToStageAll() error {
+	staged, err := git.GetStagedFileNames()
+	if err != nil || len(staged) > 0 {
+		// Errors (e.g. outside a repository) are reported by the diff commands
+		return nil
+	}
+
+	unstaged, err := git.GetUnstagedFiles()
+	if err != nil || len(unstaged) == 0 {
+		return nil
+	}
+
+	if !cfg.IsStageAllEnabled() {
+		// Never prompt when running non-interactively
+		if cfg.GetAutoCommit() || cfg.IsJSONOutput() || cfg.IsQuiet() {
+			return nil
+		}
+
+		fmt.Fprintf(uiOutput, "No staged changes found, but %d file(s) have unstaged changes:\n", len(unstaged))
+		for _, file := range unstaged {
+			fmt.Fprintf(uiOutput, "  %s\n", file)
+		}
+		fmt.Fprint(uiOutput, "Stage all and continue? (y/n): ")
+		var response string
+		fmt.Scanln(&response)
+		response = strings.ToLower(strings.TrimSpace(response))
+		if response != "y" && response != "yes" {
+			return nil
+		}
+	}
+
+	log(config.Normal, "Staging all changes (git add -A)...")
+	return git.StageAll()
+}
+
+// getDiffFromFile builds the diff information from a diff file, or stdin when diffFile is "-"
+func getDiffFromFile(diffFile string, jiraID string, jiraDesc string) (git.GitDiff, error) {
+	var diffInfo git.GitDiff
+	diffInfo.JiraID = jiraID
+	diffInfo.JiraDescription = jiraDesc
+
+	var content []byte
+	var err error
+	if diffFile == "-" {
+		logVerbose("Reading diff from stdin...")
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		logVerbose("Reading diff from file: %s", diffFile)
+		content, err = os.ReadFile(diffFile)
+	}
+	if err != nil {
+		return diffInfo, fmt.Errorf("error reading diff: %v", err)
+	}
+
+	diffInfo.Diff = string(content)
+	if strings.TrimSpace(diffInfo.Diff) == "" {
+		return diffInfo, fmt.Errorf("the provided diff is empty")
+	}
+	log(config.MoreVerbose, "Diff length: %d bytes", len(diffInfo.Diff))
+
+	// The file list comes from the diff headers rather than the index
+	diffInfo.StagedFiles = git.ParseDiffFiles(diffInfo.Diff)
+	log(config.Verbose, "Found %d files in the provided diff", len(diffInfo.StagedFiles))
+
+	// Get the branch info (from --branch or git, if available) and return
+	return addStyleExamples(getBranchInfo(diffInfo)), nil
+}
+
+// getRangeDiff builds the diff and commit subjects for everything on HEAD since ref,
+// so several commits can be summarized in one message for a squash merge
+func getRangeDiff(ref string, jiraID string, jiraDesc string, contextLines int) (git.GitDiff, error) {
+	var diffInfo git.GitDiff
+	diffInfo.JiraID = jiraID
+	diffInfo.JiraDescription = jiraDesc
+
+	if cfg.IsAmendEnabled() {
+		return diffInfo, fmt.Errorf("--since can't be combined with --amend")
+	}
+
+	logVerbose("Getting changes since %s with context lines: %d...", ref, contextLines)
+	diff, files, err := git.GetRangeDiff(ref, contextLines)
+	if err != nil {
+		return diffInfo, err
+	}
+	diffInfo.Diff = diff
+	diffInfo.StagedFiles = files
+	log(config.MoreVerbose, "Diff length: %d bytes", len(diffInfo.Diff))
+
+	subjects, err := git.GetCommitSubjects(ref)
+	if err != nil {
+		return diffInfo, fmt.Errorf("error reading commits since %s: %v", ref, err)
+	}
+	diffInfo.CommitSubjects = subjects
+	diffInfo.DiffStat = diffStat(ref + "...HEAD")
+	diffInfo.FileChanges = fileChanges(ref + "...HEAD")
+	log(config.Verbose, "Summarizing %d commits and %d files since %s", len(subjects), len(files), ref)
+
+	return addStyleExamples(getBranchInfo(diffInfo)), nil
+}
+
+// diffStat returns the "git diff --stat" summary for the prompt, or "" if git can't produce it
+func diffStat(args ...string) string {
+	stat, err := git.GetDiffStat(args...)
+	if err != nil {
+		logVerbose("Warning: Could not get the diff stat: %v", err)
+		return ""
+	}
+	log(config.MoreVerbose, "Diff stat:\n%s", stat)
+	return stat
+}
+
+// fileChanges returns the status of each changed file, or nil if git can't produce it
+func fileChanges(args ...string) []git.FileChange {
+	changes, err := git.GetFileChanges(args...)
+	if err != nil {
+		logVerbose("Warning: Could not get the file statuses: %v", err)
+		return nil
+	}
+	for _, change := range changes {
+		if note := change.Note(); note != "" {
+			log(config.MoreVerbose, "File note: %s", note)
+		}
+	}
+	return changes
+}
+
+// addStyleExamples attaches recent commit messages to the diff when style examples are enabled
+func addStyleExamples(diffInfo git.GitDiff) git.GitDiff {
+	count := cfg.GetStyleExamples()
+	if count <= 0 {
+		return diffInfo
+	}
+
+	logVerbose("Collecting the last %d commit messages as style examples...", count)
+	examples, err := git.GetRecentCommitMessages(count)
+	if err != nil {
+		logVerbose("Warning: Could not read recent commit messages: %v", err)
+		return diffInfo
+	}
+	diffInfo.StyleExamples = examples
+	log(config.MoreVerbose, "Using %d commit messages as style examples", len(examples))
+
+	return diffInfo
+}
+
+// addUntrackedFiles lists the untracked files next to the staged files when
+// untracked_context is on, so the model knows about e.g. a new test not yet added
+func addUntrackedFiles(diffInfo git.GitDiff) git.GitDiff {
+	if !cfg.IsUntrackedContextEnabled() {
+		return diffInfo
+	}
+
+	untracked, err := git.GetUntrackedFiles(diffInfo.StagedFiles, maxUntrackedFiles)
+	if err != nil {
+		logVerbose("Warning: Could not list untracked files: %v", err)
+		return diffInfo
+	}
+	diffInfo.UntrackedFiles = untracked
+	log(config.MoreVerbose, "Listing %d untracked files next to the staged files", len(untracked))
+
+	return diffInfo
+}
+
+// addWorkingTreeFiles adds the working-tree content of the staged files when
+// unstaged_context is on, so the model sees the code around the change even where
+// it was edited after staging
+func addWorkingTreeFiles(diffInfo git.GitDiff) git.GitDiff {
+	if !cfg.IsUnstagedContextEnabled() {
+		return diffInfo
+	}
+
+	files, err := git.GetWorkingTreeFiles(diffInfo.StagedFiles)
+	if err != nil {
+		logVerbose("Warning: Could not read the working tree: %v", err)
+		return diffInfo
+	}
+	diffInfo.WorkingTreeFiles = files
+	log(config.MoreVerbose, "Including the working-tree content of %d staged files", len(files))
+
+	return diffInfo
+}
+
+// getBranchInfo gets the branch information and returns the updated diffInfo
+func getBranchInfo(diffInfo git.GitDiff) git.GitDiff {
+	// Use the branch given with --branch, otherwise the current branch name
+	if branch := cfg.GetBranch(); branch != "" {
+		logVerbose("Using provided branch name: %s", branch)
+		diffInfo.Branch = branch
+	} else {
+		logVerbose("Getting current branch name...")
+		if branch, err := git.CurrentRepo().Branch(); err == nil {
+			diffInfo.Branch = branch
+		}
+	}
+
+	// Try to extract Jira ID from branch name if not provided
+	if diffInfo.JiraID == "" && diffInfo.Branch != "" && detectsJira() {
+		// Common branch naming patterns like feature/GTBUG-123-description or bugfix/GTN-456-description
+		log(config.Verbose, "Trying to extract Jira ID from branch name: %s", diffInfo.Branch)
+		
+		// Get more branch info at MoreVerbose level with clear formatting
+		if cfg.GetVerbosity() >= config.MoreVerbose {
+			log(config.MoreVerbose, "===== BRANCH INFO =====")
+			log(config.MoreVerbose, "Name: %s", diffInfo.Branch)
+			
+			// Get branch creation date
+			dateCmd := git.Command("show", "-s", "--format=%ci", diffInfo.Branch)
+			dateOutput, dateErr := dateCmd.Output()
+			if dateErr == nil {
+				log(config.MoreVerbose, "Created: %s", strings.TrimSpace(string(dateOutput)))
+			}
+			
+			// Get branch tracking info for the current branch
+			repo := git.CurrentRepo()
+			if current, _ := repo.Branch(); current == diffInfo.Branch {
+				if upstream, err := repo.Upstream(); err == nil && upstream != "" {
+					log(config.MoreVerbose, "Tracks: %s", upstream)
+				}
+			}
+			
+			// For debug level, show more detailed branch info
+			if cfg.GetVerbosity() >= config.Debug {
+				// Get last commit info
+				commitCmd := git.Command("log", "-1", "--pretty=%h %s", diffInfo.Branch)
+				commitOutput, commitErr := commitCmd.Output()
+				if commitErr == nil {
+					log(config.Debug, "Last commit: %s", strings.TrimSpace(string(commitOutput)))
+				}
+				
+				// Get commit count
+				countCmd := git.Command("rev-list", "--count", diffInfo.Branch)
+				countOutput, countErr := countCmd.Output()
+				if countErr == nil {
+					log(config.Debug, "Commit count: %s", strings.TrimSpace(string(countOutput)))
+				}
+			}
+			
+			log(config.MoreVerbose, "======================")
+		}
+
+		// Any configured prefix is recognized, as well as anything shaped like a Jira ID.
+		// Branches can reference more than one ticket (feature/GTN-1-GTBUG-2-thing).
+		diffInfo.JiraIDs = git.ExtractJiraIDsFromBranchName(diffInfo.Branch)
+		if len(diffInfo.JiraIDs) > 0 {
+			diffInfo.JiraID = diffInfo.JiraIDs[0]
+			log(config.MoreVerbose, "Extracted Jira ID from branch name: %s", diffInfo.JiraID)
+		}
+		if len(diffInfo.JiraIDs) > 1 {
+			log(config.Verbose, "Found multiple Jira IDs in branch name: %s", strings.Join(diffInfo.JiraIDs, ", "))
+		}
+	}
+
+	return addIssueRef(diffInfo)
+}
+
+// detectsJira reports whether Jira IDs are looked for in the branch name: not with
+// --no-jira or jira_enabled = false, nor when issue_style only detects GitLab issues
+func detectsJira() bool {
+	return cfg.IsJiraEnabled() && git.DetectsJira(cfg.GetIssueStyle())
+}
+
+// jiraURLs returns the links to the Jira tickets of the changes on the jira_base_url
+// site, or nil when no site is configured
+func jiraURLs(diffInfo git.GitDiff) []string {
+	ids := diffInfo.JiraIDs
+	if len(ids) == 0 && diffInfo.JiraID != "" {
+		ids = []string{diffInfo.JiraID}
+	}
+
+	var urls []string
+	for _, id := range ids {
+		if url := git.JiraURL(cfg.GetJiraBaseURL(), id); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// addIssueRef sets the GitLab issue reference from --issue, or extracts it from the
+// branch name when the configured issue style includes GitLab references
+func addIssueRef(diffInfo git.GitDiff) git.GitDiff {
+	diffInfo.IssueRef = cfg.GetIssueRef()
+	if diffInfo.IssueRef == "" && diffInfo.Branch != "" && git.DetectsGitLabIssues(cfg.GetIssueStyle()) {
+		log(config.Verbose, "Trying to extract issue reference from branch name: %s", diffInfo.Branch)
+		diffInfo.IssueRef = git.ExtractIssueRefFromBranchName(diffInfo.Branch)
+	}
+	return diffInfo
+}
+
+// withPrompts returns a copy of the diff with the system prompt and user prompt
+// template read from their files, along with the language and body settings that
+// shape the final prompts. Custom prompt files are announced with a warning.
+func withPrompts(diffInfo git.GitDiff) (git.GitDiff, error) {
+	providerName := promptProvider()
+	systemPrompt, isCustomSystemPrompt, systemPromptSource, err := readPromptFile(providerName, "system_prompt.txt")
+	if err != nil {
+		return diffInfo, fmt.Errorf("error reading system prompt: %v", err)
+	}
+
+	// Read the user prompt template for the mode, e.g. the enhanced template with -ccc
+	promptFileName := userPromptFileName()
+	log(config.Verbose, "Using user prompt template %s", promptFileName)
+
+	userPrompt, isCustomUserPrompt, userPromptSource, err := readPromptFile(providerName, promptFileName)
+	if err != nil {
+		log(config.Verbose, "%s not found, falling back to standard prompt", promptFileName)
+		userPrompt, isCustomUserPrompt, userPromptSource, err = readPromptFile(providerName, "user_prompt.txt")
+		if err != nil {
+			return diffInfo, fmt.Errorf("error reading user prompt template: %v", err)
+		}
+	}
+
+	// A template configured for the likely change type replaces the mode's template
+	if changeType, path := typeTemplate(diffInfo.ChangeType); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return diffInfo, fmt.Errorf("error reading the user prompt template for %s changes: %v", changeType, err)
+		}
+		userPrompt, isCustomUserPrompt, userPromptSource = string(content), true, fmt.Sprintf("templates.%s: %s", changeType, path)
+	}
+
+	if isCustomSystemPrompt {
+		log(config.Normal, "⚠️  Using custom system prompt from %s", systemPromptSource)
+	}
+	if isCustomUserPrompt {
+		log(config.Normal, "⚠️  Using custom user prompt from %s", userPromptSource)
+	}
+
+	diffInfo.SystemPrompt = wrapSystemPrompt(systemPrompt, cfg.GetSystemPromptPrefix(), cfg.GetSystemPromptSuffix())
+	diffInfo.UserPrompt = userPrompt
+	diffInfo.Language = cfg.GetLanguage()
+	diffInfo.Body = cfg.IsBodyEnabled()
+	return diffInfo, nil
+}
+
+// typeTemplate returns the entry of the [templates] table for the likely change type,
+// or its default entry when the type has none or couldn't be guessed, as the key and
+// the template's path. The path is empty when neither is configured, with --user-prompt,
+// and for the prompts that don't write a commit message: --analyze, --since and
+// --pr-description.
+func typeTemplate(changeType string) (string, string) {
+	if cfg.GetUserPromptPath() != "" || cfg.IsAnalyzeEnabled() || isMessageOnly() {
+		return "", ""
+	}
+	templates := cfg.GetTypeTemplates()
+	if path := templates[changeType]; changeType != "" && path != "" {
+		return changeType, path
+	}
+	return "default", templates["default"]
+}
+
+// promptProvider returns the provider whose prompts are used, so files in its
+// subdirectory of the prompt directory, e.g. prompts/openai, take precedence
+func promptProvider() string {
+	if providerName := cfg.GetProvider(); providerName != "" {
+		return providerName
+	}
+	return string(ai.ProviderAnthropic)
+}
+
+// wrapSystemPrompt adds system_prompt_prefix and system_prompt_suffix around the
+// system prompt, each separated from it by a blank line
+func wrapSystemPrompt(systemPrompt, prefix, suffix string) string {
+	if prefix = strings.TrimSpace(prefix); prefix != "" {
+		systemPrompt = prefix + "\n\n" + systemPrompt
+	}
+	if suffix = strings.TrimSpace(suffix); suffix != "" {
+		systemPrompt = strings.TrimRight(systemPrompt, "\n") + "\n\n" + suffix
+	}
+	return systemPrompt
+}
+
+// showPrompts prints the system and user prompts exactly as they will be sent, after
+// the templates are filled in, for --show-prompt and --show-prompt-only
+func showPrompts(diffInfo git.GitDiff) error {
+	diffInfo, err := withPrompts(diffInfo)
+	if err != nil {
+		return err
+	}
+	userPrompt, err := ai.FormatUserPrompt(diffInfo)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("===== SYSTEM PROMPT =====")
+	fmt.Println(ai.FormatSystemPrompt(diffInfo))
+	fmt.Println("===== USER PROMPT =====")
+	fmt.Println(userPrompt)
+	fmt.Println(strings.Repeat("=", 25))
+	return nil
+}
+
+func generateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	if err := validateModel(ai.NewAnthropicProvider(), modelName); err != nil {
+		return "", err
+	}
+
+	diffInfo, err := withPrompts(diffInfo)
+	if err != nil {
+		return "", err
+	}
+
+	// Format the user prompt with the diff information. With enhanced context the
+	// diff already carries the fields collected by git.GetEnhancedGitDiff.
+	if cfg.IsEnhancedContextEnabled() {
+		log(config.Verbose, "Formatting with enhanced context for prompt")
+	}
+	userPrompt, err := ai.FormatUserPrompt(diffInfo)
+	if err != nil {
+		return "", err
+	}
+
+	// Append the language instruction to the system prompt if a language is set
+	systemPrompt := ai.FormatSystemPrompt(diffInfo)
+
+	log(config.Verbose, "Building Claude API request...")
+	log(config.Debug, "System prompt length: %d bytes", len(systemPrompt))
+	log(config.Debug, "User prompt length: %d bytes", len(userPrompt))
+	
+	// Print full prompts at debug level with clear formatting
+	log(config.Debug, "===== SYSTEM PROMPT START =====")
+	log(config.Debug, "%s", systemPrompt)
+	log(config.Debug, "===== SYSTEM PROMPT END =====\n")
+	
+	log(config.Debug, "===== USER PROMPT START =====")
+	log(config.Debug, "%s", userPrompt)
+	log(config.Debug, "===== USER PROMPT END =====\n")
+	request := Request{
+		Model:     modelName,
+		MaxTokens: 1000,
+		System:    systemPrompt,
+		Messages: []Message{
+			{Role: "user", Content: userPrompt},
+		},
+	}
+	for _, turn := range diffInfo.Turns {
+		request.Messages = append(request.Messages, Message{Role: turn.Role, Content: turn.Content})
+	}
+
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	log(config.Verbose, "Sending request to Claude API...")
+	requestStartTime := time.Now()
+	req, err := http.NewRequestWithContext(ctx, "POST", anthropicAPI, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	// The shared client logs the request and response at debug level, keys redacted
+	client := ai.HTTPClient(cfg.GetRequestTimeout())
+	resp, err := client.Do(req)
+	requestDuration := time.Since(requestStartTime)
+	log(config.MoreVerbose, "API request took %.2f seconds", requestDuration.Seconds())
+	
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	rateLimit := ai.ParseRateLimit(resp.Header)
+	if rateLimit.Known() {
+		log(config.Verbose, "Rate limit (anthropic): %s", rateLimit)
+	}
+	
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		errorMsg := string(bodyBytes)
+		log(config.Debug, "API error response body: %s", errorMsg)
+		return "", &ai.APIError{StatusCode: resp.StatusCode, Message: errorMsg, RateLimit: rateLimit}
+	}
+
+	logVerbose("Parsing Claude API response...")
+	var response Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	if len(response.Content) == 0 {
+		return "", fmt.Errorf("empty response from API")
+	}
+
+	// Format API response for better readability
+	log(config.Debug, "===== API RESPONSE START =====")
+	formattedJSON, err := json.MarshalIndent(response, "", "  ")
+	if err == nil {
+		log(config.Debug, "%s", string(formattedJSON))
+	} else {
+		log(config.Debug, "%+v", response)
+	}
+	log(config.Debug, "===== API RESPONSE END =====\n")
+
+	recordUsage(ai.Usage{
+		Provider:     string(ai.ProviderAnthropic),
+		Model:        modelName,
+		InputTokens:  response.Usage.InputTokens,
+		OutputTokens: response.Usage.OutputTokens,
+	})
+	generatedBy = string(ai.ProviderAnthropic) + "/" + modelName
+	return sanitizeMessage(response.Content[0].Text), nil
+}
+
+// messagePreamble is the label models sometimes put before the message
+const messagePreamble = "commit message:"
+
+// sanitizeMessage removes the wrapping models sometimes add around the message: a
+// "Commit message:" preamble, a ``` code fence and surrounding quotes
+func sanitizeMessage(raw string) string {
+	message := strings.TrimSpace(raw)
+	if strings.HasPrefix(strings.ToLower(message), messagePreamble) {
+		message = strings.TrimSpace(message[len(messagePreamble):])
+	}
+
+	// Drop the opening fence line along with its language tag, e.g. ```text
+	if strings.HasPrefix(message, "```") {
+		if newline := strings.Index(message, "\n"); newline >= 0 {
+			message = message[newline+1:]
+		} else {
+			message = strings.TrimPrefix(message, "```")
+		}
+	}
+	message = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(message), "```"))
+
+	for _, quote := range []string{`"`, "'", "`"} {
+		if len(message) >= 2 && strings.HasPrefix(message, quote) && strings.HasSuffix(message, quote) {
+			message = strings.TrimSpace(message[1 : len(message)-1])
+			break
+		}
+	}
+	return message
+}
+
+// generateCommitMessageMultiProvider generates a commit message using the specified provider
+func generateCommitMessageMultiProvider(ctx context.Context, diffInfo git.GitDiff) (string, error) {
+	providerName, provider, apiKey, modelName, err := configuredProvider()
+	if err != nil {
+		return "", err
+	}
+	
+	message, err := generateWithProvider(ctx, provider, apiKey, modelName, diffInfo)
+	if err == nil {
+		generatedBy = providerName + "/" + modelName
+		return sanitizeMessage(message), nil
+	}
+	if !ai.IsRetryable(err) {
+		return "", err
+	}
+	
+	// Try each fallback provider with its own key and model before giving up
+	for _, fallback := range cfg.GetFallbackProviders() {
+		if strings.EqualFold(fallback, providerName) {
+			continue
+		}
+		log(config.Normal, "⚠️  %s failed: %v", providerName, err)
+		var apiErr *ai.APIError
+		if errors.As(err, &apiErr) && apiErr.RateLimit.Wait() > 0 {
+			log(config.Verbose, "%s expects a retry in %s", providerName, apiErr.RateLimit.Wait().Round(time.Second))
+		}
+		log(config.Normal, "Falling back to %s...", fallback)
+		
+		fallbackProvider, fallbackKey, fallbackModel, setupErr := providerByName(fallback)
+		if setupErr != nil {
+			log(config.Normal, "⚠️  Skipping fallback provider %s: %v", fallback, setupErr)
+			continue
+		}
+		
+		providerName = fallback
+		message, err = generateWithProvider(ctx, fallbackProvider, fallbackKey, fallbackModel, diffInfo)
+		if err == nil {
+			log(config.Normal, "Generated the message with fallback provider %s (%s)", fallback, fallbackModel)
+			generatedBy = fallback + "/" + fallbackModel
+			return sanitizeMessage(message), nil
+		}
+		if !ai.IsRetryable(err) {
+			return "", err
+		}
+	}
+	
+	return "", err
+}
+
+// heuristicMessage writes a basic message from the change type and staged files alone,
+// for --offline and for when every provider in the fallback chain has failed
+func heuristicMessage(diffInfo git.GitDiff) string {
+	changeType := diffInfo.ChangeType
+	if changeType == "" {
+		changeType = git.InferChangeType(diffInfo.Diff)
+	}
+	return commit.HeuristicMessage(changeType, diffInfo.StagedFiles)
+}
+
+// generateWithProvider generates a single message, streaming it to the terminal when enabled
+func generateWithProvider(ctx context.Context, provider ai.Provider, apiKey, modelName string, diffInfo git.GitDiff) (string, error) {
+	// Stream tokens to the terminal as they arrive, keeping the assembled message
+	// for the commit/edit flow
+	if isStreaming() {
+		printMessageHeader()
+		message, err := provider.GenerateCommitMessageStream(ctx, apiKey, modelName, diffInfo, resultOutput)
+		fmt.Fprintln(resultOutput)
+		return message, err
+	}
+	
+	// Generate commit message using the provider
+	return provider.GenerateCommitMessage(ctx, apiKey, modelName, diffInfo)
+}
+
+// generateCandidatesMultiProvider generates several distinct candidate messages concurrently
+func generateCandidatesMultiProvider(ctx context.Context, diffInfo git.GitDiff, count int) ([]string, error) {
+	providerName, provider, apiKey, modelName, err := configuredProvider()
+	if err != nil {
+		return nil, err
+	}
+	generatedBy = providerName + "/" + modelName
+
+	log(config.Verbose, "Generating %d candidate messages...", count)
+	candidates, err := ai.GenerateCandidates(ctx, provider, apiKey, modelName, diffInfo, count)
+	for i := range candidates {
+		candidates[i] = sanitizeMessage(candidates[i])
+	}
+	return candidates, err
+}
+
+// configuredProvider creates the configured provider and resolves its name, API key and model
+func configuredProvider() (string, ai.Provider, string, string, error) {
+	// Get provider name from config
+	providerName := cfg.GetProvider()
+	if providerName == "" {
+		providerName = string(ai.ProviderAnthropic) // Default to Anthropic
+	}
+	
+	provider, apiKey, modelName, err := providerByName(providerName)
+	return providerName, provider, apiKey, modelName, err
+}
+
+// isKeyOptional reports whether the named provider works without an API key
+func isKeyOptional(providerName string) bool {
+	provider := ai.GetProviderByName(providerName)
+	return provider != nil && provider.ValidateAPIKey("")
+}
+
+// providerByName creates the named provider and resolves its own API key and model
+func providerByName(providerName string) (ai.Provider, string, string, error) {
+	// Create provider using factory
+	provider, err := ai.NewProvider(providerName)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to create provider: %v", err)
+	}
+	
+	// Get API key for the provider
+	if err := cfg.LoadProviderKey(providerName); err != nil {
+		return nil, "", "", err
+	}
+	apiKey := cfg.GetProviderAPIKey(providerName)
+	if apiKey == "" && !provider.ValidateAPIKey("") {
+		return nil, "", "", fmt.Errorf("no API key found for provider: %s", providerName)
+	}
+	
+	// Get model name from config, or use default
+	modelName := effectiveModelName(providerName)
+	if err := validateModel(provider, modelName); err != nil {
+		return nil, "", "", err
+	}
+	
+	log(config.Verbose, "Using provider: %s with model: %s", providerName, modelName)
+	return provider, apiKey, modelName, nil
+}
+
+// validateModel checks that the model belongs to the provider unless --allow-unknown-model is set
+func validateModel(provider ai.Provider, modelName string) error {
+	if cfg.IsAllowUnknownModelEnabled() {
+		logVerbose("Skipping model validation for %s", modelName)
+		return nil
+	}
+	return ai.ValidateModel(provider, modelName)
+}
+
+// runPostGenerateHook pipes the message to the configured post_generate_hook and
+// returns its output as the new message. A non-zero exit status is an error.
+func runPostGenerateHook(message string, diffInfo git.GitDiff) (string, error) {
+	hookPath := cfg.GetPostGenerateHook()
+	providerName := cfg.GetProvider()
+	if providerName == "" {
+		providerName = string(ai.ProviderAnthropic)
+	}
+
+	logVerbose("Running post-generate hook: %s", hookPath)
+	cmd := exec.Command(hookPath)
+	cmd.Dir = git.Dir() // The repository given with --repo, if any
+	cmd.Stdin = strings.NewReader(message)
+	cmd.Stderr = os.Stderr
+	// Give the hook some context about the commit being made
+	cmd.Env = append(os.Environ(),
+		"AI_COMMIT_BRANCH="+diffInfo.Branch,
+		"AI_COMMIT_JIRA_ID="+ai.FormatJiraIDs(diffInfo),
+		"AI_COMMIT_JIRA_DESC="+diffInfo.JiraDescription,
+		"AI_COMMIT_JIRA_URL="+strings.Join(diffInfo.JiraURLs, "\n"),
+		"AI_COMMIT_ISSUE_REF="+diffInfo.IssueRef,
+		"AI_COMMIT_STAGED_FILES="+strings.Join(diffInfo.StagedFiles, "\n"),
+		"AI_COMMIT_PROVIDER="+providerName,
+		"AI_COMMIT_MODEL="+effectiveModelName(providerName),
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("hook %s failed: %v", hookPath, err)
+	}
+
+	hookedMessage := strings.TrimSpace(string(output))
+	if hookedMessage == "" {
+		return "", fmt.Errorf("hook %s returned an empty message", hookPath)
+	}
+	return hookedMessage, nil
+}
+
+// chooseModel lists the models of the current provider and switches to the one the
+// user picks for the rest of the session. Providers without a list of models, such as
+// the custom provider, take a model name instead. Pressing Enter keeps the current model.
+func chooseModel(providerName string) {
+	if providerName == "" {
+		providerName = string(ai.ProviderAnthropic)
+	}
+	provider := ai.GetProviderByName(providerName)
+	if provider == nil {
+		fmt.Fprintf(uiOutput, "Unknown provider: %s\n", providerName)
+		return
+	}
+
+	currentModel := effectiveModelName(providerName)
+	models := provider.GetAvailableModels()
+	if len(models) == 0 {
+		fmt.Fprintf(uiOutput, "\nEnter a model name for %s or press Enter to keep %s: ", strings.Title(providerName), currentModel)
+		var response string
+		fmt.Scanln(&response)
+		if response = strings.TrimSpace(response); response != "" {
+			logVerbose("Switching model to %s", response)
+			cfg.SetProviderModel(providerName, response)
+		}
+		return
+	}
+
+	fmt.Fprintf(uiOutput, "\nAvailable models for %s:\n", strings.Title(providerName))
+	for i, model := range models {
+		marker := " "
+		if model == currentModel {
+			marker = "*"
+		}
+		fmt.Fprintf(uiOutput, "  %s %d. %s\n", marker, i+1, model)
+	}
+
+	fmt.Fprintf(uiOutput, "Select a model (1-%d) or press Enter to keep %s: ", len(models), currentModel)
+	var response string
+	fmt.Scanln(&response)
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return
+	}
+
+	index, err := strconv.Atoi(response)
+	if err != nil || index < 1 || index > len(models) {
+		fmt.Fprintf(uiOutput, "Invalid choice, keeping %s.\n", currentModel)
+		return
+	}
+
+	logVerbose("Switching model to %s", models[index-1])
+	cfg.SetProviderModel(providerName, models[index-1])
+}
+
+// withKeyBindings applies key_bindings to the choices, keeping the default keys when
+// the bindings are invalid
+func withKeyBindings(choices ...ui.Choice) []ui.Choice {
+	bound, err := ui.Rebind(choices, cfg.GetKeyBindings())
+	if err != nil {
+		log(config.Normal, "⚠️  Ignoring key_bindings: %v", err)
+		return choices
+	}
+	return bound
+}
+
+// printCandidates prints the numbered list of candidate messages
+func printCandidates(candidates []string) {
+	fmt.Fprintln(uiOutput, "\n"+strings.Repeat("=", 50))
+	fmt.Fprintln(uiOutput, "Suggested commit messages:")
+	fmt.Fprintln(uiOutput, strings.Repeat("=", 50))
+	for i, candidate := range candidates {
+		if i > 0 {
+			fmt.Fprintln(uiOutput, strings.Repeat("-", 50))
+		}
+		fmt.Fprintf(uiOutput, "[%d] %s\n", i+1, candidate)
+	}
+}
+
+// chooseCandidate lets the user commit, edit or regenerate the candidate messages
+// until one is committed or the commit is aborted
+func chooseCandidate(candidates, notes []string, diffInfo git.GitDiff, count int) {
+	for {
+		fmt.Fprint(uiOutput, "Select a message, or choose an action: ")
+		choices := withKeyBindings(append(ui.CandidateChoices(len(candidates)),
+			ui.NewChoice(ui.ActionEdit, "e"),
+			ui.NewChoice(ui.ActionRegenerate, "r"),
+			ui.NewChoice(ui.ActionNo, "n"),
+		)...)
+		choice := ui.Confirm(choices)
+
+		switch choice.Action {
+		case ui.ActionRegenerate:
+			logVerbose("User selected 'regenerate', generating new candidates...")
+			ctx, stop := interruptible()
+			regenerated, err := generateCandidatesMultiProvider(ctx, diffInfo, count)
+			stop()
+			if err != nil {
+				fmt.Printf("Error generating commit message: %v\n", err)
+				continue
+			}
+			if cfg.IsNotesEnabled() {
+				notes = splitRationales(regenerated)
+			}
+			if cfg.GetPostGenerateHook() != "" {
+				for i, candidate := range regenerated {
+					if regenerated[i], err = runPostGenerateHook(candidate, diffInfo); err != nil {
+						fmt.Printf("Error running post-generate hook: %v\n", err)
+						os.Exit(1)
+					}
+				}
+			}
+			candidates = regenerated
+			printCandidates(candidates)
+			fmt.Fprintln(uiOutput, strings.Repeat("=", 50))
+			continue
+		case ui.ActionNo:
+			abortCommit()
+			return
+		}
+
+		// Editing asks which message to start from
+		edit := choice.Action == ui.ActionEdit
+		if edit {
+			fmt.Fprint(uiOutput, "Edit which message? ")
+			if choice = ui.Confirm(append(ui.CandidateChoices(len(candidates)), ui.NewChoice(ui.ActionNo, "n"))); choice.Action == ui.ActionNo {
+				continue
+			}
+		}
+
+		index := choice.Index
+		message := candidates[index-1]
+		if notes != nil {
+			commitNote = notes[index-1]
+		}
+		if edit {
+			logVerbose("User selected 'edit' for candidate %d, opening editor...", index)
+			editedMessage, err := editMessage(message)
+			if err != nil {
+				fmt.Printf("Error editing message: %v\n", err)
+				os.Exit(1)
+			}
+			if editedMessage == "" {
+				abortCommit()
+				return
+			}
+			message = editedMessage
+		}
+
+		logVerbose("User selected candidate %d, committing changes...", index)
+		if err := commitWithMessage(message); err != nil {
+			fmt.Printf("Error committing changes: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+}
+
+// effectiveModelName returns the model configured for the provider, or the provider's
+// default model. The legacy model_name setting only applies to anthropic, so switching
+// providers without --model doesn't send a Claude model name to another provider.
+func effectiveModelName(providerName string) string {
+	if providerName == "" {
+		providerName = string(ai.ProviderAnthropic)
+	}
+	modelName := cfg.GetProviderModel(providerName)
+	if modelName == "" {
+		if provider := ai.GetProviderByName(providerName); provider != nil {
+			modelName = provider.GetDefaultModel()
+		}
+	}
+	return modelName
+}
+
+// inProgressDraft warns when a merge, rebase or cherry-pick is in progress and returns
+// the message git prepared for it, so the model builds on it instead of starting from
+// scratch. --force skips the check, and amend mode describes the last commit instead.
+func inProgressDraft() string {
+	if cfg.IsForceEnabled() || cfg.IsAmendEnabled() {
+		return ""
+	}
+
+	operation, err := git.GetInProgressOperation()
+	if err != nil || operation == nil {
+		return ""
+	}
+
+	if operation.Message == "" {
+		log(config.Normal, "Warning: A %s is in progress; the commit will be part of it", operation.Name)
+		return ""
+	}
+
+	log(config.Normal, "Warning: A %s is in progress. Using its prepared message as the starting point (use --force to generate a fresh one)", operation.Name)
+	return operation.Message
+}
+
+// loadCommitTemplate returns the commit message template from --template, or from
+// git's commit.template setting, or "" if neither is set. A template configured in
+// git that can't be read is skipped with a warning.
+func loadCommitTemplate() (string, error) {
+	path := cfg.GetTemplate()
+	fromFlag := path != ""
+	if !fromFlag && cfg.GetDiffFile() == "" {
+		path = git.GetCommitTemplatePath()
+	}
+	if path == "" {
+		return "", nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if fromFlag {
+			return "", err
+		}
+		log(config.Normal, "Warning: Could not read commit.template %s: %v", path, err)
+		return "", nil
+	}
+
+	log(config.Verbose, "Using commit template from %s", path)
+	return string(content), nil
+}
+
+// maxContextFileBytes caps the project context read from --context-file so a large
+// file doesn't crowd out the diff
+const maxContextFileBytes = 16 * 1024
+
+// loadContextFile returns the project context from --context-file or context_file, or
+// "" if neither is set. A file over maxContextFileBytes is truncated with a warning.
+func loadContextFile() (string, error) {
+	path := cfg.GetContextFile()
+	if path == "" {
+		return "", nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if len(content) > maxContextFileBytes {
+		log(config.Normal, "Warning: Context file %s is %d bytes; only the first %d are sent", path, len(content), maxContextFileBytes)
+		content = content[:maxContextFileBytes]
+	}
+
+	log(config.Verbose, "Using project context from %s", path)
+	return string(content), nil
+}
+
+// loadLastRun returns the options of the last run for the rerun subcommand
+func loadLastRun() ([]string, error) {
+	configDir, err := cfg.GetConfigDirectory()
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadLastRun(configDir)
+}
+
+// saveLastRun saves the provider, model, context and Jira options of this run for the
+// rerun subcommand. Failing to save them is only worth a warning.
+func saveLastRun() {
+	configDir, err := cfg.GetConfigDirectory()
+	if err == nil {
+		err = config.SaveLastRun(configDir, runArgs)
+	}
+	if err != nil {
+		log(config.Verbose, "Warning: could not save the options for rerun: %v", err)
+	}
+}
+
+// usageLogPath returns the path of the usage log in the config directory
+func usageLogPath() (string, error) {
+	configDir, err := cfg.GetConfigDirectory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, usage.LogFileName), nil
+}
+
+// recordUsage prices the tokens used by a generation and appends them to the usage log
+func recordUsage(u ai.Usage) {
+	if u.InputTokens == 0 && u.OutputTokens == 0 {
+		return
+	}
+
+	cost, priced := usage.Cost(usage.MergePrices(cfg.GetModelPrices()), u.Model, u.InputTokens, u.OutputTokens)
+	if priced {
+		log(config.Verbose, "Usage: %d input + %d output tokens, $%.4f", u.InputTokens, u.OutputTokens, cost)
+	} else {
+		log(config.Verbose, "Usage: %d input + %d output tokens (no price configured for %s)", u.InputTokens, u.OutputTokens, u.Model)
+	}
+
+	path, err := usageLogPath()
+	if err == nil {
+		err = usage.Append(path, usage.Record{
+			Time:         time.Now(),
+			Provider:     u.Provider,
+			Model:        u.Model,
+			InputTokens:  u.InputTokens,
+			OutputTokens: u.OutputTokens,
+			Cost:         cost,
+			Priced:       priced,
+		})
+	}
+	if err != nil {
+		logVerbose("Warning: Could not record usage: %v", err)
+	}
+}
+
+// printUsageTotals prints the cumulative tokens and cost from the usage log
+func printUsageTotals() error {
+	path, err := usageLogPath()
+	if err != nil {
+		return err
+	}
+
+	totals, err := usage.ReadTotals(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("AI Commit Message Generator - Usage")
+	fmt.Println(strings.Repeat("=", 50))
+	if totals.Calls == 0 {
+		fmt.Println("No usage recorded yet.")
+		return nil
+	}
+
+	for _, model := range totals.ModelNames() {
+		modelTotals := totals.Models[model]
+		fmt.Printf("  %s: %d calls, %d input + %d output tokens, $%.4f\n",
+			model, modelTotals.Calls, modelTotals.InputTokens, modelTotals.OutputTokens, modelTotals.Cost)
+	}
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("Total: %d calls, %d input + %d output tokens, $%.4f\n",
+		totals.Calls, totals.InputTokens, totals.OutputTokens, totals.Cost)
+	if totals.Unpriced > 0 {
+		fmt.Printf("Note: %d calls used models without a price and are not included in the cost.\n", totals.Unpriced)
+	}
+	fmt.Printf("Usage log: %s\n", path)
+	return nil
+}
+
+// withPathspec appends the pathspecs to a git command's arguments after "--"
+func withPathspec(args []string, pathspec []string) []string {
+	if len(pathspec) == 0 {
+		return args
+	}
+	return append(append(append([]string{}, args...), "--"), pathspec...)
+}
+
+// checkOnlyStaged returns an error when files matching the --only pathspec have unstaged
+// changes. git commit -- <paths> commits the working-tree content of those paths, while
+// the message describes the staged content, so the unstaged edits would be committed
+// under a message that doesn't mention them.
+func checkOnlyStaged(only []string) error {
+	if len(only) == 0 {
+		return nil
+	}
+	output, err := git.Command(withPathspec([]string{"diff", "--name-only"}, only)...).Output()
+	if err != nil {
+		return fmt.Errorf("could not check %s for unstaged changes: %v", strings.Join(only, " "), err)
+	}
+	if files := strings.TrimSpace(string(output)); files != "" {
+		return fmt.Errorf("--only would also commit the unstaged changes to %s; stage or stash them first", strings.ReplaceAll(files, "\n", ", "))
+	}
+	return nil
+}
+
+// userPromptFiles lists the user prompt templates, each with whether it is filled with
+// the enhanced context
+var userPromptFiles = []struct {
+	name     string
+	enhanced bool
+}{
+	{"user_prompt.txt", false},
+	{"body_user_prompt.txt", false},
+	{"pr_description_prompt.txt", false},
+	{"analyze_prompt.txt", false},
+	{"enhanced_user_prompt.txt", true},
+}
+
+// checkPrompts checks the placeholders or format verbs of each user prompt template
+// that would be used and reports whether they are all usable
+func checkPrompts() bool {
+	fmt.Println("AI Commit Message Generator - Prompt Check")
+	fmt.Println(strings.Repeat("=", 50))
+
+	ok := true
+	for _, file := range userPromptFiles {
+		template, _, source, err := readPromptFile(promptProvider(), file.name)
+		if err != nil {
+			if file.name == "user_prompt.txt" {
+				fmt.Printf("❌ %s: %v\n", file.name, err)
+				ok = false
+			} else {
+				fmt.Printf("   %s: not found, user_prompt.txt is used instead\n", file.name)
+			}
+			continue
+		}
+
+		if !printPromptCheck(file.name, source, template, file.enhanced) {
+			ok = false
+		}
+	}
+
+	// Templates for a change type stand in for the standard template
+	templates := cfg.GetTypeTemplates()
+	changeTypes := make([]string, 0, len(templates))
+	for changeType := range templates {
+		changeTypes = append(changeTypes, changeType)
+	}
+	sort.Strings(changeTypes)
+	for _, changeType := range changeTypes {
+		name := "templates." + changeType
+		template, err := os.ReadFile(templates[changeType])
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", name, err)
+			ok = false
+			continue
+		}
+		if !printPromptCheck(name, templates[changeType], string(template), false) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// printPromptCheck prints the result of checking a user prompt template and reports
+// whether it has no problems
+func printPromptCheck(name, source, template string, enhanced bool) bool {
+	check := ai.CheckUserPrompt(template, enhanced)
+	switch {
+	case len(check.Problems) > 0:
+		fmt.Printf("❌ %s (%s)\n", name, source)
+	case len(check.Warnings) > 0:
+		fmt.Printf("⚠️  %s (%s)\n", name, source)
+	case check.Named:
+		fmt.Printf("✅ %s (%s): named placeholders\n", name, source)
+	default:
+		fmt.Printf("✅ %s (%s): %d format verbs\n", name, source, check.Verbs)
+	}
+	for _, problem := range check.Problems {
+		fmt.Printf("     - %s\n", problem)
+	}
+	for _, warning := range check.Warnings {
+		fmt.Printf("     - %s\n", warning)
+	}
+	return len(check.Problems) == 0
+}
+
+// printProfiles lists the profiles defined in the config, marking the one in use
+func printProfiles() {
+	profiles := cfg.ListProfiles()
+	if len(profiles) == 0 {
+		fmt.Println("No profiles are defined. Add a [profiles.NAME] table to the config file to create one.")
+		return
+	}
+
+	fmt.Println("Profiles:")
+	for _, name := range profiles {
+		marker := " "
+		if name == cfg.GetProfile() {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, name)
+	}
+	fmt.Println("\nUse one with --profile NAME or AI_COMMIT_PROFILE=NAME.")
+}
+
+// testProvider sends a tiny request to the provider, or the configured one, and reports
+// whether the key, the model and the network work. No staged changes are needed.
+func testProvider(providerName string) bool {
+	if providerName == "" {
+		providerName = promptProvider()
+	}
+	fmt.Printf("Testing %s...\n", strings.Title(providerName))
+
+	provider, apiKey, modelName, err := providerByName(providerName)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return false
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	result, err := ai.CheckProvider(ctx, provider, apiKey, modelName)
+	if err != nil {
+		fmt.Printf("❌ Request with model %s failed after %s\n", modelName, result.Latency.Round(time.Millisecond))
+		if reason := ai.DescribeError(err); reason != "" {
+			fmt.Printf("   Cause: %s\n", reason)
+		}
+		fmt.Printf("   Error: %v\n", err)
+		return false
 	}
 
-	// Get the diff details with context
-	logVerbose("Getting diff details with context lines: %d...", contextLines)
-	var args []string
-	args = append(args, "diff", "--cached")
-	
-	// Handle different context levels
-	if contextLines >= 0 {
-		// Use the specified number of context lines
-		args = append(args, fmt.Sprintf("--unified=%d", contextLines))
-	} else {
-		// For maximum context (-1), we need to get full file content for each changed file
-		// We'll have to handle this specially
-		logVerbose("Using maximum context (full file content)...")
-		if len(diffInfo.StagedFiles) > 0 && diffInfo.StagedFiles[0] != "" {
-			var fullDiff strings.Builder
-			
-			// Add a standard diff header
-			fmt.Fprintf(&fullDiff, "# Showing full files for maximum context\n\n")
-			
-			for _, file := range diffInfo.StagedFiles {
-				// Get the full content of each staged file
-				fileCmd := exec.Command("git", "show", fmt.Sprintf(":%s", file))
-				fileOutput, err := fileCmd.Output()
-				if err == nil {
-					fmt.Fprintf(&fullDiff, "=== %s ===\n", file)
-					fullDiff.Write(fileOutput)
-					fmt.Fprintf(&fullDiff, "\n\n")
-				}
-			}
-			
-			// Also include the standard diff for clarity on what actually changed
-			diffCmd := exec.Command("git", "diff", "--cached")
-			diffOutput, err := diffCmd.Output()
-			if err == nil {
-				fmt.Fprintf(&fullDiff, "=== CHANGES ===\n")
-				fullDiff.Write(diffOutput)
-			}
-			
-			diffInfo.Diff = fullDiff.String()
-			log(config.MoreVerbose, "Full context diff length: %d bytes", len(diffInfo.Diff))
-			
-			// Get the branch info and return
-			diffInfo = getBranchInfo(diffInfo)
-			return diffInfo, nil
+	fmt.Printf("✅ %s answered %q in %s\n", strings.Title(providerName), result.Reply, result.Latency.Round(time.Millisecond))
+	fmt.Printf("   Model: %s\n", result.Model)
+	return true
+}
+
+// userPromptFileName returns the user prompt template to use for the enabled options
+func userPromptFileName() string {
+	switch {
+	case cfg.IsAnalyzeEnabled():
+		return "analyze_prompt.txt"
+	case cfg.IsPRDescriptionEnabled():
+		return "pr_description_prompt.txt"
+	case cfg.IsEnhancedContextEnabled():
+		return "enhanced_user_prompt.txt"
+	case cfg.IsBodyEnabled():
+		return "body_user_prompt.txt"
+	}
+	return "user_prompt.txt"
+}
+
+// copyToClipboard copies the message to the clipboard. Without a clipboard tool the
+// message is printed instead, since in quiet mode it hasn't been shown yet.
+func copyToClipboard(message string) {
+	err := clipboard.Copy(message)
+	if err == nil {
+		if !cfg.IsQuiet() {
+			fmt.Fprintln(uiOutput, "Copied the message to the clipboard.")
 		}
+		return
 	}
-	
-	// Standard diff with specified context
-	cmd = exec.Command("git", args...)
-	output, err = cmd.Output()
+
+	if errors.Is(err, clipboard.ErrUnavailable) {
+		fmt.Fprintln(os.Stderr, "Could not copy the message: no clipboard tool found (install xclip, xsel or wl-clipboard on Linux).")
+	} else {
+		fmt.Fprintf(os.Stderr, "Could not copy the message: %v\n", err)
+	}
+	if cfg.IsQuiet() {
+		fmt.Fprintln(resultOutput, message)
+	}
+}
+
+// createPullRequest opens a pull request with "gh pr create", using the first line of
+// the description as the title and the rest as the body. gh may prompt, e.g. to push
+// the branch, so it is connected to the terminal.
+func createPullRequest(description string) error {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return fmt.Errorf("--gh needs the GitHub CLI (gh) on your PATH: %v", err)
+	}
+
+	description = strings.TrimSpace(description)
+	title := ai.Subject(description)
+	body := strings.TrimSpace(strings.TrimPrefix(description, title))
+
+	bodyFile, err := os.CreateTemp("", "ai-commit-msg-pr-*.md")
 	if err != nil {
-		return diffInfo, err
+		return fmt.Errorf("failed to create pull request body file: %v", err)
 	}
-	diffInfo.Diff = string(output)
-	log(config.MoreVerbose, "Diff length: %d bytes", len(diffInfo.Diff))
+	defer os.Remove(bodyFile.Name())
+	if _, err := bodyFile.WriteString(body); err != nil {
+		bodyFile.Close()
+		return fmt.Errorf("failed to write pull request body file: %v", err)
+	}
+	bodyFile.Close()
 
-	// Get the branch info and return
-	return getBranchInfo(diffInfo), nil
+	args := []string{"pr", "create", "--title", title, "--body-file", bodyFile.Name()}
+	if base := git.GetRefBranchName(cfg.GetSince()); base != "" {
+		args = append(args, "--base", base)
+	}
+
+	logVerbose("Running gh %s", strings.Join(args, " "))
+	cmd := exec.Command("gh", args...)
+	cmd.Dir = git.Dir()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
 }
 
-// getBranchInfo gets the branch information and returns the updated diffInfo
-func getBranchInfo(diffInfo GitDiff) GitDiff {
-	// Get current branch name
-	logVerbose("Getting current branch name...")
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	output, err := cmd.Output()
-	if err == nil {
-		diffInfo.Branch = strings.TrimSpace(string(output))
+// isMessageOnly reports whether the message is only printed rather than committed,
+// as it describes existing commits (--since) or a pull request (--pr-description)
+func isMessageOnly() bool {
+	return cfg.GetSince() != "" || cfg.IsPRDescriptionEnabled()
+}
+
+// useStructuredOutput reports whether to ask for the message as JSON fields with
+// --structured. The rationale for --notes and the descriptions of commits and pull
+// requests don't fit the fields, so they stay text; models without structured output
+// are asked for text as well, which the providers take care of.
+func useStructuredOutput() bool {
+	if !cfg.IsStructuredOutputEnabled() || cfg.IsNotesEnabled() || isMessageOnly() {
+		return false
+	}
+	providerName := promptProvider()
+	modelName := effectiveModelName(cfg.GetProvider())
+	if !ai.SupportsStructuredOutput(ai.ProviderType(providerName), modelName) {
+		log(config.Verbose, "%s/%s doesn't support structured output; asking for plain text", providerName, modelName)
 	}
+	return true
+}
 
-	// Try to extract Jira ID from branch name if not provided
-	if diffInfo.JiraID == "" && diffInfo.Branch != "" {
-		// Common branch naming patterns like feature/GTBUG-123-description or bugfix/GTN-456-description
-		log(config.Verbose, "Trying to extract Jira ID from branch name: %s", diffInfo.Branch)
-		
-		// Get more branch info at MoreVerbose level with clear formatting
-		if cfg.GetVerbosity() >= config.MoreVerbose {
-			log(config.MoreVerbose, "===== BRANCH INFO =====")
-			log(config.MoreVerbose, "Name: %s", diffInfo.Branch)
-			
-			// Get branch creation date
-			dateCmd := exec.Command("git", "show", "-s", "--format=%ci", diffInfo.Branch)
-			dateOutput, dateErr := dateCmd.Output()
-			if dateErr == nil {
-				log(config.MoreVerbose, "Created: %s", strings.TrimSpace(string(dateOutput)))
-			}
-			
-			// Get branch tracking info
-			trackCmd := exec.Command("git", "for-each-ref", "--format='%(upstream:short)'", "refs/heads/"+diffInfo.Branch)
-			trackOutput, trackErr := trackCmd.Output()
-			if trackErr == nil && len(trackOutput) > 0 {
-				log(config.MoreVerbose, "Tracks: %s", strings.TrimSpace(string(trackOutput)))
-			}
-			
-			// For debug level, show more detailed branch info
-			if cfg.GetVerbosity() >= config.Debug {
-				// Get last commit info
-				commitCmd := exec.Command("git", "log", "-1", "--pretty=%h %s", diffInfo.Branch)
-				commitOutput, commitErr := commitCmd.Output()
-				if commitErr == nil {
-					log(config.Debug, "Last commit: %s", strings.TrimSpace(string(commitOutput)))
-				}
-				
-				// Get commit count
-				countCmd := exec.Command("git", "rev-list", "--count", diffInfo.Branch)
-				countOutput, countErr := countCmd.Output()
-				if countErr == nil {
-					log(config.Debug, "Commit count: %s", strings.TrimSpace(string(countOutput)))
-				}
-			}
-			
-			log(config.MoreVerbose, "======================")
-		}
+// isStreaming reports whether the message should be streamed to the terminal.
+// Streaming is disabled in JSON mode, where only the final object is printed,
+// and when several candidates are generated at once.
+func isStreaming() bool {
+	return cfg.IsStreamEnabled() && !cfg.IsJSONOutput() && !cfg.IsQuiet() && cfg.GetCandidates() <= 1
+}
 
-		// Look for GTBUG-XXX pattern
-		if idx := strings.Index(strings.ToUpper(diffInfo.Branch), "GTBUG-"); idx >= 0 {
-			start := idx
-			end := start + 6 // "GTBUG-" length
+// printJSONResult writes the result object to the original stdout
+func printJSONResult(result jsonResult) error {
+	if result.StagedFiles == nil {
+		result.StagedFiles = []string{}
+	}
+	encoder := json.NewEncoder(resultOutput)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
 
-			// Find the end of the number part
-			for end < len(diffInfo.Branch) && (diffInfo.Branch[end] >= '0' && diffInfo.Branch[end] <= '9') {
-				end++
-			}
+// abortCommit reports that the user declined to commit and exits with exitAborted
+func abortCommit() {
+	fmt.Fprintln(uiOutput, "Commit aborted.")
+	exit(exitAborted)
+}
 
-			if end > start+6 { // Make sure we found at least one digit
-				diffInfo.JiraID = diffInfo.Branch[start:end]
-				log(config.MoreVerbose, "Extracted Jira ID from branch name: %s", diffInfo.JiraID)
-			}
-		} else if idx := strings.Index(strings.ToUpper(diffInfo.Branch), "GTN-"); idx >= 0 {
-			// Look for GTN-XXX pattern
-			start := idx
-			end := start + 4 // "GTN-" length
-
-			// Find the end of the number part
-			for end < len(diffInfo.Branch) && (diffInfo.Branch[end] >= '0' && diffInfo.Branch[end] <= '9') {
-				end++
-			}
+// printMessageHeader prints the banner shown above the suggested commit message
+func printMessageHeader() {
+	fmt.Fprintln(uiOutput, "\n"+strings.Repeat("=", 50))
+	fmt.Fprintln(uiOutput, "Suggested commit message:")
+	fmt.Fprintln(uiOutput, strings.Repeat("=", 50))
+}
 
-			if end > start+4 { // Make sure we found at least one digit
-				diffInfo.JiraID = diffInfo.Branch[start:end]
-				log(config.MoreVerbose, "Extracted Jira ID from branch name: %s", diffInfo.JiraID)
+// showMessage displays the suggested message, or the candidates when there are
+// several, between banners. Only the message itself goes to stdout; the banners,
+// the git note and the summary line are written to uiOutput. A streamed message was
+// already written as it arrived, so only what follows it is shown.
+func showMessage(message string, candidates []string, streamed bool) {
+	if len(candidates) > 1 {
+		printCandidates(candidates)
+	} else {
+		if !streamed {
+			printMessageHeader()
+			fmt.Fprintln(resultOutput, message)
+			if commitNote != "" {
+				fmt.Fprintf(uiOutput, "\nGit note:\n%s\n", commitNote)
 			}
 		}
+		printMessageSummary(message)
 	}
-
-	return diffInfo
+	fmt.Fprintln(uiOutput, strings.Repeat("=", 50))
 }
 
-func generateCommitMessage(apiKey string, modelName string, diffInfo GitDiff) (string, error) {
-	// Read system prompt from file
-	systemPrompt, isCustomSystemPrompt, systemPromptSource, err := readPromptFile("system_prompt.txt")
+// analyzeStagedChanges asks the model how the staged changes could be split into
+// logical commits and prints its plan, or the plan as JSON with --json
+func analyzeStagedChanges(ctx context.Context, diffInfo git.GitDiff) error {
+	diffInfo, err := withPrompts(diffInfo)
+	if err != nil {
+		return err
+	}
+	providerName, provider, apiKey, modelName, err := configuredProvider()
 	if err != nil {
-		return "", fmt.Errorf("error reading system prompt: %v", err)
+		return err
 	}
 
-	// Read user prompt template from file - use enhanced template if enabled
-	promptFileName := "user_prompt.txt"
-	if cfg.IsEnhancedContextEnabled() {
-		promptFileName = "enhanced_user_prompt.txt"
-		log(config.Verbose, "Using enhanced user prompt template")
+	if !cfg.IsQuiet() && !cfg.IsJSONOutput() {
+		fmt.Fprintf(uiOutput, "Analyzing staged changes with %s...\n", strings.Title(providerName))
 	}
-	
-	userPromptTemplate, isCustomUserPrompt, userPromptSource, err := readPromptFile(promptFileName)
+	response, err := provider.GenerateCommitMessage(ctx, apiKey, modelName, diffInfo)
 	if err != nil {
-		log(config.Verbose, "Enhanced prompt not found, falling back to standard prompt")
-		userPromptTemplate, isCustomUserPrompt, userPromptSource, err = readPromptFile("user_prompt.txt")
-		if err != nil {
-			return "", fmt.Errorf("error reading user prompt template: %v", err)
-		}
+		return err
 	}
-	
-	// Warn if using custom prompts
-	if isCustomSystemPrompt {
-		log(config.Normal, "⚠️  Using custom system prompt from %s", systemPromptSource)
+	plan, err := ai.ParseCommitPlan(response)
+	if err != nil {
+		log(config.Verbose, "Response: %s", response)
+		return err
 	}
-	if isCustomUserPrompt {
-		log(config.Normal, "⚠️  Using custom user prompt from %s", userPromptSource)
+
+	if cfg.IsJSONOutput() {
+		encoder := json.NewEncoder(resultOutput)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
 	}
 
-	// Format the user prompt with the diff information
-	userPrompt := ""
-	if cfg.IsEnhancedContextEnabled() {
-		// Use the diffInfo we already have, but convert it to enhanced format for the LLM
-		// This avoids making a second git diff call
-		log(config.Verbose, "Formatting with enhanced context for prompt")
-		
-		// Format project context, file summaries, etc. from the standard diff
-		// These will be empty/minimal since we're not calling git.GetEnhancedGitDiff again
-		projectContext := ""  // Minimal project context
-		fileSummaries := ""  // No detailed file summaries
-		commitHistory := ""  // No commit history
-		relatedFiles := ""   // No related files
-		
-		// Use the enhanced user prompt template
-		userPrompt = fmt.Sprintf(
-			userPromptTemplate,
-			diffInfo.Branch,
-			strings.Join(diffInfo.StagedFiles, "\n"),
-			diffInfo.Diff,
-			diffInfo.JiraID,
-			diffInfo.JiraDescription,
-			projectContext,
-			fileSummaries,
-			commitHistory,
-			relatedFiles,
-		)
-	} else {
-		// Regular format without enhanced context
-		userPrompt = fmt.Sprintf(
-			userPromptTemplate,
-			diffInfo.Branch,
-			strings.Join(diffInfo.StagedFiles, "\n"),
-			diffInfo.Diff,
-			diffInfo.JiraID,
-			diffInfo.JiraDescription,
-		)
+	fmt.Fprintln(uiOutput, "\n"+strings.Repeat("=", 50))
+	fmt.Fprintf(uiOutput, "Suggested commits (%d):\n", len(plan))
+	fmt.Fprintln(uiOutput, strings.Repeat("=", 50))
+	for i, group := range plan {
+		fmt.Fprintf(resultOutput, "\n%d. %s\n", i+1, strings.ReplaceAll(group.Message, "\n", "\n   "))
+		fmt.Fprintln(resultOutput, "   Files:")
+		for _, file := range group.Files {
+			fmt.Fprintf(resultOutput, "     - %s\n", file)
+		}
+	}
+	if unplanned := ai.UnplannedFiles(plan, diffInfo.StagedFiles); len(unplanned) > 0 {
+		fmt.Fprintf(resultOutput, "\nNot in any suggested commit: %s\n", strings.Join(unplanned, ", "))
 	}
+	fmt.Fprintln(uiOutput, strings.Repeat("=", 50))
+	fmt.Fprintln(uiOutput, "Nothing was committed. Unstage everything with 'git reset', then stage and commit each group.")
+	return nil
+}
 
-	log(config.Verbose, "Building Claude API request...")
-	log(config.Debug, "System prompt length: %d bytes", len(systemPrompt))
-	log(config.Debug, "User prompt length: %d bytes", len(userPrompt))
-	
-	// Print full prompts at debug level with clear formatting
-	log(config.Debug, "===== SYSTEM PROMPT START =====")
-	log(config.Debug, "%s", systemPrompt)
-	log(config.Debug, "===== SYSTEM PROMPT END =====\n")
-	
-	log(config.Debug, "===== USER PROMPT START =====")
-	log(config.Debug, "%s", userPrompt)
-	log(config.Debug, "===== USER PROMPT END =====\n")
-	request := Request{
-		Model:     modelName,
-		MaxTokens: 1000,
-		System:    systemPrompt,
-		Messages: []Message{
-			{Role: "user", Content: userPrompt},
-		},
+// printGitInstallHelp explains how to install git when it can't be run
+func printGitInstallHelp(err error) {
+	fmt.Printf("Error: %v\n", err)
+	fmt.Println("ai-commit-msg runs git to read your staged changes and commit them. Install it with:")
+	switch runtime.GOOS {
+	case "darwin":
+		fmt.Println("  xcode-select --install   (or: brew install git)")
+	case "windows":
+		fmt.Println("  winget install --id Git.Git -e   (or download it from https://git-scm.com/download/win)")
+	default:
+		fmt.Println("  sudo apt install git   (Debian/Ubuntu)")
+		fmt.Println("  sudo dnf install git   (Fedora/RHEL)")
+		fmt.Println("  sudo apk add git       (Alpine)")
+	}
+	fmt.Println("If git is already installed, make sure its directory is on your PATH.")
+}
+
+// printMessageSummary prints the subject length, format and body size of the message
+// below it, as a quick sanity check before confirming
+func printMessageSummary(message string) {
+	fmt.Fprintln(uiOutput, strings.Repeat("-", 50))
+	fmt.Fprintf(uiOutput, "Summary: %s\n", commit.Summarize(message))
+}
+
+func commitWithMessage(message string) error {
+	// Trailers go in a fixed order: Jira links, co-authors, the model, then the sign-off
+	for _, url := range jiraLinks {
+		logVerbose("Adding Jira trailer for %s", url)
+		message = git.AppendTrailer(message, "Jira", url)
 	}
 
-	requestBody, err := json.Marshal(request)
-	if err != nil {
-		return "", err
+	if coAuthors := cfg.GetCoAuthors(); len(coAuthors) > 0 {
+		coAuthoredMessage, err := git.AppendCoAuthors(message, coAuthors)
+		if err != nil {
+			return err
+		}
+		logVerbose("Adding Co-authored-by trailers for %s", strings.Join(coAuthors, ", "))
+		message = coAuthoredMessage
 	}
 
-	log(config.Verbose, "Sending request to Claude API...")
-	requestStartTime := time.Now()
-	req, err := http.NewRequest("POST", anthropicAPI, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return "", err
+	if cfg.IsAttributionEnabled() {
+		attributedMessage, err := attributeMessage(message)
+		if err != nil {
+			return err
+		}
+		message = attributedMessage
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	if cfg.IsSignoffEnabled() {
+		signedMessage, err := signoffMessage(message)
+		if err != nil {
+			return err
+		}
+		message = signedMessage
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	requestDuration := time.Since(requestStartTime)
-	log(config.MoreVerbose, "API request took %.2f seconds", requestDuration.Seconds())
-	
+	if cfg.IsAmendEnabled() {
+		if err := amendCommitWithMessage(message); err != nil {
+			return err
+		}
+		addCommitNote()
+		return nil
+	}
+
+	// Pass the message through a file so the body's blank lines and bullets survive intact
+	messageFile, err := git.WriteMessageFile(message)
 	if err != nil {
-		return "", err
+		return err
 	}
-	defer resp.Body.Close()
+	defer os.Remove(messageFile)
 
-	// Log HTTP response details at debug level with clear formatting
-	log(config.Debug, "===== HTTP RESPONSE DETAILS =====")
-	log(config.Debug, "Status: %s", resp.Status)
-	log(config.Debug, "Headers:")
-	
-	// Print headers in a more readable format
-	for key, values := range resp.Header {
-		for _, value := range values {
-			log(config.Debug, "  %s: %s", key, value)
-		}
+	// With --only, git commits just the matching paths and leaves the rest staged. It
+	// commits them as they are in the working tree, so check again for edits made since
+	// the message was generated.
+	if err := checkOnlyStaged(cfg.GetOnly()); err != nil {
+		return err
 	}
-	log(config.Debug, "==================================")
-	
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		errorMsg := string(bodyBytes)
-		log(config.Debug, "API error response body: %s", errorMsg)
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, errorMsg)
+	logVerbose("Executing git commit command...")
+	if err := runGitCommit(withPathspec(commitArgs("-F", messageFile), cfg.GetOnly())); err != nil {
+		return err
 	}
+	if !cfg.IsQuiet() {
+		fmt.Fprintln(uiOutput, "Successfully committed with message.")
+	}
+	addCommitNote()
+	return nil
+}
 
-	logVerbose("Parsing Claude API response...")
-	var response Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", err
+// addCommitNote attaches the rationale to the new commit as a git note with --notes.
+// The commit has already succeeded, so a failure is only a warning.
+func addCommitNote() {
+	if !cfg.IsNotesEnabled() || commitNote == "" {
+		return
+	}
+	if err := git.AddNote(cfg.GetNotesRef(), commitNote); err != nil {
+		log(config.Normal, "⚠️  Committed, but the git note could not be added: %v", err)
+		return
 	}
+	logVerbose("Added the rationale as a git note")
+}
 
-	if len(response.Content) == 0 {
-		return "", fmt.Errorf("empty response from API")
+// splitRationales separates the rationale from each message written with --notes,
+// leaving only the commit messages in messages, and returns the rationales
+func splitRationales(messages []string) []string {
+	notes := make([]string, len(messages))
+	for i, message := range messages {
+		messages[i], notes[i] = ai.SplitRationale(message)
 	}
+	return notes
+}
 
-	// Format API response for better readability
-	log(config.Debug, "===== API RESPONSE START =====")
-	formattedJSON, err := json.MarshalIndent(response, "", "  ")
-	if err == nil {
-		log(config.Debug, "%s", string(formattedJSON))
-	} else {
-		log(config.Debug, "%+v", response)
+// commitArgs returns the arguments for git commit: --quiet in quiet mode, --no-verify,
+// the extra --commit-arg values and then args
+func commitArgs(args ...string) []string {
+	commit := []string{"commit"}
+	if cfg.IsQuiet() {
+		commit = append(commit, "--quiet")
 	}
-	log(config.Debug, "===== API RESPONSE END =====\n")
-	return response.Content[0].Text, nil
+	if cfg.IsNoVerifyEnabled() {
+		commit = append(commit, "--no-verify")
+	}
+	commit = append(commit, signArgs()...)
+	commit = append(commit, cfg.GetCommitArgs()...)
+	return append(commit, args...)
 }
 
-// generateCommitMessageMultiProvider generates a commit message using the specified provider
-func generateCommitMessageMultiProvider(diffInfo git.GitDiff) (string, error) {
-	// Get provider name from config
-	providerName := cfg.GetProvider()
-	if providerName == "" {
-		providerName = string(ai.ProviderAnthropic) // Default to Anthropic
+// signArgs returns the signing option for git commit: --no-gpg-sign with --no-gpg-sign,
+// otherwise -S (or -S<keyid> with signing_key) when sign_commits or git's commit.gpgsign
+// is set, and nothing when commits aren't signed
+func signArgs() []string {
+	if cfg.IsNoGPGSignEnabled() {
+		return []string{"--no-gpg-sign"}
 	}
-	
-	// Create provider using factory
-	provider, err := ai.NewProvider(providerName)
+	if cfg.IsSignCommitsEnabled() || git.IsGPGSignEnabled() {
+		return git.SignArgs(cfg.GetSigningKey())
+	}
+	return nil
+}
+
+// runGitCommit runs git commit with the given arguments. A signed commit that fails
+// because of GPG is reported as a signing failure rather than a generic commit error.
+func runGitCommit(args []string) error {
+	signing := false
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-S") || strings.HasPrefix(arg, "--gpg-sign") {
+			signing = true
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd := git.Command(args...)
+	// git's summary of the new commit is chrome too, so it goes with the rest on stderr
+	cmd.Stdout = uiOutput
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	err := cmd.Run()
+	if err != nil && signing && git.IsSigningError(stderr.String()) {
+		return fmt.Errorf("signing the commit with GPG failed (%v). Check that gpg works (echo test | gpg --clearsign) "+
+			"and the signing key is available, or commit without a signature using --no-gpg-sign", err)
+	}
+	return err
+}
+
+// signoffMessage adds a Signed-off-by trailer for the configured git identity
+func signoffMessage(message string) (string, error) {
+	name, email, err := git.GetUserIdentity()
 	if err != nil {
-		return "", fmt.Errorf("failed to create provider: %v", err)
+		return "", fmt.Errorf("cannot sign off commit: %v", err)
 	}
-	
-	// Get API key for the provider
-	apiKey := cfg.GetProviderAPIKey(providerName)
-	if apiKey == "" {
-		return "", fmt.Errorf("no API key found for provider: %s", providerName)
+
+	logVerbose("Adding Signed-off-by trailer for %s <%s>", name, email)
+	return git.AppendSignoff(message, name, email), nil
+}
+
+// attributeMessage adds a trailer naming the provider and model that generated the
+// message, e.g. "AI-Generated-By: anthropic/claude-3-haiku-20240307"
+func attributeMessage(message string) (string, error) {
+	trailerKey := cfg.GetAttributionTrailer()
+	if !git.IsTrailerKey(trailerKey) {
+		return "", fmt.Errorf("invalid attribution_trailer %q: use letters, digits and dashes, e.g. %s", trailerKey, config.DefaultAttributionTrailer)
 	}
-	
-	// Get model name from config, or use default
-	modelName := cfg.GetModelName()
-	if modelName == "" {
-		modelName = provider.GetDefaultModel()
+	if generatedBy == "" {
+		log(config.Verbose, "No model generated this message; leaving out the %s trailer", trailerKey)
+		return message, nil
 	}
-	
-	log(config.Verbose, "Using provider: %s with model: %s", providerName, modelName)
-	
-	// Generate commit message using the provider
-	return provider.GenerateCommitMessage(apiKey, modelName, diffInfo)
+
+	logVerbose("Adding %s trailer for %s", trailerKey, generatedBy)
+	return git.AppendTrailer(message, trailerKey, generatedBy), nil
 }
 
-func commitWithMessage(message string) error {
-	logVerbose("Executing git commit command...")
-	cmd := exec.Command("git", "commit", "-m", message)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if err == nil {
-		fmt.Println("Successfully committed with message.")
+// amendCommitWithMessage replaces the last commit's message (and folds in any staged changes)
+func amendCommitWithMessage(message string) error {
+	messageFile, err := git.WriteMessageFile(message)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(messageFile)
+
+	logVerbose("Executing git commit --amend command...")
+	err = runGitCommit(commitArgs("--amend", "-F", messageFile))
+	if err == nil && !cfg.IsQuiet() {
+		fmt.Fprintln(uiOutput, "Successfully amended the last commit with message.")
 	}
 	return err
 }
 
+// editMessageHelp is appended to the message opened in the editor; like every other
+// line starting with "#", it is removed before committing
+const editMessageHelp = "\n\n# Edit the commit message above. Lines starting with '#' are ignored,\n# and an empty message or quitting the editor with an error aborts the commit.\n"
+
+// editMessage opens the message in the user's editor and returns what was saved,
+// without comment lines. An empty result, or an editor that exits with an error (such
+// as :cq in vim), means the user aborted.
 func editMessage(message string) (string, error) {
-	// Create a temporary file with the message
+	// Like git's COMMIT_EDITMSG, the file goes in the git directory, so it is on the
+	// repository's filesystem and editors can detect it as a commit message
 	logVerbose("Creating temporary file for message editing...")
-	tempFile, err := os.CreateTemp("", "commit-msg-*.txt")
+	dir, err := git.GetGitDir()
+	if err != nil {
+		dir = "" // Not in a repository, e.g. with --diff-file; use the system temp directory
+	}
+	tempFile, err := os.CreateTemp(dir, "AI_COMMIT_EDITMSG-*")
 	if err != nil {
 		return "", err
 	}
 	defer os.Remove(tempFile.Name())
 
-	if _, err := tempFile.WriteString(message); err != nil {
+	if _, err := tempFile.WriteString(message + editMessageHelp); err != nil {
+		tempFile.Close()
 		return "", err
 	}
 	tempFile.Close()
@@ -1561,6 +3897,11 @@ func editMessage(message string) (string, error) {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			logVerbose("Editor exited with status %d, treating it as an abort", exitErr.ExitCode())
+			return "", nil
+		}
 		return "", fmt.Errorf("error running editor '%s': %v", editor, err)
 	}
 
@@ -1571,5 +3912,5 @@ func editMessage(message string) (string, error) {
 		return "", err
 	}
 
-	return strings.TrimSpace(string(editedContent)), nil
+	return git.StripCommentLines(string(editedContent)), nil
 }
\ No newline at end of file
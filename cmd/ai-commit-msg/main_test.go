@@ -0,0 +1,666 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/nycjay/ai-commit-msg/pkg/ai"
+	"github.com/nycjay/ai-commit-msg/pkg/config"
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// TestAbortCommitExitCode tests that aborting a commit exits with a code distinct from success and errors
+func TestAbortCommitExitCode(t *testing.T) {
+	code := -1
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	abortCommit()
+
+	if code != exitAborted {
+		t.Errorf("Expected exit code %d, got %d", exitAborted, code)
+	}
+	if exitAborted == 0 || exitAborted == 1 {
+		t.Errorf("Abort exit code %d must differ from success (0) and errors (1)", exitAborted)
+	}
+}
+
+// TestCommitArgs tests the options passed through to git commit
+func TestCommitArgs(t *testing.T) {
+	cfg = config.GetInstance()
+	defer cfg.ParseCommandLineArgs(nil)
+
+	cfg.ParseCommandLineArgs([]string{"--no-verify", "--commit-arg", "--author=A <a@example.com>"})
+	got := strings.Join(commitArgs("-F", "msg.txt"), " ")
+	if expected := "commit --no-verify --author=A <a@example.com> -F msg.txt"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	cfg.ParseCommandLineArgs([]string{"-q"})
+	got = strings.Join(commitArgs("--amend", "-F", "msg.txt"), " ")
+	if expected := "commit --quiet --amend -F msg.txt"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+// TestSignArgs tests the signing options passed to git commit
+func TestSignArgs(t *testing.T) {
+	cfg = config.GetInstance()
+	defer cfg.ParseCommandLineArgs(nil)
+	defer cfg.SetSignCommits(false)
+	defer cfg.SetSigningKey("")
+
+	cfg.ParseCommandLineArgs([]string{"--gpg-key", "ABCD1234"})
+	if got := strings.Join(commitArgs("-F", "msg.txt"), " "); got != "commit -SABCD1234 -F msg.txt" {
+		t.Errorf("Expected the commit to be signed with the key, got %q", got)
+	}
+
+	cfg.ParseCommandLineArgs([]string{"--no-gpg-sign"})
+	if got := strings.Join(signArgs(), " "); got != "--no-gpg-sign" {
+		t.Errorf("Expected --no-gpg-sign to win over sign_commits, got %q", got)
+	}
+}
+
+// TestAttributeMessage tests the trailer naming the model that generated the message
+func TestAttributeMessage(t *testing.T) {
+	cfg = config.GetInstance()
+	defer func() { generatedBy = "" }()
+
+	generatedBy = "anthropic/claude-3-haiku-20240307"
+	message, err := attributeMessage("Add feature\n\n- Add the feature")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "Add feature\n\n- Add the feature\n\nAI-Generated-By: anthropic/claude-3-haiku-20240307"; message != expected {
+		t.Errorf("Expected %q, got %q", expected, message)
+	}
+
+	// A message no model generated is left alone
+	generatedBy = ""
+	if message, _ := attributeMessage("Add feature"); message != "Add feature" {
+		t.Errorf("Expected no trailer without a model, got %q", message)
+	}
+}
+
+// TestSanitizeMessage tests removing the fences, quotes and preambles models wrap messages in
+func TestSanitizeMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"plain", "Fix login redirect\n\n- Keep the return URL\n", "Fix login redirect\n\n- Keep the return URL"},
+		{"fenced", "```\nFix login redirect\n\n- Keep the return URL\n```", "Fix login redirect\n\n- Keep the return URL"},
+		{"fenced with language", "```text\nFix login redirect\n```\n", "Fix login redirect"},
+		{"fenced on one line", "```Fix login redirect```", "Fix login redirect"},
+		{"unclosed fence", "```\nFix login redirect", "Fix login redirect"},
+		{"double quoted", "\"Fix login redirect\"", "Fix login redirect"},
+		{"single quoted", "'Fix login redirect'", "Fix login redirect"},
+		{"inner quotes kept", "Handle \"quoted\" branch names", "Handle \"quoted\" branch names"},
+		{"preamble", "Commit message: Fix login redirect", "Fix login redirect"},
+		{"preamble on its own line", "commit message:\nFix login redirect", "Fix login redirect"},
+		{"preamble and fence", "Commit message:\n```\n\"Fix login redirect\"\n```", "Fix login redirect"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeMessage(tt.raw); got != tt.want {
+			t.Errorf("%s: sanitizeMessage(%q) = %q, want %q", tt.name, tt.raw, got, tt.want)
+		}
+	}
+}
+
+// TestLoadContextFile tests reading the project context and capping its size
+func TestLoadContextFile(t *testing.T) {
+	cfg = config.GetInstance()
+	defer cfg.SetContextFile("")
+
+	if content, err := loadContextFile(); err != nil || content != "" {
+		t.Errorf("Expected no context without a file, got %q, %v", content, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "CONTEXT.md")
+	os.WriteFile(path, []byte(strings.Repeat("x", maxContextFileBytes+100)), 0644)
+	cfg.SetContextFile(path)
+	if content, err := loadContextFile(); err != nil || len(content) != maxContextFileBytes {
+		t.Errorf("Expected the context truncated to %d bytes, got %d, %v", maxContextFileBytes, len(content), err)
+	}
+
+	cfg.SetContextFile(filepath.Join(t.TempDir(), "missing.md"))
+	if _, err := loadContextFile(); err == nil {
+		t.Error("Expected an error for a missing context file")
+	}
+}
+
+// TestEnforcePromptTokenLimitKeepsContext tests that reducing the context lines to fit
+// --max-prompt-tokens keeps the project context from --context-file
+func TestEnforcePromptTokenLimitKeepsContext(t *testing.T) {
+	cfg = config.GetInstance()
+	defer cfg.ParseCommandLineArgs(nil)
+	defer cfg.SetMaxPromptTokens(0)
+	defer cfg.SetContextFile("")
+
+	repo := t.TempDir()
+	if err := exec.Command("git", "init", "-q", repo).Run(); err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+	t.Chdir(repo)
+	os.WriteFile("main.go", []byte(strings.Repeat("// line\n", 50)), 0644)
+	if err := exec.Command("git", "add", "main.go").Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	contextFile := filepath.Join(t.TempDir(), "CONTEXT.md")
+	os.WriteFile(contextFile, []byte("A parser for commit messages."), 0644)
+
+	// Auto-commit reduces the context without asking
+	cfg.ParseCommandLineArgs([]string{"-a"})
+	cfg.SetContextFile(contextFile)
+	cfg.SetMaxPromptTokens(1)
+
+	diffInfo, err := getGitDiff("", "", cfg.GetContextLines())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	diffInfo.ProjectContext, _ = loadContextFile()
+
+	if reduced := enforcePromptTokenLimit(diffInfo); reduced.ProjectContext != "A parser for commit messages." {
+		t.Errorf("Expected the project context to survive the reduction, got %q", reduced.ProjectContext)
+	}
+}
+
+// TestEditMessage tests editing the message with a fake editor script
+func TestEditMessage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake editor is a shell script")
+	}
+	cfg = config.GetInstance()
+
+	repo := t.TempDir()
+	if err := exec.Command("git", "init", "-q", repo).Run(); err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+	t.Chdir(repo)
+
+	// The editor records where the file is, then runs the test's commands on it
+	editor := func(body string) {
+		script := filepath.Join(t.TempDir(), "editor.sh")
+		content := "#!/bin/sh\necho \"$1\" > " + filepath.Join(repo, "edited-path") + "\n" + body + "\n"
+		if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("EDITOR", script)
+	}
+
+	editor(`printf 'Fix the parser\n\n# a note to self\n- handle empty input\n' > "$1"`)
+	edited, err := editMessage("Generated message")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if edited != "Fix the parser\n\n- handle empty input" {
+		t.Errorf("Expected comment lines stripped, got %q", edited)
+	}
+	path, _ := os.ReadFile(filepath.Join(repo, "edited-path"))
+	if !strings.HasPrefix(strings.TrimSpace(string(path)), filepath.Join(repo, ".git")+string(filepath.Separator)) {
+		t.Errorf("Expected the file to be in the git directory, got %q", path)
+	}
+
+	// Saving the message unchanged keeps it, without the help text
+	editor(`true`)
+	if edited, err := editMessage("Generated message"); err != nil || edited != "Generated message" {
+		t.Errorf("Expected the unchanged message, got %q, %v", edited, err)
+	}
+
+	// Only comments left is an empty message, which aborts
+	editor(`printf '# nothing here\n' > "$1"`)
+	if edited, err := editMessage("Generated message"); err != nil || edited != "" {
+		t.Errorf("Expected an empty message, got %q, %v", edited, err)
+	}
+
+	// An editor exiting with an error aborts even though the file still has the message
+	editor(`exit 1`)
+	if edited, err := editMessage("Generated message"); err != nil || edited != "" {
+		t.Errorf("Expected an abort for a failing editor, got %q, %v", edited, err)
+	}
+}
+
+// TestCheckOnlyStaged tests that --only refuses paths with unstaged changes, which git
+// commit would include although the message doesn't describe them
+func TestCheckOnlyStaged(t *testing.T) {
+	repo := t.TempDir()
+	if err := exec.Command("git", "init", "-q", repo).Run(); err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+	t.Chdir(repo)
+
+	os.WriteFile("a.txt", []byte("staged\n"), 0644)
+	os.WriteFile("b.txt", []byte("staged\n"), 0644)
+	if err := exec.Command("git", "add", "a.txt", "b.txt").Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkOnlyStaged([]string{"a.txt"}); err != nil {
+		t.Errorf("Expected fully staged paths to pass, got %v", err)
+	}
+	if err := checkOnlyStaged(nil); err != nil {
+		t.Errorf("Expected no check without --only, got %v", err)
+	}
+
+	os.WriteFile("b.txt", []byte("staged\nthen edited\n"), 0644)
+	if err := checkOnlyStaged([]string{"a.txt"}); err != nil {
+		t.Errorf("Expected edits outside the pathspec to be ignored, got %v", err)
+	}
+	if err := checkOnlyStaged([]string{"b.txt"}); err == nil || !strings.Contains(err.Error(), "b.txt") {
+		t.Errorf("Expected an error naming b.txt, got %v", err)
+	}
+}
+
+// TestWrapSystemPrompt tests adding system_prompt_prefix and system_prompt_suffix
+func TestWrapSystemPrompt(t *testing.T) {
+	tests := []struct {
+		prefix, suffix string
+		want           string
+	}{
+		{"", "", "Write commit messages.\n"},
+		{"Always use the imperative mood.", "", "Always use the imperative mood.\n\nWrite commit messages.\n"},
+		{"", "  Never mention tests.\n", "Write commit messages.\n\nNever mention tests."},
+		{"Be brief.", "Never mention tests.", "Be brief.\n\nWrite commit messages.\n\nNever mention tests."},
+	}
+
+	for _, tt := range tests {
+		if got := wrapSystemPrompt("Write commit messages.\n", tt.prefix, tt.suffix); got != tt.want {
+			t.Errorf("wrapSystemPrompt(%q, %q) = %q, want %q", tt.prefix, tt.suffix, got, tt.want)
+		}
+	}
+}
+
+// TestReadPromptFileProviderSpecific tests that a prompt in the provider's subdirectory
+// of the prompt directory wins over the shared prompt and the default
+func TestReadPromptFileProviderSpecific(t *testing.T) {
+	cfg = config.GetInstance()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	oldExecutableDir := executableDir
+	executableDir = t.TempDir()
+	cfg.SetExecutableDir(executableDir)
+	defer func() {
+		executableDir = oldExecutableDir
+		cfg.SetExecutableDir(oldExecutableDir)
+	}()
+
+	promptDir, err := cfg.GetPromptDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(executableDir, "prompts"), 0755)
+	os.MkdirAll(filepath.Join(promptDir, "openai"), 0755)
+	os.WriteFile(filepath.Join(executableDir, "prompts", "system_prompt.txt"), []byte("default system"), 0644)
+	os.WriteFile(filepath.Join(promptDir, "system_prompt.txt"), []byte("shared system"), 0644)
+	os.WriteFile(filepath.Join(promptDir, "openai", "system_prompt.txt"), []byte("openai system"), 0644)
+
+	content, isCustom, source, err := readPromptFile("openai", "system_prompt.txt")
+	if err != nil || content != "openai system" || !isCustom {
+		t.Errorf("Expected the OpenAI system prompt, got %q (custom %v, %v)", content, isCustom, err)
+	}
+	if !strings.Contains(source, filepath.Join("openai", "system_prompt.txt")) {
+		t.Errorf("Expected the source to name the provider's file, got %q", source)
+	}
+
+	// Other providers use the shared prompt, and the default when there is none
+	if content, _, _, _ := readPromptFile("anthropic", "system_prompt.txt"); content != "shared system" {
+		t.Errorf("Expected the shared system prompt, got %q", content)
+	}
+	os.Remove(filepath.Join(promptDir, "system_prompt.txt"))
+	if content, isCustom, _, _ := readPromptFile("anthropic", "system_prompt.txt"); content != "default system" || isCustom {
+		t.Errorf("Expected the default system prompt, got %q (custom %v)", content, isCustom)
+	}
+}
+
+// TestCheckPromptsBundledOnly tests that check-prompts finds the prompts installed next to
+// the binary when there are no user prompts, so the executable directory must be known
+// before the subcommand runs
+func TestCheckPromptsBundledOnly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the binary")
+	}
+
+	installDir := t.TempDir()
+	binary := filepath.Join(installDir, "ai-commit-msg")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	if output, err := exec.Command("go", "build", "-o", binary, ".").CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build: %v\n%s", err, output)
+	}
+	os.MkdirAll(filepath.Join(installDir, "prompts"), 0755)
+	bundled, err := filepath.Glob(filepath.Join("..", "..", "prompts", "*.txt"))
+	if err != nil || len(bundled) == 0 {
+		t.Fatalf("Expected the bundled prompts, got %v (%v)", bundled, err)
+	}
+	for _, path := range bundled {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(installDir, "prompts", filepath.Base(path)), content, 0644)
+	}
+
+	cmd := exec.Command(binary, "check-prompts")
+	cmd.Dir = t.TempDir()
+	cmd.Env = append(os.Environ(), "HOME="+t.TempDir(), "XDG_CONFIG_HOME="+t.TempDir())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Errorf("Expected check-prompts to pass with the bundled prompts, got %v:\n%s", err, output)
+	}
+	if strings.Contains(string(output), "could not find prompt file") {
+		t.Errorf("Expected the bundled prompts to be found, got:\n%s", output)
+	}
+}
+
+// TestGenerateCommitMessageSharedTransport tests that the built-in Anthropic request
+// goes through the providers' shared transport
+func TestGenerateCommitMessageSharedTransport(t *testing.T) {
+	cfg = config.GetInstance()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	oldExecutableDir := executableDir
+	executableDir, _ = filepath.Abs(filepath.Join("..", ".."))
+	cfg.SetExecutableDir(executableDir)
+	defer func() {
+		executableDir = oldExecutableDir
+		cfg.SetExecutableDir(oldExecutableDir)
+	}()
+
+	var requested string
+	ai.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.Host
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"content": [{"type": "text", "text": "feat: Add client"}]}`)),
+		}, nil
+	}))
+	defer ai.SetTransport(nil)
+
+	model := ai.NewAnthropicProvider().GetDefaultModel()
+	message, err := generateCommitMessage(context.Background(), "sk-ant-test", model, git.GitDiff{Diff: "+change"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if message != "feat: Add client" || requested != "api.anthropic.com" {
+		t.Errorf("Expected the request to go through the shared transport, got %q to %q", message, requested)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestWithPromptsTypeTemplate tests that the user prompt template configured for the
+// likely change type is used, and the usual template when the type has none
+func TestWithPromptsTypeTemplate(t *testing.T) {
+	cfg = config.GetInstance()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	oldExecutableDir := executableDir
+	executableDir = t.TempDir()
+	cfg.SetExecutableDir(executableDir)
+	defer func() {
+		executableDir = oldExecutableDir
+		cfg.SetExecutableDir(oldExecutableDir)
+		cfg.SetTypeTemplates(nil)
+	}()
+
+	os.MkdirAll(filepath.Join(executableDir, "prompts"), 0755)
+	os.WriteFile(filepath.Join(executableDir, "prompts", "system_prompt.txt"), []byte("default system"), 0644)
+	os.WriteFile(filepath.Join(executableDir, "prompts", "user_prompt.txt"), []byte("default user"), 0644)
+	docsTemplate := filepath.Join(t.TempDir(), "docs.txt")
+	os.WriteFile(docsTemplate, []byte("docs user"), 0644)
+	cfg.SetTypeTemplates(map[string]string{"docs": docsTemplate})
+
+	for changeType, expected := range map[string]string{"docs": "docs user", "feat": "default user", "": "default user"} {
+		diffInfo, err := withPrompts(git.GitDiff{ChangeType: changeType})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if diffInfo.UserPrompt != expected {
+			t.Errorf("%q: expected the user prompt %q, got %q", changeType, expected, diffInfo.UserPrompt)
+		}
+	}
+
+	// A default template stands in for types without one of their own
+	otherTemplate := filepath.Join(t.TempDir(), "other.txt")
+	os.WriteFile(otherTemplate, []byte("other user"), 0644)
+	cfg.SetTypeTemplates(map[string]string{"docs": docsTemplate, "default": otherTemplate})
+	if diffInfo, _ := withPrompts(git.GitDiff{ChangeType: "feat"}); diffInfo.UserPrompt != "other user" {
+		t.Errorf("Expected the default template, got %q", diffInfo.UserPrompt)
+	}
+
+	// A template that can't be read is an error rather than silently skipped
+	cfg.SetTypeTemplates(map[string]string{"docs": filepath.Join(t.TempDir(), "missing.txt")})
+	if _, err := withPrompts(git.GitDiff{ChangeType: "docs"}); err == nil {
+		t.Error("Expected an error for a missing template")
+	}
+}
+
+// TestShowMessageOutputs tests that only the generated message goes to stdout, with
+// the banners and summary on stderr, so the output can be piped
+func TestShowMessageOutputs(t *testing.T) {
+	defer func(ui, result io.Writer) { uiOutput, resultOutput = ui, result }(uiOutput, resultOutput)
+	defer func() { commitNote = "" }()
+
+	var stdout, stderr strings.Builder
+	uiOutput, resultOutput = &stderr, &stdout
+
+	// A mocked generation: the message a provider would have returned, with its note
+	message := "feat(parser): Add streaming parser\n\n- Parse input incrementally"
+	commitNote = "Parsing incrementally keeps memory flat for large inputs."
+	showMessage(message, nil, false)
+
+	if stdout.String() != message+"\n" {
+		t.Errorf("Expected only the message on stdout, got %q", stdout.String())
+	}
+	for _, chrome := range []string{"Suggested commit message:", strings.Repeat("=", 50), "Summary: ", "Git note:\n" + commitNote} {
+		if !strings.Contains(stderr.String(), chrome) {
+			t.Errorf("Expected %q on stderr, got %q", chrome, stderr.String())
+		}
+	}
+	if strings.Contains(stderr.String(), "Parse input incrementally") {
+		t.Errorf("Expected the message not to be repeated on stderr, got %q", stderr.String())
+	}
+
+	// A streamed message is already on stdout, and candidates are choices on stderr
+	stdout.Reset()
+	showMessage(message, nil, true)
+	showMessage(message, []string{"feat: One", "feat: Two"}, false)
+	if stdout.Len() != 0 {
+		t.Errorf("Expected nothing more on stdout, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "[2] feat: Two") {
+		t.Errorf("Expected the candidates on stderr, got %q", stderr.String())
+	}
+}
+
+// TestInteractiveOutputOnStderr tests that prompts and status lines never reach stdout,
+// which is kept for the message so it can be piped
+func TestInteractiveOutputOnStderr(t *testing.T) {
+	cfg = config.GetInstance()
+	defer cfg.ParseCommandLineArgs(nil)
+	defer func(ui io.Writer) { uiOutput = ui }(uiOutput)
+	defer func(stdout, stdin *os.File) { os.Stdout, os.Stdin = stdout, stdin }(os.Stdout, os.Stdin)
+	exit = func(int) {}
+	defer func() { exit = os.Exit }()
+
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout, os.Stdin = stdoutWriter, stdinReader
+	var stderr strings.Builder
+	uiOutput = &stderr
+
+	// Pressing Enter keeps the current model
+	stdinWriter.WriteString("\n")
+	chooseModel("openai")
+
+	// A new key can't be read from a pipe, so this reports an error
+	stdinWriter.WriteString("y\n")
+	if reenterAPIKey("openai", errors.New("invalid key")) {
+		t.Error("Expected no new key to be read")
+	}
+
+	// Amending reports success, and git's summary of the commit, on stderr
+	repo := t.TempDir()
+	if err := exec.Command("git", "init", "-q", repo).Run(); err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+	t.Chdir(repo)
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "Test")
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "test@example.com")
+	}
+	os.WriteFile("a.txt", []byte("content\n"), 0644)
+	if err := exec.Command("git", "add", "a.txt").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := exec.Command("git", "commit", "-q", "--no-gpg-sign", "-m", "Initial commit").CombinedOutput(); err != nil {
+		t.Fatalf("Failed to commit: %v\n%s", err, output)
+	}
+	if err := amendCommitWithMessage("feat: Amended"); err != nil {
+		t.Fatalf("Failed to amend: %v", err)
+	}
+
+	cfg.ParseCommandLineArgs([]string{"--max-prompt-tokens", "1", "--diff-file", "-", "-a"})
+	enforcePromptTokenLimit(git.GitDiff{Diff: "+a line long enough to exceed the limit"})
+	exitIfInterrupted(context.Canceled)
+	abortCommit()
+
+	stdoutWriter.Close()
+	stdinWriter.Close()
+	stdout, err := io.ReadAll(stdoutReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stdout) != 0 {
+		t.Errorf("Expected nothing on stdout, got %q", stdout)
+	}
+	for _, line := range []string{"Available models for Openai:", "Select a model", "exceeds the limit", "Continuing with the provided diff.", "Cancelled", "Commit aborted.",
+		"Error reading API key", "Successfully amended the last commit", "feat: Amended"} {
+		if !strings.Contains(stderr.String(), line) {
+			t.Errorf("Expected %q on stderr, got %q", line, stderr.String())
+		}
+	}
+}
+
+// TestGetBranchInfoJiraID tests that the Jira ID is taken from the branch with the git
+// package's extraction, so every configured prefix is recognized and not just GTN and GTBUG
+func TestGetBranchInfoJiraID(t *testing.T) {
+	cfg = config.GetInstance()
+	defer cfg.ParseCommandLineArgs(nil)
+
+	testCases := []struct {
+		branch  string
+		jiraID  string
+		jiraIDs []string
+	}{
+		{branch: "TOOLS-789_update_config", jiraID: "TOOLS-789", jiraIDs: []string{"TOOLS-789"}},
+		{branch: "feature/TASK-101-improve-docs", jiraID: "TASK-101", jiraIDs: []string{"TASK-101"}},
+		{branch: "feature/GTBUG-123-some-feature", jiraID: "GTBUG-123", jiraIDs: []string{"GTBUG-123"}},
+		{branch: "fix/WEB-3-then-GTN-1", jiraID: "GTN-1", jiraIDs: []string{"GTN-1", "WEB-3"}},
+		{branch: "no-ticket-here", jiraID: ""},
+	}
+
+	for _, tc := range testCases {
+		cfg.ParseCommandLineArgs([]string{"--branch", tc.branch})
+		diffInfo := getBranchInfo(git.GitDiff{})
+		if diffInfo.JiraID != tc.jiraID {
+			t.Errorf("%s: expected Jira ID %q, got %q", tc.branch, tc.jiraID, diffInfo.JiraID)
+		}
+		if strings.Join(diffInfo.JiraIDs, ",") != strings.Join(tc.jiraIDs, ",") {
+			t.Errorf("%s: expected Jira IDs %v, got %v", tc.branch, tc.jiraIDs, diffInfo.JiraIDs)
+		}
+	}
+
+	// A Jira ID given with --jira is kept
+	cfg.ParseCommandLineArgs([]string{"--branch", "feature/TASK-101-improve-docs"})
+	if diffInfo := getBranchInfo(git.GitDiff{JiraID: "GTN-9"}); diffInfo.JiraID != "GTN-9" {
+		t.Errorf("Expected the given Jira ID to be kept, got %q", diffInfo.JiraID)
+	}
+}
+
+// TestNoJira tests that with --no-jira no Jira ID reaches the prompt, whether it would
+// come from the branch name or from --jira
+func TestNoJira(t *testing.T) {
+	cfg = config.GetInstance()
+	defer cfg.ParseCommandLineArgs(nil)
+	defer cfg.SetJiraEnabled(true)
+
+	diffFile := filepath.Join(t.TempDir(), "change.diff")
+	os.WriteFile(diffFile, []byte("diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-old\n+new\n"), 0644)
+	args := []string{"--diff-file", diffFile, "--branch", "feature/GTN-123-x", "--jira", "GTN-9", "-d", "Fix the parser"}
+
+	cfg.ParseCommandLineArgs(args)
+	diffInfo, err := getGitDiff(cfg.GetJiraID(), cfg.GetJiraDesc(), cfg.GetContextLines())
+	if err != nil || diffInfo.JiraID != "GTN-9" || diffInfo.NoJira {
+		t.Fatalf("Expected the Jira ID from --jira, got %q (%v)", diffInfo.JiraID, err)
+	}
+
+	cfg.ParseCommandLineArgs(append(args, "--no-jira"))
+	diffInfo, err = getGitDiff(cfg.GetJiraID(), cfg.GetJiraDesc(), cfg.GetContextLines())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diffInfo.JiraID != "" || len(diffInfo.JiraIDs) != 0 || diffInfo.JiraDescription != "" || len(diffInfo.JiraURLs) != 0 {
+		t.Errorf("Expected no Jira fields, got %q %v %q %v", diffInfo.JiraID, diffInfo.JiraIDs, diffInfo.JiraDescription, diffInfo.JiraURLs)
+	}
+
+	diffInfo.UserPrompt = "Jira ID: {{.JiraID}}\nJira Description: {{.JiraDescription}}\n\n{{.Diff}}"
+	prompt, err := ai.FormatUserPrompt(diffInfo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, leaked := range []string{"GTN-123", "GTN-9", "Fix the parser"} {
+		if strings.Contains(prompt, leaked) {
+			t.Errorf("Expected %q to stay out of the prompt, got %q", leaked, prompt)
+		}
+	}
+	if !diffInfo.NoJira || !strings.Contains(ai.FormatSystemPrompt(diffInfo), "doesn't use Jira") {
+		t.Error("Expected the system prompt to say the repository doesn't use Jira")
+	}
+}
+
+// TestJiraURLs tests that every Jira ID referenced by the branch is linked once a Jira
+// site is configured, and that no links are made without one
+func TestJiraURLs(t *testing.T) {
+	cfg = config.GetInstance()
+	defer cfg.SetJiraBaseURL("")
+
+	diffInfo := git.GitDiff{JiraID: "GTN-1", JiraIDs: []string{"GTN-1", "WEB-3"}}
+	if urls := jiraURLs(diffInfo); urls != nil {
+		t.Errorf("Expected no links without a Jira site, got %v", urls)
+	}
+
+	cfg.ParseCommandLineArgs([]string{"--jira-url", "https://company.atlassian.net/"})
+	expected := []string{"https://company.atlassian.net/browse/GTN-1", "https://company.atlassian.net/browse/WEB-3"}
+	if urls := jiraURLs(diffInfo); strings.Join(urls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, urls)
+	}
+	if urls := jiraURLs(git.GitDiff{JiraID: "GTN-9"}); len(urls) != 1 || urls[0] != "https://company.atlassian.net/browse/GTN-9" {
+		t.Errorf("Expected a link to the single Jira ID, got %v", urls)
+	}
+}
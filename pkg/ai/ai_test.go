@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/nycjay/ai-commit-msg/pkg/git"
 )
@@ -112,3 +113,19 @@ func TestResponseParsing(t *testing.T) {
 		t.Errorf("Expected text to be '%s', got '%s'", expectedText, response.Content[0].Text)
 	}
 }
+
+// TestSetRequestTimeout tests configuring the provider HTTP timeout
+func TestSetRequestTimeout(t *testing.T) {
+	defer SetRequestTimeout(DefaultRequestTimeout)
+
+	SetRequestTimeout(90 * time.Second)
+	if GetRequestTimeout() != 90*time.Second {
+		t.Errorf("Expected timeout to be 90s, got %s", GetRequestTimeout())
+	}
+
+	// Non-positive values restore the default
+	SetRequestTimeout(0)
+	if GetRequestTimeout() != DefaultRequestTimeout {
+		t.Errorf("Expected default timeout, got %s", GetRequestTimeout())
+	}
+}
@@ -2,12 +2,12 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/nycjay/ai-commit-msg/pkg/git"
 )
@@ -23,6 +23,7 @@ type AnthropicRequest struct {
 	MaxTokens int                `json:"max_tokens"`
 	System    string             `json:"system"`
 	Messages  []AnthropicMessage `json:"messages"`
+	Stream    bool               `json:"stream,omitempty"`
 }
 
 // AnthropicMessage represents a message in the Claude API request
@@ -36,6 +37,29 @@ type AnthropicResponse struct {
 	Content []struct {
 		Text string `json:"text"`
 	} `json:"content"`
+	Usage AnthropicUsage `json:"usage"`
+}
+
+// AnthropicUsage represents the token counts reported by the Claude API
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicStreamEvent represents a single server-sent event from the Claude streaming API
+type AnthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Message struct {
+		Usage AnthropicUsage `json:"usage"`
+	} `json:"message"` // Sent with message_start
+	Usage AnthropicUsage `json:"usage"` // Sent with message_delta
 }
 
 // NewAnthropicProvider creates a new Anthropic provider
@@ -44,111 +68,148 @@ func NewAnthropicProvider() *AnthropicProvider {
 }
 
 // GenerateCommitMessage generates a commit message using Claude AI
-func (p *AnthropicProvider) GenerateCommitMessage(apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
-	if apiKey == "" {
-		return "", fmt.Errorf("no API key found for Anthropic")
-	}
-
-	// Format prompts to be passed to provider
-	systemPrompt := diffInfo.SystemPrompt
-	userPrompt := ""
-	
-	// Check if we have enhanced context fields
-	if diffInfo.ProjectContext != "" || len(diffInfo.FileSummaries) > 0 || len(diffInfo.CommitHistory) > 0 || len(diffInfo.RelatedFiles) > 0 {
-		// Format enhanced context
-		fileSummaries := ""
-		for file, summary := range diffInfo.FileSummaries {
-			fileSummaries += fmt.Sprintf("- %s: %s\n", file, summary)
+func (p *AnthropicProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	result, err := p.GenerateCommitMessageResult(ctx, apiKey, modelName, diffInfo)
+	return result.Message, err
+}
+
+// GenerateCommitMessageResult generates a commit message using Claude AI and returns it
+// with the token usage and the rate limits from the response headers
+func (p *AnthropicProvider) GenerateCommitMessageResult(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (GenerationResult, error) {
+	resp, err := p.sendRequest(ctx, apiKey, modelName, diffInfo, false)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var response AnthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return GenerationResult{}, err
+	}
+
+	if len(response.Content) == 0 {
+		return GenerationResult{}, fmt.Errorf("empty response from API")
+	}
+
+	result := GenerationResult{
+		Message: response.Content[0].Text,
+		Usage: Usage{
+			Provider:     p.GetName(),
+			Model:        modelName,
+			InputTokens:  response.Usage.InputTokens,
+			OutputTokens: response.Usage.OutputTokens,
+		},
+		RateLimit: ParseRateLimit(resp.Header),
+	}
+	reportUsage(result.Usage)
+	return result, nil
+}
+
+// GenerateCommitMessageStream generates a commit message using the Claude streaming API,
+// writing each text delta to out as it arrives
+func (p *AnthropicProvider) GenerateCommitMessageStream(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error) {
+	resp, err := p.sendRequest(ctx, apiKey, modelName, diffInfo, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var message strings.Builder
+	usage := Usage{Provider: p.GetName(), Model: modelName}
+	err = readServerSentEvents(resp.Body, func(data string) error {
+		var event AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to parse stream event: %v", err)
 		}
-		
-		commitHistory := ""
-		for file, commits := range diffInfo.CommitHistory {
-			commitHistory += fmt.Sprintf("File: %s\n", file)
-			for _, commit := range commits {
-				commitHistory += fmt.Sprintf("  %s\n", commit)
+
+		switch event.Type {
+		case "message_start":
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Text != "" {
+				message.WriteString(event.Delta.Text)
+				fmt.Fprint(out, event.Delta.Text)
 			}
+		case "error":
+			return fmt.Errorf("API stream error: %s", event.Error.Message)
 		}
-		
-		relatedFiles := ""
-		for _, file := range diffInfo.RelatedFiles {
-			relatedFiles += fmt.Sprintf("- %s\n", file)
-		}
-		
-		// Use the enhanced format with additional context
-		userPrompt = fmt.Sprintf(
-			diffInfo.UserPrompt,
-			diffInfo.Branch,
-			strings.Join(diffInfo.StagedFiles, "\n"),
-			diffInfo.Diff,
-			diffInfo.JiraID,
-			diffInfo.JiraDescription,
-			diffInfo.ProjectContext,
-			fileSummaries,
-			commitHistory,
-			relatedFiles,
-		)
-	} else {
-		// Use the regular format without enhanced context
-		userPrompt = fmt.Sprintf(
-			diffInfo.UserPrompt,
-			diffInfo.Branch,
-			strings.Join(diffInfo.StagedFiles, "\n"),
-			diffInfo.Diff,
-			diffInfo.JiraID,
-			diffInfo.JiraDescription,
-		)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if message.Len() == 0 {
+		return "", fmt.Errorf("empty response from API")
+	}
+
+	reportUsage(usage)
+	return message.String(), nil
+}
+
+// sendRequest builds and sends a request to the Claude API, returning the response
+// only when the API reports success
+func (p *AnthropicProvider) sendRequest(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff, stream bool) (*http.Response, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("no API key found for Anthropic")
+	}
+
+	userPrompt, err := FormatUserPrompt(diffInfo)
+	if err != nil {
+		return nil, err
 	}
 
 	request := AnthropicRequest{
 		Model:     modelName,
 		MaxTokens: 1000,
-		System:    systemPrompt,
-		Messages: []AnthropicMessage{
-			{Role: "user", Content: userPrompt},
-		},
+		System:    FormatSystemPrompt(diffInfo),
+		Messages:  anthropicMessages(userPrompt, diffInfo.Turns),
+		Stream:    stream,
 	}
 
 	requestBody, err := json.Marshal(request)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", anthropicAPI, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", anthropicAPI, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-api-key", apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
-	// Use mock transport in tests, real client in production
-	client := &http.Client{Timeout: time.Second * 30}
-	if mockDoFunc != nil {
-		client.Transport = &MockTransport{}
-	}
+	client := httpClient(requestTimeoutFor(ctx))
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
+
+	rateLimit := ParseRateLimit(resp.Header)
+	reportRateLimit(p.GetName(), rateLimit)
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		errorMsg := string(bodyBytes)
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, errorMsg)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: errorMsg, RateLimit: rateLimit}
 	}
 
-	var response AnthropicResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", err
-	}
+	return resp, nil
+}
 
-	if len(response.Content) == 0 {
-		return "", fmt.Errorf("empty response from API")
+// anthropicMessages returns the user prompt followed by the turns of the conversation
+// so far, if the user asked for another message with feedback
+func anthropicMessages(userPrompt string, turns []git.Turn) []AnthropicMessage {
+	messages := []AnthropicMessage{{Role: "user", Content: userPrompt}}
+	for _, turn := range turns {
+		messages = append(messages, AnthropicMessage{Role: turn.Role, Content: turn.Content})
 	}
-
-	return response.Content[0].Text, nil
+	return messages
 }
 
 // ValidateAPIKey validates the Anthropic API key format
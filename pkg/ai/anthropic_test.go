@@ -2,6 +2,7 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -239,7 +240,7 @@ func TestAnthropicProvider_GenerateCommitMessage(t *testing.T) {
 			provider := NewAnthropicProvider()
 
 			// Call the method being tested
-			message, err := provider.GenerateCommitMessage(tc.apiKey, tc.modelName, tc.diff)
+			message, err := provider.GenerateCommitMessage(context.Background(), tc.apiKey, tc.modelName, tc.diff)
 
 			// Check error
 			if tc.expectedErrMsg != "" {
@@ -260,4 +261,151 @@ func TestAnthropicProvider_GenerateCommitMessage(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// TestAnthropicProvider_GenerateCommitMessageStream tests streaming a message from SSE events
+func TestAnthropicProvider_GenerateCommitMessageStream(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	sseBody := "event: message_start\n" +
+		"data: {\"type\": \"message_start\"}\n\n" +
+		"event: content_block_delta\n" +
+		"data: {\"type\": \"content_block_delta\", \"delta\": {\"type\": \"text_delta\", \"text\": \"fix(auth): \"}}\n\n" +
+		"event: content_block_delta\n" +
+		"data: {\"type\": \"content_block_delta\", \"delta\": {\"type\": \"text_delta\", \"text\": \"correct login flow\"}}\n\n" +
+		"event: message_stop\n" +
+		"data: {\"type\": \"message_stop\"}\n\n"
+
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody map[string]interface{}
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+
+		if reqBody["stream"] != true {
+			t.Errorf("Expected stream to be true in request body, got %v", reqBody["stream"])
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(sseBody)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"auth.go"},
+		Diff:         "diff --git a/auth.go b/auth.go\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s",
+	}
+
+	var out bytes.Buffer
+	provider := NewAnthropicProvider()
+	message, err := provider.GenerateCommitMessageStream(context.Background(), "sk-ant-test", "claude-3-haiku-20240307", diff, &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	expected := "fix(auth): correct login flow"
+	if message != expected {
+		t.Errorf("Expected message '%s', got '%s'", expected, message)
+	}
+	if out.String() != expected {
+		t.Errorf("Expected streamed output '%s', got '%s'", expected, out.String())
+	}
+}
+
+// TestAnthropicProvider_EnhancedContext tests that the enhanced context fields reach the API request
+func TestAnthropicProvider_EnhancedContext(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	enhancedDiff := git.EnhancedGitDiff{
+		GitDiff: git.GitDiff{
+			StagedFiles: []string{"auth.go"},
+			Diff:        "diff --git a/auth.go b/auth.go\n...",
+			Branch:      "feature/GTN-7-auth",
+			JiraID:      "GTN-7",
+		},
+		CommitHistory:  map[string][]string{"auth.go": {"abc123 Add login"}},
+		FileSummaries:  map[string]string{"auth.go": "Authentication handlers"},
+		RelatedFiles:   []string{"auth_test.go"},
+		ProjectContext: "Go web service",
+	}
+	diff := enhancedDiff.ToGitDiff()
+	diff.SystemPrompt = "Generate a commit message based on the provided diff."
+	diff.UserPrompt = "Branch: %s\nFiles: %s\nDiff: %s\nJira: %s %s\n" +
+		"Project: %s\nSummaries:\n%s\nHistory:\n%s\nRelated:\n%s"
+
+	var userPrompt string
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+		if len(reqBody.Messages) > 0 {
+			userPrompt = reqBody.Messages[0].Content
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"content": [{"type": "text", "text": "GTN-7: Fix login"}]}`)),
+		}, nil
+	}
+
+	provider := NewAnthropicProvider()
+	if _, err := provider.GenerateCommitMessage(context.Background(), "sk-ant-test", "claude-3-haiku-20240307", diff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	for _, expected := range []string{"Project: Go web service", "- auth.go: Authentication handlers", "abc123 Add login", "- auth_test.go"} {
+		if !strings.Contains(userPrompt, expected) {
+			t.Errorf("Expected user prompt to contain %q, got %q", expected, userPrompt)
+		}
+	}
+}
+
+// TestAnthropicProvider_FeedbackTurns tests that the rejected message and the user's
+// feedback follow the prompt as turns of the conversation
+func TestAnthropicProvider_FeedbackTurns(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	var messages []AnthropicMessage
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody AnthropicRequest
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+		messages = reqBody.Messages
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"content": [{"type": "text", "text": "fix(parser): handle empty input"}]}`)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"parser.go"},
+		Diff:         "diff --git a/parser.go b/parser.go\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s %s %s %s %s",
+	}
+	diff.Turns = FeedbackTurns(nil, "Update parser", "too vague")
+
+	provider := NewAnthropicProvider()
+	if _, err := provider.GenerateCommitMessage(context.Background(), "sk-ant-test", "claude-3-haiku-20240307", diff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	if len(messages) != 3 {
+		t.Fatalf("Expected the prompt, the message and the feedback, got %+v", messages)
+	}
+	if messages[0].Role != "user" || !strings.HasPrefix(messages[0].Content, "Here is the diff:") {
+		t.Errorf("Expected the user prompt first, got %+v", messages[0])
+	}
+	if messages[1] != (AnthropicMessage{Role: "assistant", Content: "Update parser"}) {
+		t.Errorf("Expected the rejected message second, got %+v", messages[1])
+	}
+	if messages[2].Role != "user" || !strings.HasSuffix(messages[2].Content, "Feedback: too vague") {
+		t.Errorf("Expected the feedback last, got %+v", messages[2])
+	}
+}
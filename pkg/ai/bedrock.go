@@ -0,0 +1,211 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+const (
+	// bedrockDefaultRegion is used when neither bedrock_region nor the AWS
+	// configuration names a region
+	bedrockDefaultRegion = "us-east-1"
+
+	// bedrockAnthropicVersion is the Messages API version Bedrock expects in the body
+	bedrockAnthropicVersion = "bedrock-2023-05-31"
+)
+
+// BedrockProvider implements the Provider interface for Claude models hosted on
+// AWS Bedrock. Requests are signed with SigV4 using the AWS SDK's default
+// credential chain (environment, shared config and SSO, or instance roles), so
+// no API key is needed.
+type BedrockProvider struct {
+	Region string // AWS region, e.g. us-east-1; empty for the region from the AWS configuration
+}
+
+// BedrockRequest represents the invoke-model body for a Claude model on Bedrock
+type BedrockRequest struct {
+	AnthropicVersion string             `json:"anthropic_version"`
+	MaxTokens        int                `json:"max_tokens"`
+	System           string             `json:"system"`
+	Messages         []AnthropicMessage `json:"messages"`
+}
+
+// bedrockRegion holds the configured region for new Bedrock providers
+var bedrockRegion string
+
+// SetBedrockRegion sets the region used by Bedrock providers, from bedrock_region
+func SetBedrockRegion(region string) {
+	bedrockRegion = strings.TrimSpace(region)
+}
+
+// NewBedrockProvider creates a new Bedrock provider with the configured region
+func NewBedrockProvider() *BedrockProvider {
+	return &BedrockProvider{Region: bedrockRegion}
+}
+
+// GenerateCommitMessage generates a commit message using Claude on Bedrock
+func (p *BedrockProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	result, err := p.GenerateCommitMessageResult(ctx, apiKey, modelName, diffInfo)
+	return result.Message, err
+}
+
+// GenerateCommitMessageResult generates a commit message using Claude on Bedrock and
+// returns it with the token usage
+func (p *BedrockProvider) GenerateCommitMessageResult(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (GenerationResult, error) {
+	userPrompt, err := FormatUserPrompt(diffInfo)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	requestBody, err := json.Marshal(BedrockRequest{
+		AnthropicVersion: bedrockAnthropicVersion,
+		MaxTokens:        1000,
+		System:           FormatSystemPrompt(diffInfo),
+		Messages:         anthropicMessages(userPrompt, diffInfo.Turns),
+	})
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeoutFor(ctx))
+	defer cancel()
+
+	awsCfg, err := p.loadAWSConfig(ctx)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", bedrockEndpoint(awsCfg.Region, modelName), bytes.NewReader(requestBody))
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	if err := signBedrockRequest(ctx, awsCfg, req, requestBody); err != nil {
+		return GenerationResult{}, err
+	}
+
+	client := httpClient(requestTimeoutFor(ctx))
+	resp, err := client.Do(req)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return GenerationResult{}, &APIError{StatusCode: resp.StatusCode, Message: string(bodyBytes)}
+	}
+
+	var response AnthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return GenerationResult{}, err
+	}
+
+	if len(response.Content) == 0 {
+		return GenerationResult{}, fmt.Errorf("empty response from API")
+	}
+
+	result := GenerationResult{
+		Message: response.Content[0].Text,
+		Usage: Usage{
+			Provider:     p.GetName(),
+			Model:        modelName,
+			InputTokens:  response.Usage.InputTokens,
+			OutputTokens: response.Usage.OutputTokens,
+		},
+	}
+	reportUsage(result.Usage)
+	return result, nil
+}
+
+// GenerateCommitMessageStream generates a commit message using Claude on Bedrock.
+// Bedrock streams in AWS's event stream encoding, which isn't supported yet, so the
+// message is written to out once the blocking call completes.
+func (p *BedrockProvider) GenerateCommitMessageStream(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error) {
+	return generateBlockingStream(ctx, p, apiKey, modelName, diffInfo, out)
+}
+
+// loadAWSConfig loads the AWS configuration, using the provider's region if set
+func (p *BedrockProvider) loadAWSConfig(ctx context.Context) (aws.Config, error) {
+	var options []func(*awsconfig.LoadOptions) error
+	if p.Region != "" {
+		options = append(options, awsconfig.WithRegion(p.Region))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if awsCfg.Region == "" {
+		awsCfg.Region = bedrockDefaultRegion
+	}
+	if awsCfg.Credentials == nil {
+		return aws.Config{}, fmt.Errorf("no AWS credentials found for Bedrock")
+	}
+	return awsCfg, nil
+}
+
+// bedrockEndpoint returns the invoke-model URL for the model. The colon in model IDs
+// such as anthropic.claude-3-haiku-20240307-v1:0 must be escaped in the path.
+func bedrockEndpoint(region, modelName string) string {
+	modelID := strings.ReplaceAll(url.PathEscape(modelName), ":", "%3A")
+	return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/invoke", region, modelID)
+}
+
+// signBedrockRequest signs the request with SigV4 using credentials from the AWS chain
+func signBedrockRequest(ctx context.Context, awsCfg aws.Config, req *http.Request, body []byte) error {
+	credentials, err := awsCfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("no AWS credentials found for Bedrock: %w", err)
+	}
+
+	hash := sha256.Sum256(body)
+	signer := v4.NewSigner()
+	if err := signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "bedrock", awsCfg.Region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign Bedrock request: %w", err)
+	}
+	return nil
+}
+
+// ValidateAPIKey accepts any key, including none, since Bedrock uses AWS credentials
+func (p *BedrockProvider) ValidateAPIKey(key string) bool {
+	return true
+}
+
+// GetName returns the provider name
+func (p *BedrockProvider) GetName() string {
+	return string(ProviderBedrock)
+}
+
+// GetDefaultModel returns the default model name
+func (p *BedrockProvider) GetDefaultModel() string {
+	return "anthropic.claude-3-haiku-20240307-v1:0"
+}
+
+// GetAvailableModels returns the Bedrock model IDs of the Claude models
+func (p *BedrockProvider) GetAvailableModels() []string {
+	return []string{
+		"anthropic.claude-3-5-sonnet-20241022-v2:0",
+		"anthropic.claude-3-5-sonnet-20240620-v1:0",
+		"anthropic.claude-3-5-haiku-20241022-v1:0",
+		"anthropic.claude-3-opus-20240229-v1:0",
+		"anthropic.claude-3-sonnet-20240229-v1:0",
+		"anthropic.claude-3-haiku-20240307-v1:0",
+	}
+}
@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// setAWSTestCredentials points the AWS credential chain at static test credentials
+func setAWSTestCredentials(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+// TestBedrockProvider_GenerateCommitMessage tests the signed invoke-model request and
+// parsing of the Claude response
+func TestBedrockProvider_GenerateCommitMessage(t *testing.T) {
+	setAWSTestCredentials(t)
+	SetBedrockRegion("eu-west-1")
+	defer SetBedrockRegion("")
+
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		if got, want := req.URL.String(), "https://bedrock-runtime.eu-west-1.amazonaws.com/model/anthropic.claude-3-haiku-20240307-v1%3A0/invoke"; got != want {
+			t.Errorf("Expected URL %s, got %s", want, got)
+		}
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDTEST/") || !strings.Contains(auth, "/eu-west-1/bedrock/aws4_request") {
+			t.Errorf("Expected a SigV4 signature for bedrock in eu-west-1, got %q", auth)
+		}
+
+		var request BedrockRequest
+		body, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Fatalf("Failed to parse request body: %v", err)
+		}
+		if request.AnthropicVersion != bedrockAnthropicVersion || request.System != "system" || len(request.Messages) != 1 {
+			t.Errorf("Unexpected request body: %s", body)
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"content": [{"text": "fix: Handle empty input"}], "usage": {"input_tokens": 50, "output_tokens": 5}}`)),
+		}, nil
+	}
+	defer func() { mockDoFunc = nil }()
+
+	provider, err := NewProvider("bedrock")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if !provider.ValidateAPIKey("") {
+		t.Errorf("Expected no API key to be needed")
+	}
+
+	diffInfo := git.GitDiff{SystemPrompt: "system", UserPrompt: "{{.Diff}}", Diff: "diff"}
+	result, err := Generate(context.Background(), provider, "", "anthropic.claude-3-haiku-20240307-v1:0", diffInfo)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if result.Message != "fix: Handle empty input" || result.Usage.InputTokens != 50 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+// TestBedrockProvider_APIError tests that errors from Bedrock are returned as APIErrors
+func TestBedrockProvider_APIError(t *testing.T) {
+	setAWSTestCredentials(t)
+
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		if !strings.Contains(req.URL.Host, "us-east-1") {
+			t.Errorf("Expected the default region, got %s", req.URL.Host)
+		}
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"message": "Too many requests"}`)),
+		}, nil
+	}
+	defer func() { mockDoFunc = nil }()
+
+	_, err := NewBedrockProvider().GenerateCommitMessage(context.Background(), "", "anthropic.claude-3-haiku-20240307-v1:0", git.GitDiff{UserPrompt: "{{.Diff}}"})
+	if err == nil || !IsRetryable(err) {
+		t.Errorf("Expected a retryable API error, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// maxConcurrentCandidates bounds the number of requests made at the same time
+const maxConcurrentCandidates = 4
+
+// GenerateCandidates asks the provider for n commit messages concurrently and
+// returns the distinct ones in request order. Failed requests are skipped; an
+// error is only returned if every request fails. Each request gets its own
+// candidate timeout, or the request timeout when none is set, starting when the
+// request does, so candidates queued behind others aren't cut short. The whole
+// set is bounded by the candidates deadline; requests still queued when it passes
+// are skipped.
+func GenerateCandidates(ctx context.Context, provider Provider, apiKey string, modelName string, diffInfo git.GitDiff, n int) ([]string, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of candidates must be at least 1, got %d", n)
+	}
+
+	timeout := candidateTimeout
+	if timeout <= 0 {
+		timeout = requestTimeout
+	}
+	deadline := candidatesDeadline
+	if deadline <= 0 {
+		// Allow one timeout for each batch of concurrent requests
+		batches := (n + maxConcurrentCandidates - 1) / maxConcurrentCandidates
+		deadline = time.Duration(batches) * timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	messages := make([]string, n)
+	errs := make([]error, n)
+
+	var group errgroup.Group
+	group.SetLimit(maxConcurrentCandidates)
+	for i := 0; i < n; i++ {
+		i := i
+		group.Go(func() error {
+			// Don't start a queued request once the deadline has passed
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return nil
+			}
+
+			requestCtx, cancel := context.WithTimeout(withRequestTimeout(ctx, timeout), timeout)
+			defer cancel()
+
+			// Record failures per request so one bad response doesn't discard the rest
+			messages[i], errs[i] = provider.GenerateCommitMessage(requestCtx, apiKey, modelName, diffInfo)
+			return nil
+		})
+	}
+	group.Wait()
+
+	var candidates []string
+	seen := make(map[string]bool)
+	var firstErr error
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+
+		message := strings.TrimSpace(messages[i])
+		if message != "" && !seen[message] {
+			seen[message] = true
+			candidates = append(candidates, message)
+		}
+	}
+
+	if len(candidates) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, fmt.Errorf("empty response from API")
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,248 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// fakeProvider returns a scripted response for each call to GenerateCommitMessage
+type fakeProvider struct {
+	mu        sync.Mutex
+	calls     int
+	responses []string
+	errs      []error
+}
+
+func (f *fakeProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := f.calls % len(f.responses)
+	f.calls++
+	return f.responses[i], f.errs[i]
+}
+
+func (f *fakeProvider) GenerateCommitMessageStream(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error) {
+	return generateBlockingStream(ctx, f, apiKey, modelName, diffInfo, out)
+}
+
+func (f *fakeProvider) ValidateAPIKey(key string) bool { return true }
+func (f *fakeProvider) GetName() string                { return "fake" }
+func (f *fakeProvider) GetDefaultModel() string        { return "fake-model" }
+func (f *fakeProvider) GetAvailableModels() []string   { return []string{"fake-model"} }
+
+func TestGenerateCandidates(t *testing.T) {
+	apiErr := fmt.Errorf("API error")
+
+	testCases := []struct {
+		name        string
+		responses   []string
+		errs        []error
+		n           int
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:      "distinct messages",
+			responses: []string{"feat: One", "feat: Two", "feat: Three"},
+			errs:      []error{nil, nil, nil},
+			n:         3,
+			expected:  []string{"feat: One", "feat: Two", "feat: Three"},
+		},
+		{
+			name:      "duplicates removed",
+			responses: []string{"feat: Same", " feat: Same\n"},
+			errs:      []error{nil, nil},
+			n:         4,
+			expected:  []string{"feat: Same"},
+		},
+		{
+			name:      "partial failure",
+			responses: []string{"", "fix: Works"},
+			errs:      []error{apiErr, nil},
+			n:         2,
+			expected:  []string{"fix: Works"},
+		},
+		{
+			name:        "all requests fail",
+			responses:   []string{""},
+			errs:        []error{apiErr},
+			n:           3,
+			expectError: true,
+		},
+		{
+			name:        "invalid count",
+			responses:   []string{"feat: One"},
+			errs:        []error{nil},
+			n:           0,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &fakeProvider{responses: tc.responses, errs: tc.errs}
+
+			candidates, err := GenerateCandidates(context.Background(), provider, "key", "model", git.GitDiff{}, tc.n)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, got candidates %v", candidates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateCandidates returned error: %v", err)
+			}
+
+			if provider.calls != tc.n {
+				t.Errorf("Expected %d provider calls, got %d", tc.n, provider.calls)
+			}
+			// Calls run concurrently, so responses may arrive in any order
+			sort.Strings(candidates)
+			sort.Strings(tc.expected)
+			if fmt.Sprint(candidates) != fmt.Sprint(tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, candidates)
+			}
+		})
+	}
+}
+
+// hangingProvider never answers its first request, until the request is cancelled
+type hangingProvider struct {
+	fakeProvider
+}
+
+func (h *hangingProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	h.mu.Lock()
+	i := h.calls
+	h.calls++
+	h.mu.Unlock()
+	if i == 0 {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return h.responses[i%len(h.responses)], h.errs[i%len(h.errs)]
+}
+
+// A request that never answers only costs its own candidate
+func TestGenerateCandidatesTimeout(t *testing.T) {
+	SetCandidateTimeout(50 * time.Millisecond)
+	defer SetCandidateTimeout(0)
+
+	provider := &hangingProvider{fakeProvider{responses: []string{"feat: Slow", "feat: Fast"}, errs: []error{nil, nil}}}
+	start := time.Now()
+	candidates, err := GenerateCandidates(context.Background(), provider, "key", "model", git.GitDiff{}, 2)
+	if err != nil {
+		t.Fatalf("GenerateCandidates returned error: %v", err)
+	}
+	if fmt.Sprint(candidates) != "[feat: Fast]" {
+		t.Errorf("Expected only the fast candidate, got %v", candidates)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the slow request to be abandoned, took %s", elapsed)
+	}
+
+	// The overall deadline applies even without a candidate timeout
+	SetCandidateTimeout(0)
+	provider = &hangingProvider{fakeProvider{responses: []string{"feat: Slow"}, errs: []error{nil}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := GenerateCandidates(ctx, provider, "key", "model", git.GitDiff{}, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+}
+
+// slowProvider answers each request after a delay, unless the request is cancelled first
+type slowProvider struct {
+	fakeProvider
+	delay time.Duration
+}
+
+func (s *slowProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if timeout := requestTimeoutFor(ctx); timeout != candidateTimeout && candidateTimeout > 0 {
+		return "", fmt.Errorf("expected a request timeout of %s, got %s", candidateTimeout, timeout)
+	}
+	return s.fakeProvider.GenerateCommitMessage(ctx, apiKey, modelName, diffInfo)
+}
+
+// Candidates queued behind the first batch get their own full timeout
+func TestGenerateCandidatesTimeoutPerCandidate(t *testing.T) {
+	defer SetRequestTimeout(0)
+	defer SetCandidateTimeout(0)
+
+	n := 3 * maxConcurrentCandidates
+	responses := make([]string, n)
+	errs := make([]error, n)
+	for i := range responses {
+		responses[i] = fmt.Sprintf("feat: Candidate %d", i)
+	}
+
+	// Without a candidate timeout each candidate gets the request timeout
+	SetRequestTimeout(150 * time.Millisecond)
+	provider := &slowProvider{fakeProvider{responses: responses, errs: errs}, 100 * time.Millisecond}
+	candidates, err := GenerateCandidates(context.Background(), provider, "key", "model", git.GitDiff{}, n)
+	if err != nil {
+		t.Fatalf("GenerateCandidates returned error: %v", err)
+	}
+	if len(candidates) != n {
+		t.Errorf("Expected %d candidates, got %d: %v", n, len(candidates), candidates)
+	}
+
+	// A candidate timeout longer than the request timeout replaces it
+	SetRequestTimeout(10 * time.Millisecond)
+	SetCandidateTimeout(150 * time.Millisecond)
+	provider = &slowProvider{fakeProvider{responses: responses, errs: errs}, 100 * time.Millisecond}
+	candidates, err = GenerateCandidates(context.Background(), provider, "key", "model", git.GitDiff{}, n)
+	if err != nil {
+		t.Fatalf("GenerateCandidates returned error: %v", err)
+	}
+	if len(candidates) != n {
+		t.Errorf("Expected %d candidates, got %d: %v", n, len(candidates), candidates)
+	}
+}
+
+// blockingProvider never answers, counting the requests it was sent
+type blockingProvider struct {
+	fakeProvider
+}
+
+func (b *blockingProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	b.mu.Lock()
+	b.calls++
+	b.mu.Unlock()
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+// Candidates still queued when the deadline for the whole set passes are never sent
+func TestGenerateCandidatesDeadline(t *testing.T) {
+	SetCandidateTimeout(time.Minute)
+	SetCandidatesDeadline(50 * time.Millisecond)
+	defer SetCandidateTimeout(0)
+	defer SetCandidatesDeadline(0)
+
+	provider := &blockingProvider{}
+	start := time.Now()
+	_, err := GenerateCandidates(context.Background(), provider, "key", "model", git.GitDiff{}, 2*maxConcurrentCandidates)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if provider.calls != maxConcurrentCandidates {
+		t.Errorf("Expected only the first %d requests to be sent, got %d", maxConcurrentCandidates, provider.calls)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the set to be cancelled at the deadline, took %s", elapsed)
+	}
+}
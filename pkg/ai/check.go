@@ -0,0 +1,45 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// checkSystemPrompt and checkUserPrompt make up the tiny request sent by CheckProvider.
+// The user prompt is a named template so no diff fields are filled in around it.
+const (
+	checkSystemPrompt = "You are checking that this connection works. Reply with exactly: OK"
+	checkUserPrompt   = "Reply with OK.{{.Diff}}"
+)
+
+// CheckResult describes a successful CheckProvider request
+type CheckResult struct {
+	Reply   string        // What the model answered, normally "OK"
+	Model   string        // Model that answered, as reported by the provider if it did
+	Latency time.Duration // Time the request took
+}
+
+// CheckProvider sends a prompt asking for "OK" to the provider to confirm that the key,
+// the model and the network all work, without needing any staged changes
+func CheckProvider(ctx context.Context, provider Provider, apiKey string, modelName string) (CheckResult, error) {
+	diffInfo := git.GitDiff{
+		SystemPrompt: checkSystemPrompt,
+		UserPrompt:   checkUserPrompt,
+	}
+
+	start := time.Now()
+	result, err := Generate(ctx, provider, apiKey, modelName, diffInfo)
+	latency := time.Since(start)
+	if err != nil {
+		return CheckResult{Latency: latency}, err
+	}
+
+	model := result.Usage.Model
+	if model == "" {
+		model = modelName
+	}
+	return CheckResult{Reply: strings.TrimSpace(result.Message), Model: model, Latency: latency}, nil
+}
@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+func TestCheckProvider(t *testing.T) {
+	provider := &fakeProvider{responses: []string{" OK\n"}, errs: []error{nil}}
+	result, err := CheckProvider(context.Background(), provider, "key", "fake-model")
+	if err != nil {
+		t.Fatalf("CheckProvider returned error: %v", err)
+	}
+	if result.Reply != "OK" || result.Model != "fake-model" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	provider = &fakeProvider{responses: []string{""}, errs: []error{&APIError{StatusCode: 401}}}
+	if _, err := CheckProvider(context.Background(), provider, "bad", "fake-model"); !IsAuthError(err) {
+		t.Errorf("Expected the provider's error, got %v", err)
+	}
+}
+
+func TestCheckPrompt(t *testing.T) {
+	// The check prompt is sent as is, without any empty diff sections around it
+	prompt, err := FormatUserPrompt(git.GitDiff{SystemPrompt: checkSystemPrompt, UserPrompt: checkUserPrompt})
+	if err != nil {
+		t.Fatalf("FormatUserPrompt returned error: %v", err)
+	}
+	if prompt != "Reply with OK." {
+		t.Errorf("Unexpected check prompt %q", prompt)
+	}
+}
+
+func TestDescribeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unauthorized", &APIError{StatusCode: 401}, "the API key was rejected"},
+		{"model not found", &APIError{StatusCode: 404}, "the model was not found"},
+		{"rate limit", &APIError{StatusCode: 429}, "the provider is rate limiting requests"},
+		{"overloaded", &APIError{StatusCode: 529}, "the provider is unavailable"},
+		{"deadline", context.DeadlineExceeded, "the request timed out"},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, "the provider could not be reached"},
+		{"bad request", &APIError{StatusCode: 400}, ""},
+		{"other", errors.New("empty response from API"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DescribeError(tt.err); got != tt.want {
+				t.Errorf("DescribeError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"net/http"
+	"time"
+)
+
+// maxIdleConnsPerHost keeps enough idle connections for parallel candidate requests
+const maxIdleConnsPerHost = 8
+
+// defaultTransport is shared by all provider requests so connections to the same
+// API are kept alive and reused. It honors the standard proxy environment variables.
+var defaultTransport http.RoundTripper = newDefaultTransport()
+
+// transport is the RoundTripper used for provider requests
+var transport = defaultTransport
+
+// newDefaultTransport returns a copy of http.DefaultTransport tuned for provider requests
+func newDefaultTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	return t
+}
+
+// SetTransport replaces the RoundTripper used for provider requests.
+// A nil transport restores the shared default.
+func SetTransport(rt http.RoundTripper) {
+	if rt == nil {
+		rt = defaultTransport
+	}
+	transport = rt
+}
+
+// httpClient returns a client for provider requests with the given timeout. Tests
+// that set mockDoFunc get the mock transport instead of the shared one.
+func httpClient(timeout time.Duration) *http.Client {
+	rt := transport
+	if mockDoFunc != nil {
+		rt = &MockTransport{}
+	}
+	if debugf != nil {
+		rt = &debugTransport{next: rt}
+	}
+	return &http.Client{Timeout: timeout, Transport: rt}
+}
+
+// HTTPClient returns a client with the given timeout for requests made outside the
+// providers, sharing their transport, test mocks and debug logging
+func HTTPClient(timeout time.Duration) *http.Client {
+	return httpClient(timeout)
+}
+
+// Logger receives debug messages about provider requests
+type Logger func(format string, args ...interface{})
+
+// debugf logs each request and response when set
+var debugf Logger
+
+// SetDebugLogger sets the logger that receives the URL, headers and status of every
+// provider request, with keys redacted. A nil logger turns the logging off.
+func SetDebugLogger(logger Logger) {
+	debugf = logger
+}
+
+// debugTransport logs requests and responses, with keys redacted, before passing
+// them on
+type debugTransport struct {
+	next http.RoundTripper
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	debugf("===== HTTP REQUEST =====")
+	debugf("%s %s", req.Method, Redact(req.URL.String()))
+	for _, line := range RedactHeader(req.Header) {
+		debugf("  %s", line)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		debugf("Request failed: %s", Redact(err.Error()))
+		return resp, err
+	}
+
+	debugf("===== HTTP RESPONSE =====")
+	debugf("Status: %s", resp.Status)
+	for _, line := range RedactHeader(resp.Header) {
+		debugf("  %s", line)
+	}
+	return resp, nil
+}
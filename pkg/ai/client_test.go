@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestHTTPClient tests that provider clients share the configured transport
+func TestHTTPClient(t *testing.T) {
+	client := httpClient(5 * time.Second)
+	if client.Timeout != 5*time.Second {
+		t.Errorf("Expected a 5s timeout, got %v", client.Timeout)
+	}
+	if client.Transport != defaultTransport || httpClient(time.Second).Transport != client.Transport {
+		t.Errorf("Expected every client to use the shared default transport")
+	}
+
+	// The mock transport takes over while a test sets mockDoFunc
+	mockDoFunc = func(req *http.Request) (*http.Response, error) { return nil, nil }
+	if _, ok := httpClient(time.Second).Transport.(*MockTransport); !ok {
+		t.Errorf("Expected the mock transport when mockDoFunc is set")
+	}
+	mockDoFunc = nil
+}
+
+// TestSetTransport tests swapping the transport used by the providers
+func TestSetTransport(t *testing.T) {
+	defer SetTransport(nil)
+
+	var requested string
+	SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.Host
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"choices": [{"message": {"content": "feat: add client"}}]}`)),
+		}, nil
+	}))
+
+	diff := git.GitDiff{SystemPrompt: "Generate a commit message.", UserPrompt: "Here is the diff: %s"}
+	message, err := NewOpenAIProvider().GenerateCommitMessage(context.Background(), "sk-test", "gpt-4o", diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if message != "feat: add client" || requested != "api.openai.com" {
+		t.Errorf("Expected the request to go through the swapped transport, got %q to %q", message, requested)
+	}
+
+	SetTransport(nil)
+	if httpClient(time.Second).Transport != defaultTransport {
+		t.Errorf("Expected SetTransport(nil) to restore the default transport")
+	}
+}
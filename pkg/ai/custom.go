@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// chatCompletionsPath is appended to the custom base URL to reach the chat completions endpoint
+const chatCompletionsPath = "/chat/completions"
+
+// CustomProvider implements the Provider interface for self-hosted servers with an
+// OpenAI-compatible chat completions API, such as vLLM, LM Studio or llama.cpp
+type CustomProvider struct {
+	BaseURL       string // Base URL of the API, e.g. http://localhost:8000/v1
+	Model         string // Model used when none is configured for the provider
+	RequireAPIKey bool   // Whether the server needs an API key
+}
+
+// customProviderSettings holds the configured settings for new custom providers
+var customProviderSettings CustomProvider
+
+// SetCustomProviderSettings sets the base URL, default model and API key
+// requirement used by custom providers, from the custom_* config options
+func SetCustomProviderSettings(baseURL string, model string, requireAPIKey bool) {
+	customProviderSettings = CustomProvider{
+		BaseURL:       strings.TrimSpace(baseURL),
+		Model:         strings.TrimSpace(model),
+		RequireAPIKey: requireAPIKey,
+	}
+}
+
+// NewCustomProvider creates a new custom provider with the configured settings
+func NewCustomProvider() *CustomProvider {
+	provider := customProviderSettings
+	return &provider
+}
+
+// endpoint returns the chat completions URL for the configured base URL
+func (p *CustomProvider) endpoint() (string, error) {
+	if p.BaseURL == "" {
+		return "", fmt.Errorf("no base URL configured for the custom provider (set custom_base_url)")
+	}
+
+	baseURL := strings.TrimRight(p.BaseURL, "/")
+	if strings.HasSuffix(baseURL, chatCompletionsPath) {
+		return baseURL, nil
+	}
+	return baseURL + chatCompletionsPath, nil
+}
+
+// checkRequest returns the endpoint to use, or an error if the provider isn't configured
+func (p *CustomProvider) checkRequest(apiKey string) (string, error) {
+	if !p.ValidateAPIKey(apiKey) {
+		return "", fmt.Errorf("no API key found for the custom provider")
+	}
+	return p.endpoint()
+}
+
+// GenerateCommitMessage generates a commit message using the custom server
+func (p *CustomProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	result, err := p.GenerateCommitMessageResult(ctx, apiKey, modelName, diffInfo)
+	return result.Message, err
+}
+
+// GenerateCommitMessageResult generates a commit message using the custom server and
+// returns it with the token usage and any rate limits the server reports
+func (p *CustomProvider) GenerateCommitMessageResult(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (GenerationResult, error) {
+	endpoint, err := p.checkRequest(apiKey)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	return generateChatCompletion(ctx, p.GetName(), endpoint, apiKey, modelName, diffInfo)
+}
+
+// GenerateCommitMessageStream generates a commit message using the custom server's
+// SSE streaming, writing each content delta to out as it arrives
+func (p *CustomProvider) GenerateCommitMessageStream(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error) {
+	endpoint, err := p.checkRequest(apiKey)
+	if err != nil {
+		return "", err
+	}
+	return streamChatCompletion(ctx, p.GetName(), endpoint, apiKey, modelName, diffInfo, out)
+}
+
+// ValidateAPIKey accepts any non-empty key, or no key if the server doesn't require one
+func (p *CustomProvider) ValidateAPIKey(key string) bool {
+	return key != "" || !p.RequireAPIKey
+}
+
+// GetName returns the provider name
+func (p *CustomProvider) GetName() string {
+	return string(ProviderCustom)
+}
+
+// GetDefaultModel returns the configured model name
+func (p *CustomProvider) GetDefaultModel() string {
+	return p.Model
+}
+
+// GetAvailableModels returns nil, since the models depend on the server
+func (p *CustomProvider) GetAvailableModels() []string {
+	return nil
+}
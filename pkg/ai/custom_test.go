@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// TestCustomProvider_Settings tests that new custom providers use the configured settings
+func TestCustomProvider_Settings(t *testing.T) {
+	defer SetCustomProviderSettings("", "", false)
+
+	SetCustomProviderSettings(" http://localhost:8000/v1 ", "llama3", true)
+
+	provider, err := NewProvider("custom")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if provider.GetName() != "custom" {
+		t.Errorf("Expected name 'custom', got '%s'", provider.GetName())
+	}
+	if provider.GetDefaultModel() != "llama3" {
+		t.Errorf("Expected default model 'llama3', got '%s'", provider.GetDefaultModel())
+	}
+	if provider.ValidateAPIKey("") {
+		t.Errorf("Expected an empty key to be rejected when a key is required")
+	}
+	if !provider.ValidateAPIKey("any-key") {
+		t.Errorf("Expected any non-empty key to be accepted")
+	}
+
+	// Any model is accepted since the models depend on the server
+	if err := ValidateModel(provider, "some-local-model"); err != nil {
+		t.Errorf("Expected any model to be accepted, got '%s'", err.Error())
+	}
+}
+
+// TestCustomProvider_Endpoint tests building the chat completions URL from the base URL
+func TestCustomProvider_Endpoint(t *testing.T) {
+	testCases := []struct {
+		baseURL  string
+		expected string
+	}{
+		{baseURL: "http://localhost:8000/v1", expected: "http://localhost:8000/v1/chat/completions"},
+		{baseURL: "http://localhost:1234/v1/", expected: "http://localhost:1234/v1/chat/completions"},
+		{baseURL: "http://host/v1/chat/completions", expected: "http://host/v1/chat/completions"},
+	}
+
+	for _, tc := range testCases {
+		provider := &CustomProvider{BaseURL: tc.baseURL}
+		endpoint, err := provider.endpoint()
+		if err != nil || endpoint != tc.expected {
+			t.Errorf("endpoint() for %q = %q (err: %v), expected %q", tc.baseURL, endpoint, err, tc.expected)
+		}
+	}
+
+	if _, err := (&CustomProvider{}).endpoint(); err == nil || !strings.Contains(err.Error(), "custom_base_url") {
+		t.Errorf("Expected an error mentioning custom_base_url, got %v", err)
+	}
+}
+
+// TestCustomProvider_GenerateCommitMessage tests a request to a server that needs no API key
+func TestCustomProvider_GenerateCommitMessage(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		if req.URL.String() != "http://localhost:8000/v1/chat/completions" {
+			t.Errorf("Unexpected URL: %s", req.URL.String())
+		}
+		if auth := req.Header.Get("Authorization"); auth != "" {
+			t.Errorf("Expected no Authorization header without a key, got %q", auth)
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"choices": [{"message": {"content": "fix: handle empty input"}}]}`)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"main.go"},
+		Diff:         "diff --git a/main.go b/main.go\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s",
+	}
+
+	provider := &CustomProvider{BaseURL: "http://localhost:8000/v1", Model: "llama3"}
+	message, err := provider.GenerateCommitMessage(context.Background(), "", "llama3", diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if message != "fix: handle empty input" {
+		t.Errorf("Expected message 'fix: handle empty input', got '%s'", message)
+	}
+
+	// A required key must be present
+	provider.RequireAPIKey = true
+	if _, err := provider.GenerateCommitMessage(context.Background(), "", "llama3", diff); err == nil {
+		t.Errorf("Expected an error without a required API key")
+	}
+}
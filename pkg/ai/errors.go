@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// APIError is returned when a provider's API responds with a status other than 200 OK
+type APIError struct {
+	StatusCode int
+	Message    string    // Response body as returned by the API
+	RateLimit  RateLimit // Quota reported in the response headers
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// IsRetryable reports whether err is worth retrying with another provider: rate limits,
+// exhausted quotas, overloaded or unavailable servers, timeouts and network failures.
+// Errors such as a bad request or an invalid API key would fail the same way again,
+// and a request cancelled with Ctrl-C shouldn't be sent to another provider.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusRequestTimeout,
+			http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+			529: // Anthropic's "overloaded"
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// IsAuthError reports whether err means the provider rejected the API key
+func IsAuthError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
+}
+
+// DescribeError explains in a few words what kind of failure err is, e.g. "the API key
+// was rejected", or returns "" when it isn't one of the failures recognized here
+func DescribeError(err error) string {
+	if IsAuthError(err) {
+		return "the API key was rejected"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "the request timed out"
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusNotFound:
+			return "the model was not found"
+		case http.StatusTooManyRequests:
+			return "the provider is rate limiting requests"
+		}
+		if IsRetryable(err) {
+			return "the provider is unavailable"
+		}
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return "the request timed out"
+		}
+		return "the provider could not be reached"
+	}
+	return ""
+}
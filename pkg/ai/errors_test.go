@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestAPIErrorMessage(t *testing.T) {
+	err := &APIError{StatusCode: 429, Message: "rate limited"}
+	if got, want := err.Error(), "API error (status 429): rate limited"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limit", &APIError{StatusCode: 429}, true},
+		{"overloaded", &APIError{StatusCode: 529}, true},
+		{"unavailable", &APIError{StatusCode: 503}, true},
+		{"wrapped server error", fmt.Errorf("generating: %w", &APIError{StatusCode: 500}), true},
+		{"bad request", &APIError{StatusCode: 400}, false},
+		{"unauthorized", &APIError{StatusCode: 401}, false},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"cancelled", &url.Error{Op: "Post", URL: "https://example.com", Err: context.Canceled}, false},
+		{"other", errors.New("failed to parse response"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAuthError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"unauthorized", &APIError{StatusCode: 401}, true},
+		{"forbidden", fmt.Errorf("generating: %w", &APIError{StatusCode: 403}), true},
+		{"rate limit", &APIError{StatusCode: 429}, false},
+		{"other", errors.New("no API key found for provider: openai"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAuthError(tt.err); got != tt.want {
+				t.Errorf("IsAuthError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -18,6 +18,10 @@ func NewProvider(providerName string) (Provider, error) {
 		return NewOpenAIProvider(), nil
 	case string(ProviderGemini), "google":
 		return NewGeminiProvider(), nil
+	case string(ProviderCustom):
+		return NewCustomProvider(), nil
+	case string(ProviderBedrock):
+		return NewBedrockProvider(), nil
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", providerName)
 	}
@@ -29,6 +33,8 @@ func GetAllProviders() []Provider {
 		NewAnthropicProvider(),
 		NewOpenAIProvider(),
 		NewGeminiProvider(),
+		NewCustomProvider(),
+		NewBedrockProvider(),
 	}
 }
 
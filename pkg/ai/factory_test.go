@@ -32,6 +32,18 @@ func TestNewProvider(t *testing.T) {
 			expectedType: "*ai.GeminiProvider",
 			expectError:  false,
 		},
+		{
+			name:         "Create custom provider",
+			providerName: "custom",
+			expectedType: "*ai.CustomProvider",
+			expectError:  false,
+		},
+		{
+			name:         "Create Bedrock provider",
+			providerName: "bedrock",
+			expectedType: "*ai.BedrockProvider",
+			expectError:  false,
+		},
 		{
 			name:          "Unknown provider",
 			providerName:  "unknown",
@@ -81,9 +93,9 @@ func TestNewProvider(t *testing.T) {
 func TestGetAllProviders(t *testing.T) {
 	providers := GetAllProviders()
 
-	// Check that we have all three providers
-	if len(providers) != 3 {
-		t.Errorf("Expected 3 providers, got %d", len(providers))
+	// Check that we have all five providers
+	if len(providers) != 5 {
+		t.Errorf("Expected 5 providers, got %d", len(providers))
 	}
 
 	// Check that all provider names are present
@@ -91,6 +103,8 @@ func TestGetAllProviders(t *testing.T) {
 		"anthropic": false,
 		"openai":    false,
 		"gemini":    false,
+		"custom":    false,
+		"bedrock":   false,
 	}
 
 	for _, p := range providers {
@@ -192,6 +206,10 @@ func getTypeName(i interface{}) string {
 		return "*ai.OpenAIProvider"
 	case *GeminiProvider:
 		return "*ai.GeminiProvider"
+	case *CustomProvider:
+		return "*ai.CustomProvider"
+	case *BedrockProvider:
+		return "*ai.BedrockProvider"
 	default:
 		return "unknown type"
 	}
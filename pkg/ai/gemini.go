@@ -2,12 +2,11 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
-	"time"
 
 	"github.com/nycjay/ai-commit-msg/pkg/git"
 )
@@ -27,8 +26,10 @@ type GeminiContent struct {
 type GeminiRequest struct {
 	Contents []GeminiContent `json:"contents"`
 	GenerationConfig struct {
-		MaxOutputTokens int     `json:"maxOutputTokens"`
-		Temperature     float64 `json:"temperature"`
+		MaxOutputTokens  int                    `json:"maxOutputTokens"`
+		Temperature      float64                `json:"temperature"`
+		ResponseMimeType string                 `json:"responseMimeType,omitempty"` // application/json for structured output
+		ResponseSchema   map[string]interface{} `json:"responseSchema,omitempty"`
 	} `json:"generationConfig"`
 }
 
@@ -41,6 +42,10 @@ type GeminiResponse struct {
 			} `json:"parts"`
 		} `json:"content"`
 	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
 }
 
 // NewGeminiProvider creates a new Gemini provider
@@ -49,7 +54,7 @@ func NewGeminiProvider() *GeminiProvider {
 }
 
 // GenerateCommitMessage generates a commit message using Gemini
-func (p *GeminiProvider) GenerateCommitMessage(apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+func (p *GeminiProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
 	if apiKey == "" {
 		return "", fmt.Errorf("no API key found for Gemini")
 	}
@@ -61,56 +66,12 @@ func (p *GeminiProvider) GenerateCommitMessage(apiKey string, modelName string,
 	// Format prompts to be passed to provider
 	// Gemini doesn't have separate system/user roles like OpenAI/Anthropic,
 	// so we combine them for Gemini
-	systemPrompt := diffInfo.SystemPrompt
-	userPromptTemplate := diffInfo.UserPrompt
+	systemPrompt := FormatSystemPrompt(diffInfo)
 	
 	// Format the user prompt with the diff information
-	userPrompt := ""
-	
-	// Check if we have enhanced context fields
-	if diffInfo.ProjectContext != "" || len(diffInfo.FileSummaries) > 0 || len(diffInfo.CommitHistory) > 0 || len(diffInfo.RelatedFiles) > 0 {
-		// Format enhanced context
-		fileSummaries := ""
-		for file, summary := range diffInfo.FileSummaries {
-			fileSummaries += fmt.Sprintf("- %s: %s\n", file, summary)
-		}
-		
-		commitHistory := ""
-		for file, commits := range diffInfo.CommitHistory {
-			commitHistory += fmt.Sprintf("File: %s\n", file)
-			for _, commit := range commits {
-				commitHistory += fmt.Sprintf("  %s\n", commit)
-			}
-		}
-		
-		relatedFiles := ""
-		for _, file := range diffInfo.RelatedFiles {
-			relatedFiles += fmt.Sprintf("- %s\n", file)
-		}
-		
-		// Use the enhanced format with additional context
-		userPrompt = fmt.Sprintf(
-			userPromptTemplate,
-			diffInfo.Branch,
-			strings.Join(diffInfo.StagedFiles, "\n"),
-			diffInfo.Diff,
-			diffInfo.JiraID,
-			diffInfo.JiraDescription,
-			diffInfo.ProjectContext,
-			fileSummaries,
-			commitHistory,
-			relatedFiles,
-		)
-	} else {
-		// Use the regular format without enhanced context
-		userPrompt = fmt.Sprintf(
-			userPromptTemplate,
-			diffInfo.Branch,
-			strings.Join(diffInfo.StagedFiles, "\n"),
-			diffInfo.Diff,
-			diffInfo.JiraID,
-			diffInfo.JiraDescription,
-		)
+	userPrompt, err := FormatUserPrompt(diffInfo)
+	if err != nil {
+		return "", err
 	}
 	
 	// Combine system and user prompts for Gemini
@@ -128,36 +89,38 @@ func (p *GeminiProvider) GenerateCommitMessage(apiKey string, modelName string,
 			},
 		},
 	}
+	request.Contents = append(request.Contents, geminiTurns(diffInfo.Turns)...)
 	request.GenerationConfig.MaxOutputTokens = 1000
 	request.GenerationConfig.Temperature = 0.7
+	structured := diffInfo.Structured && SupportsStructuredOutput(ProviderGemini, modelName)
+	if structured {
+		request.GenerationConfig.ResponseMimeType = "application/json"
+		request.GenerationConfig.ResponseSchema = commitMessageSchema(true)
+	}
 	
 	requestBody, err := json.Marshal(request)
 	if err != nil {
 		return "", err
 	}
 	
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return "", err
 	}
 	
 	req.Header.Set("Content-Type", "application/json")
 	
-	// Use mock transport in tests, real client in production
-	client := &http.Client{Timeout: time.Second * 30}
-	if mockDoFunc != nil {
-		client.Transport = &MockTransport{}
-	}
+	client := httpClient(requestTimeoutFor(ctx))
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", redactURLError(err)
 	}
 	defer resp.Body.Close()
 	
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		errorMsg := string(bodyBytes)
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, errorMsg)
+		return "", &APIError{StatusCode: resp.StatusCode, Message: errorMsg}
 	}
 	
 	var response GeminiResponse
@@ -169,9 +132,41 @@ func (p *GeminiProvider) GenerateCommitMessage(apiKey string, modelName string,
 		return "", fmt.Errorf("empty response from API")
 	}
 	
+	reportUsage(Usage{
+		Provider:     p.GetName(),
+		Model:        modelName,
+		InputTokens:  response.UsageMetadata.PromptTokenCount,
+		OutputTokens: response.UsageMetadata.CandidatesTokenCount,
+	})
+	if structured {
+		return structuredText(response.Candidates[0].Content.Parts[0].Text), nil
+	}
 	return response.Candidates[0].Content.Parts[0].Text, nil
 }
 
+// geminiTurns converts the turns of the conversation so far to Gemini contents, where
+// the model's own messages have the role "model"
+func geminiTurns(turns []git.Turn) []GeminiContent {
+	var contents []GeminiContent
+	for _, turn := range turns {
+		content := GeminiContent{Role: turn.Role}
+		if turn.Role == git.TurnAssistant {
+			content.Role = "model"
+		}
+		content.Parts = append(content.Parts, struct {
+			Text string `json:"text"`
+		}{Text: turn.Content})
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+// GenerateCommitMessageStream generates a commit message using Gemini. Streaming is not
+// supported yet, so the message is written to out once the blocking call completes.
+func (p *GeminiProvider) GenerateCommitMessageStream(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error) {
+	return generateBlockingStream(ctx, p, apiKey, modelName, diffInfo, out)
+}
+
 // ValidateAPIKey validates the Gemini API key format
 func (p *GeminiProvider) ValidateAPIKey(key string) bool {
 	// Gemini API keys can vary in length but are typically at least 20 characters
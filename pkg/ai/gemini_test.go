@@ -2,6 +2,7 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -158,7 +159,7 @@ func TestGeminiProvider_GenerateCommitMessage(t *testing.T) {
 			httpResponse:   nil,
 			httpError:      io.ErrUnexpectedEOF,
 			expectedMsg:    "",
-			expectedErrMsg: "Post \"https://generativelanguage.googleapis.com/v1/models/gemini-1.5-pro:generateContent?key=AIzaSyD-test-key\": unexpected EOF",
+			expectedErrMsg: "Post \"https://generativelanguage.googleapis.com/v1/models/gemini-1.5-pro:generateContent?key=****\": unexpected EOF",
 		},
 		{
 			name:      "API error response",
@@ -243,7 +244,7 @@ func TestGeminiProvider_GenerateCommitMessage(t *testing.T) {
 			provider := NewGeminiProvider()
 
 			// Call the method being tested
-			message, err := provider.GenerateCommitMessage(tc.apiKey, tc.modelName, tc.diff)
+			message, err := provider.GenerateCommitMessage(context.Background(), tc.apiKey, tc.modelName, tc.diff)
 
 			// Check error
 			if tc.expectedErrMsg != "" {
@@ -264,4 +265,125 @@ func TestGeminiProvider_GenerateCommitMessage(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// TestGeminiProvider_GenerateCommitMessageStream tests the blocking fallback used for streaming
+func TestGeminiProvider_GenerateCommitMessageStream(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(bytes.NewBufferString(`{
+				"candidates": [{"content": {"parts": [{"text": "docs: update README"}]}}]
+			}`)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"README.md"},
+		Diff:         "diff --git a/README.md b/README.md\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s",
+	}
+
+	var out bytes.Buffer
+	provider := NewGeminiProvider()
+	message, err := provider.GenerateCommitMessageStream(context.Background(), "AIzaSyD-test-key", "gemini-1.5-pro", diff, &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	if message != "docs: update README" {
+		t.Errorf("Expected message 'docs: update README', got '%s'", message)
+	}
+	if out.String() != message {
+		t.Errorf("Expected fallback to write the full message, got '%s'", out.String())
+	}
+}
+
+// TestGeminiProvider_FeedbackTurns tests that the rejected message is sent with Gemini's
+// "model" role, followed by the feedback
+func TestGeminiProvider_FeedbackTurns(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	var contents []GeminiContent
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody GeminiRequest
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+		contents = reqBody.Contents
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(bytes.NewBufferString(`{
+				"candidates": [{"content": {"parts": [{"text": "docs(readme): describe setup"}]}}]
+			}`)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"README.md"},
+		Diff:         "diff --git a/README.md b/README.md\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s",
+		Turns:        FeedbackTurns(nil, "docs: update README", "say what changed"),
+	}
+
+	provider := NewGeminiProvider()
+	if _, err := provider.GenerateCommitMessage(context.Background(), "AIzaSyD-test-key", "gemini-1.5-pro", diff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	if len(contents) != 3 || contents[1].Role != "model" || contents[1].Parts[0].Text != "docs: update README" || contents[2].Role != "user" {
+		t.Errorf("Expected the prompt, the model's message and the feedback, got %+v", contents)
+	}
+}
+
+// TestGeminiProvider_StructuredOutput tests asking Gemini for the message as JSON fields
+func TestGeminiProvider_StructuredOutput(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	var mimeType string
+	var schema map[string]interface{}
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody GeminiRequest
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+		mimeType, schema = reqBody.GenerationConfig.ResponseMimeType, reqBody.GenerationConfig.ResponseSchema
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(bytes.NewBufferString(`{
+				"candidates": [{"content": {"parts": [{"text": "{\"type\": \"docs\", \"scope\": \"\", \"subject\": \"Describe setup\", \"body\": \"\"}"}]}}]
+			}`)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"README.md"},
+		Diff:         "diff --git a/README.md b/README.md\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s",
+		Structured:   true,
+	}
+
+	provider := NewGeminiProvider()
+	message, err := provider.GenerateCommitMessage(context.Background(), "AIzaSyD-test-key", "gemini-1.5-flash", diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if mimeType != "application/json" || schema["type"] != "OBJECT" {
+		t.Errorf("Expected a JSON response with the schema, got %q and %v", mimeType, schema)
+	}
+	if message != "docs: Describe setup" {
+		t.Errorf("Expected the fields to be joined into a message, got %q", message)
+	}
+
+	// Gemini 1.0 is asked for text
+	if _, err := provider.GenerateCommitMessage(context.Background(), "AIzaSyD-test-key", "gemini-1.0-pro", diff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if mimeType != "" || schema != nil {
+		t.Errorf("Expected no response schema for gemini-1.0-pro, got %q and %v", mimeType, schema)
+	}
+}
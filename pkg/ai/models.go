@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateModel checks that the model is one of the provider's available models.
+// The error lists the valid models and suggests the closest match. Providers
+// without a fixed list of models, such as self-hosted servers, accept any model.
+func ValidateModel(provider Provider, modelName string) error {
+	models := provider.GetAvailableModels()
+	if len(models) == 0 {
+		return nil
+	}
+	for _, model := range models {
+		if model == modelName {
+			return nil
+		}
+	}
+
+	message := fmt.Sprintf("model %q is not available for provider %s", modelName, provider.GetName())
+	if closest := ClosestModel(models, modelName); closest != "" {
+		message += fmt.Sprintf(" (did you mean %q?)", closest)
+	}
+	message += fmt.Sprintf("\nValid models for %s:\n  %s", provider.GetName(), strings.Join(models, "\n  "))
+	message += "\nUse --allow-unknown-model to use a model that is not in this list."
+	return fmt.Errorf("%s", message)
+}
+
+// ClosestModel returns the model with the smallest edit distance to name,
+// or "" if there are no models
+func ClosestModel(models []string, name string) string {
+	closest := ""
+	bestDistance := -1
+	for _, model := range models {
+		distance := levenshtein(strings.ToLower(model), strings.ToLower(name))
+		if bestDistance < 0 || distance < bestDistance {
+			closest = model
+			bestDistance = distance
+		}
+	}
+	return closest
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// minInt returns the smallest of the given integers
+func minInt(values ...int) int {
+	smallest := values[0]
+	for _, value := range values[1:] {
+		if value < smallest {
+			smallest = value
+		}
+	}
+	return smallest
+}
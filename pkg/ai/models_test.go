@@ -0,0 +1,49 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "", b: "", expected: 0},
+		{a: "gpt-4", b: "", expected: 5},
+		{a: "gpt-4", b: "gpt-4", expected: 0},
+		{a: "gpt-4", b: "gpt-4o", expected: 1},
+		{a: "kitten", b: "sitting", expected: 3},
+	}
+
+	for _, tc := range testCases {
+		if got := levenshtein(tc.a, tc.b); got != tc.expected {
+			t.Errorf("levenshtein(%q, %q) = %d, expected %d", tc.a, tc.b, got, tc.expected)
+		}
+	}
+}
+
+func TestValidateModel(t *testing.T) {
+	provider := NewOpenAIProvider()
+
+	if err := ValidateModel(provider, "gpt-4o"); err != nil {
+		t.Errorf("Expected gpt-4o to be valid, got %v", err)
+	}
+
+	err := ValidateModel(provider, "gpt-4-trubo")
+	if err == nil {
+		t.Fatalf("Expected an error for a misspelled model")
+	}
+	if !strings.Contains(err.Error(), `did you mean "gpt-4-turbo"?`) {
+		t.Errorf("Expected the closest model to be suggested, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "gpt-3.5-turbo") {
+		t.Errorf("Expected the valid models to be listed, got %v", err)
+	}
+
+	// A model from another provider is rejected
+	if err := ValidateModel(provider, "claude-3-opus-20240229"); err == nil {
+		t.Errorf("Expected an error for an Anthropic model with the OpenAI provider")
+	}
+}
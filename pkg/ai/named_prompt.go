@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// PromptData holds the values a named user prompt template can use, e.g.
+// {{.Branch}} or {{.Diff}}. Every field is text, empty when there is nothing to show.
+type PromptData struct {
+	Branch          string
+	Files           string // Staged files, one per line
+	Diff            string
+	JiraID          string // Every Jira ID found in the branch name, joined with commas
+	JiraDescription string
+	JiraURL         string // Links to the Jira tickets, with jira_base_url, joined with commas
+	IssueRef        string // GitLab issue reference such as #123
+
+	// Enhanced context, filled in with --enhanced
+	ProjectContext string
+	FileSummaries  string
+	CommitHistory  string
+	RelatedFiles   string
+
+	StyleExamples  string // Recent commit messages, with an instruction to match their style
+	CommitSubjects string // Subjects of the commits being summarized, one per line
+	DiffStat       string // "git diff --stat" summary of the changes
+	FileChanges    string // Notes on renamed, copied, binary and mode-changed files, one per line
+	UntrackedFiles string // Untracked files next to the changed files, one per line
+	FileTypes      string // Kinds of files changed, e.g. "3 Go files, 1 YAML file"; only where the template puts it
+	WorkingTree    string // Working-tree content of the staged files, with --include-diff-context-from-unstaged
+}
+
+// IsNamedTemplate reports whether a user prompt template uses {{...}} placeholders
+// rather than positional %s verbs
+func IsNamedTemplate(template string) bool {
+	return strings.Contains(template, "{{")
+}
+
+// NewPromptData collects the values for a named user prompt template from the diff
+func NewPromptData(diffInfo git.GitDiff) PromptData {
+	fileSummaries, commitHistory, relatedFiles := formatEnhancedContext(diffInfo)
+	return PromptData{
+		Branch:          diffInfo.Branch,
+		Files:           strings.Join(diffInfo.StagedFiles, "\n"),
+		Diff:            diffInfo.Diff,
+		JiraID:          FormatJiraIDs(diffInfo),
+		JiraDescription: diffInfo.JiraDescription,
+		JiraURL:         strings.Join(diffInfo.JiraURLs, ", "),
+		IssueRef:        diffInfo.IssueRef,
+		ProjectContext:  diffInfo.ProjectContext,
+		FileSummaries:   fileSummaries,
+		CommitHistory:   commitHistory,
+		RelatedFiles:    relatedFiles,
+		StyleExamples:   FormatStyleExamples(diffInfo.StyleExamples),
+		CommitSubjects:  strings.Join(diffInfo.CommitSubjects, "\n"),
+		DiffStat:        diffInfo.DiffStat,
+		FileChanges:     strings.Join(fileChangeNotes(diffInfo.FileChanges), "\n"),
+		UntrackedFiles:  strings.Join(diffInfo.UntrackedFiles, "\n"),
+		FileTypes:       git.DescribeFileTypes(diffInfo.StagedFiles),
+		WorkingTree:     workingTreeBlocks(diffInfo.WorkingTreeFiles),
+	}
+}
+
+// ParseUserPrompt parses a named user prompt template and renders it once with empty
+// values, so misspelled fields are reported before any request is sent
+func ParseUserPrompt(text string) (*template.Template, error) {
+	tmpl, err := template.New("user prompt").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user prompt template: %w", err)
+	}
+	if err := tmpl.Execute(new(strings.Builder), PromptData{}); err != nil {
+		return nil, fmt.Errorf("invalid user prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// formatNamedUserPrompt renders a named user prompt template. As with positional
+// templates, the project context is put before the prompt and the optional sections
+// are appended to it when the template doesn't place them itself.
+func formatNamedUserPrompt(diffInfo git.GitDiff) (string, error) {
+	tmpl, err := ParseUserPrompt(diffInfo.UserPrompt)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, NewPromptData(diffInfo)); err != nil {
+		return "", fmt.Errorf("failed to render user prompt: %w", err)
+	}
+	prompt := builder.String()
+
+	uses := func(field string) bool {
+		return strings.Contains(diffInfo.UserPrompt, "."+field)
+	}
+	if !uses("ProjectContext") {
+		if projectContext := FormatProjectContext(diffInfo.ProjectContext); projectContext != "" {
+			prompt = projectContext + "\n\n" + prompt
+		}
+	}
+
+	sections := []struct {
+		field   string
+		section string
+	}{
+		{"StyleExamples", FormatStyleExamples(diffInfo.StyleExamples)},
+		{"IssueRef", FormatIssueRef(diffInfo.IssueRef)},
+		{"CommitSubjects", FormatCommitSubjects(diffInfo.CommitSubjects)},
+		{"DiffStat", FormatDiffStat(diffInfo)},
+		{"FileChanges", FormatFileChanges(diffInfo.FileChanges)},
+		{"UntrackedFiles", FormatUntrackedFiles(diffInfo.UntrackedFiles)},
+		{"WorkingTree", FormatWorkingTreeFiles(diffInfo.WorkingTreeFiles)},
+	}
+	for _, s := range sections {
+		if s.section != "" && !uses(s.field) {
+			prompt += "\n\n" + s.section
+		}
+	}
+	return prompt, nil
+}
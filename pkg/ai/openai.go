@@ -2,12 +2,12 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/nycjay/ai-commit-msg/pkg/git"
 )
@@ -19,10 +19,71 @@ type OpenAIProvider struct{}
 
 // OpenAIRequest represents a request to the OpenAI API
 type OpenAIRequest struct {
-	Model       string         `json:"model"`
-	Messages    []OpenAIMessage `json:"messages"`
-	MaxTokens   int            `json:"max_tokens"`
-	Temperature float64        `json:"temperature"`
+	Model               string               `json:"model"`
+	Messages            []OpenAIMessage      `json:"messages"`
+	MaxTokens           int                  `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int                  `json:"max_completion_tokens,omitempty"` // Replaces max_tokens for reasoning models
+	Temperature         float64              `json:"temperature,omitempty"`           // Rejected by reasoning models
+	Stream              bool                 `json:"stream,omitempty"`
+	StreamOptions       *OpenAIStreamOptions `json:"stream_options,omitempty"`
+	ResponseFormat      *OpenAIJSONFormat    `json:"response_format,omitempty"` // Structured output, with --structured
+}
+
+// OpenAIJSONFormat asks the OpenAI API for a response matching a JSON schema
+type OpenAIJSONFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+// OpenAIJSONSchema is the schema a structured response must match
+type OpenAIJSONSchema struct {
+	Name   string                 `json:"name"`
+	Strict bool                   `json:"strict"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+// commitMessageFormat asks for the message as a CommitMessage object
+func commitMessageFormat() *OpenAIJSONFormat {
+	return &OpenAIJSONFormat{
+		Type:       "json_schema",
+		JSONSchema: &OpenAIJSONSchema{Name: "commit_message", Strict: true, Schema: commitMessageSchema(false)},
+	}
+}
+
+// isStructured reports whether a blocking request asks for structured output: only
+// OpenAI's own API supports it, not every OpenAI-compatible server
+func isStructured(endpoint string, modelName string, diffInfo git.GitDiff) bool {
+	return diffInfo.Structured && endpoint == openaiAPI && SupportsStructuredOutput(ProviderOpenAI, modelName)
+}
+
+// reasoningModelPrefixes are the prefixes of OpenAI's reasoning models, which reject
+// temperature and take max_completion_tokens instead of max_tokens
+var reasoningModelPrefixes = []string{"o1", "o3"}
+
+// reasoningMaxCompletionTokens leaves room for the hidden reasoning tokens, which count
+// against max_completion_tokens along with the message itself
+const reasoningMaxCompletionTokens = 8000
+
+// isReasoningModel reports whether the model is one of OpenAI's reasoning models
+func isReasoningModel(modelName string) bool {
+	modelName = strings.ToLower(modelName)
+	for _, prefix := range reasoningModelPrefixes {
+		if modelName == prefix || strings.HasPrefix(modelName, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenAIStreamOptions asks the OpenAI API to report token usage at the end of a stream
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAIUsage represents the token counts reported by the OpenAI API
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
 }
 
 // OpenAIMessage represents a message in the OpenAI chat API
@@ -38,6 +99,17 @@ type OpenAIResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage OpenAIUsage `json:"usage"`
+}
+
+// OpenAIStreamChunk represents a single chunk from the OpenAI streaming API
+type OpenAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *OpenAIUsage `json:"usage"` // Only set on the final chunk, if at all
 }
 
 // NewOpenAIProvider creates a new OpenAI provider
@@ -46,111 +118,175 @@ func NewOpenAIProvider() *OpenAIProvider {
 }
 
 // GenerateCommitMessage generates a commit message using OpenAI
-func (p *OpenAIProvider) GenerateCommitMessage(apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+func (p *OpenAIProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	result, err := p.GenerateCommitMessageResult(ctx, apiKey, modelName, diffInfo)
+	return result.Message, err
+}
+
+// GenerateCommitMessageResult generates a commit message using OpenAI and returns it
+// with the token usage and the rate limits from the response headers
+func (p *OpenAIProvider) GenerateCommitMessageResult(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (GenerationResult, error) {
+	if apiKey == "" {
+		return GenerationResult{}, fmt.Errorf("no API key found for OpenAI")
+	}
+	return generateChatCompletion(ctx, p.GetName(), openaiAPI, apiKey, modelName, diffInfo)
+}
+
+// GenerateCommitMessageStream generates a commit message using OpenAI's SSE streaming,
+// writing each content delta to out as it arrives
+func (p *OpenAIProvider) GenerateCommitMessageStream(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error) {
 	if apiKey == "" {
 		return "", fmt.Errorf("no API key found for OpenAI")
 	}
+	return streamChatCompletion(ctx, p.GetName(), openaiAPI, apiKey, modelName, diffInfo, out)
+}
+
+// generateChatCompletion sends a blocking request to an OpenAI-compatible chat
+// completions endpoint and returns the message with its usage and rate limits
+func generateChatCompletion(ctx context.Context, providerName string, endpoint string, apiKey string, modelName string, diffInfo git.GitDiff) (GenerationResult, error) {
+	resp, err := sendChatCompletionRequest(ctx, providerName, endpoint, apiKey, modelName, diffInfo, false)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var response OpenAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return GenerationResult{}, err
+	}
 
-	// Format prompts to be passed to provider
-	systemPrompt := diffInfo.SystemPrompt
-	userPrompt := ""
-	
-	// Check if we have enhanced context fields
-	if diffInfo.ProjectContext != "" || len(diffInfo.FileSummaries) > 0 || len(diffInfo.CommitHistory) > 0 || len(diffInfo.RelatedFiles) > 0 {
-		// Format enhanced context
-		fileSummaries := ""
-		for file, summary := range diffInfo.FileSummaries {
-			fileSummaries += fmt.Sprintf("- %s: %s\n", file, summary)
+	if len(response.Choices) == 0 {
+		return GenerationResult{}, fmt.Errorf("empty response from API")
+	}
+
+	message := response.Choices[0].Message.Content
+	if isStructured(endpoint, modelName, diffInfo) {
+		message = structuredText(message)
+	}
+
+	result := GenerationResult{
+		Message: message,
+		Usage: Usage{
+			Provider:     providerName,
+			Model:        modelName,
+			InputTokens:  response.Usage.PromptTokens,
+			OutputTokens: response.Usage.CompletionTokens,
+		},
+		RateLimit: ParseRateLimit(resp.Header),
+	}
+	reportUsage(result.Usage)
+	return result, nil
+}
+
+// streamChatCompletion sends a streaming request to an OpenAI-compatible chat
+// completions endpoint, writing each content delta to out as it arrives
+func streamChatCompletion(ctx context.Context, providerName string, endpoint string, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error) {
+	resp, err := sendChatCompletionRequest(ctx, providerName, endpoint, apiKey, modelName, diffInfo, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var message strings.Builder
+	usage := Usage{Provider: providerName, Model: modelName}
+	err = readServerSentEvents(resp.Body, func(data string) error {
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream event: %v", err)
 		}
-		
-		commitHistory := ""
-		for file, commits := range diffInfo.CommitHistory {
-			commitHistory += fmt.Sprintf("File: %s\n", file)
-			for _, commit := range commits {
-				commitHistory += fmt.Sprintf("  %s\n", commit)
-			}
+
+		if chunk.Usage != nil {
+			usage.InputTokens = chunk.Usage.PromptTokens
+			usage.OutputTokens = chunk.Usage.CompletionTokens
 		}
-		
-		relatedFiles := ""
-		for _, file := range diffInfo.RelatedFiles {
-			relatedFiles += fmt.Sprintf("- %s\n", file)
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				message.WriteString(choice.Delta.Content)
+				fmt.Fprint(out, choice.Delta.Content)
+			}
 		}
-		
-		// Use the enhanced format with additional context
-		userPrompt = fmt.Sprintf(
-			diffInfo.UserPrompt,
-			diffInfo.Branch,
-			strings.Join(diffInfo.StagedFiles, "\n"),
-			diffInfo.Diff,
-			diffInfo.JiraID,
-			diffInfo.JiraDescription,
-			diffInfo.ProjectContext,
-			fileSummaries,
-			commitHistory,
-			relatedFiles,
-		)
-	} else {
-		// Use the regular format without enhanced context
-		userPrompt = fmt.Sprintf(
-			diffInfo.UserPrompt,
-			diffInfo.Branch,
-			strings.Join(diffInfo.StagedFiles, "\n"),
-			diffInfo.Diff,
-			diffInfo.JiraID,
-			diffInfo.JiraDescription,
-		)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if message.Len() == 0 {
+		return "", fmt.Errorf("empty response from API")
+	}
+
+	reportUsage(usage)
+	return message.String(), nil
+}
+
+// sendChatCompletionRequest builds and sends a chat completion request, returning
+// the response only when the API reports success. The Authorization header is
+// left out when there is no API key, as local servers often don't need one.
+func sendChatCompletionRequest(ctx context.Context, providerName string, endpoint string, apiKey string, modelName string, diffInfo git.GitDiff, stream bool) (*http.Response, error) {
+	userPrompt, err := FormatUserPrompt(diffInfo)
+	if err != nil {
+		return nil, err
 	}
 
 	request := OpenAIRequest{
 		Model: modelName,
 		Messages: []OpenAIMessage{
-			{Role: "system", Content: systemPrompt},
+			{Role: "system", Content: FormatSystemPrompt(diffInfo)},
 			{Role: "user", Content: userPrompt},
 		},
-		MaxTokens:   1000,
-		Temperature: 0.7,
+		Stream: stream,
+	}
+	for _, turn := range diffInfo.Turns {
+		request.Messages = append(request.Messages, OpenAIMessage{Role: turn.Role, Content: turn.Content})
+	}
+	if isReasoningModel(modelName) {
+		request.MaxCompletionTokens = reasoningMaxCompletionTokens
+	} else {
+		request.MaxTokens = 1000
+		request.Temperature = 0.7
+	}
+	if !stream && isStructured(endpoint, modelName, diffInfo) {
+		// Streamed messages are shown as they arrive, so they stay plain text
+		request.ResponseFormat = commitMessageFormat()
+	}
+	if stream && endpoint == openaiAPI {
+		// Other OpenAI-compatible servers may not accept stream_options
+		request.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
 	}
 
 	requestBody, err := json.Marshal(request)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", openaiAPI, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-
-	// Use mock transport in tests, real client in production
-	client := &http.Client{Timeout: time.Second * 30}
-	if mockDoFunc != nil {
-		client.Transport = &MockTransport{}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
 	}
+
+	client := httpClient(requestTimeoutFor(ctx))
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
+
+	rateLimit := ParseRateLimit(resp.Header)
+	reportRateLimit(providerName, rateLimit)
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		errorMsg := string(bodyBytes)
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, errorMsg)
-	}
-
-	var response OpenAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", err
-	}
-
-	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("empty response from API")
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: errorMsg, RateLimit: rateLimit}
 	}
 
-	return response.Choices[0].Message.Content, nil
+	return resp, nil
 }
 
 // ValidateAPIKey validates the OpenAI API key format
@@ -176,5 +312,9 @@ func (p *OpenAIProvider) GetAvailableModels() []string {
 		"gpt-4-turbo",
 		"gpt-4",
 		"gpt-3.5-turbo",
+		"o1",
+		"o1-mini",
+		"o3",
+		"o3-mini",
 	}
 }
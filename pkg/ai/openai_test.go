@@ -2,6 +2,7 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -235,7 +236,7 @@ func TestOpenAIProvider_GenerateCommitMessage(t *testing.T) {
 			provider := NewOpenAIProvider()
 
 			// Call the method being tested
-			message, err := provider.GenerateCommitMessage(tc.apiKey, tc.modelName, tc.diff)
+			message, err := provider.GenerateCommitMessage(context.Background(), tc.apiKey, tc.modelName, tc.diff)
 
 			// Check error
 			if tc.expectedErrMsg != "" {
@@ -257,3 +258,245 @@ func TestOpenAIProvider_GenerateCommitMessage(t *testing.T) {
 		})
 	}
 }
+
+// TestOpenAIProvider_GenerateCommitMessageStream tests streaming a message from SSE chunks
+func TestOpenAIProvider_GenerateCommitMessageStream(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	sseBody := "data: {\"choices\": [{\"delta\": {\"role\": \"assistant\"}}]}\n\n" +
+		"data: {\"choices\": [{\"delta\": {\"content\": \"feat(api): \"}}]}\n\n" +
+		"data: {\"choices\": [{\"delta\": {\"content\": \"add pagination\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody map[string]interface{}
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+
+		if reqBody["stream"] != true {
+			t.Errorf("Expected stream to be true in request body, got %v", reqBody["stream"])
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(sseBody)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"api.go"},
+		Diff:         "diff --git a/api.go b/api.go\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s",
+	}
+
+	var out bytes.Buffer
+	provider := NewOpenAIProvider()
+	message, err := provider.GenerateCommitMessageStream(context.Background(), "sk-test", "gpt-4o", diff, &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	expected := "feat(api): add pagination"
+	if message != expected {
+		t.Errorf("Expected message '%s', got '%s'", expected, message)
+	}
+	if out.String() != expected {
+		t.Errorf("Expected streamed output '%s', got '%s'", expected, out.String())
+	}
+}
+
+// TestOpenAIProvider_GenerateCommitMessageLanguage tests that the language instruction reaches the system message
+func TestOpenAIProvider_GenerateCommitMessageLanguage(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody OpenAIRequest
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+
+		if len(reqBody.Messages) == 0 || reqBody.Messages[0].Role != "system" {
+			t.Fatalf("Expected a system message first, got %+v", reqBody.Messages)
+		}
+		if !strings.Contains(reqBody.Messages[0].Content, "Write the commit message in Spanish.") {
+			t.Errorf("Expected the language instruction in the system message, got %q", reqBody.Messages[0].Content)
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"choices": [{"message": {"content": "feat: añadir paginación"}}]}`)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"api.go"},
+		Diff:         "diff --git a/api.go b/api.go\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s",
+		Language:     "es",
+	}
+
+	provider := NewOpenAIProvider()
+	if _, err := provider.GenerateCommitMessage(context.Background(), "sk-test", "gpt-4o", diff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+}
+
+// TestOpenAIProvider_FeedbackTurns tests that earlier messages and the feedback on them
+// follow the system and user messages
+func TestOpenAIProvider_FeedbackTurns(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	var roles []string
+	var last string
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody OpenAIRequest
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+		for _, message := range reqBody.Messages {
+			roles = append(roles, message.Role)
+			last = message.Content
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"choices": [{"message": {"content": "feat(api): add pagination"}}]}`)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"api.go"},
+		Diff:         "diff --git a/api.go b/api.go\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s %s %s %s %s",
+	}
+	diff.Turns = FeedbackTurns(nil, "Update api", "too vague")
+	diff.Turns = FeedbackTurns(diff.Turns, "feat: add pagination", "wrong scope, use api")
+
+	provider := NewOpenAIProvider()
+	if _, err := provider.GenerateCommitMessage(context.Background(), "sk-test", "gpt-4o", diff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	expected := []string{"system", "user", "assistant", "user", "assistant", "user"}
+	if strings.Join(roles, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected roles %v, got %v", expected, roles)
+	}
+	if !strings.HasSuffix(last, "Feedback: wrong scope, use api") {
+		t.Errorf("Expected the latest feedback last, got %q", last)
+	}
+}
+
+// TestOpenAIProvider_ReasoningModelRequest tests that reasoning models get max_completion_tokens
+// and no temperature, while chat models keep max_tokens and temperature
+func TestOpenAIProvider_ReasoningModelRequest(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	var requests []map[string]interface{}
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody map[string]interface{}
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+		requests = append(requests, reqBody)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"choices": [{"message": {"content": "fix: Handle empty input"}}]}`)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"api.go"},
+		Diff:         "diff --git a/api.go b/api.go\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s",
+	}
+
+	provider := NewOpenAIProvider()
+	for _, model := range []string{"gpt-4o", "o3-mini"} {
+		if _, err := provider.GenerateCommitMessage(context.Background(), "sk-test", model, diff); err != nil {
+			t.Fatalf("Expected no error for %s, got '%s'", model, err.Error())
+		}
+	}
+
+	chat, reasoning := requests[0], requests[1]
+	if chat["max_tokens"] == nil || chat["temperature"] == nil || chat["max_completion_tokens"] != nil {
+		t.Errorf("Expected max_tokens and temperature for a chat model, got %v", chat)
+	}
+	if reasoning["max_completion_tokens"] == nil || reasoning["temperature"] != nil || reasoning["max_tokens"] != nil {
+		t.Errorf("Expected only max_completion_tokens for a reasoning model, got %v", reasoning)
+	}
+}
+
+// TestIsReasoningModel tests detecting OpenAI reasoning models by name
+func TestIsReasoningModel(t *testing.T) {
+	for model, expected := range map[string]bool{
+		"o1":          true,
+		"o1-mini":     true,
+		"o3-mini":     true,
+		"O3":          true,
+		"gpt-4o":      false,
+		"gpt-4o-mini": false,
+		"omni-model":  false,
+	} {
+		if got := isReasoningModel(model); got != expected {
+			t.Errorf("isReasoningModel(%q) = %v, want %v", model, got, expected)
+		}
+	}
+}
+
+// TestOpenAIProvider_StructuredOutput tests asking for the message as JSON fields, and
+// that models and servers without structured output are asked for text
+func TestOpenAIProvider_StructuredOutput(t *testing.T) {
+	defer func() { mockDoFunc = nil }()
+
+	var format *OpenAIJSONFormat
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		var reqBody OpenAIRequest
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &reqBody)
+		format = reqBody.ResponseFormat
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(bytes.NewBufferString(`{"choices": [{"message": {"content": ` +
+				`"{\"type\": \"feat\", \"scope\": \"api\", \"subject\": \"Add pagination\", \"body\": \"- Limit pages to 100 items\"}"}}]}`)),
+		}, nil
+	}
+
+	diff := git.GitDiff{
+		StagedFiles:  []string{"api.go"},
+		Diff:         "diff --git a/api.go b/api.go\n...",
+		SystemPrompt: "Generate a commit message based on the provided diff.",
+		UserPrompt:   "Here is the diff: %s",
+		Structured:   true,
+	}
+
+	provider := NewOpenAIProvider()
+	message, err := provider.GenerateCommitMessage(context.Background(), "sk-test", "gpt-4o", diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if format == nil || format.Type != "json_schema" || format.JSONSchema == nil || !format.JSONSchema.Strict {
+		t.Errorf("Expected a strict json_schema response format, got %+v", format)
+	}
+	if message != "feat(api): Add pagination\n\n- Limit pages to 100 items" {
+		t.Errorf("Expected the fields to be joined into a message, got %q", message)
+	}
+
+	// gpt-4 has no structured output, so the response is taken as text
+	if _, err := provider.GenerateCommitMessage(context.Background(), "sk-test", "gpt-4", diff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if format != nil {
+		t.Errorf("Expected no response format for gpt-4, got %+v", format)
+	}
+
+	// Neither do OpenAI-compatible servers
+	if _, err := generateChatCompletion(context.Background(), "custom", "http://localhost:11434/v1/chat/completions", "", "gpt-4o", diff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if format != nil {
+		t.Errorf("Expected no response format for a custom server, got %+v", format)
+	}
+}
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CommitGroup is one commit suggested by --analyze: the files it contains and its message
+type CommitGroup struct {
+	Files   []string `json:"files"`
+	Message string   `json:"message"`
+}
+
+// ParseCommitPlan reads the commits the model suggested for splitting the staged
+// changes. The response should be a JSON array; text or code fences around it are
+// ignored.
+func ParseCommitPlan(response string) ([]CommitGroup, error) {
+	start := strings.Index(response, "[")
+	end := strings.LastIndex(response, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the response doesn't contain a list of commits")
+	}
+
+	var groups []CommitGroup
+	if err := json.Unmarshal([]byte(response[start:end+1]), &groups); err != nil {
+		return nil, fmt.Errorf("could not read the suggested commits: %w", err)
+	}
+
+	var plan []CommitGroup
+	for _, group := range groups {
+		group.Message = strings.TrimSpace(group.Message)
+		if group.Message == "" || len(group.Files) == 0 {
+			continue
+		}
+		plan = append(plan, group)
+	}
+	if len(plan) == 0 {
+		return nil, fmt.Errorf("the response suggests no commits")
+	}
+	return plan, nil
+}
+
+// UnplannedFiles returns the staged files that no suggested commit contains, in the
+// order they were staged
+func UnplannedFiles(plan []CommitGroup, stagedFiles []string) []string {
+	planned := make(map[string]bool)
+	for _, group := range plan {
+		for _, file := range group.Files {
+			planned[file] = true
+		}
+	}
+
+	var unplanned []string
+	for _, file := range stagedFiles {
+		if file != "" && !planned[file] {
+			unplanned = append(unplanned, file)
+		}
+	}
+	return unplanned
+}
@@ -0,0 +1,37 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommitPlan(t *testing.T) {
+	response := "Here is the plan:\n```json\n" + `[
+  {"files": ["parser.go", "parser_test.go"], "message": "Accept empty input in the parser"},
+  {"files": [], "message": "Dropped: no files"},
+  {"files": ["README.md"], "message": "  Document the parser  "}
+]` + "\n```"
+
+	plan, err := ParseCommitPlan(response)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []CommitGroup{
+		{Files: []string{"parser.go", "parser_test.go"}, Message: "Accept empty input in the parser"},
+		{Files: []string{"README.md"}, Message: "Document the parser"},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, plan)
+	}
+
+	unplanned := UnplannedFiles(plan, []string{"parser.go", "main.go", "README.md"})
+	if !reflect.DeepEqual(unplanned, []string{"main.go"}) {
+		t.Errorf("Expected main.go to be unplanned, got %v", unplanned)
+	}
+
+	for _, bad := range []string{"Fix the parser", "[{\"files\": \"parser.go\"}]", "[]"} {
+		if _, err := ParseCommitPlan(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
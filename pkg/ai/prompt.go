@@ -0,0 +1,470 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// Number of format arguments expected by the standard and enhanced user prompt templates
+const (
+	standardPromptArgs = 5
+	enhancedPromptArgs = 9
+)
+
+// languageNames maps common ISO 639-1 codes to the language names used in the prompt
+var languageNames = map[string]string{
+	"ar": "Arabic",
+	"cs": "Czech",
+	"da": "Danish",
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fi": "Finnish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"no": "Norwegian",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"sv": "Swedish",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"zh": "Chinese",
+}
+
+// LanguageName returns the language name for a code such as "es" or "pt-BR".
+// Unknown values are returned unchanged so full names like "Spanish" also work.
+func LanguageName(language string) string {
+	language = strings.TrimSpace(language)
+	code := strings.ToLower(language)
+	if idx := strings.IndexAny(code, "-_"); idx >= 0 {
+		code = code[:idx]
+	}
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return language
+}
+
+// bodyDirective asks the model for a subject line followed by a bulleted body
+const bodyDirective = "Always write a subject line, then a blank line, then a body of bullet points (\"- \") " +
+	"summarizing each area of the change. Group related files into one bullet and keep each bullet to one or two lines."
+
+// templateDirective asks the model to return the commit template with its sections filled in
+const templateDirective = "Write the commit message by filling in the commit message template below. " +
+	"Keep its sections and headings in the same order, replace placeholders and instructions with content " +
+	"based on the changes, and leave out comment lines starting with \"#\". Return only the completed template."
+
+// draftDirective asks the model to build on the message git prepared for a merge, rebase or cherry-pick
+const draftDirective = "A merge, rebase or cherry-pick is in progress and git has already prepared the commit message below. " +
+	"Use it as the starting point: keep its subject line and any trailers, and only add a short description of " +
+	"the changes, such as how conflicts were resolved."
+
+// subjectDirective asks the model to write only the body for a subject line the user wrote
+const subjectDirective = "The user has already written the subject line of the commit message, shown below. " +
+	"Write only the body that goes with it: do not repeat, rewrite or add a subject line. Start directly with the body, " +
+	"using bullet points (\"- \") that summarize each area of the change and stay consistent with the subject."
+
+// subjectLimitDirective asks for a shorter subject line after the model wrote one over the limit
+const subjectLimitDirective = "The subject line must be at most %d characters long. A previous attempt was too long, " +
+	"so keep the subject short and move any details into the body."
+
+// feedbackDirective asks for a new message after the user rejected the last one, saying why
+const feedbackDirective = "I don't want to use that commit message. Write a new one for the same changes, " +
+	"following the same instructions and taking this feedback into account. Reply with only the new message.\n\nFeedback: %s"
+
+// FeedbackTurns returns turns with the rejected message and the user's feedback on it
+// appended, so the next request sees what was written and why it wasn't used
+func FeedbackTurns(turns []git.Turn, message, feedback string) []git.Turn {
+	return append(append([]git.Turn{}, turns...),
+		git.Turn{Role: git.TurnAssistant, Content: message},
+		git.Turn{Role: git.TurnUser, Content: fmt.Sprintf(feedbackDirective, strings.TrimSpace(feedback))},
+	)
+}
+
+// changeTypeDirective nudges the model towards the commit type guessed from the changed files
+const changeTypeDirective = "Likely type: %s, judging by the changed files. If the message uses a type prefix such as " +
+	"\"feat:\" or \"fix:\", prefer this one, but use another if the diff clearly shows a different kind of change."
+
+// noJiraDirective overrides the Jira instructions of the default prompts with --no-jira
+const noJiraDirective = "This repository doesn't use Jira. Don't put a Jira ID, or a placeholder such as GTBUG-???, " +
+	"in the commit message, and ignore any instructions above about Jira IDs."
+
+// Subject returns the first non-empty line of a commit message, trimmed
+func Subject(message string) string {
+	for _, line := range strings.Split(message, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// ComposeWithSubject returns the subject followed by the body the model wrote for it
+// with the subject directive. The subject is kept verbatim, and dropped from the
+// response if the model repeated it anyway.
+func ComposeWithSubject(subject, response string) string {
+	body := strings.TrimSpace(response)
+	if first := Subject(body); strings.EqualFold(first, strings.TrimSpace(subject)) {
+		body = strings.TrimSpace(strings.TrimPrefix(body, first))
+	}
+	if body == "" {
+		return subject
+	}
+	return subject + "\n\n" + body
+}
+
+// RationaleSeparator is the line that separates the commit message from the rationale
+// the model writes for --notes
+const RationaleSeparator = "--- Rationale ---"
+
+// notesDirective asks the model for a longer rationale after the message, to be stored as a git note
+const notesDirective = "After the commit message, add a line containing only \"" + RationaleSeparator + "\" followed by " +
+	"a longer explanation of the change: why it was made, how it works and anything a reviewer should know. " +
+	"The explanation is stored separately as a git note, so keep the commit message itself concise."
+
+// SplitRationale separates a response written with the notes directive into the commit
+// message and the rationale. The rationale is empty if the response has no separator.
+func SplitRationale(response string) (message, rationale string) {
+	lines := strings.Split(response, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == RationaleSeparator {
+			message = strings.TrimSpace(strings.Join(lines[:i], "\n"))
+			rationale = strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+			return message, rationale
+		}
+	}
+	return strings.TrimSpace(response), ""
+}
+
+// FormatSystemPrompt returns the system prompt with the body directive (or the user's
+// subject line when only the body is to be written), the subject length limit, the
+// prepared draft message, the commit template, the likely change type, the notes
+// directive, the no-Jira directive and an instruction to write the message in the configured language
+// appended, if set
+func FormatSystemPrompt(diffInfo git.GitDiff) string {
+	prompt := diffInfo.SystemPrompt
+
+	if strings.TrimSpace(diffInfo.Subject) != "" {
+		prompt += fmt.Sprintf("\n\n%s\n\n--- Subject line ---\n%s\n--- End of subject line ---",
+			subjectDirective, strings.TrimSpace(diffInfo.Subject))
+	} else if diffInfo.Body {
+		prompt += "\n\n" + bodyDirective
+	}
+
+	if diffInfo.SubjectLimit > 0 {
+		prompt += "\n\n" + fmt.Sprintf(subjectLimitDirective, diffInfo.SubjectLimit)
+	}
+
+	if strings.TrimSpace(diffInfo.Draft) != "" {
+		prompt += fmt.Sprintf("\n\n%s\n\n--- Prepared message ---\n%s\n--- End of prepared message ---",
+			draftDirective, strings.TrimSpace(diffInfo.Draft))
+	}
+
+	if strings.TrimSpace(diffInfo.Template) != "" {
+		prompt += fmt.Sprintf("\n\n%s\n\n--- Template ---\n%s\n--- End of template ---",
+			templateDirective, strings.TrimRight(diffInfo.Template, "\n"))
+	}
+
+	if diffInfo.ChangeType != "" {
+		prompt += "\n\n" + fmt.Sprintf(changeTypeDirective, diffInfo.ChangeType)
+	}
+
+	if diffInfo.Notes {
+		prompt += "\n\n" + notesDirective
+	}
+
+	if diffInfo.NoJira {
+		prompt += "\n\n" + noJiraDirective
+	}
+
+	if strings.TrimSpace(diffInfo.Language) != "" {
+		prompt += fmt.Sprintf(
+			"\n\nWrite the commit message in %s. Keep Jira IDs, code identifiers and file names exactly as they are.",
+			LanguageName(diffInfo.Language),
+		)
+	}
+
+	return prompt
+}
+
+// FormatProjectContext renders the project context, e.g. from --context-file, for
+// templates without a slot for it. It returns an empty string when there is none.
+func FormatProjectContext(projectContext string) string {
+	if strings.TrimSpace(projectContext) == "" {
+		return ""
+	}
+	return fmt.Sprintf("Project context:\n%s", strings.TrimSpace(projectContext))
+}
+
+// CountFormatVerbs returns the number of format verbs in a prompt template, ignoring escaped %%
+func CountFormatVerbs(template string) int {
+	count := 0
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			continue
+		}
+		if i+1 < len(template) && template[i+1] == '%' {
+			i++
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// FormatStyleExamples renders past commit messages as a few-shot section for the prompt.
+// It returns an empty string when there are no examples.
+func FormatStyleExamples(examples []string) string {
+	if len(examples) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Recent commit messages from this repository. Match their style and conventions:\n")
+	for i, example := range examples {
+		fmt.Fprintf(&builder, "\n--- Example %d ---\n%s\n", i+1, strings.TrimSpace(example))
+	}
+	return builder.String()
+}
+
+// FormatJiraIDs returns the Jira IDs for the prompt: every ID found in the branch
+// name joined with commas (e.g. "GTN-1, GTBUG-2"), or the single JiraID
+func FormatJiraIDs(diffInfo git.GitDiff) string {
+	if len(diffInfo.JiraIDs) > 1 {
+		return strings.Join(diffInfo.JiraIDs, ", ")
+	}
+	return diffInfo.JiraID
+}
+
+// FormatIssueRef renders the GitLab issue reference for the prompt.
+// It returns an empty string when there is no reference.
+func FormatIssueRef(issueRef string) string {
+	if issueRef == "" {
+		return ""
+	}
+	return fmt.Sprintf("Issue: %s\nReference this issue in the commit message, for example \"Refs %s\".", issueRef, issueRef)
+}
+
+// FormatCommitSubjects renders the subjects of the commits being summarized for the prompt.
+// It returns an empty string when there are none.
+func FormatCommitSubjects(subjects []string) string {
+	if len(subjects) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Commits being combined, oldest first. Write a single message that covers all of them:\n")
+	for _, subject := range subjects {
+		fmt.Fprintf(&builder, "- %s\n", subject)
+	}
+	return builder.String()
+}
+
+// FormatDiffStat renders the "git diff --stat" summary for the prompt, noting when it
+// stands in for the diff itself. It returns an empty string when there is no summary.
+func FormatDiffStat(diffInfo git.GitDiff) string {
+	if diffInfo.DiffStat == "" {
+		return ""
+	}
+	if diffInfo.Diff == "" {
+		return fmt.Sprintf("Summary of the changes (git diff --stat). The full diff was left out to save tokens, "+
+			"so describe the change from this summary and the file names:\n%s", diffInfo.DiffStat)
+	}
+	return fmt.Sprintf("Summary of the changes (git diff --stat):\n%s", diffInfo.DiffStat)
+}
+
+// fileChangeNotes returns the notes for renamed, copied, binary, mode-changed and
+// whitespace-only files, whose diff says little
+func fileChangeNotes(changes []git.FileChange) []string {
+	var notes []string
+	for _, change := range changes {
+		if note := change.Note(); note != "" {
+			notes = append(notes, note)
+		}
+	}
+	return notes
+}
+
+// FormatFileChanges renders notes on renamed, copied, binary and mode-changed files for
+// the prompt.
+// It returns an empty string when no file needs a note.
+func FormatFileChanges(changes []git.FileChange) string {
+	notes := fileChangeNotes(changes)
+	if len(notes) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Files whose diff doesn't show the whole change:\n")
+	for _, note := range notes {
+		fmt.Fprintf(&builder, "- %s\n", note)
+	}
+	return builder.String()
+}
+
+// FormatWorkingTreeFiles renders the working-tree content of the staged files as
+// read-only context, labelled so that unstaged edits aren't mistaken for part of the
+// commit. It returns an empty string when there is none.
+func FormatWorkingTreeFiles(files []git.WorkingTreeFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+	return "Working-tree content of the staged files, for context only. The commit contains only the staged changes in the diff; " +
+		"files marked as having unstaged edits differ from what is committed, and those edits must not be described.\n" +
+		workingTreeBlocks(files)
+}
+
+// workingTreeBlocks renders each working-tree file under a header naming it and
+// saying whether it has unstaged edits
+func workingTreeBlocks(files []git.WorkingTreeFile) string {
+	var builder strings.Builder
+	for _, file := range files {
+		label := "same as staged"
+		if file.Unstaged {
+			label = "has unstaged edits, not part of this commit"
+		}
+		fmt.Fprintf(&builder, "\n=== %s (working tree, %s) ===\n%s\n", file.Path, label, strings.TrimRight(file.Content, "\n"))
+	}
+	return builder.String()
+}
+
+// FormatUntrackedFiles lists the untracked files next to the changed files, which
+// aren't part of the commit but can explain it. It returns an empty string when there
+// are none.
+func FormatUntrackedFiles(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Untracked files next to the changed files (not part of this commit):\n")
+	for _, file := range files {
+		fmt.Fprintf(&builder, "- %s\n", file)
+	}
+	return builder.String()
+}
+
+// FormatUserPrompt fills the user prompt template with the diff information. Templates
+// with {{...}} placeholders are rendered with text/template against PromptData; any
+// other template is filled positionally with fmt.Sprintf.
+func FormatUserPrompt(diffInfo git.GitDiff) (string, error) {
+	if IsNamedTemplate(diffInfo.UserPrompt) {
+		return formatNamedUserPrompt(diffInfo)
+	}
+	return formatPositionalUserPrompt(diffInfo), nil
+}
+
+// formatPositionalUserPrompt fills a %s-style user prompt template.
+// The enhanced template takes four extra arguments on top of the standard five; with
+// other templates the project context, if any, is put before the prompt instead.
+// Style examples, the issue reference, the commit subjects, the diff stat, the file
+// change notes and then the untracked files fill one more slot each if the template
+// has them, otherwise they are appended to the end of the prompt so existing
+// templates keep working. The file types follow and are only sent to templates
+// with a slot for them. The working-tree content comes last, in its own slot or
+// appended like the other sections.
+func formatPositionalUserPrompt(diffInfo git.GitDiff) string {
+	verbs := CountFormatVerbs(diffInfo.UserPrompt)
+
+	args := []interface{}{
+		diffInfo.Branch,
+		strings.Join(diffInfo.StagedFiles, "\n"),
+		diffInfo.Diff,
+		FormatJiraIDs(diffInfo),
+		diffInfo.JiraDescription,
+	}
+
+	projectContext := FormatProjectContext(diffInfo.ProjectContext)
+	if verbs >= enhancedPromptArgs {
+		projectContext = ""
+		fileSummaries, commitHistory, relatedFiles := formatEnhancedContext(diffInfo)
+		args = append(args, diffInfo.ProjectContext, fileSummaries, commitHistory, relatedFiles)
+	}
+
+	// Style examples, the issue reference, the commit subjects, the diff stat, the file notes and the untracked files use the next slots if
+	// the template has them, in that order; otherwise they are appended to the end of the prompt
+	styleExamples := FormatStyleExamples(diffInfo.StyleExamples)
+	if verbs > len(args) {
+		args = append(args, styleExamples)
+		styleExamples = ""
+	}
+
+	issueRef := FormatIssueRef(diffInfo.IssueRef)
+	if verbs > len(args) {
+		args = append(args, diffInfo.IssueRef)
+		issueRef = ""
+	}
+
+	commitSubjects := FormatCommitSubjects(diffInfo.CommitSubjects)
+	if verbs > len(args) {
+		args = append(args, strings.Join(diffInfo.CommitSubjects, "\n"))
+		commitSubjects = ""
+	}
+
+	diffStat := FormatDiffStat(diffInfo)
+	if verbs > len(args) {
+		args = append(args, diffInfo.DiffStat)
+		diffStat = ""
+	}
+
+	fileChanges := FormatFileChanges(diffInfo.FileChanges)
+	if verbs > len(args) {
+		args = append(args, strings.Join(fileChangeNotes(diffInfo.FileChanges), "\n"))
+		fileChanges = ""
+	}
+
+	untrackedFiles := FormatUntrackedFiles(diffInfo.UntrackedFiles)
+	if verbs > len(args) {
+		args = append(args, strings.Join(diffInfo.UntrackedFiles, "\n"))
+		untrackedFiles = ""
+	}
+
+	if verbs > len(args) {
+		args = append(args, git.DescribeFileTypes(diffInfo.StagedFiles))
+	}
+
+	workingTreeFiles := FormatWorkingTreeFiles(diffInfo.WorkingTreeFiles)
+	if verbs > len(args) {
+		args = append(args, workingTreeBlocks(diffInfo.WorkingTreeFiles))
+		workingTreeFiles = ""
+	}
+
+	prompt := fmt.Sprintf(diffInfo.UserPrompt, args...)
+	if projectContext != "" {
+		prompt = projectContext + "\n\n" + prompt
+	}
+	for _, section := range []string{styleExamples, issueRef, commitSubjects, diffStat, fileChanges, untrackedFiles, workingTreeFiles} {
+		if section != "" {
+			prompt += "\n\n" + section
+		}
+	}
+	return prompt
+}
+
+// formatEnhancedContext renders the file summaries, commit history and related files
+// collected for enhanced context
+func formatEnhancedContext(diffInfo git.GitDiff) (fileSummaries, commitHistory, relatedFiles string) {
+	for file, summary := range diffInfo.FileSummaries {
+		fileSummaries += fmt.Sprintf("- %s: %s\n", file, summary)
+	}
+
+	for file, commits := range diffInfo.CommitHistory {
+		commitHistory += fmt.Sprintf("File: %s\n", file)
+		for _, commit := range commits {
+			commitHistory += fmt.Sprintf("  %s\n", commit)
+		}
+	}
+
+	for _, file := range diffInfo.RelatedFiles {
+		relatedFiles += fmt.Sprintf("- %s\n", file)
+	}
+	return fileSummaries, commitHistory, relatedFiles
+}
@@ -0,0 +1,645 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+func TestCountFormatVerbs(t *testing.T) {
+	testCases := []struct {
+		template string
+		expected int
+	}{
+		{template: "", expected: 0},
+		{template: "Branch %s, files %s", expected: 2},
+		{template: "100%% done on %s", expected: 1},
+	}
+
+	for _, tc := range testCases {
+		if got := CountFormatVerbs(tc.template); got != tc.expected {
+			t.Errorf("CountFormatVerbs(%q) = %d, expected %d", tc.template, got, tc.expected)
+		}
+	}
+}
+
+// formatUserPrompt formats the user prompt, failing the test on a template error
+func formatUserPrompt(t *testing.T, diffInfo git.GitDiff) string {
+	t.Helper()
+	prompt, err := FormatUserPrompt(diffInfo)
+	if err != nil {
+		t.Fatalf("FormatUserPrompt() failed: %v", err)
+	}
+	return prompt
+}
+
+func TestFormatUserPromptStyleExamples(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:        "main",
+		StagedFiles:   []string{"a.go"},
+		Diff:          "diff",
+		JiraID:        "GTN-1",
+		StyleExamples: []string{"feat: First example", "fix: Second example\n\nWith a body"},
+	}
+
+	// Without a dedicated slot the examples are appended to the prompt
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s"
+	prompt := formatUserPrompt(t, diffInfo)
+	if !strings.HasPrefix(prompt, "main|a.go|diff|GTN-1|\n\n") {
+		t.Errorf("Expected standard arguments first, got %q", prompt)
+	}
+	if !strings.Contains(prompt, "--- Example 2 ---\nfix: Second example\n\nWith a body") {
+		t.Errorf("Expected style examples to be appended, got %q", prompt)
+	}
+	if strings.Contains(prompt, "%!") {
+		t.Errorf("Prompt contains formatting errors: %q", prompt)
+	}
+
+	// A template with an extra slot receives the examples in place
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s\nEXAMPLES:\n%s\nEND"
+	prompt = formatUserPrompt(t, diffInfo)
+	if !strings.Contains(prompt, "EXAMPLES:\nRecent commit messages") || !strings.HasSuffix(prompt, "\nEND") {
+		t.Errorf("Expected style examples in the template slot, got %q", prompt)
+	}
+
+	// Without examples an existing template is formatted unchanged
+	diffInfo.StyleExamples = nil
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s"
+	if prompt = formatUserPrompt(t, diffInfo); prompt != "main|a.go|diff|GTN-1|" {
+		t.Errorf("Expected unchanged prompt, got %q", prompt)
+	}
+}
+
+func TestFormatUserPromptIssueRef(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:      "feature/#12-login",
+		StagedFiles: []string{"a.go"},
+		Diff:        "diff",
+		IssueRef:    "group/project#12",
+	}
+
+	// Without a dedicated slot the reference is appended to the prompt
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s"
+	prompt := formatUserPrompt(t, diffInfo)
+	if !strings.HasSuffix(prompt, "\n\nIssue: group/project#12\nReference this issue in the commit message, for example \"Refs group/project#12\".") {
+		t.Errorf("Expected the issue reference to be appended, got %q", prompt)
+	}
+
+	// The slot after the style examples receives the reference in place
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s|%s|ISSUE:%s"
+	prompt = formatUserPrompt(t, diffInfo)
+	if prompt != "feature/#12-login|a.go|diff||||ISSUE:group/project#12" {
+		t.Errorf("Expected the issue reference in the template slot, got %q", prompt)
+	}
+
+	// Jira and issue references coexist
+	diffInfo.JiraID = "GTN-1"
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s"
+	if prompt = formatUserPrompt(t, diffInfo); !strings.HasPrefix(prompt, "feature/#12-login|a.go|diff|GTN-1|") || !strings.Contains(prompt, "Issue: group/project#12") {
+		t.Errorf("Expected both the Jira ID and issue reference, got %q", prompt)
+	}
+}
+
+func TestFormatJiraIDs(t *testing.T) {
+	diffInfo := git.GitDiff{JiraID: "GTN-1"}
+	if got := FormatJiraIDs(diffInfo); got != "GTN-1" {
+		t.Errorf("Expected GTN-1, got %q", got)
+	}
+
+	diffInfo.JiraIDs = []string{"GTN-1", "GTBUG-2"}
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s"
+	if prompt := formatUserPrompt(t, diffInfo); prompt != "|||GTN-1, GTBUG-2|" {
+		t.Errorf("Expected both Jira IDs in the prompt, got %q", prompt)
+	}
+}
+
+func TestFormatUserPromptCommitSubjects(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:         "feature/login",
+		StagedFiles:    []string{"a.go"},
+		Diff:           "diff",
+		CommitSubjects: []string{"Add login form", "Validate passwords"},
+	}
+
+	// Without a dedicated slot the subjects are appended to the prompt
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s"
+	prompt := formatUserPrompt(t, diffInfo)
+	if !strings.HasSuffix(prompt, "oldest first. Write a single message that covers all of them:\n- Add login form\n- Validate passwords\n") {
+		t.Errorf("Expected the commit subjects to be appended, got %q", prompt)
+	}
+
+	// The slot after the issue reference receives the subjects in place
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s|%s|%s|COMMITS:%s"
+	prompt = formatUserPrompt(t, diffInfo)
+	if prompt != "feature/login|a.go|diff|||||COMMITS:Add login form\nValidate passwords" {
+		t.Errorf("Expected the commit subjects in the template slot, got %q", prompt)
+	}
+}
+
+func TestFormatUserPromptDiffStat(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:      "main",
+		StagedFiles: []string{"a.go"},
+		Diff:        "diff",
+		DiffStat:    " a.go | 2 +-\n 1 file changed, 1 insertion(+), 1 deletion(-)",
+	}
+
+	// Without a dedicated slot the summary is appended to the prompt
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s"
+	prompt := formatUserPrompt(t, diffInfo)
+	if !strings.HasSuffix(prompt, "\n\nSummary of the changes (git diff --stat):\n"+diffInfo.DiffStat) {
+		t.Errorf("Expected the diff stat to be appended, got %q", prompt)
+	}
+
+	// The slot after the commit subjects receives the summary in place. A standard
+	// template with nine verbs counts as enhanced, so the slot is the thirteenth.
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|STAT:%s"
+	prompt = formatUserPrompt(t, diffInfo)
+	if prompt != "main|a.go|diff||||||||||STAT:"+diffInfo.DiffStat {
+		t.Errorf("Expected the diff stat in the template slot, got %q", prompt)
+	}
+
+	// Without the diff the model is told the summary stands in for it
+	diffInfo.Diff = ""
+	if section := FormatDiffStat(diffInfo); !strings.Contains(section, "The full diff was left out") {
+		t.Errorf("Expected a note that the diff was left out, got %q", section)
+	}
+}
+
+func TestFormatUserPromptFileChanges(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:      "main",
+		StagedFiles: []string{"b.go", "logo.png", "c.go"},
+		FileChanges: []git.FileChange{
+			{Status: "R", OldPath: "a.go", Path: "b.go"},
+			{Status: "M", Path: "logo.png", Binary: true},
+			{Status: "M", Path: "c.go"},
+		},
+		UserPrompt: "%s|%s|%s|%s|%s",
+	}
+
+	prompt := formatUserPrompt(t, diffInfo)
+	if !strings.HasSuffix(prompt, "\n\nFiles whose diff doesn't show the whole change:\n- Renamed a.go → b.go\n- Updated binary logo.png\n") {
+		t.Errorf("Expected notes for the rename and the binary file only, got %q", prompt)
+	}
+
+	// Ordinary modifications need no note
+	diffInfo.FileChanges = diffInfo.FileChanges[2:]
+	if section := FormatFileChanges(diffInfo.FileChanges); section != "" {
+		t.Errorf("Expected no notes, got %q", section)
+	}
+}
+
+func TestFormatUserPromptUntrackedFiles(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:         "main",
+		StagedFiles:    []string{"parser/parser.go"},
+		UntrackedFiles: []string{"parser/parser_test.go"},
+		UserPrompt:     "%s|%s|%s|%s|%s",
+	}
+
+	prompt := formatUserPrompt(t, diffInfo)
+	if !strings.HasSuffix(prompt, "\n\nUntracked files next to the changed files (not part of this commit):\n- parser/parser_test.go\n") {
+		t.Errorf("Expected the untracked files at the end of the prompt, got %q", prompt)
+	}
+
+	// A named template can place the list itself
+	diffInfo.UserPrompt = "Files nearby:\n{{.UntrackedFiles}}"
+	prompt = formatUserPrompt(t, diffInfo)
+	if prompt != "Files nearby:\nparser/parser_test.go" {
+		t.Errorf("Unexpected prompt %q", prompt)
+	}
+
+	if section := FormatUntrackedFiles(nil); section != "" {
+		t.Errorf("Expected no section without untracked files, got %q", section)
+	}
+}
+
+func TestFormatUserPromptFileTypes(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:      "main",
+		StagedFiles: []string{"parser/parser.go", "config.yaml", "parser/lexer.go", "Makefile", "main.go"},
+		UserPrompt:  "Files changed{{if .FileTypes}} ({{.FileTypes}}){{end}}:\n{{.Files}}",
+	}
+
+	prompt := formatUserPrompt(t, diffInfo)
+	if !strings.HasPrefix(prompt, "Files changed (3 Go files, 1 YAML file, 1 other file):\n") {
+		t.Errorf("Unexpected prompt %q", prompt)
+	}
+
+	// Templates without a place for the file types don't get them appended
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s"
+	if prompt = formatUserPrompt(t, diffInfo); strings.Contains(prompt, "Go files") {
+		t.Errorf("Expected no file types in %q", prompt)
+	}
+
+	// A positional template gets them in the slot after the untracked files
+	diffInfo.UserPrompt = strings.Repeat("%s|", 15) + "%s"
+	if prompt = formatUserPrompt(t, diffInfo); !strings.HasSuffix(prompt, "|3 Go files, 1 YAML file, 1 other file") {
+		t.Errorf("Expected the file types in the last slot, got %q", prompt)
+	}
+}
+
+func TestFormatUserPromptWorkingTreeFiles(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:      "main",
+		StagedFiles: []string{"parser/parser.go", "main.go"},
+		WorkingTreeFiles: []git.WorkingTreeFile{
+			{Path: "parser/parser.go", Content: "package parser\n\nfunc Parse() {}\n", Unstaged: true},
+			{Path: "main.go", Content: "package main\n"},
+		},
+		UserPrompt: "%s|%s|%s|%s|%s",
+	}
+
+	prompt := formatUserPrompt(t, diffInfo)
+	expected := "\n\n=== parser/parser.go (working tree, has unstaged edits, not part of this commit) ===\npackage parser\n\nfunc Parse() {}\n" +
+		"\n=== main.go (working tree, same as staged) ===\npackage main\n"
+	if !strings.Contains(prompt, "\n\nWorking-tree content of the staged files, for context only.") || !strings.HasSuffix(prompt, expected) {
+		t.Errorf("Expected the labelled working-tree content at the end of the prompt, got %q", prompt)
+	}
+
+	// A named template can place the content itself
+	diffInfo.UserPrompt = "Diff:\n{{.Diff}}\nAround it:{{.WorkingTree}}"
+	prompt = formatUserPrompt(t, diffInfo)
+	if prompt != "Diff:\n\nAround it:"+expected[1:] {
+		t.Errorf("Unexpected prompt %q", prompt)
+	}
+
+	// A positional template gets it in the last slot, after the file types
+	diffInfo.UserPrompt = strings.Repeat("%s|", 16) + "%s"
+	if prompt = formatUserPrompt(t, diffInfo); !strings.HasSuffix(prompt, "|2 Go files|"+expected[1:]) {
+		t.Errorf("Expected the working-tree content in the last slot, got %q", prompt)
+	}
+
+	if section := FormatWorkingTreeFiles(nil); section != "" {
+		t.Errorf("Expected no section without working-tree files, got %q", section)
+	}
+}
+
+func TestFormatUserPromptEnhancedTemplateWithoutContext(t *testing.T) {
+	// An enhanced template gets empty enhanced fields instead of missing arguments
+	diffInfo := git.GitDiff{
+		Branch:     "main",
+		UserPrompt: "%s %s %s %s %s %s %s %s %s",
+	}
+
+	prompt := formatUserPrompt(t, diffInfo)
+	if strings.Contains(prompt, "%!") {
+		t.Errorf("Prompt contains formatting errors: %q", prompt)
+	}
+}
+
+func TestFormatSystemPromptLanguage(t *testing.T) {
+	diffInfo := git.GitDiff{SystemPrompt: "You write commit messages."}
+
+	// No language leaves the prompt untouched
+	if prompt := FormatSystemPrompt(diffInfo); prompt != diffInfo.SystemPrompt {
+		t.Errorf("Expected unchanged system prompt, got %q", prompt)
+	}
+
+	testCases := []struct {
+		language string
+		expected string
+	}{
+		{language: "es", expected: "Spanish"},
+		{language: "JA", expected: "Japanese"},
+		{language: "pt-BR", expected: "Portuguese"},
+		{language: "Klingon", expected: "Klingon"},
+	}
+
+	for _, tc := range testCases {
+		diffInfo.Language = tc.language
+		prompt := FormatSystemPrompt(diffInfo)
+		if !strings.HasPrefix(prompt, diffInfo.SystemPrompt) {
+			t.Errorf("Expected the original system prompt to be kept, got %q", prompt)
+		}
+		if !strings.Contains(prompt, "Write the commit message in "+tc.expected+".") {
+			t.Errorf("Expected instruction for %s, got %q", tc.expected, prompt)
+		}
+	}
+}
+
+func TestFormatSystemPromptTemplate(t *testing.T) {
+	diffInfo := git.GitDiff{
+		SystemPrompt: "You write commit messages.",
+		Template:     "Summary:\n\nWhy:\n\nTesting:\n# Describe how you tested\n",
+		Language:     "fr",
+	}
+
+	prompt := FormatSystemPrompt(diffInfo)
+	if !strings.HasPrefix(prompt, diffInfo.SystemPrompt+"\n\n"+templateDirective) {
+		t.Errorf("Expected the template directive after the system prompt, got %q", prompt)
+	}
+	if !strings.Contains(prompt, "--- Template ---\nSummary:\n\nWhy:\n\nTesting:\n# Describe how you tested\n--- End of template ---") {
+		t.Errorf("Expected the template content, got %q", prompt)
+	}
+	if !strings.HasSuffix(prompt, "Write the commit message in French. Keep Jira IDs, code identifiers and file names exactly as they are.") {
+		t.Errorf("Expected the language instruction to come last, got %q", prompt)
+	}
+
+	// A blank template adds nothing
+	diffInfo.Template = "  \n"
+	diffInfo.Language = ""
+	if prompt = FormatSystemPrompt(diffInfo); prompt != diffInfo.SystemPrompt {
+		t.Errorf("Expected unchanged system prompt, got %q", prompt)
+	}
+}
+
+func TestFormatSystemPromptDraft(t *testing.T) {
+	diffInfo := git.GitDiff{
+		SystemPrompt: "You write commit messages.",
+		Draft:        "Merge branch 'feature' into main\n",
+		Template:     "Summary:\n",
+	}
+
+	prompt := FormatSystemPrompt(diffInfo)
+	draftIndex := strings.Index(prompt, draftDirective+"\n\n--- Prepared message ---\nMerge branch 'feature' into main\n--- End of prepared message ---")
+	templateIndex := strings.Index(prompt, templateDirective)
+	if draftIndex < 0 || templateIndex < 0 {
+		t.Fatalf("Expected both the draft and template directives, got %q", prompt)
+	}
+	if draftIndex > templateIndex {
+		t.Errorf("Expected the draft before the template, got %q", prompt)
+	}
+}
+
+func TestFormatSystemPromptChangeType(t *testing.T) {
+	diffInfo := git.GitDiff{SystemPrompt: "You write commit messages.", ChangeType: "feat"}
+
+	prompt := FormatSystemPrompt(diffInfo)
+	if prompt != diffInfo.SystemPrompt+"\n\nLikely type: feat, judging by the changed files. "+
+		"If the message uses a type prefix such as \"feat:\" or \"fix:\", prefer this one, "+
+		"but use another if the diff clearly shows a different kind of change." {
+		t.Errorf("Expected the change type hint after the system prompt, got %q", prompt)
+	}
+
+	// Without a guess there is no hint
+	diffInfo.ChangeType = ""
+	if prompt = FormatSystemPrompt(diffInfo); prompt != diffInfo.SystemPrompt {
+		t.Errorf("Expected unchanged system prompt, got %q", prompt)
+	}
+}
+
+func TestFormatSystemPromptNoJira(t *testing.T) {
+	diffInfo := git.GitDiff{SystemPrompt: "You write commit messages.", NoJira: true}
+	if prompt := FormatSystemPrompt(diffInfo); prompt != diffInfo.SystemPrompt+"\n\n"+noJiraDirective {
+		t.Errorf("Expected the no-Jira directive after the system prompt, got %q", prompt)
+	}
+}
+
+func TestFormatSystemPromptBody(t *testing.T) {
+	diffInfo := git.GitDiff{SystemPrompt: "You write commit messages.", Body: true, Language: "de"}
+
+	prompt := FormatSystemPrompt(diffInfo)
+	if !strings.HasPrefix(prompt, diffInfo.SystemPrompt) {
+		t.Errorf("Expected the original system prompt to be kept, got %q", prompt)
+	}
+
+	bodyIndex := strings.Index(prompt, bodyDirective)
+	languageIndex := strings.Index(prompt, "Write the commit message in German.")
+	if bodyIndex < 0 || languageIndex < 0 {
+		t.Fatalf("Expected both the body and language directives, got %q", prompt)
+	}
+	if bodyIndex > languageIndex {
+		t.Errorf("Expected the language instruction to come last, got %q", prompt)
+	}
+}
+
+func TestFormatSystemPromptNotes(t *testing.T) {
+	diffInfo := git.GitDiff{SystemPrompt: "You write commit messages."}
+	if strings.Contains(FormatSystemPrompt(diffInfo), RationaleSeparator) {
+		t.Error("Expected no notes directive unless notes are requested")
+	}
+
+	diffInfo.Notes = true
+	diffInfo.Language = "fr"
+	prompt := FormatSystemPrompt(diffInfo)
+	notesIndex := strings.Index(prompt, notesDirective)
+	languageIndex := strings.Index(prompt, "Write the commit message in French.")
+	if notesIndex < 0 || languageIndex < 0 {
+		t.Fatalf("Expected both the notes and language directives, got %q", prompt)
+	}
+	if notesIndex > languageIndex {
+		t.Errorf("Expected the language instruction to come last, got %q", prompt)
+	}
+}
+
+func TestSplitRationale(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      string
+		wantMessage   string
+		wantRationale string
+	}{
+		{
+			name:          "with rationale",
+			response:      "feat: Add retries\n\n--- Rationale ---\nThe API drops requests under load.\n\nRetrying twice covers it.\n",
+			wantMessage:   "feat: Add retries",
+			wantRationale: "The API drops requests under load.\n\nRetrying twice covers it.",
+		},
+		{
+			name:          "message with body",
+			response:      "fix: Handle empty input\n\n- Return early\n  --- Rationale ---  \nCrashed on empty files.",
+			wantMessage:   "fix: Handle empty input\n\n- Return early",
+			wantRationale: "Crashed on empty files.",
+		},
+		{
+			name:        "no separator",
+			response:    "chore: Bump version\n",
+			wantMessage: "chore: Bump version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, rationale := SplitRationale(tt.response)
+			if message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", message, tt.wantMessage)
+			}
+			if rationale != tt.wantRationale {
+				t.Errorf("rationale = %q, want %q", rationale, tt.wantRationale)
+			}
+		})
+	}
+}
+
+func TestFormatSystemPromptSubject(t *testing.T) {
+	diffInfo := git.GitDiff{SystemPrompt: "You write commit messages.", Body: true, Subject: "  fix: Keep my subject  "}
+
+	prompt := FormatSystemPrompt(diffInfo)
+	if !strings.Contains(prompt, subjectDirective+"\n\n--- Subject line ---\nfix: Keep my subject\n--- End of subject line ---") {
+		t.Errorf("Expected the subject directive with the subject, got %q", prompt)
+	}
+	if strings.Contains(prompt, bodyDirective) {
+		t.Errorf("Expected no body directive asking for a subject line, got %q", prompt)
+	}
+}
+
+func TestComposeWithSubject(t *testing.T) {
+	tests := []struct {
+		name     string
+		subject  string
+		response string
+		want     string
+	}{
+		{"body only", "fix: Keep my Subject", "- Trim input\n- Add tests\n", "fix: Keep my Subject\n\n- Trim input\n- Add tests"},
+		{"repeated subject", "fix: Keep my Subject", "fix: keep my subject\n\n- Trim input", "fix: Keep my Subject\n\n- Trim input"},
+		{"empty body", "fix: Keep my Subject", "  \n", "fix: Keep my Subject"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComposeWithSubject(tt.subject, tt.response); got != tt.want {
+				t.Errorf("ComposeWithSubject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := Subject("\n  feat: Add login  \n\nBody"); got != "feat: Add login" {
+		t.Errorf("Subject() = %q, want %q", got, "feat: Add login")
+	}
+}
+
+func TestFormatSystemPromptSubjectLimit(t *testing.T) {
+	diffInfo := git.GitDiff{SystemPrompt: "You write commit messages.", Body: true, SubjectLimit: 50}
+
+	prompt := FormatSystemPrompt(diffInfo)
+	expected := diffInfo.SystemPrompt + "\n\n" + bodyDirective + "\n\n" + fmt.Sprintf(subjectLimitDirective, 50)
+	if prompt != expected {
+		t.Errorf("Expected the subject limit after the body directive, got %q", prompt)
+	}
+	if !strings.Contains(prompt, "at most 50 characters") {
+		t.Errorf("Expected the limit in the prompt, got %q", prompt)
+	}
+}
+
+func TestFormatUserPromptProjectContext(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:         "main",
+		ProjectContext: "A CLI that writes commit messages.\n",
+		UserPrompt:     "%s|%s|%s|%s|%s",
+	}
+
+	// A standard template has no slot, so the context goes first
+	prompt := formatUserPrompt(t, diffInfo)
+	if prompt != "Project context:\nA CLI that writes commit messages.\n\nmain||||" {
+		t.Errorf("Expected the project context before the prompt, got %q", prompt)
+	}
+
+	// The enhanced template has a slot for it
+	diffInfo.UserPrompt = "%s|%s|%s|%s|%s|%s|%s|%s|%s"
+	prompt = formatUserPrompt(t, diffInfo)
+	if prompt != "main|||||A CLI that writes commit messages.\n|||" {
+		t.Errorf("Expected the project context in its slot, got %q", prompt)
+	}
+}
+
+func TestCheckUserPrompt(t *testing.T) {
+	tests := []struct {
+		name         string
+		template     string
+		enhanced     bool
+		wantProblems int
+		wantWarnings int
+	}{
+		{name: "standard", template: "%s %s %s %s %s", wantProblems: 0},
+		{name: "standard with optional slots", template: "%s %s %s %s %s %s %s", wantProblems: 0},
+		{name: "too few", template: "%s %s %s", wantProblems: 1},
+		{name: "too many", template: strings.Repeat("%s ", 18), wantProblems: 1},
+		{name: "unsupported verb", template: "%s %s %d %s %s", wantProblems: 1},
+		{name: "escaped percent and width", template: "100%% %s %-10s %s %v %s", wantProblems: 0},
+		{name: "enhanced", template: strings.Repeat("%s ", 9), enhanced: true},
+		{name: "enhanced with too few", template: strings.Repeat("%s ", 6), enhanced: true, wantWarnings: 1},
+		{name: "standard filled as enhanced", template: strings.Repeat("%s ", 9), wantWarnings: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := CheckUserPrompt(tt.template, tt.enhanced)
+			if len(check.Problems) != tt.wantProblems || len(check.Warnings) != tt.wantWarnings {
+				t.Errorf("CheckUserPrompt(%q) = %d problems %v, %d warnings %v; want %d and %d",
+					tt.template, len(check.Problems), check.Problems, len(check.Warnings), check.Warnings, tt.wantProblems, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+// The bundled templates must pass the check
+func TestCheckBundledPrompts(t *testing.T) {
+	for _, file := range []struct {
+		name     string
+		enhanced bool
+	}{
+		{"user_prompt.txt", false},
+		{"body_user_prompt.txt", false},
+		{"pr_description_prompt.txt", false},
+		{"analyze_prompt.txt", false},
+		{"enhanced_user_prompt.txt", true},
+	} {
+		content, err := os.ReadFile(filepath.Join("..", "..", "prompts", file.name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file.name, err)
+		}
+		if check := CheckUserPrompt(string(content), file.enhanced); len(check.Problems) > 0 || len(check.Warnings) > 0 {
+			t.Errorf("%s: problems %v, warnings %v", file.name, check.Problems, check.Warnings)
+		}
+	}
+}
+
+func TestFormatUserPromptNamed(t *testing.T) {
+	diffInfo := git.GitDiff{
+		Branch:        "feature/GTN-1-login",
+		StagedFiles:   []string{"a.go", "b.go"},
+		Diff:          "diff",
+		JiraIDs:       []string{"GTN-1", "GTBUG-2"},
+		JiraURLs:      []string{"https://jira.example.com/browse/GTN-1", "https://jira.example.com/browse/GTBUG-2"},
+		DiffStat:      " a.go | 2 +-",
+		StyleExamples: []string{"feat: Example"},
+		UserPrompt:    "Diff:\n{{.Diff}}\nBranch {{.Branch}}{{if .JiraID}} ({{.JiraID}}){{end}}\n{{.Files}}\n{{.DiffStat}}\n{{.JiraURL}}",
+	}
+
+	prompt := formatUserPrompt(t, diffInfo)
+	if !strings.HasPrefix(prompt, "Diff:\ndiff\nBranch feature/GTN-1-login (GTN-1, GTBUG-2)\na.go\nb.go\n a.go | 2 +-\nhttps://jira.example.com/browse/GTN-1, https://jira.example.com/browse/GTBUG-2") {
+		t.Errorf("Unexpected prompt: %q", prompt)
+	}
+	// The diff stat is placed by the template; the style examples are appended
+	if strings.Contains(prompt, "Summary of the changes") || !strings.Contains(prompt, "--- Example 1 ---") {
+		t.Errorf("Expected only the unused sections to be appended, got %q", prompt)
+	}
+
+	diffInfo.UserPrompt = "{{.Dif}}"
+	if _, err := FormatUserPrompt(diffInfo); err == nil {
+		t.Errorf("Expected an error for an unknown field")
+	}
+}
+
+func TestCheckUserPromptNamed(t *testing.T) {
+	tests := []struct {
+		name         string
+		template     string
+		enhanced     bool
+		wantProblems int
+		wantWarnings int
+	}{
+		{name: "valid", template: "{{.Branch}} {{.Diff}}"},
+		{name: "unknown field", template: "{{.Branch}} {{.Diffs}}", wantProblems: 1},
+		{name: "syntax error", template: "{{.Diff}", wantProblems: 1},
+		{name: "no diff", template: "{{.Branch}} {{.DiffStat}}", wantWarnings: 1},
+		{name: "mixed verbs", template: "{{.Diff}} %s", wantWarnings: 1},
+		{name: "enhanced without context", template: "{{.Diff}}", enhanced: true, wantWarnings: 1},
+		{name: "enhanced", template: "{{.Diff}} {{.FileSummaries}}", enhanced: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := CheckUserPrompt(tt.template, tt.enhanced)
+			if !check.Named || len(check.Problems) != tt.wantProblems || len(check.Warnings) != tt.wantWarnings {
+				t.Errorf("CheckUserPrompt(%q) = %+v; want %d problems and %d warnings", tt.template, check, tt.wantProblems, tt.wantWarnings)
+			}
+		})
+	}
+}
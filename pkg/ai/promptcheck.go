@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// optionalPromptArgs is the number of slots after the standard or enhanced arguments
+// that FormatUserPrompt fills when a template has them: the style examples, the issue
+// reference, the commit subjects, the diff stat, the file change notes, the untracked
+// files, the file types and the working-tree content
+const optionalPromptArgs = 8
+
+// PromptCheck is the result of checking a user prompt template
+type PromptCheck struct {
+	Named    bool     // Whether the template uses {{...}} placeholders
+	Verbs    int      // Number of format verbs in the template
+	Problems []string // Mistakes that put %!(EXTRA ...) or %!s(MISSING) in the prompt
+	Warnings []string // Templates that work but probably not as intended
+}
+
+// diffField matches {{.Diff}} but not {{.DiffStat}}
+var diffField = regexp.MustCompile(`\.Diff\b`)
+
+// enhancedFields are the PromptData fields only filled in with enhanced context
+var enhancedFields = []string{".ProjectContext", ".FileSummaries", ".CommitHistory", ".RelatedFiles"}
+
+// CheckUserPrompt checks a user prompt template. A named template must parse and only
+// use PromptData fields. A positional template needs as many format verbs as
+// FormatUserPrompt passes arguments: 5 for a standard template, 9 for an enhanced one,
+// plus up to 8 optional slots. Only %s and %v are accepted, since every argument is a
+// string.
+func CheckUserPrompt(template string, enhanced bool) PromptCheck {
+	if IsNamedTemplate(template) {
+		return checkNamedUserPrompt(template, enhanced)
+	}
+
+	check := PromptCheck{Verbs: CountFormatVerbs(template)}
+
+	for _, verb := range formatVerbs(template) {
+		if verb != 's' && verb != 'v' {
+			check.Problems = append(check.Problems, fmt.Sprintf("uses %%%c; only %%s and %%v work, as every value is text (write %%%% for a literal %%)", verb))
+		}
+	}
+
+	maxVerbs := enhancedPromptArgs + optionalPromptArgs
+	switch {
+	case check.Verbs < standardPromptArgs:
+		check.Problems = append(check.Problems, fmt.Sprintf(
+			"has %d format verbs but needs at least %d (branch, files, diff, Jira ID, Jira description); the rest would be appended as %%!(EXTRA ...)",
+			check.Verbs, standardPromptArgs))
+	case check.Verbs > maxVerbs:
+		check.Problems = append(check.Problems, fmt.Sprintf(
+			"has %d format verbs but at most %d values are passed; the rest would show up as %%!s(MISSING)",
+			check.Verbs, maxVerbs))
+	case enhanced && check.Verbs < enhancedPromptArgs:
+		check.Warnings = append(check.Warnings, fmt.Sprintf(
+			"has %d format verbs; the enhanced context (project context, file summaries, history, related files) is only filled in with at least %d",
+			check.Verbs, enhancedPromptArgs))
+	case !enhanced && check.Verbs >= enhancedPromptArgs:
+		check.Warnings = append(check.Warnings, fmt.Sprintf(
+			"has %d format verbs, so verbs 6 to 9 are filled with the enhanced context rather than the optional sections",
+			check.Verbs))
+	}
+
+	return check
+}
+
+// checkNamedUserPrompt checks a user prompt template with {{...}} placeholders
+func checkNamedUserPrompt(template string, enhanced bool) PromptCheck {
+	check := PromptCheck{Named: true}
+
+	if _, err := ParseUserPrompt(template); err != nil {
+		check.Problems = append(check.Problems, err.Error())
+		return check
+	}
+	if !diffField.MatchString(template) {
+		check.Warnings = append(check.Warnings, "doesn't use {{.Diff}}, so the changes aren't sent")
+	}
+	if CountFormatVerbs(template) > 0 {
+		check.Warnings = append(check.Warnings, "mixes % verbs with {{...}} placeholders; the % verbs are sent as they are")
+	}
+	if enhanced {
+		usesEnhanced := false
+		for _, field := range enhancedFields {
+			usesEnhanced = usesEnhanced || strings.Contains(template, field)
+		}
+		if !usesEnhanced {
+			check.Warnings = append(check.Warnings, "doesn't use any enhanced context field ({{.FileSummaries}}, {{.CommitHistory}}, {{.RelatedFiles}}); the project context is put before the prompt")
+		}
+	}
+	return check
+}
+
+// formatVerbs returns the verb character of each format verb in the template. Flags,
+// widths and precisions such as %-10s are skipped.
+func formatVerbs(template string) []byte {
+	var verbs []byte
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			continue
+		}
+		if i+1 < len(template) && template[i+1] == '%' {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(template) && isVerbModifier(template[j]) {
+			j++
+		}
+		if j < len(template) {
+			verbs = append(verbs, template[j])
+		}
+		i = j
+	}
+	return verbs
+}
+
+func isVerbModifier(c byte) bool {
+	return c == '+' || c == '-' || c == '#' || c == ' ' || c == '.' || (c >= '0' && c <= '9')
+}
@@ -1,13 +1,23 @@
 package ai
 
 import (
+	"context"
+	"io"
+	"time"
+
 	"github.com/nycjay/ai-commit-msg/pkg/git"
 )
 
 // Provider represents an LLM provider interface
 type Provider interface {
-	// GenerateCommitMessage generates a commit message using the provider's LLM
-	GenerateCommitMessage(apiKey string, modelName string, diffInfo git.GitDiff) (string, error)
+	// GenerateCommitMessage generates a commit message using the provider's LLM. The
+	// request is abandoned when ctx is cancelled or its deadline passes.
+	GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error)
+	
+	// GenerateCommitMessageStream writes the commit message to out as it is generated
+	// and returns the fully assembled message. Providers without a streaming API
+	// fall back to the blocking call.
+	GenerateCommitMessageStream(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error)
 	
 	// ValidateAPIKey validates the format of the API key
 	ValidateAPIKey(key string) bool
@@ -22,6 +32,30 @@ type Provider interface {
 	GetAvailableModels() []string
 }
 
+// GenerationResult is a generated message along with what the provider reported about
+// the request
+type GenerationResult struct {
+	Message   string
+	Usage     Usage
+	RateLimit RateLimit
+}
+
+// ResultProvider is implemented by providers that can return the usage and rate limits
+// of a generation along with the message
+type ResultProvider interface {
+	GenerateCommitMessageResult(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (GenerationResult, error)
+}
+
+// Generate generates a commit message and returns it as a GenerationResult. Providers
+// that don't implement ResultProvider only fill in the message.
+func Generate(ctx context.Context, provider Provider, apiKey string, modelName string, diffInfo git.GitDiff) (GenerationResult, error) {
+	if rp, ok := provider.(ResultProvider); ok {
+		return rp.GenerateCommitMessageResult(ctx, apiKey, modelName, diffInfo)
+	}
+	message, err := provider.GenerateCommitMessage(ctx, apiKey, modelName, diffInfo)
+	return GenerationResult{Message: message}, err
+}
+
 // ProviderType enumerates the supported LLM providers
 type ProviderType string
 
@@ -34,4 +68,72 @@ const (
 	
 	// ProviderGemini represents Google's Gemini models
 	ProviderGemini ProviderType = "gemini"
+	
+	// ProviderCustom represents a self-hosted server with an OpenAI-compatible API
+	ProviderCustom ProviderType = "custom"
+	
+	// ProviderBedrock represents Claude models hosted on AWS Bedrock
+	ProviderBedrock ProviderType = "bedrock"
 )
+
+// DefaultRequestTimeout is the HTTP timeout used when none has been configured
+const DefaultRequestTimeout = 30 * time.Second
+
+// requestTimeout is the HTTP timeout applied to every provider request
+var requestTimeout = DefaultRequestTimeout
+
+// SetRequestTimeout sets the HTTP timeout for provider requests.
+// A non-positive timeout restores the default.
+func SetRequestTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	requestTimeout = timeout
+}
+
+// GetRequestTimeout returns the HTTP timeout for provider requests
+func GetRequestTimeout() time.Duration {
+	return requestTimeout
+}
+
+// candidateTimeout limits each request made by GenerateCandidates; 0 means each
+// candidate gets the request timeout
+var candidateTimeout time.Duration
+
+// SetCandidateTimeout sets the time allowed for each candidate request, in place of
+// the request timeout. A non-positive timeout restores the request timeout.
+func SetCandidateTimeout(timeout time.Duration) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	candidateTimeout = timeout
+}
+
+// candidatesDeadline bounds the whole set of requests made by GenerateCandidates; 0
+// means the candidate timeout for each batch of concurrent requests
+var candidatesDeadline time.Duration
+
+// SetCandidatesDeadline sets the time allowed for the whole set of candidate requests.
+// A non-positive deadline restores the default of one candidate timeout per batch.
+func SetCandidatesDeadline(deadline time.Duration) {
+	if deadline < 0 {
+		deadline = 0
+	}
+	candidatesDeadline = deadline
+}
+
+// timeoutKey carries a per-request timeout that replaces requestTimeout
+type timeoutKey struct{}
+
+// withRequestTimeout returns a context whose requests use timeout instead of requestTimeout
+func withRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, timeout)
+}
+
+// requestTimeoutFor returns the HTTP timeout for a request made with ctx
+func requestTimeoutFor(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(timeoutKey{}).(time.Duration); ok && timeout > 0 {
+		return timeout
+	}
+	return requestTimeout
+}
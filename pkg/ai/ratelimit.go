@@ -0,0 +1,186 @@
+package ai
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is the remaining quota a provider reported in its response headers.
+// Counts the provider didn't report are -1.
+type RateLimit struct {
+	RequestsLimit     int
+	RequestsRemaining int
+	RequestsReset     time.Duration // Time until the request quota is refilled
+	TokensLimit       int
+	TokensRemaining   int
+	TokensReset       time.Duration // Time until the token quota is refilled
+	RetryAfter        time.Duration // From the retry-after header of a rate limited response
+}
+
+// rateLimitHeaders names the headers for one provider's quota. Anthropic sends
+// RFC 3339 reset times while OpenAI sends durations such as "6m0s".
+type rateLimitHeaders struct {
+	requestsLimit, requestsRemaining, requestsReset string
+	tokensLimit, tokensRemaining, tokensReset       string
+}
+
+var (
+	anthropicRateLimitHeaders = rateLimitHeaders{
+		requestsLimit:     "anthropic-ratelimit-requests-limit",
+		requestsRemaining: "anthropic-ratelimit-requests-remaining",
+		requestsReset:     "anthropic-ratelimit-requests-reset",
+		tokensLimit:       "anthropic-ratelimit-tokens-limit",
+		tokensRemaining:   "anthropic-ratelimit-tokens-remaining",
+		tokensReset:       "anthropic-ratelimit-tokens-reset",
+	}
+	openaiRateLimitHeaders = rateLimitHeaders{
+		requestsLimit:     "x-ratelimit-limit-requests",
+		requestsRemaining: "x-ratelimit-remaining-requests",
+		requestsReset:     "x-ratelimit-reset-requests",
+		tokensLimit:       "x-ratelimit-limit-tokens",
+		tokensRemaining:   "x-ratelimit-remaining-tokens",
+		tokensReset:       "x-ratelimit-reset-tokens",
+	}
+)
+
+// ParseRateLimit reads the Anthropic or OpenAI rate limit headers of a response
+func ParseRateLimit(header http.Header) RateLimit {
+	return parseRateLimit(header, time.Now())
+}
+
+func parseRateLimit(header http.Header, now time.Time) RateLimit {
+	names := openaiRateLimitHeaders
+	if header.Get(anthropicRateLimitHeaders.requestsRemaining) != "" || header.Get(anthropicRateLimitHeaders.tokensRemaining) != "" {
+		names = anthropicRateLimitHeaders
+	}
+
+	limit := RateLimit{
+		RequestsLimit:     headerInt(header, names.requestsLimit),
+		RequestsRemaining: headerInt(header, names.requestsRemaining),
+		RequestsReset:     headerReset(header, names.requestsReset, now),
+		TokensLimit:       headerInt(header, names.tokensLimit),
+		TokensRemaining:   headerInt(header, names.tokensRemaining),
+		TokensReset:       headerReset(header, names.tokensReset, now),
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header.Get("retry-after"))); err == nil && seconds > 0 {
+		limit.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return limit
+}
+
+// headerInt returns the header as a number, or -1 when it is missing or malformed
+func headerInt(header http.Header, name string) int {
+	value, err := strconv.Atoi(strings.TrimSpace(header.Get(name)))
+	if err != nil {
+		return -1
+	}
+	return value
+}
+
+// headerReset returns the time until a reset header's moment, accepting both an
+// RFC 3339 timestamp and a duration
+func headerReset(header http.Header, name string, now time.Time) time.Duration {
+	value := strings.TrimSpace(header.Get(name))
+	if value == "" {
+		return 0
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	if wait, err := time.ParseDuration(value); err == nil && wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Known reports whether the provider sent any rate limit headers
+func (r RateLimit) Known() bool {
+	return r.RequestsRemaining >= 0 || r.TokensRemaining >= 0 || r.RetryAfter > 0
+}
+
+// Wait returns how long to wait before retrying: the retry-after header if there was
+// one, otherwise the reset time of an exhausted quota, or 0 if nothing is exhausted
+func (r RateLimit) Wait() time.Duration {
+	if r.RetryAfter > 0 {
+		return r.RetryAfter
+	}
+	var wait time.Duration
+	if r.RequestsRemaining == 0 && r.RequestsReset > wait {
+		wait = r.RequestsReset
+	}
+	if r.TokensRemaining == 0 && r.TokensReset > wait {
+		wait = r.TokensReset
+	}
+	return wait
+}
+
+// String describes the remaining quota, e.g. "49/50 requests, 39000/40000 tokens remaining"
+func (r RateLimit) String() string {
+	var parts []string
+	if part := formatQuota(r.RequestsRemaining, r.RequestsLimit, r.RequestsReset, "requests"); part != "" {
+		parts = append(parts, part)
+	}
+	if part := formatQuota(r.TokensRemaining, r.TokensLimit, r.TokensReset, "tokens"); part != "" {
+		parts = append(parts, part)
+	}
+
+	description := "no rate limit reported"
+	if len(parts) > 0 {
+		description = strings.Join(parts, ", ") + " remaining"
+	}
+	if r.RetryAfter > 0 {
+		description += fmt.Sprintf("; retry after %s", r.RetryAfter)
+	}
+	return description
+}
+
+func formatQuota(remaining, limit int, reset time.Duration, unit string) string {
+	if remaining < 0 {
+		return ""
+	}
+	quota := fmt.Sprintf("%d %s", remaining, unit)
+	if limit >= 0 {
+		quota = fmt.Sprintf("%d/%d %s", remaining, limit, unit)
+	}
+	if reset > 0 {
+		quota += fmt.Sprintf(" (resets in %s)", reset.Round(time.Second))
+	}
+	return quota
+}
+
+// RateLimitHandler receives the rate limits reported with every response
+type RateLimitHandler func(provider string, limit RateLimit)
+
+var (
+	rateLimitMu      sync.Mutex
+	rateLimitHandler RateLimitHandler
+)
+
+// SetRateLimitHandler sets the function called with the rate limits of every
+// response, including rejected ones. A nil handler turns reporting off.
+func SetRateLimitHandler(handler RateLimitHandler) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	rateLimitHandler = handler
+}
+
+// reportRateLimit passes the rate limits to the handler. Responses without rate
+// limit headers are ignored.
+func reportRateLimit(provider string, limit RateLimit) {
+	if !limit.Known() {
+		return
+	}
+
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	if rateLimitHandler != nil {
+		rateLimitHandler(provider, limit)
+	}
+}
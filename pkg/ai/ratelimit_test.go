@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    RateLimit
+	}{
+		{
+			name: "anthropic",
+			headers: map[string]string{
+				"anthropic-ratelimit-requests-limit":     "50",
+				"anthropic-ratelimit-requests-remaining": "49",
+				"anthropic-ratelimit-requests-reset":     "2024-06-01T12:00:30Z",
+				"anthropic-ratelimit-tokens-limit":       "40000",
+				"anthropic-ratelimit-tokens-remaining":   "39000",
+				"anthropic-ratelimit-tokens-reset":       "2024-06-01T11:59:00Z",
+			},
+			want: RateLimit{RequestsLimit: 50, RequestsRemaining: 49, RequestsReset: 30 * time.Second,
+				TokensLimit: 40000, TokensRemaining: 39000},
+		},
+		{
+			name: "openai",
+			headers: map[string]string{
+				"x-ratelimit-limit-requests":     "500",
+				"x-ratelimit-remaining-requests": "0",
+				"x-ratelimit-reset-requests":     "6m0s",
+				"x-ratelimit-remaining-tokens":   "1200",
+				"x-ratelimit-reset-tokens":       "20ms",
+				"retry-after":                    "7",
+			},
+			want: RateLimit{RequestsLimit: 500, RequestsRemaining: 0, RequestsReset: 6 * time.Minute,
+				TokensLimit: -1, TokensRemaining: 1200, TokensReset: 20 * time.Millisecond, RetryAfter: 7 * time.Second},
+		},
+		{
+			name:    "none",
+			headers: map[string]string{},
+			want:    RateLimit{RequestsLimit: -1, RequestsRemaining: -1, TokensLimit: -1, TokensRemaining: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.headers {
+				header.Set(key, value)
+			}
+			if got := parseRateLimit(header, now); got != tt.want {
+				t.Errorf("parseRateLimit() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit RateLimit
+		want  time.Duration
+	}{
+		{"retry after", RateLimit{RequestsRemaining: 0, RequestsReset: time.Minute, RetryAfter: 5 * time.Second}, 5 * time.Second},
+		{"exhausted tokens", RateLimit{RequestsRemaining: 3, RequestsReset: time.Minute, TokensRemaining: 0, TokensReset: 10 * time.Second}, 10 * time.Second},
+		{"quota left", RateLimit{RequestsRemaining: 3, RequestsReset: time.Minute, TokensRemaining: 100, TokensReset: time.Minute}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limit.Wait(); got != tt.want {
+				t.Errorf("Wait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitString(t *testing.T) {
+	limit := RateLimit{RequestsLimit: 50, RequestsRemaining: 49, RequestsReset: 1500 * time.Millisecond, TokensLimit: -1, TokensRemaining: 100}
+	if got, want := limit.String(), "49/50 requests (resets in 2s), 100 tokens remaining"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateResultRateLimit(t *testing.T) {
+	var reported []RateLimit
+	SetRateLimitHandler(func(provider string, limit RateLimit) {
+		reported = append(reported, limit)
+	})
+	t.Cleanup(func() { SetRateLimitHandler(nil) })
+
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("anthropic-ratelimit-requests-remaining", "9")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"content": [{"text": "fix: a"}], "usage": {"input_tokens": 10, "output_tokens": 2}}`)),
+		}, nil
+	}
+	t.Cleanup(func() { mockDoFunc = nil })
+
+	result, err := Generate(context.Background(), NewAnthropicProvider(), "sk-ant-test", "claude-3-haiku-20240307", usageTestDiff)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if result.Message != "fix: a" || result.Usage.InputTokens != 10 || result.RateLimit.RequestsRemaining != 9 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(reported) != 1 || reported[0].RequestsRemaining != 9 {
+		t.Errorf("Expected the rate limit to be reported once, got %+v", reported)
+	}
+}
+
+func TestAPIErrorRateLimit(t *testing.T) {
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("x-ratelimit-remaining-requests", "0")
+		header.Set("retry-after", "20")
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"error": "rate limited"}`)),
+		}, nil
+	}
+	t.Cleanup(func() { mockDoFunc = nil })
+
+	_, err := NewOpenAIProvider().GenerateCommitMessage(context.Background(), "sk-test", "gpt-4o", usageTestDiff)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an APIError, got %v", err)
+	}
+	if apiErr.RateLimit.Wait() != 20*time.Second {
+		t.Errorf("Expected a 20s wait, got %v", apiErr.RateLimit.Wait())
+	}
+}
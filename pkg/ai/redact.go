@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redactedMask replaces the secret part of a key
+const redactedMask = "****"
+
+// keyPatterns match API keys by their well-known prefixes: Anthropic and OpenAI keys
+// start with sk- and Google keys with AIza. The prefix is kept so the log still shows
+// which kind of key was used.
+var keyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(sk-)[A-Za-z0-9_\-]{8,}`),
+	regexp.MustCompile(`(AIza)[A-Za-z0-9_\-]{20,}`),
+	regexp.MustCompile(`([?&](?:key|api_key|apikey)=)[^&\s"']+`),
+}
+
+// secretHeaders are request headers whose values are credentials
+var secretHeaders = map[string]bool{
+	"Authorization":        true,
+	"X-Api-Key":            true,
+	"X-Goog-Api-Key":       true,
+	"X-Amz-Security-Token": true,
+}
+
+// Redact masks the given secrets and anything that looks like an API key in text,
+// e.g. "sk-ant-api03-abc..." becomes "sk-****" and "?key=AIza..." becomes "?key=****"
+func Redact(text string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); len(secret) >= 8 {
+			text = strings.ReplaceAll(text, secret, maskSecret(secret))
+		}
+	}
+	for _, pattern := range keyPatterns {
+		text = pattern.ReplaceAllString(text, "${1}"+redactedMask)
+	}
+	return text
+}
+
+// maskSecret keeps the sk- or AIza prefix of a key and masks the rest
+func maskSecret(secret string) string {
+	for _, prefix := range []string{"sk-", "AIza"} {
+		if strings.HasPrefix(secret, prefix) {
+			return prefix + redactedMask
+		}
+	}
+	return redactedMask
+}
+
+// RedactHeader returns the header lines of a request or response, sorted, with the
+// values of credential headers masked
+func RedactHeader(header http.Header) []string {
+	var lines []string
+	for name, values := range header {
+		for _, value := range values {
+			if secretHeaders[http.CanonicalHeaderKey(name)] {
+				value = redactedMask
+			}
+			lines = append(lines, name+": "+Redact(value))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// redactURLError masks the key in the URL of a failed request. http.Client errors
+// quote the whole URL, which for Gemini carries the key as a query parameter.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = Redact(urlErr.URL)
+	}
+	return err
+}
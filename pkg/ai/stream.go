@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// streamDoneMarker is sent by OpenAI-compatible APIs to signal the end of a stream
+const streamDoneMarker = "[DONE]"
+
+// generateBlockingStream is the fallback used by providers without streaming support.
+// It performs the blocking call and writes the complete message to out once available.
+func generateBlockingStream(ctx context.Context, provider Provider, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error) {
+	message, err := provider.GenerateCommitMessage(ctx, apiKey, modelName, diffInfo)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(out, message)
+	return message, nil
+}
+
+// readServerSentEvents reads a text/event-stream body and calls handle with the
+// payload of every "data:" line until the stream ends or handle returns an error
+func readServerSentEvents(body io.Reader, handle func(data string) error) error {
+	scanner := bufio.NewScanner(body)
+	// Allow for large individual events
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			// Skip event names, comments and blank separator lines
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == streamDoneMarker {
+			return nil
+		}
+
+		if err := handle(data); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CommitMessage is a commit message returned as structured output, split into the
+// fields of a Conventional Commits message
+type CommitMessage struct {
+	Type    string `json:"type"`    // e.g. feat, or empty when the message has no type
+	Scope   string `json:"scope"`   // e.g. config, or empty
+	Subject string `json:"subject"` // Subject line without the type and scope
+	Body    string `json:"body"`    // Empty for a one-line message
+}
+
+// commitMessageFields describes each field of CommitMessage to the model, in order
+var commitMessageFields = []struct {
+	name        string
+	description string
+}{
+	{"type", "Conventional Commits type such as feat, fix or docs; an empty string if the message doesn't use one"},
+	{"scope", "Optional Conventional Commits scope such as config; an empty string for none"},
+	{"subject", "Subject line in the imperative mood, without the type and scope"},
+	{"body", "Body of the message, with its line breaks; an empty string for a one-line message"},
+}
+
+// typedSubject matches a subject line that already starts with a type, e.g. "feat(ui): "
+var typedSubject = regexp.MustCompile(`^[a-z]+(?:\([^()\s]+\))?!?: `)
+
+// commitMessageSchema returns the JSON schema of CommitMessage. OpenAI takes JSON
+// Schema type names and needs additionalProperties in strict mode; Gemini takes
+// OpenAPI type names and rejects additionalProperties.
+func commitMessageSchema(openAPI bool) map[string]interface{} {
+	objectType, stringType := "object", "string"
+	if openAPI {
+		objectType, stringType = "OBJECT", "STRING"
+	}
+
+	properties := make(map[string]interface{})
+	var required []string
+	for _, field := range commitMessageFields {
+		properties[field.name] = map[string]interface{}{"type": stringType, "description": field.description}
+		required = append(required, field.name)
+	}
+
+	schema := map[string]interface{}{
+		"type":       objectType,
+		"properties": properties,
+		"required":   required,
+	}
+	if !openAPI {
+		schema["additionalProperties"] = false
+	}
+	return schema
+}
+
+// SupportsStructuredOutput reports whether a model can be asked for the message as JSON
+// matching a schema. Only OpenAI and Gemini support it; older models of theirs that
+// don't are listed here, so newer ones are assumed to.
+func SupportsStructuredOutput(provider ProviderType, modelName string) bool {
+	modelName = strings.ToLower(modelName)
+	switch provider {
+	case ProviderOpenAI:
+		for _, prefix := range []string{"gpt-3.5", "gpt-4-", "o1-mini", "o1-preview"} {
+			if strings.HasPrefix(modelName, prefix) {
+				return false
+			}
+		}
+		return modelName != "gpt-4"
+	case ProviderGemini:
+		return !strings.HasPrefix(modelName, "gemini-1.0") && modelName != "gemini-pro"
+	}
+	return false
+}
+
+// ParseCommitMessage reads a message returned as structured output. Text or code
+// fences around the JSON object are ignored.
+func ParseCommitMessage(response string) (CommitMessage, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return CommitMessage{}, fmt.Errorf("the response doesn't contain a JSON object")
+	}
+
+	var message CommitMessage
+	if err := json.Unmarshal([]byte(response[start:end+1]), &message); err != nil {
+		return CommitMessage{}, fmt.Errorf("could not read the structured message: %w", err)
+	}
+
+	message.Type = strings.ToLower(strings.TrimSpace(message.Type))
+	message.Scope = strings.TrimSpace(message.Scope)
+	message.Subject = strings.TrimSpace(message.Subject)
+	message.Body = strings.TrimSpace(message.Body)
+	if message.Subject == "" {
+		return CommitMessage{}, fmt.Errorf("the structured message has no subject")
+	}
+	return message, nil
+}
+
+// String renders the message as text, e.g. "feat(config): Add profiles" followed by a
+// blank line and the body. A subject the model already prefixed with its type is kept
+// as it is.
+func (m CommitMessage) String() string {
+	subject := m.Subject
+	if m.Type != "" && !typedSubject.MatchString(subject) {
+		prefix := m.Type
+		if m.Scope != "" {
+			prefix += "(" + m.Scope + ")"
+		}
+		subject = prefix + ": " + subject
+	}
+
+	if m.Body == "" {
+		return subject
+	}
+	return subject + "\n\n" + m.Body
+}
+
+// structuredText returns the text of a structured response, or the response itself
+// when it can't be read, as the model may have answered in plain text after all
+func structuredText(response string) string {
+	message, err := ParseCommitMessage(response)
+	if err != nil {
+		return response
+	}
+	return message.String()
+}
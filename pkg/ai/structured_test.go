@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseCommitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     CommitMessage
+		text     string
+	}{
+		{
+			name:     "all fields",
+			response: `{"type": "feat", "scope": "config", "subject": "Add profiles", "body": "- Read [profiles.NAME] tables\n- Select one with --profile"}`,
+			want:     CommitMessage{Type: "feat", Scope: "config", Subject: "Add profiles", Body: "- Read [profiles.NAME] tables\n- Select one with --profile"},
+			text:     "feat(config): Add profiles\n\n- Read [profiles.NAME] tables\n- Select one with --profile",
+		},
+		{
+			name:     "no scope or body",
+			response: `{"type": "fix", "scope": "", "subject": "Handle empty diffs", "body": ""}`,
+			want:     CommitMessage{Type: "fix", Subject: "Handle empty diffs"},
+			text:     "fix: Handle empty diffs",
+		},
+		{
+			name:     "no type",
+			response: `{"type": "", "scope": "", "subject": "Update the README", "body": ""}`,
+			want:     CommitMessage{Subject: "Update the README"},
+			text:     "Update the README",
+		},
+		{
+			name:     "subject already has its type",
+			response: `{"type": "docs", "scope": "readme", "subject": "docs(readme): Describe setup", "body": ""}`,
+			want:     CommitMessage{Type: "docs", Scope: "readme", Subject: "docs(readme): Describe setup"},
+			text:     "docs(readme): Describe setup",
+		},
+		{
+			name:     "fenced and padded",
+			response: "```json\n{\"type\": \" Feat \", \"subject\": \" Add --structured \", \"body\": \"\\nDetails\\n\"}\n```",
+			want:     CommitMessage{Type: "feat", Subject: "Add --structured", Body: "Details"},
+			text:     "feat: Add --structured\n\nDetails",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := ParseCommitMessage(tt.response)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if message != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, message)
+			}
+			if message.String() != tt.text {
+				t.Errorf("Expected %q, got %q", tt.text, message.String())
+			}
+		})
+	}
+
+	for _, response := range []string{"feat: Add profiles", `{"type": "feat"`, `{"type": "feat", "subject": " "}`, `{"subject": 42}`} {
+		if _, err := ParseCommitMessage(response); err == nil {
+			t.Errorf("Expected an error for %q", response)
+		}
+	}
+
+	// A plain text answer is kept as it is
+	if text := structuredText("feat: Add profiles"); text != "feat: Add profiles" {
+		t.Errorf("Expected the text to be kept, got %q", text)
+	}
+}
+
+func TestSupportsStructuredOutput(t *testing.T) {
+	tests := []struct {
+		provider ProviderType
+		model    string
+		want     bool
+	}{
+		{ProviderOpenAI, "gpt-4o", true},
+		{ProviderOpenAI, "gpt-4o-mini", true},
+		{ProviderOpenAI, "gpt-4.1", true},
+		{ProviderOpenAI, "o3-mini", true},
+		{ProviderOpenAI, "gpt-4", false},
+		{ProviderOpenAI, "gpt-4-turbo", false},
+		{ProviderOpenAI, "gpt-3.5-turbo", false},
+		{ProviderOpenAI, "o1-mini", false},
+		{ProviderGemini, "gemini-1.5-pro", true},
+		{ProviderGemini, "gemini-1.0-pro", false},
+		{ProviderAnthropic, "claude-3-haiku-20240307", false},
+		{ProviderCustom, "llama3", false},
+		{ProviderBedrock, "anthropic.claude-3-haiku-20240307-v1:0", false},
+	}
+
+	for _, tt := range tests {
+		if got := SupportsStructuredOutput(tt.provider, tt.model); got != tt.want {
+			t.Errorf("SupportsStructuredOutput(%s, %s) = %v, want %v", tt.provider, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestCommitMessageSchema(t *testing.T) {
+	schema, err := json.Marshal(commitMessageSchema(false))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var decoded struct {
+		Type                 string                            `json:"type"`
+		Properties           map[string]map[string]interface{} `json:"properties"`
+		Required             []string                          `json:"required"`
+		AdditionalProperties *bool                             `json:"additionalProperties"`
+	}
+	json.Unmarshal(schema, &decoded)
+	if decoded.Type != "object" || len(decoded.Properties) != 4 || len(decoded.Required) != 4 {
+		t.Errorf("Expected an object with four required fields, got %s", schema)
+	}
+	if decoded.AdditionalProperties == nil || *decoded.AdditionalProperties {
+		t.Errorf("Expected additionalProperties to be false for strict mode, got %s", schema)
+	}
+
+	// Gemini takes OpenAPI type names and no additionalProperties
+	gemini := commitMessageSchema(true)
+	if gemini["type"] != "OBJECT" || gemini["additionalProperties"] != nil {
+		t.Errorf("Unexpected Gemini schema %v", gemini)
+	}
+}
@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// maxConcurrentSummaries bounds the number of file summary requests made at the same time
+const maxConcurrentSummaries = 4
+
+// fileSummarySystemPrompt is sent with every file summary request of SummarizeFiles
+const fileSummarySystemPrompt = "You summarize the changes to one file for someone writing a commit message. Reply with a single line and nothing else."
+
+// DefaultFileSummaryPrompt asks for a one-line summary of one file's diff. It is used
+// when there is no file_summary_prompt.txt, and can use the same named placeholders
+// as the user prompt; {{.Files}} is the file's path and {{.Diff}} its diff.
+const DefaultFileSummaryPrompt = `Summarize the following change to {{.Files}} in one line of at most 20 words.
+Describe what changed and, if the diff shows it, why. Don't repeat the file name.
+
+Diff:
+{{.Diff}}`
+
+// FileSummary is the model's one-line summary of the changes to one file
+type FileSummary struct {
+	Path    string
+	Summary string
+}
+
+// SummarizeFiles asks the model for a one-line summary of each file's diff, using the
+// named prompt template for every request. The requests run concurrently, at most
+// maxConcurrentSummaries at a time, and the summaries are returned in the order of
+// files. The first failed request cancels the others and is returned, since a
+// message written from some of the files would miss the rest.
+func SummarizeFiles(ctx context.Context, provider Provider, apiKey string, modelName string, files []git.FileDiff, prompt string) ([]FileSummary, error) {
+	if !IsNamedTemplate(prompt) {
+		return nil, fmt.Errorf("the file summary prompt must use named placeholders such as {{.Diff}}")
+	}
+	if _, err := ParseUserPrompt(prompt); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]FileSummary, len(files))
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(maxConcurrentSummaries)
+	for i, file := range files {
+		i, file := i, file
+		group.Go(func() error {
+			diffInfo := git.GitDiff{
+				StagedFiles:  []string{file.Path},
+				Diff:         file.Diff,
+				SystemPrompt: fileSummarySystemPrompt,
+				UserPrompt:   prompt,
+			}
+			response, err := provider.GenerateCommitMessage(groupCtx, apiKey, modelName, diffInfo)
+			if err != nil {
+				return fmt.Errorf("failed to summarize %s: %w", file.Path, err)
+			}
+			summaries[i] = FileSummary{Path: file.Path, Summary: firstLine(response)}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// FormatFileSummaries lists the summaries, one file per line, to stand in for the diff
+// when the commit message is written from them
+func FormatFileSummaries(summaries []FileSummary) string {
+	var builder strings.Builder
+	builder.WriteString("Instead of the diff, these are summaries of the changes to each file:\n")
+	for _, summary := range summaries {
+		text := summary.Summary
+		if text == "" {
+			text = "(no summary)"
+		}
+		fmt.Fprintf(&builder, "- %s: %s\n", summary.Path, text)
+	}
+	return builder.String()
+}
+
+// firstLine returns the first non-empty line of a response, without list markers
+func firstLine(response string) string {
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*"))
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
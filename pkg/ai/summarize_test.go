@@ -0,0 +1,100 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// summaryProvider answers each file summary request with a line naming the file,
+// failing for the files in fail, and records how many requests ran at once
+type summaryProvider struct {
+	mu      sync.Mutex
+	fail    map[string]bool
+	running int
+	peak    int
+}
+
+func (p *summaryProvider) GenerateCommitMessage(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff) (string, error) {
+	p.mu.Lock()
+	p.running++
+	if p.running > p.peak {
+		p.peak = p.running
+	}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	path := diffInfo.StagedFiles[0]
+	if p.fail[path] {
+		return "", errors.New("API error")
+	}
+	return "\n- Changed " + path + "\nSecond line", nil
+}
+
+func (p *summaryProvider) GenerateCommitMessageStream(ctx context.Context, apiKey string, modelName string, diffInfo git.GitDiff, out io.Writer) (string, error) {
+	return generateBlockingStream(ctx, p, apiKey, modelName, diffInfo, out)
+}
+
+func (p *summaryProvider) ValidateAPIKey(key string) bool { return true }
+func (p *summaryProvider) GetName() string                { return "summary" }
+func (p *summaryProvider) GetDefaultModel() string        { return "summary-model" }
+func (p *summaryProvider) GetAvailableModels() []string   { return []string{"summary-model"} }
+
+// TestSummarizeFiles tests summarizing each file with bounded parallelism, keeping
+// the order of the files
+func TestSummarizeFiles(t *testing.T) {
+	var files []git.FileDiff
+	for _, name := range []string{"a.go", "b.go", "c.go", "d.go", "e.go", "f.go", "g.go"} {
+		files = append(files, git.FileDiff{Path: name, Diff: "diff --git a/" + name + " b/" + name + "\n"})
+	}
+
+	provider := &summaryProvider{}
+	summaries, err := SummarizeFiles(context.Background(), provider, "key", "summary-model", files, DefaultFileSummaryPrompt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(summaries) != len(files) {
+		t.Fatalf("Expected %d summaries, got %d", len(files), len(summaries))
+	}
+	for i, summary := range summaries {
+		if summary.Path != files[i].Path || summary.Summary != "Changed "+files[i].Path {
+			t.Errorf("Unexpected summary %d: %+v", i, summary)
+		}
+	}
+	if provider.peak > maxConcurrentSummaries {
+		t.Errorf("Expected at most %d requests at once, got %d", maxConcurrentSummaries, provider.peak)
+	}
+
+	formatted := FormatFileSummaries(summaries[:2])
+	if !strings.Contains(formatted, "- a.go: Changed a.go\n- b.go: Changed b.go\n") {
+		t.Errorf("Unexpected formatted summaries:\n%s", formatted)
+	}
+
+	// One failed file fails the whole summary
+	provider = &summaryProvider{fail: map[string]bool{"c.go": true}}
+	if _, err := SummarizeFiles(context.Background(), provider, "key", "summary-model", files, DefaultFileSummaryPrompt); err == nil || !strings.Contains(err.Error(), "c.go") {
+		t.Errorf("Expected an error naming c.go, got %v", err)
+	}
+
+	// Positional and broken prompts are rejected before any request is sent
+	for _, prompt := range []string{"Summarize %s", "Summarize {{.Nope}}"} {
+		provider = &summaryProvider{}
+		if _, err := SummarizeFiles(context.Background(), provider, "key", "summary-model", files, prompt); err == nil {
+			t.Errorf("Expected an error for prompt %q", prompt)
+		}
+		if provider.peak != 0 {
+			t.Errorf("Expected no requests for prompt %q", prompt)
+		}
+	}
+}
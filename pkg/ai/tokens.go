@@ -0,0 +1,36 @@
+package ai
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// charsPerToken is the rough number of characters per token for English text and
+// code. It is close enough across the supported providers for a size warning.
+const charsPerToken = 4
+
+// EstimateTokens returns a rough token count for text using a chars/4 heuristic
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// EstimatePromptTokens returns a rough token count for the prompt that would be sent
+// for diffInfo. If the prompt templates are not loaded yet, or the user prompt can't be
+// rendered, only the diff content is counted.
+func EstimatePromptTokens(diffInfo git.GitDiff) int {
+	if diffInfo.UserPrompt != "" {
+		if userPrompt, err := FormatUserPrompt(diffInfo); err == nil {
+			return EstimateTokens(FormatSystemPrompt(diffInfo)) + EstimateTokens(userPrompt)
+		}
+	}
+
+	return EstimateTokens(diffInfo.Diff) +
+		EstimateTokens(strings.Join(diffInfo.StagedFiles, "\n")) +
+		EstimateTokens(diffInfo.Branch+diffInfo.JiraID+diffInfo.JiraDescription) +
+		EstimateTokens(FormatStyleExamples(diffInfo.StyleExamples))
+}
@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected int
+	}{
+		{text: "", expected: 0},
+		{text: "abc", expected: 1},
+		{text: "abcd", expected: 1},
+		{text: "abcde", expected: 2},
+		{text: strings.Repeat("x", 4000), expected: 1000},
+		// Multi-byte characters are counted once each
+		{text: "añadir", expected: 2},
+	}
+
+	for _, tc := range testCases {
+		if got := EstimateTokens(tc.text); got != tc.expected {
+			t.Errorf("EstimateTokens(%q) = %d, expected %d", tc.text, got, tc.expected)
+		}
+	}
+}
+
+func TestEstimatePromptTokens(t *testing.T) {
+	diffInfo := git.GitDiff{
+		StagedFiles: []string{"main.go"},
+		Diff:        strings.Repeat("+ line of code\n", 400),
+	}
+
+	// Without templates the diff dominates the estimate
+	contentOnly := EstimatePromptTokens(diffInfo)
+	if contentOnly < EstimateTokens(diffInfo.Diff) {
+		t.Errorf("Expected estimate of at least the diff size, got %d", contentOnly)
+	}
+
+	// With templates the formatted prompts are counted
+	diffInfo.SystemPrompt = strings.Repeat("Write good commit messages. ", 20)
+	diffInfo.UserPrompt = "Branch %s\nFiles %s\nDiff %s\nJira %s %s"
+	withTemplates := EstimatePromptTokens(diffInfo)
+	if withTemplates <= contentOnly {
+		t.Errorf("Expected templates to increase the estimate, got %d (content only %d)", withTemplates, contentOnly)
+	}
+}
@@ -0,0 +1,41 @@
+package ai
+
+import "sync"
+
+// Usage is the number of tokens a provider reported for one generation
+type Usage struct {
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+}
+
+// UsageHandler receives the token usage of every successful generation
+type UsageHandler func(usage Usage)
+
+var (
+	usageMu      sync.Mutex
+	usageHandler UsageHandler
+)
+
+// SetUsageHandler sets the function called with the token usage of every
+// successful generation. A nil handler turns usage reporting off.
+func SetUsageHandler(handler UsageHandler) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	usageHandler = handler
+}
+
+// reportUsage passes the usage to the handler. Calls are serialized because
+// candidates are generated concurrently. Responses without usage are ignored.
+func reportUsage(usage Usage) {
+	if usage.InputTokens == 0 && usage.OutputTokens == 0 {
+		return
+	}
+
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	if usageHandler != nil {
+		usageHandler(usage)
+	}
+}
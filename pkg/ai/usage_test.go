@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// recordUsage installs a usage handler that collects the reported usage
+func recordUsage(t *testing.T) *[]Usage {
+	var reported []Usage
+	SetUsageHandler(func(usage Usage) {
+		reported = append(reported, usage)
+	})
+	t.Cleanup(func() { SetUsageHandler(nil) })
+	return &reported
+}
+
+// mockResponse makes every request return the given status and body
+func mockResponse(t *testing.T, body string, check func(req *http.Request)) {
+	mockDoFunc = func(req *http.Request) (*http.Response, error) {
+		if check != nil {
+			check(req)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+	}
+	t.Cleanup(func() { mockDoFunc = nil })
+}
+
+var usageTestDiff = git.GitDiff{SystemPrompt: "Generate a commit message.", UserPrompt: "Here is the diff: %s"}
+
+func TestUsageAnthropic(t *testing.T) {
+	reported := recordUsage(t)
+	mockResponse(t, `{"content": [{"text": "fix: a"}], "usage": {"input_tokens": 120, "output_tokens": 8}}`, nil)
+
+	if _, err := NewAnthropicProvider().GenerateCommitMessage(context.Background(), "sk-ant-test", "claude-3-haiku-20240307", usageTestDiff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	expected := Usage{Provider: "anthropic", Model: "claude-3-haiku-20240307", InputTokens: 120, OutputTokens: 8}
+	if len(*reported) != 1 || (*reported)[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *reported)
+	}
+}
+
+func TestUsageAnthropicStream(t *testing.T) {
+	reported := recordUsage(t)
+	mockResponse(t, "data: {\"type\": \"message_start\", \"message\": {\"usage\": {\"input_tokens\": 90}}}\n\n"+
+		"data: {\"type\": \"content_block_delta\", \"delta\": {\"text\": \"fix: b\"}}\n\n"+
+		"data: {\"type\": \"message_delta\", \"usage\": {\"output_tokens\": 6}}\n\n", nil)
+
+	var out bytes.Buffer
+	if _, err := NewAnthropicProvider().GenerateCommitMessageStream(context.Background(), "sk-ant-test", "claude-3-haiku-20240307", usageTestDiff, &out); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	if len(*reported) != 1 || (*reported)[0].InputTokens != 90 || (*reported)[0].OutputTokens != 6 {
+		t.Errorf("Expected 90 input and 6 output tokens, got %+v", *reported)
+	}
+}
+
+func TestUsageOpenAIStream(t *testing.T) {
+	reported := recordUsage(t)
+	mockResponse(t, "data: {\"choices\": [{\"delta\": {\"content\": \"feat: c\"}}]}\n\n"+
+		"data: {\"choices\": [], \"usage\": {\"prompt_tokens\": 70, \"completion_tokens\": 4}}\n\n"+
+		"data: [DONE]\n\n", func(req *http.Request) {
+		var request OpenAIRequest
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &request)
+		if request.StreamOptions == nil || !request.StreamOptions.IncludeUsage {
+			t.Errorf("Expected the stream request to ask for usage")
+		}
+	})
+
+	var out bytes.Buffer
+	if _, err := NewOpenAIProvider().GenerateCommitMessageStream(context.Background(), "sk-test", "gpt-4o", usageTestDiff, &out); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+
+	expected := Usage{Provider: "openai", Model: "gpt-4o", InputTokens: 70, OutputTokens: 4}
+	if len(*reported) != 1 || (*reported)[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *reported)
+	}
+}
+
+func TestUsageMissing(t *testing.T) {
+	reported := recordUsage(t)
+	mockResponse(t, `{"choices": [{"message": {"content": "feat: d"}}]}`, nil)
+
+	if _, err := NewOpenAIProvider().GenerateCommitMessage(context.Background(), "sk-test", "gpt-4o", usageTestDiff); err != nil {
+		t.Fatalf("Expected no error, got '%s'", err.Error())
+	}
+	if len(*reported) != 0 {
+		t.Errorf("Expected no usage to be reported without a usage block, got %+v", *reported)
+	}
+}
@@ -0,0 +1,61 @@
+// Package clipboard copies text to the system clipboard with the platform's
+// command-line tools: pbcopy on macOS, clip on Windows and wl-copy, xclip or xsel on Linux
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when none of the platform's clipboard tools is installed
+var ErrUnavailable = errors.New("no clipboard tool found")
+
+// tool is a command that reads the text to copy from stdin
+type tool struct {
+	name string
+	args []string
+}
+
+// tools returns the clipboard commands to try on an OS, in order. On Linux wl-copy
+// comes first under Wayland, where xclip may only reach the X11 clipboard.
+func tools(goos string, wayland bool) []tool {
+	switch goos {
+	case "darwin":
+		return []tool{{name: "pbcopy"}}
+	case "windows":
+		return []tool{{name: "clip"}}
+	}
+
+	x11 := []tool{
+		{name: "xclip", args: []string{"-selection", "clipboard"}},
+		{name: "xsel", args: []string{"--clipboard", "--input"}},
+	}
+	wlCopy := tool{name: "wl-copy"}
+	if wayland {
+		return append([]tool{wlCopy}, x11...)
+	}
+	return append(x11, wlCopy)
+}
+
+// Copy puts text on the clipboard with the first available tool. It returns
+// ErrUnavailable if there is none, so the caller can show the text instead.
+func Copy(text string) error {
+	for _, t := range tools(runtime.GOOS, os.Getenv("WAYLAND_DISPLAY") != "") {
+		path, err := exec.LookPath(t.name)
+		if err != nil {
+			continue
+		}
+
+		cmd := exec.Command(path, t.args...)
+		cmd.Stdin = strings.NewReader(text)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s failed: %v: %s", t.name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	return ErrUnavailable
+}
@@ -0,0 +1,78 @@
+package clipboard
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestTools tests the clipboard commands tried on each platform
+func TestTools(t *testing.T) {
+	names := func(tools []tool) []string {
+		var names []string
+		for _, t := range tools {
+			names = append(names, t.name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		goos    string
+		wayland bool
+		want    []string
+	}{
+		{"darwin", false, []string{"pbcopy"}},
+		{"windows", false, []string{"clip"}},
+		{"linux", false, []string{"xclip", "xsel", "wl-copy"}},
+		{"linux", true, []string{"wl-copy", "xclip", "xsel"}},
+	}
+
+	for _, tt := range tests {
+		got := names(tools(tt.goos, tt.wayland))
+		if len(got) != len(tt.want) {
+			t.Errorf("tools(%s, %v) = %v, want %v", tt.goos, tt.wayland, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("tools(%s, %v) = %v, want %v", tt.goos, tt.wayland, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// TestCopy tests copying with a stand-in xclip and the fallback without any tool
+func TestCopy(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses a shell script in place of xclip")
+	}
+
+	binDir := t.TempDir()
+	cat, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("cat is not available")
+	}
+	t.Setenv("PATH", binDir)
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	if err := Copy("Fix login redirect"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Expected ErrUnavailable without a clipboard tool, got %v", err)
+	}
+
+	copied := filepath.Join(t.TempDir(), "copied.txt")
+	script := "#!/bin/sh\n" + cat + " > " + copied + "\n"
+	if err := os.WriteFile(filepath.Join(binDir, "xclip"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write stand-in xclip: %v", err)
+	}
+
+	if err := Copy("Fix login redirect"); err != nil {
+		t.Fatalf("Expected the copy to succeed, got %v", err)
+	}
+	if content, _ := os.ReadFile(copied); string(content) != "Fix login redirect" {
+		t.Errorf("Expected the message on the clipboard, got %q", content)
+	}
+}
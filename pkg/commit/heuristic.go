@@ -0,0 +1,82 @@
+package commit
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// maxHeuristicFiles is the number of files HeuristicMessage lists in the body
+const maxHeuristicFiles = 10
+
+// genericDirs are directory names too broad to make a useful scope
+var genericDirs = map[string]bool{"src": true, "pkg": true, "internal": true, "lib": true, "cmd": true, "app": true}
+
+// HeuristicMessage writes a basic Conventional Commits message from local signals only,
+// for when no model can be asked: the change type, e.g. from git.InferChangeType, the
+// scope inferred from the files' common directory and the files themselves, e.g.
+// "chore(config): update 2 files" followed by the list of files. An empty change type
+// is written as "chore".
+func HeuristicMessage(changeType string, files []string) string {
+	verb := "update"
+	switch changeType {
+	case "feat":
+		verb = "add"
+	case "chore":
+		// InferChangeType only says chore when files were deleted
+		verb = "remove"
+	case "":
+		changeType = "chore"
+	}
+
+	subject := changeType
+	if scope := InferScope(files); scope != "" {
+		subject += "(" + scope + ")"
+	}
+	switch len(files) {
+	case 0:
+		return subject + ": " + verb + " files"
+	case 1:
+		return subject + ": " + verb + " " + path.Base(files[0])
+	}
+	subject += fmt.Sprintf(": %s %d files", verb, len(files))
+
+	var builder strings.Builder
+	builder.WriteString(subject + "\n\n")
+	for i, file := range files {
+		if i == maxHeuristicFiles {
+			fmt.Fprintf(&builder, "- and %d more\n", len(files)-maxHeuristicFiles)
+			break
+		}
+		builder.WriteString("- " + file + "\n")
+	}
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+// InferScope returns the name of the deepest directory containing all the files, e.g.
+// "config" for pkg/config/config.go and pkg/config/starter.go, or "" when the files
+// are spread across the repository or that directory is as broad as src or pkg
+func InferScope(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	common := strings.Split(path.Dir(files[0]), "/")
+	for _, file := range files[1:] {
+		dirs := strings.Split(path.Dir(file), "/")
+		n := 0
+		for n < len(common) && n < len(dirs) && common[n] == dirs[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	if len(common) == 0 {
+		return ""
+	}
+	scope := common[len(common)-1]
+	if scope == "." || genericDirs[scope] {
+		return ""
+	}
+	return scope
+}
@@ -0,0 +1,86 @@
+package commit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestHeuristicMessage tests writing a message from the change type and files alone
+func TestHeuristicMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		changeType string
+		files      []string
+		want       string
+	}{
+		{
+			"no type, shared directory",
+			"",
+			[]string{"pkg/config/config.go", "pkg/config/starter.go"},
+			"chore(config): update 2 files\n\n- pkg/config/config.go\n- pkg/config/starter.go",
+		},
+		{
+			"new file",
+			"feat",
+			[]string{"pkg/commit/heuristic.go"},
+			"feat(commit): add heuristic.go",
+		},
+		{
+			"deleted files at the root",
+			"chore",
+			[]string{"old.txt", "pkg/unused.go"},
+			"chore: remove 2 files\n\n- old.txt\n- pkg/unused.go",
+		},
+		{
+			"docs",
+			"docs",
+			[]string{"README.md"},
+			"docs: update README.md",
+		},
+		{
+			"no files",
+			"",
+			nil,
+			"chore: update files",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := HeuristicMessage(tt.changeType, tt.files); got != tt.want {
+			t.Errorf("%s: HeuristicMessage = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Long lists are cut short
+	var files []string
+	for i := 0; i < maxHeuristicFiles+3; i++ {
+		files = append(files, fmt.Sprintf("docs/page%d.md", i))
+	}
+	message := HeuristicMessage("docs", files)
+	if !strings.HasPrefix(message, "docs(docs): update 13 files\n\n") || !strings.HasSuffix(message, "- docs/page9.md\n- and 3 more") {
+		t.Errorf("Unexpected message for many files:\n%s", message)
+	}
+}
+
+// TestInferScope tests picking a scope from the files' common directory
+func TestInferScope(t *testing.T) {
+	tests := []struct {
+		files []string
+		want  string
+	}{
+		{[]string{"pkg/git/git.go"}, "git"},
+		{[]string{"pkg/git/git.go", "pkg/git/sub/runner.go"}, "git"},
+		{[]string{"pkg/git/git.go", "pkg/ai/ai.go"}, ""},
+		{[]string{"cmd/ai-commit-msg/main.go"}, "ai-commit-msg"},
+		{[]string{"main.go"}, ""},
+		{[]string{"web/app.js", "api/server.go"}, ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := InferScope(tt.files); got != tt.want {
+			t.Errorf("InferScope(%v) = %q, want %q", tt.files, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Package commit checks generated commit messages before they are committed
+package commit
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SubjectLine returns the first non-empty line of a commit message, trimmed
+func SubjectLine(message string) string {
+	for _, line := range strings.Split(message, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// CheckSubjectLength returns the length in characters of the message's subject line
+// when it is longer than max, or 0 when it fits. A max of 0 or less disables the check.
+func CheckSubjectLength(message string, max int) int {
+	if max <= 0 {
+		return 0
+	}
+	if length := utf8.RuneCountInString(SubjectLine(message)); length > max {
+		return length
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package commit
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCheckSubjectLength tests flagging subject lines over the limit
+func TestCheckSubjectLength(t *testing.T) {
+	long := strings.Repeat("a", 80)
+	tests := []struct {
+		name    string
+		message string
+		max     int
+		want    int
+	}{
+		{"fits", "Fix login redirect", 72, 0},
+		{"exactly at the limit", strings.Repeat("a", 72), 72, 0},
+		{"too long", long, 72, 80},
+		{"only the subject counts", "Fix login redirect\n\n- " + long, 72, 0},
+		{"leading blank lines are skipped", "\n\n" + long + "\n\nBody", 72, 80},
+		{"characters, not bytes", strings.Repeat("é", 72), 72, 0},
+		{"no limit", long, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := CheckSubjectLength(tt.message, tt.max); got != tt.want {
+			t.Errorf("%s: CheckSubjectLength = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
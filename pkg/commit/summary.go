@@ -0,0 +1,73 @@
+package commit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// conventionalSubject matches a Conventional Commits subject such as
+// "feat(parser)!: accept empty input", capturing the type, scope and breaking marker
+var conventionalSubject = regexp.MustCompile(`^([a-z]+)(?:\(([^()\s]+)\))?(!)?: \S`)
+
+// Summary describes the shape of a commit message, as a quick check before committing
+type Summary struct {
+	SubjectLength int    // In characters
+	Conventional  bool   // Whether the subject follows the Conventional Commits format
+	Type          string // Conventional type, e.g. feat or fix
+	Scope         string // Conventional scope, empty if there is none
+	Breaking      bool   // Whether the subject marks a breaking change with "!"
+	BodyLines     int    // Non-empty lines after the subject line
+}
+
+// Summarize returns the summary of a commit message
+func Summarize(message string) Summary {
+	subject := SubjectLine(message)
+	summary := Summary{SubjectLength: utf8.RuneCountInString(subject)}
+
+	if match := conventionalSubject.FindStringSubmatch(subject); match != nil {
+		summary.Conventional = true
+		summary.Type = match[1]
+		summary.Scope = match[2]
+		summary.Breaking = match[3] != ""
+	}
+
+	afterSubject := false
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if afterSubject {
+			summary.BodyLines++
+		}
+		afterSubject = true
+	}
+	return summary
+}
+
+// String renders the summary on one line, e.g.
+// "48-char subject, conventional feat(parser), 3 body lines"
+func (s Summary) String() string {
+	format := "not conventional"
+	if s.Conventional {
+		format = "conventional " + s.Type
+		if s.Scope != "" {
+			format += "(" + s.Scope + ")"
+		}
+		if s.Breaking {
+			format += "!, breaking"
+		}
+	}
+
+	body := "no body"
+	switch {
+	case s.BodyLines == 1:
+		body = "1 body line"
+	case s.BodyLines > 1:
+		body = fmt.Sprintf("%d body lines", s.BodyLines)
+	}
+
+	return fmt.Sprintf("%d-char subject, %s, %s", s.SubjectLength, format, body)
+}
@@ -0,0 +1,48 @@
+package commit
+
+import "testing"
+
+// TestSummarize tests describing the shape of a message
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    Summary
+		text    string
+	}{
+		{
+			"plain subject",
+			"Fix login redirect",
+			Summary{SubjectLength: 18},
+			"18-char subject, not conventional, no body",
+		},
+		{
+			"conventional with scope and body",
+			"feat(parser): accept empty input\n\n- Return an empty tree\n- Add a test\n",
+			Summary{SubjectLength: 32, Conventional: true, Type: "feat", Scope: "parser", BodyLines: 2},
+			"32-char subject, conventional feat(parser), 2 body lines",
+		},
+		{
+			"breaking change",
+			"fix!: drop the v1 endpoint\n\nClients must move to v2.",
+			Summary{SubjectLength: 26, Conventional: true, Type: "fix", Breaking: true, BodyLines: 1},
+			"26-char subject, conventional fix!, breaking, 1 body line",
+		},
+		{
+			"missing space after the colon",
+			"feat:accept empty input",
+			Summary{SubjectLength: 23},
+			"23-char subject, not conventional, no body",
+		},
+	}
+
+	for _, tt := range tests {
+		got := Summarize(tt.message)
+		if got != tt.want {
+			t.Errorf("%s: Summarize = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if text := got.String(); text != tt.text {
+			t.Errorf("%s: String = %q, want %q", tt.name, text, tt.text)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package completion
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Shells lists the shells a completion script can be generated for
+var Shells = []string{"bash", "zsh", "fish"}
+
+// Spec describes the command line to complete
+type Spec struct {
+	Program     string   // Name of the executable, e.g. "ai-commit-msg"
+	Subcommands []string // Subcommands such as "list-models"
+	SingleFlags []string // Flags that take no value
+	ParamFlags  []string // Flags that take a value
+	FileFlags   []string // Param flags whose value is a file path
+	Providers   []string // Completed after -p/--provider and list-models
+	Models      []string // Completed after -m/--model
+}
+
+// Generate returns the completion script for the given shell
+func Generate(shell string, spec Spec) (string, error) {
+	switch strings.ToLower(shell) {
+	case "bash":
+		return bashScript(spec), nil
+	case "zsh":
+		return zshScript(spec), nil
+	case "fish":
+		return fishScript(spec), nil
+	default:
+		return "", fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(Shells, ", "))
+	}
+}
+
+// functionName returns the shell function name for the program, e.g. "_ai_commit_msg"
+func functionName(program string) string {
+	return "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program)
+}
+
+// valueFlags returns the param flags that are neither file flags nor completed from a word list
+func valueFlags(spec Spec) []string {
+	skip := map[string]bool{"-p": true, "--provider": true, "-m": true, "--model": true}
+	for _, flag := range spec.FileFlags {
+		skip[flag] = true
+	}
+
+	var flags []string
+	for _, flag := range spec.ParamFlags {
+		if !skip[flag] {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+func bashScript(spec Spec) string {
+	fn := functionName(spec.Program)
+	flags := append(append([]string{}, spec.SingleFlags...), spec.ParamFlags...)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", spec.Program)
+	fmt.Fprintf(&b, "# Load with: source <(%s completion bash)\n\n", spec.Program)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur prev\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+	b.WriteString("    case \"$prev\" in\n")
+	fmt.Fprintf(&b, "        -p|--provider|list-models)\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n            return ;;\n", strings.Join(spec.Providers, " "))
+	fmt.Fprintf(&b, "        -m|--model)\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n            return ;;\n", strings.Join(spec.Models, " "))
+	fmt.Fprintf(&b, "        completion)\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n            return ;;\n", strings.Join(Shells, " "))
+	if len(spec.FileFlags) > 0 {
+		fmt.Fprintf(&b, "        %s)\n            COMPREPLY=($(compgen -f -- \"$cur\"))\n            return ;;\n", strings.Join(spec.FileFlags, "|"))
+	}
+	if others := valueFlags(spec); len(others) > 0 {
+		fmt.Fprintf(&b, "        %s)\n            return ;;\n", strings.Join(others, "|"))
+	}
+	b.WriteString("    esac\n\n")
+	b.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(flags, " "))
+	b.WriteString("    else\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(spec.Subcommands, " "))
+	b.WriteString("    fi\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", fn, spec.Program)
+	return b.String()
+}
+
+func zshScript(spec Spec) string {
+	fn := functionName(spec.Program)
+	flags := append(append([]string{}, spec.SingleFlags...), spec.ParamFlags...)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n", spec.Program)
+	fmt.Fprintf(&b, "# zsh completion for %s\n", spec.Program)
+	fmt.Fprintf(&b, "# Load with: source <(%s completion zsh)\n\n", spec.Program)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    case \"${words[CURRENT-1]}\" in\n")
+	fmt.Fprintf(&b, "        -p|--provider|list-models)\n            compadd -- %s\n            return ;;\n", strings.Join(spec.Providers, " "))
+	fmt.Fprintf(&b, "        -m|--model)\n            compadd -- %s\n            return ;;\n", strings.Join(spec.Models, " "))
+	fmt.Fprintf(&b, "        completion)\n            compadd -- %s\n            return ;;\n", strings.Join(Shells, " "))
+	if len(spec.FileFlags) > 0 {
+		fmt.Fprintf(&b, "        %s)\n            _files\n            return ;;\n", strings.Join(spec.FileFlags, "|"))
+	}
+	if others := valueFlags(spec); len(others) > 0 {
+		fmt.Fprintf(&b, "        %s)\n            return ;;\n", strings.Join(others, "|"))
+	}
+	b.WriteString("    esac\n\n")
+	b.WriteString("    if [[ \"$PREFIX\" == -* ]]; then\n")
+	fmt.Fprintf(&b, "        compadd -- %s\n", strings.Join(flags, " "))
+	b.WriteString("    else\n")
+	fmt.Fprintf(&b, "        compadd -- %s\n", strings.Join(spec.Subcommands, " "))
+	b.WriteString("    fi\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "compdef %s %s\n", fn, spec.Program)
+	return b.String()
+}
+
+// fishOption returns the fish option for a flag: -l for --long, -s for a single
+// character and -o for old-style multi-character flags such as -vv
+func fishOption(flag string) string {
+	switch {
+	case strings.HasPrefix(flag, "--"):
+		return "-l " + strings.TrimPrefix(flag, "--")
+	case len(flag) == 2:
+		return "-s " + strings.TrimPrefix(flag, "-")
+	default:
+		return "-o " + strings.TrimPrefix(flag, "-")
+	}
+}
+
+func fishScript(spec Spec) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", spec.Program)
+	fmt.Fprintf(&b, "# Load with: %s completion fish | source\n\n", spec.Program)
+	fmt.Fprintf(&b, "complete -c %s -f\n", spec.Program)
+	fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand -a %q\n", spec.Program, strings.Join(spec.Subcommands, " "))
+	fmt.Fprintf(&b, "complete -c %s -n \"__fish_seen_subcommand_from completion\" -a %q\n", spec.Program, strings.Join(Shells, " "))
+	fmt.Fprintf(&b, "complete -c %s -n \"__fish_seen_subcommand_from list-models\" -a %q\n", spec.Program, strings.Join(spec.Providers, " "))
+
+	fileFlags := make(map[string]bool)
+	for _, flag := range spec.FileFlags {
+		fileFlags[flag] = true
+	}
+
+	for _, flag := range spec.SingleFlags {
+		fmt.Fprintf(&b, "complete -c %s %s\n", spec.Program, fishOption(flag))
+	}
+	for _, flag := range spec.ParamFlags {
+		switch {
+		case flag == "-p" || flag == "--provider":
+			fmt.Fprintf(&b, "complete -c %s %s -x -a %q\n", spec.Program, fishOption(flag), strings.Join(spec.Providers, " "))
+		case flag == "-m" || flag == "--model":
+			fmt.Fprintf(&b, "complete -c %s %s -x -a %q\n", spec.Program, fishOption(flag), strings.Join(spec.Models, " "))
+		case fileFlags[flag]:
+			fmt.Fprintf(&b, "complete -c %s %s -r -F\n", spec.Program, fishOption(flag))
+		default:
+			fmt.Fprintf(&b, "complete -c %s %s -x\n", spec.Program, fishOption(flag))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package completion
+
+import (
+	"strings"
+	"testing"
+)
+
+func testSpec() Spec {
+	return Spec{
+		Program:     "ai-commit-msg",
+		Subcommands: []string{"list-models", "completion"},
+		SingleFlags: []string{"--verbose", "-v", "-vv"},
+		ParamFlags:  []string{"--model", "--provider", "--template", "-j", "-m", "-p"},
+		FileFlags:   []string{"--template"},
+		Providers:   []string{"anthropic", "openai"},
+		Models:      []string{"claude-3-haiku-20240307", "gpt-4o"},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		shell    string
+		expected []string
+	}{
+		{shell: "bash", expected: []string{
+			"complete -F _ai_commit_msg ai-commit-msg",
+			"-p|--provider|list-models)\n            COMPREPLY=($(compgen -W \"anthropic openai\"",
+			"-m|--model)\n            COMPREPLY=($(compgen -W \"claude-3-haiku-20240307 gpt-4o\"",
+			"--template)\n            COMPREPLY=($(compgen -f",
+			"--verbose -v -vv --model --provider --template -j -m -p",
+			"compgen -W \"list-models completion\"",
+		}},
+		{shell: "zsh", expected: []string{
+			"#compdef ai-commit-msg",
+			"compdef _ai_commit_msg ai-commit-msg",
+			"compadd -- anthropic openai",
+			"--template)\n            _files",
+		}},
+		{shell: "FISH", expected: []string{
+			"complete -c ai-commit-msg -n __fish_use_subcommand -a \"list-models completion\"",
+			"complete -c ai-commit-msg -l provider -x -a \"anthropic openai\"",
+			"complete -c ai-commit-msg -s m -x -a \"claude-3-haiku-20240307 gpt-4o\"",
+			"complete -c ai-commit-msg -l template -r -F",
+			"complete -c ai-commit-msg -o vv\n",
+			"complete -c ai-commit-msg -s j -x\n",
+		}},
+	}
+
+	for _, tc := range testCases {
+		script, err := Generate(tc.shell, testSpec())
+		if err != nil {
+			t.Fatalf("Generate(%q) returned error: %v", tc.shell, err)
+		}
+		for _, want := range tc.expected {
+			if !strings.Contains(script, want) {
+				t.Errorf("Generate(%q) missing %q in:\n%s", tc.shell, want, script)
+			}
+		}
+	}
+}
+
+func TestGenerateUnsupportedShell(t *testing.T) {
+	if _, err := Generate("powershell", testSpec()); err == nil {
+		t.Error("Expected an error for an unsupported shell")
+	}
+}
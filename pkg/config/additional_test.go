@@ -1,8 +1,18 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/nycjay/ai-commit-msg/pkg/ai"
+	"github.com/nycjay/ai-commit-msg/pkg/key"
 )
 
 // TestEnvironmentVariables tests loading configuration from environment variables
@@ -203,6 +213,81 @@ func TestProviderChangeWithModel(t *testing.T) {
 	}
 }
 
+// newTestConfig returns a config loaded from an empty config directory
+func newTestConfig(t *testing.T, configHome string) *Config {
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	cfg := &Config{
+		v:          viper.New(),
+		keyManager: key.NewKeyManager(false),
+	}
+	cfg.setDefaults()
+	if err := cfg.LoadConfig(); err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	return cfg
+}
+
+// TestModelFollowsProvider tests that switching providers doesn't reuse another provider's model
+func TestModelFollowsProvider(t *testing.T) {
+	configHome := t.TempDir()
+	cfg := newTestConfig(t, configHome)
+	cfg.SetModelName("claude-3-opus-20240229")
+
+	// Switching provider without --model uses that provider's default, not the Claude model
+	cfg.ParseCommandLineArgs([]string{"--provider", "openai"})
+	if model := cfg.GetProviderModel("openai"); model != "gpt-4o" {
+		t.Errorf("Expected the openai default model, got %s", model)
+	}
+
+	// --model applies to the selected provider wherever --provider appears
+	cfg.ParseCommandLineArgs([]string{"-m", "gpt-4o-mini", "-p", "openai"})
+	if model := cfg.GetProviderModel("openai"); model != "gpt-4o-mini" {
+		t.Errorf("Expected gpt-4o-mini for openai, got %s", model)
+	}
+	if model := cfg.GetProviderModel("anthropic"); model != "claude-3-opus-20240229" {
+		t.Errorf("Expected the anthropic model to be unchanged, got %s", model)
+	}
+
+	// Without --remember nothing is saved, so a new run starts from the defaults
+	cfg = newTestConfig(t, configHome)
+	if cfg.GetProvider() != "anthropic" || cfg.GetProviderModel("openai") != "gpt-4o" {
+		t.Errorf("Expected nothing to be remembered, got provider %s and openai model %s",
+			cfg.GetProvider(), cfg.GetProviderModel("openai"))
+	}
+}
+
+// TestModelFollowsProviderWithRemember tests that remembered models stay with their provider
+func TestModelFollowsProviderWithRemember(t *testing.T) {
+	configHome := t.TempDir()
+	cfg := newTestConfig(t, configHome)
+
+	cfg.ParseCommandLineArgs([]string{"-p", "anthropic", "-m", "claude-3-opus-20240229", "--remember"})
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("Error saving config: %v", err)
+	}
+	cfg.ParseCommandLineArgs([]string{"-p", "openai", "-m", "gpt-4o-mini", "--remember"})
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("Error saving config: %v", err)
+	}
+
+	cfg = newTestConfig(t, configHome)
+	if cfg.GetProvider() != "openai" {
+		t.Errorf("Expected the remembered provider openai, got %s", cfg.GetProvider())
+	}
+	if model := cfg.GetProviderModel("openai"); model != "gpt-4o-mini" {
+		t.Errorf("Expected the remembered openai model gpt-4o-mini, got %s", model)
+	}
+	if model := cfg.GetModelName(); model != "claude-3-opus-20240229" {
+		t.Errorf("Expected the legacy model to keep the Claude model, got %s", model)
+	}
+
+	// Switching back to anthropic without --model uses its own remembered model
+	cfg.ParseCommandLineArgs([]string{"-p", "anthropic"})
+	if model := cfg.GetProviderModel(cfg.GetProvider()); model != "claude-3-opus-20240229" {
+		t.Errorf("Expected the remembered anthropic model, got %s", model)
+	}
+}
+
 // TestJiraSettings tests setting and getting Jira ID and description
 func TestJiraSettings(t *testing.T) {
 	// Create a clean config
@@ -244,3 +329,548 @@ func TestVerbosityLevels(t *testing.T) {
 		}
 	}
 }
+
+// TestJiraPrefixesFromEnvironment tests loading Jira prefixes from a comma-separated environment variable
+func TestJiraPrefixesFromEnvironment(t *testing.T) {
+	origPrefixes := os.Getenv("AI_COMMIT_JIRA_PREFIXES")
+	defer os.Setenv("AI_COMMIT_JIRA_PREFIXES", origPrefixes)
+
+	os.Setenv("AI_COMMIT_JIRA_PREFIXES", "OPS,PLAT,WEB")
+
+	cfg := GetInstance()
+	cfg.LoadConfig()
+
+	prefixes := cfg.GetJiraPrefixes()
+	expected := []string{"OPS", "PLAT", "WEB"}
+	if len(prefixes) != len(expected) {
+		t.Fatalf("Expected prefixes %v, got %v", expected, prefixes)
+	}
+	for i, prefix := range expected {
+		if prefixes[i] != prefix {
+			t.Errorf("Expected prefix %s at position %d, got %s", prefix, i, prefixes[i])
+		}
+	}
+}
+
+// TestRequestTimeout tests the --timeout flag and the AI_COMMIT_TIMEOUT environment variable
+func TestRequestTimeout(t *testing.T) {
+	origTimeout := os.Getenv("AI_COMMIT_TIMEOUT")
+	defer os.Setenv("AI_COMMIT_TIMEOUT", origTimeout)
+
+	cfg := GetInstance()
+	defer cfg.SetRequestTimeout(ai.DefaultRequestTimeout)
+
+	os.Setenv("AI_COMMIT_TIMEOUT", "45s")
+	if err := cfg.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig returned error: %v", err)
+	}
+	if cfg.GetRequestTimeout() != 45*time.Second {
+		t.Errorf("Expected timeout from environment to be 45s, got %s", cfg.GetRequestTimeout())
+	}
+
+	if _, err := cfg.ParseCommandLineArgs([]string{"--timeout", "2m"}); err != nil {
+		t.Errorf("Unexpected error parsing a valid timeout: %v", err)
+	}
+	if cfg.GetRequestTimeout() != 2*time.Minute {
+		t.Errorf("Expected timeout from flag to be 2m, got %s", cfg.GetRequestTimeout())
+	}
+
+	// Invalid and non-positive durations are rejected and leave the value unchanged
+	for _, value := range []string{"soon", "0s", "-5s"} {
+		if _, err := cfg.ParseCommandLineArgs([]string{"--timeout", value}); err == nil {
+			t.Errorf("Expected an error for --timeout %s", value)
+		}
+		if cfg.GetRequestTimeout() != 2*time.Minute {
+			t.Errorf("Invalid timeout %s should not change the value, got %s", value, cfg.GetRequestTimeout())
+		}
+	}
+
+	// A non-positive value from the environment falls back to the default
+	os.Setenv("AI_COMMIT_TIMEOUT", "-1s")
+	if err := cfg.LoadConfig(); err == nil {
+		t.Errorf("Expected an error for a negative timeout in the environment")
+	}
+	if cfg.GetRequestTimeout() != ai.DefaultRequestTimeout {
+		t.Errorf("Expected fallback to the default timeout, got %s", cfg.GetRequestTimeout())
+	}
+}
+
+// TestCandidateTimeout tests the --timeout-per-candidate flag
+func TestCandidateTimeout(t *testing.T) {
+	cfg := newTestConfig(t, t.TempDir())
+
+	if cfg.GetCandidateTimeout() != 0 {
+		t.Errorf("Expected no candidate timeout by default, got %s", cfg.GetCandidateTimeout())
+	}
+
+	if _, err := cfg.ParseCommandLineArgs([]string{"--timeout-per-candidate", "20s"}); err != nil {
+		t.Errorf("Unexpected error parsing a valid timeout: %v", err)
+	}
+	if cfg.GetCandidateTimeout() != 20*time.Second {
+		t.Errorf("Expected candidate timeout from flag to be 20s, got %s", cfg.GetCandidateTimeout())
+	}
+
+	for _, value := range []string{"later", "0s", "-5s"} {
+		if _, err := cfg.ParseCommandLineArgs([]string{"--timeout-per-candidate", value}); err == nil {
+			t.Errorf("Expected an error for --timeout-per-candidate %s", value)
+		}
+	}
+}
+
+// TestCandidatesDeadline tests the --candidates-deadline flag
+func TestCandidatesDeadline(t *testing.T) {
+	cfg := newTestConfig(t, t.TempDir())
+
+	if cfg.GetCandidatesDeadline() != 0 {
+		t.Errorf("Expected no candidates deadline by default, got %s", cfg.GetCandidatesDeadline())
+	}
+
+	if _, err := cfg.ParseCommandLineArgs([]string{"--candidates-deadline", "2m"}); err != nil {
+		t.Errorf("Unexpected error parsing a valid deadline: %v", err)
+	}
+	if cfg.GetCandidatesDeadline() != 2*time.Minute {
+		t.Errorf("Expected candidates deadline from flag to be 2m, got %s", cfg.GetCandidatesDeadline())
+	}
+
+	for _, value := range []string{"later", "0s", "-5s"} {
+		if _, err := cfg.ParseCommandLineArgs([]string{"--candidates-deadline", value}); err == nil {
+			t.Errorf("Expected an error for --candidates-deadline %s", value)
+		}
+	}
+}
+
+// TestLoadProviderKey tests reading keys from --key-file and api_key_command
+func TestLoadProviderKey(t *testing.T) {
+	cfg := newTestConfig(t, t.TempDir())
+	t.Setenv("OPENAI_API_KEY", "sk-from-environment")
+
+	// Without a key file or command the environment is used as before
+	if err := cfg.LoadProviderKey("openai"); err != nil {
+		t.Fatalf("LoadProviderKey returned error: %v", err)
+	}
+	if key := cfg.GetProviderAPIKey("openai"); key != "sk-from-environment" {
+		t.Errorf("Expected the environment key, got %q", key)
+	}
+
+	// The command's trimmed output takes precedence over the environment
+	cfg.APIKeyCommands = map[string]string{"openai": "echo '  sk-from-command  '"}
+	if err := cfg.LoadProviderKey("openai"); err != nil {
+		t.Fatalf("LoadProviderKey returned error: %v", err)
+	}
+	if key := cfg.GetProviderAPIKey("openai"); key != "sk-from-command" {
+		t.Errorf("Expected the command's key, got %q", key)
+	}
+
+	// A failing or silent command is an error
+	cfg.ProviderKeys = nil
+	cfg.APIKeyCommands = map[string]string{"openai": "exit 3"}
+	if err := cfg.LoadProviderKey("openai"); err == nil {
+		t.Error("Expected an error from a failing command")
+	}
+	cfg.APIKeyCommands = map[string]string{"openai": "true"}
+	if err := cfg.LoadProviderKey("openai"); err == nil {
+		t.Error("Expected an error from a command that prints nothing")
+	}
+
+	// --key-file applies to the selected provider and --key wins over it
+	keyFile := t.TempDir() + "/key"
+	os.WriteFile(keyFile, []byte("sk-from-file\n"), 0600)
+	cfg.ParseCommandLineArgs([]string{"-p", "openai", "--key-file", keyFile})
+	cfg.ProviderKeys = nil
+	if err := cfg.LoadProviderKey("openai"); err != nil {
+		t.Fatalf("LoadProviderKey returned error: %v", err)
+	}
+	if key := cfg.GetProviderAPIKey("openai"); key != "sk-from-file" {
+		t.Errorf("Expected the key file's key, got %q", key)
+	}
+
+	cfg.ParseCommandLineArgs([]string{"-p", "openai", "--key-file", keyFile, "--key", "sk-from-command-line"})
+	if err := cfg.LoadProviderKey("openai"); err != nil {
+		t.Fatalf("LoadProviderKey returned error: %v", err)
+	}
+	if key := cfg.GetProviderAPIKey("openai"); key != "sk-from-command-line" {
+		t.Errorf("Expected the command-line key, got %q", key)
+	}
+}
+
+// TestFallbackProviders tests that fallback providers are read from the config file and saved back
+func TestFallbackProviders(t *testing.T) {
+	configHome := t.TempDir()
+	cfg := newTestConfig(t, configHome)
+	if providers := cfg.GetFallbackProviders(); len(providers) != 0 {
+		t.Errorf("Expected no fallback providers by default, got %v", providers)
+	}
+
+	configFile := filepath.Join(configHome, ConfigDirName, ConfigFileName+".toml")
+	os.MkdirAll(filepath.Dir(configFile), 0755)
+	content := "config_version = 1\nprovider = \"anthropic\"\nfallback_providers = [\"openai\", \"gemini\"]\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Could not write config: %v", err)
+	}
+
+	cfg = newTestConfig(t, configHome)
+	providers := cfg.GetFallbackProviders()
+	if len(providers) != 2 || providers[0] != "openai" || providers[1] != "gemini" {
+		t.Fatalf("Expected fallback providers [openai gemini], got %v", providers)
+	}
+
+	// The returned slice is a copy
+	providers[0] = "custom"
+	if cfg.GetFallbackProviders()[0] != "openai" {
+		t.Error("Modifying the returned providers changed the config")
+	}
+
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("Error saving config: %v", err)
+	}
+	cfg = newTestConfig(t, configHome)
+	if providers := cfg.GetFallbackProviders(); len(providers) != 2 || providers[1] != "gemini" {
+		t.Errorf("Expected fallback providers to survive a save, got %v", providers)
+	}
+}
+
+// TestSecretScanSettings tests that the secret scan is on by default and that extra
+// patterns are read from the config file and saved back
+func TestSecretScanSettings(t *testing.T) {
+	configHome := t.TempDir()
+	cfg := newTestConfig(t, configHome)
+	if !cfg.IsScanSecretsEnabled() || len(cfg.GetSecretPatterns()) != 0 {
+		t.Errorf("Expected the scan on with no extra patterns, got %v and %v", cfg.IsScanSecretsEnabled(), cfg.GetSecretPatterns())
+	}
+
+	configFile := filepath.Join(configHome, ConfigDirName, ConfigFileName+".toml")
+	os.MkdirAll(filepath.Dir(configFile), 0755)
+	content := "config_version = 1\nscan_secrets = false\nsecret_patterns = ['corp_[0-9]{6}']\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Could not write config: %v", err)
+	}
+
+	cfg = newTestConfig(t, configHome)
+	if cfg.IsScanSecretsEnabled() {
+		t.Error("Expected scan_secrets = false to turn the scan off")
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("Error saving config: %v", err)
+	}
+	cfg = newTestConfig(t, configHome)
+	if patterns := cfg.GetSecretPatterns(); len(patterns) != 1 || patterns[0] != "corp_[0-9]{6}" {
+		t.Errorf("Expected the secret patterns to survive a save, got %v", patterns)
+	}
+}
+
+// TestTypeTemplates tests that the [templates] table is read from the config file and saved back
+func TestTypeTemplates(t *testing.T) {
+	configHome := t.TempDir()
+	cfg := newTestConfig(t, configHome)
+	if templates := cfg.GetTypeTemplates(); len(templates) != 0 {
+		t.Errorf("Expected no templates by default, got %v", templates)
+	}
+
+	configFile := filepath.Join(configHome, ConfigDirName, ConfigFileName+".toml")
+	os.MkdirAll(filepath.Dir(configFile), 0755)
+	content := "config_version = 1\n\n[templates]\nDocs = \"/prompts/docs.txt\"\ndefault = \"/prompts/other.txt\"\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Could not write config: %v", err)
+	}
+
+	cfg = newTestConfig(t, configHome)
+	templates := cfg.GetTypeTemplates()
+	if len(templates) != 2 || templates["docs"] != "/prompts/docs.txt" || templates["default"] != "/prompts/other.txt" {
+		t.Fatalf("Expected the docs and default templates, got %v", templates)
+	}
+
+	// The returned map is a copy
+	templates["feat"] = "/prompts/feat.txt"
+	if _, ok := cfg.GetTypeTemplates()["feat"]; ok {
+		t.Error("Modifying the returned templates changed the config")
+	}
+
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("Error saving config: %v", err)
+	}
+	cfg = newTestConfig(t, configHome)
+	if templates := cfg.GetTypeTemplates(); templates["docs"] != "/prompts/docs.txt" {
+		t.Errorf("Expected the templates to survive a save, got %v", templates)
+	}
+}
+
+// TestPromptDirOverride tests that prompt_dir replaces the prompt directory and is searched
+// before the config directory
+func TestPromptDirOverride(t *testing.T) {
+	configHome := t.TempDir()
+	cfg := newTestConfig(t, configHome)
+	defaultDir := filepath.Join(configHome, ConfigDirName, "prompts")
+
+	sharedDir := t.TempDir()
+	cfg.ParseCommandLineArgs([]string{"--prompt-dir", sharedDir})
+	if promptDir, err := cfg.GetPromptDirectory(); err != nil || promptDir != sharedDir {
+		t.Fatalf("Expected prompt directory %s, got %s (%v)", sharedDir, promptDir, err)
+	}
+	if dirs := cfg.GetPromptSearchDirectories(); len(dirs) != 2 || dirs[0] != sharedDir || dirs[1] != defaultDir {
+		t.Errorf("Expected search directories [%s %s], got %v", sharedDir, defaultDir, dirs)
+	}
+
+	// The shared directory wins, and prompts missing from it come from the config directory
+	os.MkdirAll(filepath.Join(sharedDir, "openai"), 0755)
+	os.MkdirAll(defaultDir, 0755)
+	os.WriteFile(filepath.Join(sharedDir, "openai", "system_prompt.txt"), []byte("shared system"), 0644)
+	os.WriteFile(filepath.Join(defaultDir, "system_prompt.txt"), []byte("personal system"), 0644)
+	os.WriteFile(filepath.Join(defaultDir, "user_prompt.txt"), []byte("personal user"), 0644)
+
+	if content, _, err := cfg.ReadProviderPrompt("openai", "system_prompt.txt"); err != nil || content != "shared system" {
+		t.Errorf("Expected the shared system prompt, got %q (%v)", content, err)
+	}
+	if content, _, err := cfg.ReadProviderPrompt("openai", "user_prompt.txt"); err != nil || content != "personal user" {
+		t.Errorf("Expected the user prompt from the config directory, got %q (%v)", content, err)
+	}
+
+	// A relative prompt_dir is resolved against the directory of the repository config
+	cfg.PromptDir = "tools/prompts"
+	cfg.repoConfigFile = filepath.Join(sharedDir, RepoConfigFileName)
+	if promptDir, _ := cfg.GetPromptDirectory(); promptDir != filepath.Join(sharedDir, "tools", "prompts") {
+		t.Errorf("Expected a prompt directory relative to the repository, got %s", promptDir)
+	}
+}
+
+// TestContextFile tests that --context-file is made absolute and a relative context_file
+// in a repository config is relative to the repository root
+func TestContextFile(t *testing.T) {
+	cfg := newTestConfig(t, t.TempDir())
+
+	cfg.ParseCommandLineArgs([]string{"--context-file", "docs/CONTEXT.md"})
+	wd, _ := os.Getwd()
+	if path := cfg.GetContextFile(); path != filepath.Join(wd, "docs", "CONTEXT.md") {
+		t.Errorf("Expected the context file relative to the working directory, got %s", path)
+	}
+
+	repoDir := t.TempDir()
+	cfg.SetContextFile("docs/CONTEXT.md")
+	cfg.repoConfigFile = filepath.Join(repoDir, RepoConfigFileName)
+	if path := cfg.GetContextFile(); path != filepath.Join(repoDir, "docs", "CONTEXT.md") {
+		t.Errorf("Expected the context file relative to the repository, got %s", path)
+	}
+}
+
+// TestProviderModelEnv tests choosing each provider's model with AI_COMMIT_<PROVIDER>_MODEL
+func TestProviderModelEnv(t *testing.T) {
+	tests := []struct {
+		provider string
+		envVar   string
+		model    string
+	}{
+		{"anthropic", "AI_COMMIT_ANTHROPIC_MODEL", "claude-3-5-sonnet-20240620"},
+		{"openai", "AI_COMMIT_OPENAI_MODEL", "gpt-4o-mini"},
+		{"gemini", "AI_COMMIT_GEMINI_MODEL", "gemini-1.5-flash"},
+		{"custom", "AI_COMMIT_CUSTOM_MODEL", "llama3"},
+		{"bedrock", "AI_COMMIT_BEDROCK_MODEL", "anthropic.claude-3-5-haiku-20241022-v1:0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			if envVar := ProviderModelEnvVar(tt.provider); envVar != tt.envVar {
+				t.Errorf("Expected %s, got %s", tt.envVar, envVar)
+			}
+			t.Setenv(tt.envVar, tt.model)
+
+			cfg := newTestConfig(t, t.TempDir())
+			if model := cfg.GetProviderModel(tt.provider); model != tt.model {
+				t.Errorf("Expected %s from %s, got %s", tt.model, tt.envVar, model)
+			}
+
+			// --model still wins over the environment
+			cfg.ParseCommandLineArgs([]string{"-p", tt.provider, "-m", "from-flag"})
+			if model := cfg.GetProviderModel(tt.provider); model != "from-flag" {
+				t.Errorf("Expected --model to win, got %s", model)
+			}
+		})
+	}
+
+	// The provider-specific variable wins over the legacy one for anthropic
+	t.Setenv("AI_COMMIT_MODEL_NAME", "claude-3-opus-20240229")
+	t.Setenv("AI_COMMIT_ANTHROPIC_MODEL", "claude-3-5-sonnet-20240620")
+	cfg := newTestConfig(t, t.TempDir())
+	if model := cfg.GetProviderModel("anthropic"); model != "claude-3-5-sonnet-20240620" {
+		t.Errorf("Expected AI_COMMIT_ANTHROPIC_MODEL to win, got %s", model)
+	}
+	if model := cfg.GetProviderModel("openai"); model != "gpt-4o" {
+		t.Errorf("Expected the other providers to keep their defaults, got %s", model)
+	}
+}
+
+// TestProfiles tests that a profile's settings are applied over the base config and
+// under the command-line flags
+func TestProfiles(t *testing.T) {
+	configHome := t.TempDir()
+	configDir := filepath.Join(configHome, "ai-commit-msg")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := `provider = "anthropic"
+style_examples = 3
+
+[profiles.work]
+provider = "openai"
+credential_namespace = "work"
+jira_prefixes = ["ACME"]
+
+[profiles.personal]
+provider = "gemini"
+`
+	if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AI_COMMIT_PROFILE", "")
+
+	load := func(profile string) (*Config, error) {
+		t.Setenv("XDG_CONFIG_HOME", configHome)
+		cfg := &Config{
+			v:          viper.New(),
+			keyManager: key.NewKeyManager(false),
+		}
+		cfg.setDefaults()
+		cfg.SetProfile(profile)
+		return cfg, cfg.LoadConfig()
+	}
+
+	// Without a profile the base config is used
+	cfg, err := load("")
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if provider := cfg.GetProvider(); provider != "anthropic" {
+		t.Errorf("Expected the base provider, got %s", provider)
+	}
+	if profiles := cfg.ListProfiles(); len(profiles) != 2 || profiles[0] != "personal" || profiles[1] != "work" {
+		t.Errorf("Expected profiles [personal work], got %v", profiles)
+	}
+
+	// The profile overrides the keys it sets and keeps the rest of the base
+	cfg, err = load("Work")
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if profile := cfg.GetProfile(); profile != "work" {
+		t.Errorf("Expected profile work, got %q", profile)
+	}
+	if provider := cfg.GetProvider(); provider != "openai" {
+		t.Errorf("Expected the profile's provider, got %s", provider)
+	}
+	if namespace := cfg.GetCredentialNamespace(); namespace != "work" {
+		t.Errorf("Expected the profile's credential namespace, got %s", namespace)
+	}
+	if prefixes := cfg.GetJiraPrefixes(); len(prefixes) != 1 || prefixes[0] != "ACME" {
+		t.Errorf("Expected the profile's Jira prefixes, got %v", prefixes)
+	}
+	if examples := cfg.GetStyleExamples(); examples != 3 {
+		t.Errorf("Expected the base style_examples, got %d", examples)
+	}
+
+	// Command-line flags still win over the profile
+	cfg.ParseCommandLineArgs([]string{"--profile", "work", "--provider", "gemini"})
+	if provider := cfg.GetProvider(); provider != "gemini" {
+		t.Errorf("Expected --provider to win over the profile, got %s", provider)
+	}
+
+	// Settings aren't saved while a profile is in use
+	if err := cfg.SaveConfig(); err == nil {
+		t.Error("Expected SaveConfig to fail with a profile in use")
+	}
+
+	// AI_COMMIT_PROFILE selects a profile too
+	t.Setenv("AI_COMMIT_PROFILE", "personal")
+	cfg, err = load("")
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if provider := cfg.GetProvider(); provider != "gemini" {
+		t.Errorf("Expected the provider from AI_COMMIT_PROFILE, got %s", provider)
+	}
+
+	// An unknown profile is an error, but the base config is still loaded
+	cfg, err = load("missing")
+	if !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("Expected ErrUnknownProfile, got %v", err)
+	}
+	if provider := cfg.GetProvider(); provider != "anthropic" {
+		t.Errorf("Expected the base provider with an unknown profile, got %s", provider)
+	}
+}
+
+func TestProfileFromArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-v", "--profile", "work"}, "work"},
+		{[]string{"--profile"}, ""},
+		{[]string{"-p", "openai"}, ""},
+	}
+	for _, tt := range tests {
+		if got := ProfileFromArgs(tt.args); got != tt.want {
+			t.Errorf("ProfileFromArgs(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestLastRunRoundTrip tests saving the options of a run and loading them for rerun,
+// with the flags given along with rerun taking precedence
+func TestLastRunRoundTrip(t *testing.T) {
+	configHome := t.TempDir()
+	cfg := newTestConfig(t, configHome)
+	configDir, err := cfg.GetConfigDirectory()
+	if err != nil {
+		t.Fatalf("GetConfigDirectory returned error: %v", err)
+	}
+
+	if _, err := LoadLastRun(configDir); !errors.Is(err, ErrNoLastRun) {
+		t.Errorf("Expected ErrNoLastRun before any run, got %v", err)
+	}
+
+	args := []string{"-p", "openai", "-k", "sk-secret", "-m", "gpt-4o-mini", "-c", "5", "-a", "-j", "GTN-123", "-d", "Fix the login"}
+	if err := SaveLastRun(configDir, args); err != nil {
+		t.Fatalf("SaveLastRun returned error: %v", err)
+	}
+
+	saved, err := LoadLastRun(configDir)
+	if err != nil {
+		t.Fatalf("LoadLastRun returned error: %v", err)
+	}
+	expected := []string{"--provider", "openai", "--model", "gpt-4o-mini", "--context", "5", "--jira", "GTN-123", "--jira-desc", "Fix the login"}
+	if !reflect.DeepEqual(saved, expected) {
+		t.Errorf("Expected %v, got %v", expected, saved)
+	}
+	if data, _ := os.ReadFile(filepath.Join(configDir, LastRunFileName)); strings.Contains(string(data), "sk-secret") {
+		t.Errorf("Expected the key not to be saved, got %s", data)
+	}
+
+	// Replaying the options restores them, and a flag given with rerun wins
+	cfg = newTestConfig(t, configHome)
+	if _, err := cfg.ParseCommandLineArgs(append(saved, "--jira", "GTN-456")); err != nil {
+		t.Fatalf("ParseCommandLineArgs returned error: %v", err)
+	}
+	if cfg.GetProvider() != "openai" || cfg.GetProviderModel("openai") != "gpt-4o-mini" || cfg.GetContextLines() != 5 {
+		t.Errorf("Expected the saved provider, model and context, got %s, %s and %d",
+			cfg.GetProvider(), cfg.GetProviderModel("openai"), cfg.GetContextLines())
+	}
+	if cfg.GetJiraID() != "GTN-456" || cfg.GetJiraDesc() != "Fix the login" {
+		t.Errorf("Expected the new Jira ID with the saved description, got %q and %q", cfg.GetJiraID(), cfg.GetJiraDesc())
+	}
+	if cfg.GetAutoCommit() {
+		t.Error("Expected --auto not to be repeated")
+	}
+	if kept := LastRunArgs([]string{"-v", "-ccc", "rerun"}); !reflect.DeepEqual(kept, []string{"-ccc"}) {
+		t.Errorf("Expected only the context level to be kept, got %v", kept)
+	}
+	if kept := LastRunArgs([]string{"--jira-url", "https://company.atlassian.net", "-S"}); !reflect.DeepEqual(kept, []string{"--jira-url", "https://company.atlassian.net"}) {
+		t.Errorf("Expected the Jira site to be kept, got %v", kept)
+	}
+	if kept := LastRunArgs([]string{"--no-jira", "-a"}); !reflect.DeepEqual(kept, []string{"--no-jira"}) {
+		t.Errorf("Expected --no-jira to be kept, got %v", kept)
+	}
+
+	// A broken file is reported rather than ignored
+	os.WriteFile(filepath.Join(configDir, LastRunFileName), []byte("{"), 0600)
+	if _, err := LoadLastRun(configDir); err == nil || errors.Is(err, ErrNoLastRun) {
+		t.Errorf("Expected an error for an invalid file, got %v", err)
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
 
+	"github.com/nycjay/ai-commit-msg/pkg/ai"
 	"github.com/nycjay/ai-commit-msg/pkg/key"
 )
 
@@ -23,6 +26,12 @@ const (
 
 	// EnvPrefix is the prefix for environment variables
 	EnvPrefix = "AI_COMMIT"
+
+	// DefaultMaxDiffBytes is the default size above which diffs are truncated
+	DefaultMaxDiffBytes = 100000
+
+	// DefaultAttributionTrailer is the trailer key --attribution adds to commits
+	DefaultAttributionTrailer = "AI-Generated-By"
 )
 
 // VerbosityLevel represents the level of verbosity for logging
@@ -44,24 +53,102 @@ const (
 // Config holds all the configuration for the application
 type Config struct {
 	// Configuration values stored in Viper
-	Verbosity         VerbosityLevel `mapstructure:"verbosity"`
-	ContextLines      int            `mapstructure:"context_lines"`
-	RememberFlags     bool           `mapstructure:"remember_flags"`
-	ModelName         string         `mapstructure:"model_name"`
-	SystemPromptPath  string         `mapstructure:"system_prompt_path"`
-	UserPromptPath    string         `mapstructure:"user_prompt_path"`
-	EnhancedContext   bool           `mapstructure:"enhanced_context"`
+	ConfigVersion       int            `mapstructure:"config_version"` // Format version, upgraded by migrate
+	Verbosity           VerbosityLevel `mapstructure:"verbosity"`
+	ContextLines        int            `mapstructure:"context_lines"`
+	RememberFlags       bool           `mapstructure:"remember_flags"`
+	ModelName           string         `mapstructure:"model_name"`
+	SystemPromptPath    string         `mapstructure:"system_prompt_path"`
+	SystemPromptPrefix  string         `mapstructure:"system_prompt_prefix"`
+	SystemPromptSuffix  string         `mapstructure:"system_prompt_suffix"`
+	UserPromptPath      string         `mapstructure:"user_prompt_path"`
+	PromptDir           string         `mapstructure:"prompt_dir"`
+	EnhancedContext     bool           `mapstructure:"enhanced_context"`
+	Stream              bool           `mapstructure:"stream"`
+	Body                bool           `mapstructure:"body"`
+	Signoff             bool           `mapstructure:"signoff"`
+	Attribution         bool           `mapstructure:"attribution"`
+	AttributionTrailer  string         `mapstructure:"attribution_trailer"`
+	CoAuthors           []string       `mapstructure:"co_authors"` // "Name <email>" of each regular co-author
+	EditAlways          bool           `mapstructure:"edit_always"`
+	StructuredOutput    bool           `mapstructure:"structured_output"`
+	UntrackedContext    bool           `mapstructure:"untracked_context"`
+	UnstagedContext     bool           `mapstructure:"unstaged_context"`
+	ScanSecrets         bool           `mapstructure:"scan_secrets"`
+	SecretPatterns      []string       `mapstructure:"secret_patterns"` // Regular expressions added to the secret scan's rules
+	JiraEnabled         bool           `mapstructure:"jira_enabled"`
+	JiraPrefixes        []string       `mapstructure:"jira_prefixes"`
+	JiraBaseURL         string         `mapstructure:"jira_base_url"` // e.g. https://company.atlassian.net, for links to tickets
+	StyleExamples       int            `mapstructure:"style_examples"`
+	RequestTimeout      time.Duration  `mapstructure:"timeout"`
+	CandidateTimeout    time.Duration  `mapstructure:"candidate_timeout"`
+	CandidatesDeadline  time.Duration  `mapstructure:"candidates_deadline"`
+	Language            string         `mapstructure:"language"`
+	MaxPromptTokens     int            `mapstructure:"max_prompt_tokens"`
+	MaxDiffBytes        int            `mapstructure:"max_diff_bytes"`
+	Candidates          int            `mapstructure:"candidates"`
+	PostGenerateHook    string         `mapstructure:"post_generate_hook"`
+	LogFile             string         `mapstructure:"log_file"`
+	LargeFileThreshold  int64          `mapstructure:"large_file_threshold"`
+	IssueStyle          string         `mapstructure:"issue_style"`
+	AllowUnknownModel   bool           `mapstructure:"allow_unknown_model"`
+	NotesRef            string         `mapstructure:"notes_ref"`
+	MaxSubjectLength    int            `mapstructure:"max_subject_length"`
+	CredentialNamespace string         `mapstructure:"credential_namespace"`
+	ContextFile         string         `mapstructure:"context_file"`
+	SignCommits         bool           `mapstructure:"sign_commits"`
+	SigningKey          string         `mapstructure:"signing_key"`
+	KeyBindings         map[string]string `mapstructure:"key_bindings"` // Per action, e.g. regenerate = "g"
+	TypeTemplates       map[string]string `mapstructure:"templates"` // User prompt template per change type, e.g. docs = "prompts/docs.txt"
 	
 	// Provider configuration
-	Provider         string               `mapstructure:"provider"`
-	ProviderModels   map[string]string    `mapstructure:"provider_models"`
+	Provider          string                        `mapstructure:"provider"`
+	ProviderModels    map[string]string             `mapstructure:"provider_models"`
+	ModelAliases      map[string]string             `mapstructure:"model_aliases"`
+	ModelPrices       map[string]map[string]float64 `mapstructure:"model_prices"`
+	CustomBaseURL     string                        `mapstructure:"custom_base_url"`
+	CustomModel       string                        `mapstructure:"custom_model"`
+	CustomRequireKey  bool                          `mapstructure:"custom_require_key"`
+	BedrockRegion     string                        `mapstructure:"bedrock_region"`
+	APIKeyCommands    map[string]string             `mapstructure:"api_key_command"` // Per provider, e.g. openai = "op read ..."
+	FallbackProviders []string                      `mapstructure:"fallback_providers"` // Tried in order when the provider is unavailable
 
 	// Runtime-only values (not saved to config)
-	APIKey        string `mapstructure:"-"` // Sensitive, stored in keychain
-	JiraID        string `mapstructure:"-"` // Command-line only
-	JiraDesc      string `mapstructure:"-"` // Command-line only
-	AutoCommit    bool   `mapstructure:"-"` // Command-line only
-	StoreKey      bool   `mapstructure:"-"` // Command-line only
+	APIKey         string   `mapstructure:"-"` // Sensitive, stored in keychain
+	JiraID         string   `mapstructure:"-"` // Command-line only
+	JiraDesc       string   `mapstructure:"-"` // Command-line only
+	IssueRef       string   `mapstructure:"-"` // Command-line only
+	AutoCommit     bool     `mapstructure:"-"` // Command-line only
+	StoreKey       bool     `mapstructure:"-"` // Command-line only
+	Amend          bool     `mapstructure:"-"` // Command-line only
+	DiffFile       string   `mapstructure:"-"` // Command-line only
+	Branch         string   `mapstructure:"-"` // Command-line only
+	JSONOutput     bool     `mapstructure:"-"` // Command-line only
+	StageAll       bool     `mapstructure:"-"` // Command-line only
+	Since          string   `mapstructure:"-"` // Command-line only
+	PRDescription  bool     `mapstructure:"-"` // Command-line only
+	Template       string   `mapstructure:"-"` // Command-line only
+	Only           []string `mapstructure:"-"` // Command-line only
+	Force          bool     `mapstructure:"-"` // Command-line only
+	StatOnly       bool     `mapstructure:"-"` // Command-line only
+	SummarizeFirst bool     `mapstructure:"-"` // Command-line only
+	Offline        bool     `mapstructure:"-"` // Command-line only
+	NoEmoji        bool     `mapstructure:"-"` // Command-line only
+	KeyFile        string   `mapstructure:"-"` // Command-line only
+	Quiet          bool     `mapstructure:"-"` // Command-line only
+	NoVerify       bool     `mapstructure:"-"` // Command-line only
+	CommitArgs     []string `mapstructure:"-"` // Command-line only
+	CoAuthorFlags  []string `mapstructure:"-"` // Command-line only, added to CoAuthors
+	Notes          bool     `mapstructure:"-"` // Command-line only
+	NonInteractive bool     `mapstructure:"-"` // Command-line only
+	GH             bool     `mapstructure:"-"` // Command-line only
+	Strict         bool     `mapstructure:"-"` // Command-line only
+	NoGPGSign      bool     `mapstructure:"-"` // Command-line only
+	Clipboard      bool     `mapstructure:"-"` // Command-line only
+	ShowPrompt     bool     `mapstructure:"-"` // Command-line only
+	ShowPromptOnly bool     `mapstructure:"-"` // Command-line only
+	Analyze        bool     `mapstructure:"-"` // Command-line only
+	Profile        string   `mapstructure:"-"` // From --profile or AI_COMMIT_PROFILE, applied by LoadConfig
 
 	
 	// Provider-specific API keys (runtime only, not saved to config)
@@ -70,6 +157,9 @@ type Config struct {
 	// Directory containing the executable (for finding default prompts)
 	executableDir string `mapstructure:"-"`
 
+	// Repository config file merged over the global config, if any
+	repoConfigFile string `mapstructure:"-"`
+
 	// KeyManager for handling API keys
 	keyManager *key.KeyManager
 	// Viper instance
@@ -104,6 +194,7 @@ func GetInstance() *Config {
 		instance = &Config{
 			v:          viper.New(),
 			keyManager: key.NewKeyManager(false), // Initially not verbose
+			JiraEnabled: true, // Until LoadConfig reads jira_enabled
 		}
 		// Set default values
 		instance.setDefaults()
@@ -119,15 +210,13 @@ func (c *Config) LoadConfig() error {
 	c.v.SetConfigName(ConfigFileName)
 	c.v.SetConfigType("toml") // Using TOML format for better readability
 
-	// Add config file search paths
+	// Add config file search paths. Per-repository settings live in a separate
+	// .ai-commit-msg.toml so they can't be mistaken for the global config.
 	configDir, err := c.getConfigDirectory()
 	if err == nil {
 		c.v.AddConfigPath(configDir)
 	}
 
-	// Also look in current directory for config
-	c.v.AddConfigPath(".")
-
 	// Set up environment variables
 	c.v.SetEnvPrefix(EnvPrefix)
 	c.v.AutomaticEnv()
@@ -142,13 +231,31 @@ func (c *Config) LoadConfig() error {
 		}
 	}
 
+	// Upgrade a config file written by an older version. If it can't be rewritten
+	// the old settings are still loaded and the error is reported at the end.
+	migrateErr := c.migrate()
+
+	// Repository settings override the global config
+	if err := c.mergeRepoConfig(); err != nil {
+		return err
+	}
+
+	// The selected profile overrides both. An unknown profile is reported at the end
+	// so the base config is still loaded.
+	profileErr := c.applyProfile()
+
 	// Unmarshal the config into the struct
 	if err := c.v.Unmarshal(c); err != nil {
 		return fmt.Errorf("unable to decode config: %w", err)
 	}
 
-	// Update the key manager's verbosity
+	// AutomaticEnv can't reach into the provider_models table, so the per-provider
+	// variables such as AI_COMMIT_OPENAI_MODEL are read explicitly
+	c.applyProviderModelEnv()
+
+	// Update the key manager's verbosity and the credential store entries it uses
 	c.keyManager.SetVerbose(c.Verbosity >= Verbose)
+	c.keyManager.SetNamespace(c.CredentialNamespace)
 
 	// Try to get API key from keychain or environment
 	apiKey, _ := c.keyManager.GetKey("")
@@ -156,7 +263,17 @@ func (c *Config) LoadConfig() error {
 		c.APIKey = apiKey
 	}
 
-	return nil
+	// A zero or negative timeout would disable or break every request
+	if c.RequestTimeout <= 0 {
+		invalid := c.RequestTimeout
+		c.RequestTimeout = ai.DefaultRequestTimeout
+		return fmt.Errorf("invalid timeout %s: must be a positive duration, using default %s", invalid, ai.DefaultRequestTimeout)
+	}
+
+	if profileErr != nil {
+		return profileErr
+	}
+	return migrateErr
 }
 
 // SaveConfig saves the current configuration to the config file
@@ -164,6 +281,12 @@ func (c *Config) SaveConfig() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// The profile's settings are merged into Viper, so writing the config would copy
+	// them into the base settings
+	if c.Profile != "" {
+		return fmt.Errorf("settings aren't saved while profile %q is in use; edit [profiles.%s] in the config file instead", c.Profile, c.Profile)
+	}
+
 	// Only remember flags if enabled
 	if !c.RememberFlags {
 		// Load the config again to get the original values
@@ -172,24 +295,80 @@ func (c *Config) SaveConfig() error {
 				return fmt.Errorf("error reading config file: %w", err)
 			}
 		}
+		if err := c.mergeRepoConfig(); err != nil {
+			return err
+		}
 	}
 
 	// Update Viper with current values for persistent settings only
 	// We only persist settings that make sense to reuse across multiple commits
 	// Transaction-specific settings are not persisted
-	c.v.Set("verbosity", c.Verbosity)
-	c.v.Set("context_lines", c.ContextLines)
-	c.v.Set("remember_flags", c.RememberFlags)
-	c.v.Set("model_name", c.ModelName)
-	c.v.Set("system_prompt_path", c.SystemPromptPath)
-	c.v.Set("user_prompt_path", c.UserPromptPath)
-	c.v.Set("enhanced_context", c.EnhancedContext)
+	settings := make(map[string]interface{})
+	set := func(key string, value interface{}) {
+		c.v.Set(key, value)
+		settings[key] = value
+	}
+	set("config_version", CurrentConfigVersion)
+	set("verbosity", c.Verbosity)
+	set("context_lines", c.ContextLines)
+	set("remember_flags", c.RememberFlags)
+	set("model_name", c.ModelName)
+	set("system_prompt_path", c.SystemPromptPath)
+	set("system_prompt_prefix", c.SystemPromptPrefix)
+	set("system_prompt_suffix", c.SystemPromptSuffix)
+	set("prompt_dir", c.PromptDir)
+	set("user_prompt_path", c.UserPromptPath)
+	set("enhanced_context", c.EnhancedContext)
+	set("stream", c.Stream)
+	set("body", c.Body)
+	set("signoff", c.Signoff)
+	set("attribution", c.Attribution)
+	set("attribution_trailer", c.AttributionTrailer)
+	set("co_authors", c.CoAuthors)
+	set("edit_always", c.EditAlways)
+	set("structured_output", c.StructuredOutput)
+	set("untracked_context", c.UntrackedContext)
+	set("unstaged_context", c.UnstagedContext)
+	set("scan_secrets", c.ScanSecrets)
+	set("secret_patterns", c.SecretPatterns)
+	set("jira_prefixes", c.JiraPrefixes)
+	set("jira_base_url", c.JiraBaseURL)
+	set("jira_enabled", c.JiraEnabled)
+	set("style_examples", c.StyleExamples)
+	set("language", c.Language)
+	set("max_prompt_tokens", c.MaxPromptTokens)
+	set("max_diff_bytes", c.MaxDiffBytes)
+	set("candidates", c.Candidates)
+	set("post_generate_hook", c.PostGenerateHook)
+	set("log_file", c.LogFile)
+	set("large_file_threshold", c.LargeFileThreshold)
+	set("issue_style", c.IssueStyle)
+	set("allow_unknown_model", c.AllowUnknownModel)
+	set("notes_ref", c.NotesRef)
+	set("max_subject_length", c.MaxSubjectLength)
+	set("credential_namespace", c.CredentialNamespace)
+	set("context_file", c.ContextFile)
+	set("sign_commits", c.SignCommits)
+	set("signing_key", c.SigningKey)
+	set("key_bindings", c.KeyBindings)
+	set("templates", c.TypeTemplates)
+	if c.RequestTimeout > 0 {
+		set("timeout", c.RequestTimeout.String())
+	}
+	set("candidate_timeout", c.CandidateTimeout.String())
+	set("candidates_deadline", c.CandidatesDeadline.String())
 	
 	// Provider-specific persistent settings
-	c.v.Set("provider", c.Provider)
-	c.v.Set("provider_models", c.ProviderModels)
-	
-	// Note: We no longer persist JiraPrefix as it will be hardcoded in the application
+	set("provider", c.Provider)
+	set("provider_models", c.ProviderModels)
+	set("model_aliases", c.ModelAliases)
+	set("model_prices", c.ModelPrices)
+	set("custom_base_url", c.CustomBaseURL)
+	set("custom_model", c.CustomModel)
+	set("custom_require_key", c.CustomRequireKey)
+	set("bedrock_region", c.BedrockRegion)
+	set("api_key_command", c.APIKeyCommands)
+	set("fallback_providers", c.FallbackProviders)
 	
 	// Note: We intentionally don't persist these transaction-specific parameters:
 	// - APIKey (sensitive data, stored in keychain instead)
@@ -197,6 +376,24 @@ func (c *Config) SaveConfig() error {
 	// - JiraDesc (specific to a single commit)
 	// - AutoCommit (potentially dangerous to always auto-commit)
 	// - StoreKey (one-time operation)
+	// - Amend (specific to a single commit)
+	// - DiffFile and Branch (specific to a single commit)
+	// - StageAll (stages files as a side effect, so it must be asked for each time)
+	// - Since, PRDescription and GH (specific to a single range of commits)
+	// - Template, Only, Force and StatOnly (specific to a single commit)
+	// - SummarizeFirst (costs a request per file, so it must be asked for each time)
+	// - Offline and NoEmoji (specific to a single run)
+	// - KeyFile and Quiet (specific to a single run)
+	// - NoVerify and CommitArgs (skipping hooks or changing the author must be asked for each time)
+	// - CoAuthorFlags (who is pairing changes from commit to commit; co_authors holds the regulars)
+	// - Notes (specific to a single commit)
+	// - NonInteractive (depends on where the command runs)
+	// - Strict (costs an extra request, so it must be asked for each time)
+	// - NoGPGSign (skipping a required signature must be asked for each time)
+	// - Clipboard (replaces committing, so it must be asked for each time)
+	// - ShowPrompt and ShowPromptOnly (for debugging prompts in a single run)
+	// - Analyze (replaces generating a message, so it must be asked for each time)
+	// - Profile (chosen per run; its settings live in its own table)
 
 	// Create config directory if it doesn't exist
 	configDir, err := c.getConfigDirectory()
@@ -210,9 +407,56 @@ func (c *Config) SaveConfig() error {
 
 	// Save the config file
 	configFile := filepath.Join(configDir, ConfigFileName+".toml")
+	if c.repoConfigFile != "" {
+		return c.saveWithRepoConfig(settings, configFile)
+	}
 	return c.v.WriteConfigAs(configFile)
 }
 
+// saveWithRepoConfig saves the settings inside a repository with its own config. Viper
+// holds the global and repository configs merged, so each file is read again on its
+// own: settings the repository file overrides are updated there when they changed, and
+// the rest go to the global config file, so personal settings such as api_key_command
+// or signing_key never end up in a file that is usually committed. The caller must
+// hold the lock.
+func (c *Config) saveWithRepoConfig(settings map[string]interface{}, configFile string) error {
+	repo := viper.New()
+	repo.SetConfigFile(c.repoConfigFile)
+	repo.SetConfigType("toml")
+	if err := repo.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading repository config file %s: %w", c.repoConfigFile, err)
+	}
+
+	global := viper.New()
+	global.SetConfigFile(configFile)
+	global.SetConfigType("toml")
+	if _, err := os.Stat(configFile); err == nil {
+		if err := global.ReadInConfig(); err != nil {
+			return fmt.Errorf("error reading config file: %w", err)
+		}
+	}
+
+	repoChanged := false
+	for key, value := range settings {
+		if !repo.IsSet(key) {
+			global.Set(key, value)
+			continue
+		}
+		// TOML reads numbers as int64 and lists as []interface{}, so compare as text
+		if fmt.Sprint(repo.Get(key)) != fmt.Sprint(value) {
+			repo.Set(key, value)
+			repoChanged = true
+		}
+	}
+
+	if repoChanged {
+		if err := repo.WriteConfigAs(c.repoConfigFile); err != nil {
+			return err
+		}
+	}
+	return global.WriteConfigAs(configFile)
+}
+
 // setDefaults sets the default values for the configuration
 func (c *Config) setDefaults() {
 	c.v.SetDefault("verbosity", Silent)
@@ -220,8 +464,47 @@ func (c *Config) setDefaults() {
 	c.v.SetDefault("remember_flags", false)
 	c.v.SetDefault("model_name", "claude-3-haiku-20240307") // Legacy default model
 	c.v.SetDefault("system_prompt_path", "")
+	c.v.SetDefault("system_prompt_prefix", "") // Text added before the system prompt
+	c.v.SetDefault("system_prompt_suffix", "") // Text added after the system prompt
 	c.v.SetDefault("user_prompt_path", "")
+	c.v.SetDefault("prompt_dir", "") // Empty means the prompts directory in the config directory
 	c.v.SetDefault("enhanced_context", false) // Enhanced context is disabled by default
+	c.v.SetDefault("stream", false)           // Wait for the full message by default
+	c.v.SetDefault("body", false)             // Let the prompt decide whether to include a body
+	c.v.SetDefault("signoff", false)          // No Signed-off-by trailer by default
+	c.v.SetDefault("attribution", false)      // Commits don't name the model by default
+	c.v.SetDefault("attribution_trailer", DefaultAttributionTrailer)
+	c.v.SetDefault("co_authors", []string{})  // No Co-authored-by trailers by default
+	c.v.SetDefault("edit_always", false)      // Ask before committing by default
+	c.v.SetDefault("structured_output", false) // Ask for the message as plain text by default
+	c.v.SetDefault("untracked_context", false) // Untracked files aren't mentioned by default
+	c.v.SetDefault("unstaged_context", false)  // Only the staged changes are sent by default
+	c.v.SetDefault("scan_secrets", true)       // Check diffs for secrets before sending them
+	c.v.SetDefault("secret_patterns", []string{}) // Only the built-in secret rules by default
+	c.v.SetDefault("jira_enabled", true)       // Look for Jira IDs and pass them to the prompt
+	c.v.SetDefault("jira_prefixes", []string{"GTN", "GTBUG", "TOOLS", "TASK"})
+	c.v.SetDefault("jira_base_url", "")      // No links to Jira tickets by default
+	c.v.SetDefault("style_examples", 0)      // No past commit messages in the prompt by default
+	c.v.SetDefault("timeout", ai.DefaultRequestTimeout.String())
+	c.v.SetDefault("candidate_timeout", "0s") // Each candidate gets the request timeout by default
+	c.v.SetDefault("candidates_deadline", "0s") // One candidate timeout per batch of candidates by default
+	c.v.SetDefault("language", "")          // Empty means English
+	c.v.SetDefault("max_prompt_tokens", 0)  // No prompt size limit by default
+	c.v.SetDefault("max_diff_bytes", DefaultMaxDiffBytes)
+	c.v.SetDefault("candidates", 1)         // A single suggested message by default
+	c.v.SetDefault("post_generate_hook", "") // No hook by default
+	c.v.SetDefault("log_file", "")          // Log to the terminal by default
+	c.v.SetDefault("large_file_threshold", 100*1024) // Files above 100KB are left out of enhanced context
+	c.v.SetDefault("issue_style", "jira")   // Detect Jira IDs (jira, gitlab or both)
+	c.v.SetDefault("allow_unknown_model", false) // Reject models the provider doesn't list
+	c.v.SetDefault("notes_ref", "")         // Empty means git's default, refs/notes/commits
+	c.v.SetDefault("max_subject_length", 72) // Conventional limit for subject lines
+	c.v.SetDefault("credential_namespace", "") // Keys are stored under the plain ai-commit-msg service
+	c.v.SetDefault("context_file", "")       // Project context is derived from the README by default
+	c.v.SetDefault("sign_commits", false)    // Follow git's commit.gpgsign by default
+	c.v.SetDefault("signing_key", "")        // Use git's user.signingkey by default
+	c.v.SetDefault("key_bindings", map[string]string{}) // Default keys for the choices after a message
+	c.v.SetDefault("templates", map[string]string{}) // The same user prompt for every change type
 	
 	// Provider configuration defaults
 	c.v.SetDefault("provider", "anthropic") // Default to Anthropic for backward compatibility
@@ -229,14 +512,21 @@ func (c *Config) setDefaults() {
 		"anthropic": "claude-3-haiku-20240307",
 		"openai":    "gpt-4o",
 		"gemini":    "gemini-1.5-pro",
+		"bedrock":   "anthropic.claude-3-haiku-20240307-v1:0",
 	})
+	c.v.SetDefault("model_aliases", map[string]string{}) // Short names for model IDs, e.g. sonnet
+	c.v.SetDefault("model_prices", map[string]map[string]float64{}) // Extra or updated prices for usage accounting
+	c.v.SetDefault("custom_base_url", "")    // OpenAI-compatible server for the custom provider
+	c.v.SetDefault("custom_model", "")       // Model to request from the custom server
+	c.v.SetDefault("custom_require_key", false) // Local servers usually don't need an API key
+	c.v.SetDefault("bedrock_region", "")     // Use the region from the AWS configuration
+	c.v.SetDefault("api_key_command", map[string]string{}) // Commands that print a provider's API key
+	c.v.SetDefault("fallback_providers", []string{}) // No fallback providers by default
 	
 	// Initialize runtime maps
 	if c.ProviderKeys == nil {
 		c.ProviderKeys = make(map[string]string)
 	}
-	
-	// Note: JiraPrefix is no longer configurable, it's hardcoded in pkg/git/jira.go
 }
 
 // getConfigDirectory returns the directory where the config file should be located
@@ -244,8 +534,25 @@ func (c *Config) getConfigDirectory() (string, error) {
 	return c.GetConfigDirectory()
 }
 
-// GetPromptDirectory returns the directory where custom prompt files should be located
+// GetPromptDirectory returns the directory where custom prompt files should be located:
+// prompt_dir or --prompt-dir if set, otherwise the prompts directory in the config directory.
+// A relative prompt_dir in a repository config file is relative to the repository root.
 func (c *Config) GetPromptDirectory() (string, error) {
+	c.mu.RLock()
+	promptDir, repoConfigFile := c.PromptDir, c.repoConfigFile
+	c.mu.RUnlock()
+
+	if promptDir == "" {
+		return c.GetDefaultPromptDirectory()
+	}
+	if !filepath.IsAbs(promptDir) && repoConfigFile != "" {
+		promptDir = filepath.Join(filepath.Dir(repoConfigFile), promptDir)
+	}
+	return promptDir, nil
+}
+
+// GetDefaultPromptDirectory returns the prompts directory in the config directory
+func (c *Config) GetDefaultPromptDirectory() (string, error) {
 	configDir, err := c.GetConfigDirectory()
 	if err != nil {
 		return "", err
@@ -253,90 +560,92 @@ func (c *Config) GetPromptDirectory() (string, error) {
 	return filepath.Join(configDir, "prompts"), nil
 }
 
-// GetConfigDirectory returns the directory where the config file should be located
-func (c *Config) GetConfigDirectory() (string, error) {
-	// Check XDG_CONFIG_HOME first, applicable to all platforms
-	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
-	if xdgConfigHome != "" {
-		return filepath.Join(xdgConfigHome, ConfigDirName), nil
+// GetPromptSearchDirectories returns the directories searched for custom prompt files, in
+// order: the prompt_dir override, if set, and then the prompts directory in the config directory
+func (c *Config) GetPromptSearchDirectories() []string {
+	var dirs []string
+	if promptDir, err := c.GetPromptDirectory(); err == nil {
+		dirs = append(dirs, promptDir)
 	}
-
-	// Detect operating system for platform-specific fallbacks
-	switch runtime.GOOS {
-	case "windows":
-		return c.getWindowsConfigDirectory()
-	case "darwin":
-		return c.getMacOSConfigDirectory()
-	case "linux":
-		return c.getLinuxConfigDirectory()
-	default:
-		return c.getLinuxConfigDirectory()
+	if defaultDir, err := c.GetDefaultPromptDirectory(); err == nil && (len(dirs) == 0 || dirs[0] != defaultDir) {
+		dirs = append(dirs, defaultDir)
 	}
+	return dirs
 }
 
-// getWindowsConfigDirectory returns the config directory for Windows
-func (c *Config) getWindowsConfigDirectory() (string, error) {
-	// Check environment variables in order of preference
-	appData := os.Getenv("APPDATA")
-	if appData != "" {
-		return filepath.Join(appData, ConfigDirName), nil
-	}
+// GetConfigDirectory returns the directory where the config file should be located.
+// Looking it up has no side effects; the directory is created when something is first
+// saved in it.
+func (c *Config) GetConfigDirectory() (string, error) {
+	return configLocator{
+		goos:   runtime.GOOS,
+		getenv: os.Getenv,
+		home:   homedir.Dir,
+		exists: dirExists,
+	}.configDirectory()
+}
 
-	// Fallback to user profile directory
-	home, err := homedir.Dir()
-	if err != nil {
-		return "", err
-	}
+// configLocator holds what the config directory is worked out from, so that the lookup
+// for every platform can be tested on any of them
+type configLocator struct {
+	goos   string
+	getenv func(key string) string
+	home   func() (string, error)
+	exists func(path string) bool
+}
 
-	// Try multiple Windows-specific paths
-	paths := []string{
-		filepath.Join(home, ".config", ConfigDirName),
-		filepath.Join(home, "AppData", "Local", ConfigDirName),
-		filepath.Join(home, "Application Data", ConfigDirName),
+// configDirectory returns, in order of precedence: $XDG_CONFIG_HOME/ai-commit-msg on
+// every platform, %APPDATA%\ai-commit-msg on Windows, the first of the platform's
+// candidate directories that already exists, and otherwise the platform's default
+func (l configLocator) configDirectory() (string, error) {
+	if xdgConfigHome := l.getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
+		return filepath.Join(xdgConfigHome, ConfigDirName), nil
 	}
-
-	for _, path := range paths {
-		// Check if the directory exists or can be created
-		if err := os.MkdirAll(path, 0755); err == nil {
-			return path, nil
+	if l.goos == "windows" {
+		if appData := l.getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, ConfigDirName), nil
 		}
 	}
 
-	// If all else fails, use a default in .config
-	return filepath.Join(home, ".config", ConfigDirName), nil
-}
-
-// getMacOSConfigDirectory returns the config directory for macOS
-func (c *Config) getMacOSConfigDirectory() (string, error) {
-	home, err := homedir.Dir()
+	home, err := l.home()
 	if err != nil {
 		return "", err
 	}
-
-	// Try macOS-specific paths
-	paths := []string{
-		filepath.Join(home, ".config", ConfigDirName),
-		filepath.Join(home, "Library", "Application Support", ConfigDirName),
-	}
-
-	for _, path := range paths {
-		// Check if the directory exists or can be created
-		if err := os.MkdirAll(path, 0755); err == nil {
-			return path, nil
+	candidates, fallback := configCandidates(l.goos, home)
+	for _, dir := range candidates {
+		if l.exists(dir) {
+			return dir, nil
 		}
 	}
-
-	// If all else fails, use .config
-	return filepath.Join(home, ".config", ConfigDirName), nil
+	return fallback, nil
 }
 
-// getLinuxConfigDirectory returns the config directory for Linux
-func (c *Config) getLinuxConfigDirectory() (string, error) {
-	home, err := homedir.Dir()
-	if err != nil {
-		return "", err
+// configCandidates returns the directories a config directory is looked for in on
+// goos, in order of precedence, and the one used when none of them exists yet
+func configCandidates(goos, home string) ([]string, string) {
+	dotConfig := filepath.Join(home, ".config", ConfigDirName)
+	switch goos {
+	case "windows":
+		return []string{
+			dotConfig,
+			filepath.Join(home, "AppData", "Local", ConfigDirName),
+			filepath.Join(home, "Application Data", ConfigDirName),
+		}, dotConfig
+	case "darwin":
+		// Library is where Mac applications keep their settings. Earlier versions
+		// created ~/.config/ai-commit-msg on every Mac, so it is still used when it
+		// is the only one that exists.
+		library := filepath.Join(home, "Library", "Application Support", ConfigDirName)
+		return []string{library, dotConfig}, library
+	default:
+		return nil, dotConfig
 	}
-	return filepath.Join(home, ".config", ConfigDirName), nil
+}
+
+// dirExists reports whether path is an existing directory
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
 }
 
 // GetVerbosity returns the current verbosity level
@@ -366,6 +675,95 @@ func (c *Config) GetProviderModels() map[string]string {
 	return modelsCopy
 }
 
+// GetModelAliases returns the map of model aliases to full model names
+func (c *Config) GetModelAliases() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Create a copy to prevent direct modification
+	aliasesCopy := make(map[string]string)
+	for k, v := range c.ModelAliases {
+		aliasesCopy[k] = v
+	}
+	return aliasesCopy
+}
+
+// GetKeyBindings returns the keys chosen for the actions offered after a message is
+// shown, keyed by action name, e.g. "regenerate" to "g"
+func (c *Config) GetKeyBindings() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Create a copy to prevent direct modification
+	bindingsCopy := make(map[string]string)
+	for k, v := range c.KeyBindings {
+		bindingsCopy[k] = v
+	}
+	return bindingsCopy
+}
+
+// GetTypeTemplates returns the user prompt templates chosen for change types, keyed by
+// type, e.g. "docs" to the path of the template used for documentation changes
+func (c *Config) GetTypeTemplates() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Create a copy to prevent direct modification
+	templatesCopy := make(map[string]string)
+	for k, v := range c.TypeTemplates {
+		templatesCopy[strings.ToLower(k)] = v
+	}
+	return templatesCopy
+}
+
+// SetTypeTemplates sets the user prompt templates chosen for change types
+func (c *Config) SetTypeTemplates(templates map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.TypeTemplates = templates
+}
+
+// GetModelPrices returns the configured model prices in US dollars per million
+// tokens, keyed by model name and then by "input" or "output"
+func (c *Config) GetModelPrices() map[string]map[string]float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Create a copy to prevent direct modification
+	pricesCopy := make(map[string]map[string]float64)
+	for model, price := range c.ModelPrices {
+		pricesCopy[model] = make(map[string]float64)
+		for k, v := range price {
+			pricesCopy[model][k] = v
+		}
+	}
+	return pricesCopy
+}
+
+// SetModelAliases replaces the map of model aliases to full model names
+func (c *Config) SetModelAliases(aliases map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ModelAliases = aliases
+}
+
+// ResolveModelAlias expands a model alias to the full model name.
+// Names that are not aliases are returned unchanged.
+func (c *Config) ResolveModelAlias(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.resolveModelAlias(name)
+}
+
+// resolveModelAlias expands a model alias; the caller must hold the lock
+func (c *Config) resolveModelAlias(name string) string {
+	// Viper lowercases map keys, so aliases are matched case-insensitively
+	if model, ok := c.ModelAliases[strings.ToLower(name)]; ok && model != "" {
+		return model
+	}
+	return name
+}
+
 // SetVerbosity sets the verbosity level
 func (c *Config) SetVerbosity(level VerbosityLevel) {
 	c.mu.Lock()
@@ -407,13 +805,64 @@ func (c *Config) SetAPIKey(key string) {
 	c.APIKey = key
 }
 
-// Note: JiraPrefix methods have been removed as Jira prefixes are now hardcoded in pkg/git/jira.go
+// GetJiraPrefixes returns the configured Jira project prefixes
+func (c *Config) GetJiraPrefixes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	// Create a copy to prevent direct modification
+	prefixes := make([]string, len(c.JiraPrefixes))
+	copy(prefixes, c.JiraPrefixes)
+	return prefixes
+}
+
+// SetJiraPrefixes sets the Jira project prefixes
+func (c *Config) SetJiraPrefixes(prefixes []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.JiraPrefixes = prefixes
+}
+
+// IsJiraEnabled returns whether Jira IDs are looked for and passed to the prompt
+func (c *Config) IsJiraEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.JiraEnabled
+}
+
+// SetJiraEnabled sets whether Jira IDs are looked for and passed to the prompt
+func (c *Config) SetJiraEnabled(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.JiraEnabled = enabled
+}
+
+// GetJiraBaseURL returns the Jira site that links to tickets are built from
+func (c *Config) GetJiraBaseURL() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.JiraBaseURL
+}
+
+// SetJiraBaseURL sets the Jira site that links to tickets are built from
+func (c *Config) SetJiraBaseURL(baseURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.JiraBaseURL = baseURL
+}
+
+// GetConfigVersion returns the format version of the loaded config file
+func (c *Config) GetConfigVersion() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ConfigVersion
+}
 
 // GetModelName returns the Claude model name
 func (c *Config) GetModelName() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.ModelName
+	return c.resolveModelAlias(c.ModelName)
 }
 
 // SetModelName sets the Claude model name
@@ -437,6 +886,20 @@ func (c *Config) SetSystemPromptPath(path string) {
 	c.SystemPromptPath = path
 }
 
+// GetSystemPromptPrefix returns the text added before the system prompt, or ""
+func (c *Config) GetSystemPromptPrefix() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.SystemPromptPrefix
+}
+
+// GetSystemPromptSuffix returns the text added after the system prompt, or ""
+func (c *Config) GetSystemPromptSuffix() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.SystemPromptSuffix
+}
+
 // GetUserPromptPath returns the custom user prompt path
 func (c *Config) GetUserPromptPath() string {
 	c.mu.RLock()
@@ -465,189 +928,1071 @@ func (c *Config) SetEnhancedContext(enabled bool) {
 	c.EnhancedContext = enabled
 }
 
-// StoreAPIKey stores the API key in the keychain
-func (c *Config) StoreAPIKey(key string) error {
-	return c.keyManager.StoreInKeychain(key)
+// GetStyleExamples returns the number of recent commit messages to include as style examples
+func (c *Config) GetStyleExamples() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StyleExamples
 }
 
-// ParseCommandLineArgs parses command line arguments into the config
-func (c *Config) ParseCommandLineArgs(args []string) ([]string, error) {
+// SetStyleExamples sets the number of recent commit messages to include as style examples
+func (c *Config) SetStyleExamples(count int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.StyleExamples = count
+}
 
-	// Reset runtime values
-	c.JiraID = ""
-	c.JiraDesc = ""
-	c.AutoCommit = false
-	c.StoreKey = false
-
-	// Known flags
-	knownSingleFlags := map[string]bool{
-		"-v": true, "--verbose": true,
-		"-vv": true,
-		"-vvv": true,
-		"-a": true, "--auto": true,
-		"-s": true, "--store-key": true,
-		"-h": true, "--help": true,
-		"-cc": true, // Medium context level
-		"-ccc": true, // Maximum context level with enhanced mode
-		"--remember": true, // Remember settings for future use
-	}
-
-	knownParamFlags := map[string]bool{
-		"-k": true, "--key": true,
-		"-j": true, "--jira": true,
-		"-d": true, "--jira-desc": true,
-		"-c": true, "--context": true,
-		"-m": true, "--model": true,
-		"-p": true, "--provider": true, // Select provider
-		"--system-prompt": true,
-		"--user-prompt": true,
-	}
-
-	// Collect any unknown flags
-	var unknownFlags []string
-
-	// Process all args
-	for i := 0; i < len(args); i++ {
-		arg := args[i]
-
-		// Check for flags that don't require values
-		if knownSingleFlags[arg] {
-			// Set appropriate flag
-			switch arg {
-			case "-v", "--verbose":
-				c.Verbosity = Verbose
-			case "-vv":
-				c.Verbosity = MoreVerbose
-			case "-vvv":
-				c.Verbosity = Debug
-			case "-a", "--auto":
-				c.AutoCommit = true
-			case "-s", "--store-key":
-				c.StoreKey = true
-			case "-cc":
-				c.ContextLines = 10 // Medium context
-			case "-ccc":
-				c.ContextLines = -1 // Signal for maximum context
-				c.EnhancedContext = true // Automatically enable enhanced context with -ccc
-			case "--remember":
-				c.RememberFlags = true
-			}
-			continue
-		}
-
-		// Check for flags that require values
-		if knownParamFlags[arg] && i+1 < len(args) {
-			// Set appropriate value
-			switch arg {
-			case "-k", "--key":
-				// Store key in both legacy field and provider-specific map
-				c.APIKey = args[i+1]
-				if c.Provider != "" {
-					// Also store in provider-specific map
-					if c.ProviderKeys == nil {
-						c.ProviderKeys = make(map[string]string)
-					}
-					c.ProviderKeys[c.Provider] = args[i+1]
-				}
-			case "-j", "--jira":
-				c.JiraID = args[i+1]
-			case "-d", "--jira-desc":
-				c.JiraDesc = args[i+1]
-			case "-c", "--context":
-				// Try to parse context lines as an integer
-				fmt.Sscanf(args[i+1], "%d", &c.ContextLines)
-			case "-m", "--model":
-				// Store model in both legacy field and provider-specific map
-				c.ModelName = args[i+1]
-				// If provider is set, also store in provider-specific map
-				if c.Provider != "" {
-					if c.ProviderModels == nil {
-						c.ProviderModels = make(map[string]string)
-					}
-					c.ProviderModels[c.Provider] = args[i+1]
-				}
-			case "-p", "--provider":
-				c.Provider = args[i+1]
-			case "--system-prompt":
-				c.SystemPromptPath = args[i+1]
-			case "--user-prompt":
-				c.UserPromptPath = args[i+1]
-			}
-			i++ // Skip the next argument since we've used it
-			continue
-		}
-
-		// Check for combined forms like -vah (verbose+auto+help)
-		if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && len(arg) > 2 {
-			// For combined flags like -vah, process each character
-			validCombined := true
-			for _, char := range arg[1:] {
-				flagChar := fmt.Sprintf("-%c", char)
-				if knownSingleFlags[flagChar] {
-					// Set appropriate flag
-					switch flagChar {
-					case "-v":
-						// In combined flags, treat -v as basic verbose
-						if c.Verbosity < Verbose {
-							c.Verbosity = Verbose
-						}
-					case "-a":
-						c.AutoCommit = true
-					case "-s":
-						c.StoreKey = true
-					}
-				} else if knownParamFlags[flagChar] {
-					// This is a flag that needs a parameter, which isn't valid in combined form
-					validCombined = false
-					break
-				} else {
-					validCombined = false
-					break
-				}
-			}
-
-			if validCombined {
-				continue
-			}
-		}
-
-		// If we get here, it's an unknown flag or parameter
-		if strings.HasPrefix(arg, "-") {
-			unknownFlags = append(unknownFlags, arg)
-		}
+// GetCandidates returns the number of candidate messages to generate (at least 1)
+func (c *Config) GetCandidates() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Candidates < 1 {
+		return 1
 	}
-
-	return unknownFlags, nil
+	return c.Candidates
 }
 
-// GetKeyManager returns the key manager instance
-func (c *Config) GetKeyManager() *key.KeyManager {
-	return c.keyManager
+// SetCandidates sets the number of candidate messages to generate
+func (c *Config) SetCandidates(count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Candidates = count
 }
 
-// IsRememberFlagsEnabled returns whether flag values should be remembered
-func (c *Config) IsRememberFlagsEnabled() bool {
+// GetMaxPromptTokens returns the estimated prompt size above which the user is warned (0 disables the check)
+func (c *Config) GetMaxPromptTokens() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.RememberFlags
+	return c.MaxPromptTokens
 }
 
-// SetRememberFlags sets whether flag values should be remembered
-func (c *Config) SetRememberFlags(remember bool) {
+// SetMaxPromptTokens sets the estimated prompt size above which the user is warned
+func (c *Config) SetMaxPromptTokens(tokens int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.RememberFlags = remember
+	c.MaxPromptTokens = tokens
 }
 
-// GetAutoCommit returns whether auto-commit is enabled
+// GetMaxDiffBytes returns the diff size above which the diff is truncated (0 disables truncation)
+func (c *Config) GetMaxDiffBytes() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.MaxDiffBytes
+}
+
+// SetMaxDiffBytes sets the diff size above which the diff is truncated
+func (c *Config) SetMaxDiffBytes(bytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.MaxDiffBytes = bytes
+}
+
+// GetLanguage returns the language to write commit messages in (empty for English)
+func (c *Config) GetLanguage() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Language
+}
+
+// SetLanguage sets the language to write commit messages in
+func (c *Config) SetLanguage(language string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Language = language
+}
+
+// GetLargeFileThreshold returns the size in bytes above which file contents are left
+// out of the enhanced context
+func (c *Config) GetLargeFileThreshold() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.LargeFileThreshold
+}
+
+// SetLargeFileThreshold sets the size in bytes above which file contents are left
+// out of the enhanced context
+func (c *Config) SetLargeFileThreshold(bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.LargeFileThreshold = bytes
+}
+
+// GetLogFile returns the file log output is written to, or "" for the terminal
+func (c *Config) GetLogFile() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.LogFile
+}
+
+// SetLogFile sets the file log output is written to
+func (c *Config) SetLogFile(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.LogFile = path
+}
+
+// GetPostGenerateHook returns the executable that post-processes generated messages
+func (c *Config) GetPostGenerateHook() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PostGenerateHook
+}
+
+// SetPostGenerateHook sets the executable that post-processes generated messages
+func (c *Config) SetPostGenerateHook(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.PostGenerateHook = path
+}
+
+// GetRequestTimeout returns the timeout for requests to the LLM provider
+func (c *Config) GetRequestTimeout() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.RequestTimeout
+}
+
+// SetRequestTimeout sets the timeout for requests to the LLM provider
+func (c *Config) SetRequestTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.RequestTimeout = timeout
+}
+
+// GetCandidateTimeout returns the time allowed for each candidate request with
+// --candidates, or 0 to use the request timeout
+func (c *Config) GetCandidateTimeout() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CandidateTimeout
+}
+
+// GetCandidatesDeadline returns the time allowed for the whole set of candidates with
+// --candidates, or 0 for one candidate timeout per batch of concurrent requests
+func (c *Config) GetCandidatesDeadline() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CandidatesDeadline
+}
+
+// IsStreamEnabled returns whether the generated message should be streamed to the terminal
+func (c *Config) IsStreamEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Stream
+}
+
+// SetStream sets whether the generated message should be streamed to the terminal
+func (c *Config) SetStream(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Stream = enabled
+}
+
+// IsBodyEnabled returns whether the message should include a bulleted body
+func (c *Config) IsBodyEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Body
+}
+
+// SetBody sets whether the message should include a bulleted body
+func (c *Config) SetBody(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Body = enabled
+}
+
+// IsUntrackedContextEnabled returns whether untracked files next to the changed files
+// should be listed in the prompt
+func (c *Config) IsUntrackedContextEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.UntrackedContext
+}
+
+// SetUntrackedContext sets whether untracked files next to the changed files should
+// be listed in the prompt
+func (c *Config) SetUntrackedContext(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.UntrackedContext = enabled
+}
+
+// IsUnstagedContextEnabled returns whether the working-tree content of the staged
+// files, unstaged edits included, should be sent as context
+func (c *Config) IsUnstagedContextEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.UnstagedContext
+}
+
+// SetUnstagedContext sets whether the working-tree content of the staged files,
+// unstaged edits included, should be sent as context
+func (c *Config) SetUnstagedContext(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.UnstagedContext = enabled
+}
+
+// IsSignoffEnabled returns whether a Signed-off-by trailer should be added to commits
+func (c *Config) IsSignoffEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Signoff
+}
+
+// SetSignoff sets whether a Signed-off-by trailer should be added to commits
+func (c *Config) SetSignoff(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Signoff = enabled
+}
+
+// IsAttributionEnabled returns whether commits get a trailer naming the model that
+// generated the message
+func (c *Config) IsAttributionEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Attribution
+}
+
+// SetAttribution sets whether commits get a trailer naming the model
+func (c *Config) SetAttribution(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Attribution = enabled
+}
+
+// GetAttributionTrailer returns the key of the trailer --attribution adds
+func (c *Config) GetAttributionTrailer() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if strings.TrimSpace(c.AttributionTrailer) == "" {
+		return DefaultAttributionTrailer
+	}
+	return strings.TrimSpace(c.AttributionTrailer)
+}
+
+// GetCoAuthors returns the co-authors from co_authors followed by those given with
+// --co-author, leaving out repeats
+func (c *Config) GetCoAuthors() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var coAuthors []string
+	seen := make(map[string]bool)
+	for _, coAuthor := range append(append([]string(nil), c.CoAuthors...), c.CoAuthorFlags...) {
+		coAuthor = strings.TrimSpace(coAuthor)
+		if coAuthor != "" && !seen[strings.ToLower(coAuthor)] {
+			seen[strings.ToLower(coAuthor)] = true
+			coAuthors = append(coAuthors, coAuthor)
+		}
+	}
+	return coAuthors
+}
+
+// IsScanSecretsEnabled returns whether the diff should be checked for secrets before
+// it is sent to a provider
+func (c *Config) IsScanSecretsEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ScanSecrets
+}
+
+// SetScanSecrets sets whether the diff should be checked for secrets
+func (c *Config) SetScanSecrets(scan bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ScanSecrets = scan
+}
+
+// GetSecretPatterns returns the regular expressions added to the secret scan's rules
+func (c *Config) GetSecretPatterns() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	patterns := make([]string, len(c.SecretPatterns))
+	copy(patterns, c.SecretPatterns)
+	return patterns
+}
+
+// IsEditAlwaysEnabled returns whether the message should always be opened in the editor
+// instead of asking whether to use it
+func (c *Config) IsEditAlwaysEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.EditAlways
+}
+
+// SetEditAlways sets whether the message should always be opened in the editor
+func (c *Config) SetEditAlways(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.EditAlways = enabled
+}
+
+// IsStructuredOutputEnabled returns whether the message should be requested as JSON
+// fields from the models that support structured output
+func (c *Config) IsStructuredOutputEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StructuredOutput
+}
+
+// SetStructuredOutput sets whether the message should be requested as JSON fields
+func (c *Config) SetStructuredOutput(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.StructuredOutput = enabled
+}
+
+// StoreAPIKey stores the API key in the keychain
+func (c *Config) StoreAPIKey(key string) error {
+	return c.keyManager.StoreInKeychain(key)
+}
+
+// Flags that take no value and flags that take one, used by ParseCommandLineArgs and shell completion
+var knownSingleFlags = map[string]bool{
+	"-v": true, "--verbose": true,
+	"-vv": true,
+	"-vvv": true,
+	"-a": true, "--auto": true,
+	"-s": true, "--store-key": true,
+	"-h": true, "--help": true,
+	"-cc": true, // Medium context level
+	"-ccc": true, // Maximum context level with enhanced mode
+	"--remember": true, // Remember settings for future use
+	"--stream": true, // Stream the message as it is generated
+	"--body": true, // Generate a subject line plus a bulleted body
+	"--include-untracked-context": true, // List untracked files next to the changed files
+	"--include-diff-context-from-unstaged": true, // Send the working-tree content of the staged files
+	"-S": true, "--signoff": true, // Add a Signed-off-by trailer
+	"--attribution": true, // Add a trailer naming the model that generated the message
+	"--edit": true, // Always open the message in the editor instead of asking
+	"--structured": true, // Ask for the message as JSON fields where the model supports structured output
+	"--amend": true, // Regenerate the message for the last commit
+	"--json": true, // Print the result as a JSON object
+	"-q": true, "--quiet": true, // Print only the message, without banners or progress
+	"--no-verify": true, // Skip the pre-commit and commit-msg hooks
+	"--notes": true, // Attach the model's rationale to the commit as a git note
+	"--non-interactive": true, // Never prompt; fail with instructions instead of running the first-time setup
+	"-A": true, "--all": true, // Stage all changes when nothing is staged
+	"--allow-unknown-model": true, // Skip checking the model against the provider's list
+	"--pr-description": true, // Write a pull request description instead of a commit message
+	"--gh": true, // Open a pull request with the description using the gh CLI
+	"--force": true, // Generate a fresh message even when a merge, rebase or cherry-pick is in progress
+	"--stat-only": true, // Send the diff stat and file list instead of the full diff
+	"--summarize-first": true, // Summarize each file's diff separately, then write the message from the summaries
+	"--offline": true, // Write a basic message from the changed files without calling a provider
+	"--no-emoji": true, // Leave emoji out of the setup messages
+	"--strict": true, // Ask the model again when the subject line is too long, and stop on possible secrets
+	"--gpg-sign": true, // Sign commits with GPG
+	"--no-gpg-sign": true, // Don't sign commits, even with commit.gpgsign or sign_commits
+	"--no-jira": true, // Skip Jira IDs entirely: no extraction, no Jira fields in the prompt
+	"-C": true, "--clipboard": true, // Copy the message to the clipboard instead of committing
+	"--show-prompt": true, // Print the system and user prompts before sending them
+	"--show-prompt-only": true, // Print the prompts and exit without calling the provider
+	"--analyze": true, // Suggest how to split the staged changes into several commits
+}
+
+var knownParamFlags = map[string]bool{
+	"-k": true, "--key": true,
+	"--key-file": true, // Read the API key for the selected provider from a file
+	"--commit-arg": true, // Extra argument for git commit (repeatable)
+	"--co-author": true, // Co-author to credit with a Co-authored-by trailer (repeatable)
+	"-j": true, "--jira": true,
+	"-d": true, "--jira-desc": true,
+	"--jira-url": true, // Jira site to link tickets to, overriding jira_base_url
+	"-i": true, "--issue": true, // GitLab issue reference (e.g. #123)
+	"-c": true, "--context": true,
+	"-m": true, "--model": true,
+	"-p": true, "--provider": true, // Select provider
+	"--system-prompt": true,
+	"--user-prompt": true,
+	"--prompt-dir": true, // Directory with custom prompt files, overriding the config directory
+	"--context-file": true, // File describing the project, sent as project context
+	"--gpg-key": true, // Key ID to sign commits with
+	"-L": true, "--language": true, // Language to write the commit message in
+	"--style-examples": true, // Number of recent commit messages to use as style examples
+	"--timeout": true, // Request timeout as a Go duration (e.g. 60s)
+	"--timeout-per-candidate": true, // Time allowed for each candidate request with --candidates
+	"--candidates-deadline": true, // Time allowed for the whole set of candidates with --candidates
+	"--profile": true, // Config profile to use; applied by LoadConfig, see ProfileFromArgs
+	"-R": true, "--repo": true, // Repository to run git in; applied by main, see RepoDirFromArgs
+	"--diff-file": true, // Read the diff from a file ("-" for stdin) instead of git
+	"--branch": true, // Branch name to use instead of the current git branch
+	"--max-prompt-tokens": true, // Warn before sending prompts larger than this
+	"--max-diff-bytes": true, // Truncate diffs larger than this
+	"--max-subject-length": true, // Longest subject line accepted without a warning
+	"--log-file": true, // Write log output to a file instead of the terminal
+	"-N": true, "--candidates": true, // Number of candidate messages to choose from
+	"--since": true, // Summarize the commits between REF and HEAD instead of the staged changes
+	"--template": true, // Commit message template for the model to fill in
+	"--only": true, // Describe and commit only the staged files matching this pathspec (repeatable)
+}
+
+// verbosityFlagCount returns the number of levels a verbosity flag adds: one for
+// --verbose and one per v in -v, -vv, -vvvv and so on, or 0 for any other argument
+func verbosityFlagCount(arg string) int {
+	if arg == "--verbose" {
+		return 1
+	}
+	if len(arg) < 2 || arg[0] != '-' || strings.Trim(arg[1:], "v") != "" {
+		return 0
+	}
+	return len(arg) - 1
+}
+
+// verbosityFromCount maps the number of v's on the command line to a verbosity level:
+// one for Verbose, two for MoreVerbose and three or more for Debug
+func verbosityFromCount(count int) VerbosityLevel {
+	if level := Normal + VerbosityLevel(count); level < Debug {
+		return level
+	}
+	return Debug
+}
+
+// combinedFlagLetters are the single-letter flags without a value that can be combined,
+// as in -vaS. Each one needs a case in the combined-flag switch of ParseCommandLineArgs;
+// -h only works on its own.
+const combinedFlagLetters = "vasqSAC"
+
+// isCombinedFlag reports whether arg combines several single-letter flags that take no
+// value, such as -vas
+func isCombinedFlag(arg string) bool {
+	if len(arg) <= 2 || arg[0] != '-' || arg[1] == '-' {
+		return false
+	}
+	for _, char := range arg[1:] {
+		if !strings.ContainsRune(combinedFlagLetters, char) {
+			return false
+		}
+	}
+	return true
+}
+
+// SingleFlags returns the known flags that take no value, sorted
+func SingleFlags() []string {
+	return sortedFlags(knownSingleFlags)
+}
+
+// ParamFlags returns the known flags that take a value, sorted
+func ParamFlags() []string {
+	return sortedFlags(knownParamFlags)
+}
+
+func sortedFlags(flags map[string]bool) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseCommandLineArgs parses command line arguments into the config
+func (c *Config) ParseCommandLineArgs(args []string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Reset runtime values
+	c.JiraID = ""
+	c.JiraDesc = ""
+	c.IssueRef = ""
+	c.AutoCommit = false
+	c.StoreKey = false
+	c.Amend = false
+	c.DiffFile = ""
+	c.Branch = ""
+	c.JSONOutput = false
+	c.StageAll = false
+	c.Since = ""
+	c.PRDescription = false
+	c.Template = ""
+	c.Only = nil
+	c.Force = false
+	c.StatOnly = false
+	c.SummarizeFirst = false
+	c.Offline = false
+	c.NoEmoji = false
+	c.KeyFile = ""
+	c.Quiet = false
+	c.NoVerify = false
+	c.CommitArgs = nil
+	c.CoAuthorFlags = nil
+	c.Notes = false
+	c.Strict = false
+	c.NoGPGSign = false
+	c.Clipboard = false
+	c.ShowPrompt = false
+	c.ShowPromptOnly = false
+	c.Analyze = false
+	c.NonInteractive = false
+	c.GH = false
+
+	// Collect any unknown flags
+	var unknownFlags []string
+	var parseErr error
+	var modelFlag string
+	var editFlag bool
+	var verbosityCount int // Every v given, as in -v, -vv, -vvvv or -vas
+
+	// Process all args
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// -v, -vv, -vvvv and so on raise the verbosity by one level per v
+		if count := verbosityFlagCount(arg); count > 0 {
+			verbosityCount += count
+			continue
+		}
+
+		// Check for flags that don't require values
+		if knownSingleFlags[arg] {
+			// Set appropriate flag
+			switch arg {
+			case "-a", "--auto":
+				c.AutoCommit = true
+			case "-s", "--store-key":
+				c.StoreKey = true
+			case "-cc":
+				c.ContextLines = 10 // Medium context
+			case "-ccc":
+				c.ContextLines = -1 // Signal for maximum context
+				c.EnhancedContext = true // Automatically enable enhanced context with -ccc
+			case "--remember":
+				c.RememberFlags = true
+			case "--stream":
+				c.Stream = true
+			case "--body":
+				c.Body = true
+			case "--include-untracked-context":
+				c.UntrackedContext = true
+			case "--include-diff-context-from-unstaged":
+				c.UnstagedContext = true
+			case "-S", "--signoff":
+				c.Signoff = true
+			case "--attribution":
+				c.Attribution = true
+			case "--edit":
+				c.EditAlways = true
+				editFlag = true
+			case "--structured":
+				c.StructuredOutput = true
+			case "--amend":
+				c.Amend = true
+			case "--json":
+				c.JSONOutput = true
+			case "-q", "--quiet":
+				c.Quiet = true
+			case "--no-verify":
+				c.NoVerify = true
+			case "--notes":
+				c.Notes = true
+			case "--strict":
+				c.Strict = true
+			case "--gpg-sign":
+				c.SignCommits = true
+			case "--no-gpg-sign":
+				c.NoGPGSign = true
+			case "--no-jira":
+				c.JiraEnabled = false
+			case "-C", "--clipboard":
+				c.Clipboard = true
+			case "--show-prompt":
+				c.ShowPrompt = true
+			case "--show-prompt-only":
+				c.ShowPrompt = true
+				c.ShowPromptOnly = true
+			case "--analyze":
+				c.Analyze = true
+			case "--non-interactive":
+				c.NonInteractive = true
+			case "-A", "--all":
+				c.StageAll = true
+			case "--allow-unknown-model":
+				c.AllowUnknownModel = true
+			case "--pr-description":
+				c.PRDescription = true
+			case "--gh":
+				c.GH = true
+			case "--force":
+				c.Force = true
+			case "--stat-only":
+				c.StatOnly = true
+			case "--summarize-first":
+				c.SummarizeFirst = true
+			case "--offline":
+				c.Offline = true
+			case "--no-emoji":
+				c.NoEmoji = true
+			}
+			continue
+		}
+
+		// Check for flags that require values
+		if knownParamFlags[arg] && i+1 < len(args) {
+			// Set appropriate value
+			switch arg {
+			case "-k", "--key":
+				// Store key in both legacy field and provider-specific map
+				c.APIKey = args[i+1]
+				if c.Provider != "" {
+					// Also store in provider-specific map
+					if c.ProviderKeys == nil {
+						c.ProviderKeys = make(map[string]string)
+					}
+					c.ProviderKeys[c.Provider] = args[i+1]
+				}
+			case "--key-file":
+				c.KeyFile = args[i+1]
+			case "--commit-arg":
+				c.CommitArgs = append(c.CommitArgs, args[i+1])
+			case "--co-author":
+				c.CoAuthorFlags = append(c.CoAuthorFlags, args[i+1])
+			case "-j", "--jira":
+				c.JiraID = args[i+1]
+			case "-d", "--jira-desc":
+				c.JiraDesc = args[i+1]
+			case "--jira-url":
+				c.JiraBaseURL = args[i+1]
+			case "-i", "--issue":
+				c.IssueRef = args[i+1]
+			case "-c", "--context":
+				// Try to parse context lines as an integer
+				fmt.Sscanf(args[i+1], "%d", &c.ContextLines)
+			case "-m", "--model":
+				// Applied after the loop, once the provider is known
+				modelFlag = args[i+1]
+			case "-p", "--provider":
+				c.Provider = args[i+1]
+			case "--system-prompt":
+				c.SystemPromptPath = args[i+1]
+			case "--user-prompt":
+				c.UserPromptPath = args[i+1]
+			case "--prompt-dir":
+				// Relative to where the command is run, not to the config file
+				if dir, err := filepath.Abs(args[i+1]); err == nil {
+					c.PromptDir = dir
+				} else {
+					c.PromptDir = args[i+1]
+				}
+			case "--gpg-key":
+				c.SigningKey = args[i+1]
+				c.SignCommits = true
+			case "--context-file":
+				// Relative to where the command is run, not to the config file
+				if path, err := filepath.Abs(args[i+1]); err == nil {
+					c.ContextFile = path
+				} else {
+					c.ContextFile = args[i+1]
+				}
+			case "-L", "--language":
+				c.Language = args[i+1]
+			case "-N", "--candidates":
+				fmt.Sscanf(args[i+1], "%d", &c.Candidates)
+			case "--log-file":
+				c.LogFile = args[i+1]
+			case "--max-prompt-tokens":
+				fmt.Sscanf(args[i+1], "%d", &c.MaxPromptTokens)
+			case "--max-diff-bytes":
+				fmt.Sscanf(args[i+1], "%d", &c.MaxDiffBytes)
+			case "--max-subject-length":
+				fmt.Sscanf(args[i+1], "%d", &c.MaxSubjectLength)
+			case "--diff-file":
+				c.DiffFile = args[i+1]
+			case "--since":
+				c.Since = args[i+1]
+			case "--template":
+				c.Template = args[i+1]
+			case "--only":
+				c.Only = append(c.Only, args[i+1])
+			case "--branch":
+				c.Branch = args[i+1]
+			case "--style-examples":
+				fmt.Sscanf(args[i+1], "%d", &c.StyleExamples)
+			case "--timeout":
+				timeout, err := time.ParseDuration(args[i+1])
+				if err != nil || timeout <= 0 {
+					parseErr = fmt.Errorf("invalid --timeout value %q: must be a positive duration such as 30s or 2m", args[i+1])
+				} else {
+					c.RequestTimeout = timeout
+				}
+			case "--profile":
+				// The profile has to be applied before the other flags, so LoadConfig
+				// does it with the name from ProfileFromArgs
+			case "-R", "--repo":
+				// The repository has to be known before LoadConfig reads its config, so
+				// main applies it with the directory from RepoDirFromArgs
+			case "--timeout-per-candidate":
+				timeout, err := time.ParseDuration(args[i+1])
+				if err != nil || timeout <= 0 {
+					parseErr = fmt.Errorf("invalid --timeout-per-candidate value %q: must be a positive duration such as 20s", args[i+1])
+				} else {
+					c.CandidateTimeout = timeout
+				}
+			case "--candidates-deadline":
+				deadline, err := time.ParseDuration(args[i+1])
+				if err != nil || deadline <= 0 {
+					parseErr = fmt.Errorf("invalid --candidates-deadline value %q: must be a positive duration such as 2m", args[i+1])
+				} else {
+					c.CandidatesDeadline = deadline
+				}
+			}
+			i++ // Skip the next argument since we've used it
+			continue
+		}
+
+		// Check for combined forms like -vah (verbose+auto+help). Nothing is set unless
+		// every character is a flag that takes no value; each v counts, so -vvs is -vv -s.
+		if isCombinedFlag(arg) {
+			for _, char := range arg[1:] {
+				switch char {
+				case 'v':
+					verbosityCount++
+				case 'a':
+					c.AutoCommit = true
+				case 's':
+					c.StoreKey = true
+				case 'q':
+					c.Quiet = true
+				case 'S':
+					c.Signoff = true
+				case 'A':
+					c.StageAll = true
+				case 'C':
+					c.Clipboard = true
+				}
+			}
+			continue
+		}
+
+		// If we get here, it's an unknown flag or parameter
+		if strings.HasPrefix(arg, "-") {
+			unknownFlags = append(unknownFlags, arg)
+		}
+	}
+
+	if verbosityCount > 0 {
+		c.Verbosity = verbosityFromCount(verbosityCount)
+	}
+
+	// --model belongs to the provider selected on the same command line, wherever
+	// --provider appears, so it can't leak into another provider's model
+	if modelFlag != "" {
+		c.setProviderModel(c.Provider, modelFlag)
+	}
+
+	// With edit_always in the config file, --auto simply takes precedence; asking for
+	// both on the same command line is a mistake
+	if editFlag && c.AutoCommit && parseErr == nil {
+		parseErr = fmt.Errorf("--edit and --auto can't be used together")
+	}
+
+	return unknownFlags, parseErr
+}
+
+// GetKeyManager returns the key manager instance
+func (c *Config) GetKeyManager() *key.KeyManager {
+	return c.keyManager
+}
+
+// IsRememberFlagsEnabled returns whether flag values should be remembered
+func (c *Config) IsRememberFlagsEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.RememberFlags
+}
+
+// SetRememberFlags sets whether flag values should be remembered
+func (c *Config) SetRememberFlags(remember bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.RememberFlags = remember
+}
+
+// GetAutoCommit returns whether auto-commit is enabled
 func (c *Config) GetAutoCommit() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.AutoCommit
 }
 
+// IsAmendEnabled returns whether the last commit should be amended instead of creating a new one
+func (c *Config) IsAmendEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Amend
+}
+
+// IsForceEnabled returns whether a fresh message should be generated even when a merge,
+// rebase or cherry-pick has already prepared one
+func (c *Config) IsForceEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Force
+}
+
+// IsStatOnlyEnabled returns whether only the diff stat and file list should be sent instead of the full diff
+func (c *Config) IsStatOnlyEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StatOnly
+}
+
+// IsSummarizeFirstEnabled returns whether each file's diff should be summarized on its
+// own before the commit message is written from the summaries
+func (c *Config) IsSummarizeFirstEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.SummarizeFirst
+}
+
+// IsOfflineEnabled returns whether the message should be written from the changed files
+// alone, without calling a provider
+func (c *Config) IsOfflineEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Offline
+}
+
+// IsNoEmoji returns whether emoji should be left out of the setup messages
+func (c *Config) IsNoEmoji() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.NoEmoji
+}
+
+// IsQuiet returns whether only the message should be printed, without banners or progress
+func (c *Config) IsQuiet() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Quiet
+}
+
+// IsNoVerifyEnabled returns whether git commit should skip the pre-commit and commit-msg hooks
+func (c *Config) IsNoVerifyEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.NoVerify
+}
+
+// GetCommitArgs returns the extra arguments passed to git commit with --commit-arg
+func (c *Config) GetCommitArgs() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.CommitArgs...)
+}
+
+// IsNotesEnabled returns whether the model's rationale should be attached to the commit as a git note
+func (c *Config) IsNotesEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Notes
+}
+
+// GetNotesRef returns the notes ref that --notes writes to, or "" for git's default
+func (c *Config) GetNotesRef() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.NotesRef
+}
+
+// GetContextFile returns the file whose contents are sent as project context, or "" to
+// derive it from the README. A relative context_file in a repository config file is
+// relative to the repository root.
+func (c *Config) GetContextFile() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.ContextFile != "" && !filepath.IsAbs(c.ContextFile) && c.repoConfigFile != "" {
+		return filepath.Join(filepath.Dir(c.repoConfigFile), c.ContextFile)
+	}
+	return c.ContextFile
+}
+
+// SetContextFile sets the file whose contents are sent as project context
+func (c *Config) SetContextFile(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ContextFile = path
+}
+
+// IsSignCommitsEnabled returns whether commits should be signed even if git's commit.gpgsign is off
+func (c *Config) IsSignCommitsEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.SignCommits
+}
+
+// SetSignCommits sets whether commits should be signed
+func (c *Config) SetSignCommits(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SignCommits = enabled
+}
+
+// GetSigningKey returns the key ID to sign commits with, or "" for git's user.signingkey
+func (c *Config) GetSigningKey() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.SigningKey
+}
+
+// SetSigningKey sets the key ID to sign commits with
+func (c *Config) SetSigningKey(keyID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SigningKey = keyID
+}
+
+// IsClipboardEnabled returns whether the message should be copied to the clipboard instead of committed
+func (c *Config) IsClipboardEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Clipboard
+}
+
+// IsShowPromptEnabled returns whether the final prompts should be printed before they are sent
+func (c *Config) IsShowPromptEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ShowPrompt
+}
+
+// IsShowPromptOnly returns whether to print the final prompts and exit without calling the provider
+func (c *Config) IsShowPromptOnly() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ShowPromptOnly
+}
+
+// IsAnalyzeEnabled returns whether to suggest how to split the staged changes into
+// several commits instead of writing one message
+func (c *Config) IsAnalyzeEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Analyze
+}
+
+// IsNoGPGSignEnabled returns whether signing was turned off for this run with --no-gpg-sign
+func (c *Config) IsNoGPGSignEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.NoGPGSign
+}
+
+// GetCredentialNamespace returns the namespace appended to the credential store service name, or "" for none
+func (c *Config) GetCredentialNamespace() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CredentialNamespace
+}
+
+// GetMaxSubjectLength returns the longest subject line accepted without a warning (0 disables the check)
+func (c *Config) GetMaxSubjectLength() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.MaxSubjectLength
+}
+
+// SetMaxSubjectLength sets the longest subject line accepted without a warning
+func (c *Config) SetMaxSubjectLength(length int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.MaxSubjectLength = length
+}
+
+// IsStrictEnabled returns whether a message with a subject line over the limit should be regenerated
+func (c *Config) IsStrictEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Strict
+}
+
+// IsNonInteractive returns whether prompting was disabled with --non-interactive
+func (c *Config) IsNonInteractive() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.NonInteractive
+}
+
+// IsJSONOutput returns whether the result should be printed as a JSON object
+func (c *Config) IsJSONOutput() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.JSONOutput
+}
+
+// IsStageAllEnabled returns whether all changes should be staged when nothing is staged
+func (c *Config) IsStageAllEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StageAll
+}
+
+// GetDiffFile returns the file to read the diff from ("-" for stdin), or "" to use git
+func (c *Config) GetDiffFile() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.DiffFile
+}
+
+// GetSince returns the ref whose commits up to HEAD should be summarized, or "" to use the staged changes
+func (c *Config) GetSince() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Since
+}
+
+// SetSince sets the ref whose commits up to HEAD should be summarized
+func (c *Config) SetSince(ref string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Since = ref
+}
+
+// IsPRDescriptionEnabled returns whether a pull request description should be written instead of a commit message
+func (c *Config) IsPRDescriptionEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PRDescription
+}
+
+// SetPRDescription sets whether a pull request description should be written instead of a commit message
+func (c *Config) SetPRDescription(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.PRDescription = enabled
+}
+
+// IsGHEnabled returns whether the pull request description should be passed to "gh pr create"
+func (c *Config) IsGHEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.GH
+}
+
+// GetTemplate returns the commit message template file given on the command line, or "" to use commit.template
+func (c *Config) GetTemplate() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Template
+}
+
+// GetOnly returns the pathspecs that restrict the diff and the commit, or nil for all staged files
+func (c *Config) GetOnly() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.Only...)
+}
+
+// GetBranch returns the branch name given on the command line, or "" to use the current git branch
+func (c *Config) GetBranch() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Branch
+}
+
 // GetJiraID returns the Jira ID
 func (c *Config) GetJiraID() string {
 	c.mu.RLock()
@@ -655,6 +2000,42 @@ func (c *Config) GetJiraID() string {
 	return c.JiraID
 }
 
+// IsAllowUnknownModelEnabled returns whether models missing from the provider's list are allowed
+func (c *Config) IsAllowUnknownModelEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.AllowUnknownModel
+}
+
+// SetAllowUnknownModel sets whether models missing from the provider's list are allowed
+func (c *Config) SetAllowUnknownModel(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.AllowUnknownModel = enabled
+}
+
+// GetIssueRef returns the GitLab issue reference given on the command line
+func (c *Config) GetIssueRef() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.IssueRef
+}
+
+// GetIssueStyle returns which issue references are detected from branch names:
+// "jira", "gitlab" or "both"
+func (c *Config) GetIssueStyle() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.IssueStyle
+}
+
+// SetIssueStyle sets which issue references are detected from branch names
+func (c *Config) SetIssueStyle(style string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.IssueStyle = style
+}
+
 // GetJiraDesc returns the Jira description
 func (c *Config) GetJiraDesc() string {
 	c.mu.RLock()
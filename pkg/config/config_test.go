@@ -1,11 +1,15 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	
 	"github.com/spf13/viper"
+	"github.com/nycjay/ai-commit-msg/pkg/ai"
 	"github.com/nycjay/ai-commit-msg/pkg/key"
 )
 
@@ -38,6 +42,18 @@ func TestConfigDefaults(t *testing.T) {
 	if cfg.RememberFlags != false {
 		t.Errorf("Default remember flags should be false, got %v", cfg.RememberFlags)
 	}
+
+	if len(cfg.JiraPrefixes) != 4 || cfg.JiraPrefixes[0] != "GTN" {
+		t.Errorf("Default Jira prefixes should be [GTN GTBUG TOOLS TASK], got %v", cfg.JiraPrefixes)
+	}
+
+	if cfg.RequestTimeout != ai.DefaultRequestTimeout {
+		t.Errorf("Default request timeout should be %s, got %s", ai.DefaultRequestTimeout, cfg.RequestTimeout)
+	}
+
+	if cfg.LargeFileThreshold != 100*1024 {
+		t.Errorf("Default large file threshold should be 102400, got %v", cfg.LargeFileThreshold)
+	}
 }
 
 func TestConfigParseArgs(t *testing.T) {
@@ -112,6 +128,512 @@ func TestConfigParseArgs(t *testing.T) {
 	if !cfg.IsStoreKeyEnabled() {
 		t.Errorf("StoreKey should be true")
 	}
+
+	// Test --amend is runtime-only and reset on every parse
+	args = []string{"program", "--amend"}
+	cfg.ParseCommandLineArgs(args[1:])
+
+	if !cfg.IsAmendEnabled() {
+		t.Errorf("--amend should enable amend mode")
+	}
+
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsAmendEnabled() {
+		t.Errorf("Amend should be reset when the flag is not given")
+	}
+
+	// Test --diff-file, --branch and --json
+	args = []string{"program", "--diff-file", "-", "--branch", "feature/GTN-42-ci", "--json"}
+	unknownFlags, _ = cfg.ParseCommandLineArgs(args[1:])
+
+	if len(unknownFlags) > 0 {
+		t.Errorf("Should not have unknown flags, got %v", unknownFlags)
+	}
+
+	if cfg.GetDiffFile() != "-" {
+		t.Errorf("DiffFile should be -, got %v", cfg.GetDiffFile())
+	}
+
+	if cfg.GetBranch() != "feature/GTN-42-ci" {
+		t.Errorf("Branch should be feature/GTN-42-ci, got %v", cfg.GetBranch())
+	}
+
+	if !cfg.IsJSONOutput() {
+		t.Errorf("--json should enable JSON output")
+	}
+
+	// Test -A is runtime-only and reset on every parse
+	cfg.ParseCommandLineArgs([]string{"-A"})
+	if !cfg.IsStageAllEnabled() {
+		t.Errorf("-A should enable staging all changes")
+	}
+
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsStageAllEnabled() {
+		t.Errorf("StageAll should be reset when the flag is not given")
+	}
+
+	// Test --since, --pr-description and --template are runtime-only
+	cfg.ParseCommandLineArgs([]string{"--since", "main", "--pr-description", "--template", ".gitmessage"})
+	if cfg.GetSince() != "main" {
+		t.Errorf("Since should be main, got %v", cfg.GetSince())
+	}
+	if !cfg.IsPRDescriptionEnabled() {
+		t.Errorf("--pr-description should enable the PR description prompt")
+	}
+	if cfg.GetTemplate() != ".gitmessage" {
+		t.Errorf("Template should be .gitmessage, got %v", cfg.GetTemplate())
+	}
+
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.GetSince() != "" || cfg.IsPRDescriptionEnabled() || cfg.GetTemplate() != "" {
+		t.Errorf("Since, PRDescription and Template should be reset when the flags are not given")
+	}
+
+	// Test --strict is runtime-only
+	cfg.ParseCommandLineArgs([]string{"--strict"})
+	if !cfg.IsStrictEnabled() {
+		t.Errorf("--strict should enable regenerating long subject lines")
+	}
+
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsStrictEnabled() {
+		t.Errorf("Strict should be reset when the flag is not given")
+	}
+
+	// Test --gpg-sign and --gpg-key are remembered, and --no-gpg-sign is runtime-only
+	defer cfg.SetSignCommits(false)
+	defer cfg.SetSigningKey("")
+	cfg.ParseCommandLineArgs([]string{"--gpg-key", "ABCD1234", "--no-gpg-sign"})
+	if !cfg.IsSignCommitsEnabled() || cfg.GetSigningKey() != "ABCD1234" {
+		t.Errorf("--gpg-key should enable signing with ABCD1234, got %v, %q", cfg.IsSignCommitsEnabled(), cfg.GetSigningKey())
+	}
+	if !cfg.IsNoGPGSignEnabled() {
+		t.Errorf("--no-gpg-sign should turn signing off")
+	}
+
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsNoGPGSignEnabled() {
+		t.Errorf("NoGPGSign should be reset when the flag is not given")
+	}
+
+	// Test -C is runtime-only
+	cfg.ParseCommandLineArgs([]string{"-C"})
+	if !cfg.IsClipboardEnabled() {
+		t.Errorf("-C should enable copying to the clipboard")
+	}
+
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsClipboardEnabled() {
+		t.Errorf("Clipboard should be reset when the flag is not given")
+	}
+
+	// Test --gh is runtime-only
+	cfg.ParseCommandLineArgs([]string{"--pr-description", "--gh"})
+	if !cfg.IsGHEnabled() {
+		t.Errorf("--gh should enable opening a pull request")
+	}
+
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsGHEnabled() {
+		t.Errorf("GH should be reset when the flag is not given")
+	}
+
+	// Test --only can be repeated and is reset on every parse
+	cfg.ParseCommandLineArgs([]string{"--only", "pkg/git", "--only", "*.md"})
+	if only := cfg.GetOnly(); len(only) != 2 || only[0] != "pkg/git" || only[1] != "*.md" {
+		t.Errorf("Only should be [pkg/git *.md], got %v", only)
+	}
+
+	cfg.ParseCommandLineArgs([]string{})
+	if len(cfg.GetOnly()) != 0 {
+		t.Errorf("Only should be reset when the flag is not given")
+	}
+
+	// Test --force is command-line only and reset on every parse
+	cfg.ParseCommandLineArgs([]string{"--force"})
+	if !cfg.IsForceEnabled() {
+		t.Errorf("Force should be enabled")
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsForceEnabled() {
+		t.Errorf("Force should be reset when the flag is not given")
+	}
+
+	// Test --stat-only is command-line only
+	cfg.ParseCommandLineArgs([]string{"--stat-only"})
+	if !cfg.IsStatOnlyEnabled() {
+		t.Errorf("StatOnly should be enabled")
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsStatOnlyEnabled() {
+		t.Errorf("StatOnly should be reset when the flag is not given")
+	}
+
+	// Test --summarize-first is command-line only
+	cfg.ParseCommandLineArgs([]string{"--summarize-first"})
+	if !cfg.IsSummarizeFirstEnabled() {
+		t.Errorf("SummarizeFirst should be enabled")
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsSummarizeFirstEnabled() {
+		t.Errorf("SummarizeFirst should be reset when the flag is not given")
+	}
+
+	// Test --offline is command-line only
+	cfg.ParseCommandLineArgs([]string{"--offline"})
+	if !cfg.IsOfflineEnabled() {
+		t.Errorf("Offline should be enabled")
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsOfflineEnabled() {
+		t.Errorf("Offline should be reset when the flag is not given")
+	}
+
+	// Test --no-emoji is command-line only
+	cfg.ParseCommandLineArgs([]string{"--no-emoji"})
+	if !cfg.IsNoEmoji() {
+		t.Errorf("NoEmoji should be enabled")
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsNoEmoji() {
+		t.Errorf("NoEmoji should be reset when the flag is not given")
+	}
+
+	// Test -q and --quiet, also inside combined short flags
+	for _, args := range [][]string{{"-q"}, {"--quiet"}, {"-qa"}} {
+		cfg.ParseCommandLineArgs(args)
+		if !cfg.IsQuiet() {
+			t.Errorf("Quiet should be enabled by %v", args)
+		}
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsQuiet() {
+		t.Errorf("Quiet should be reset when the flag is not given")
+	}
+
+	// Test --no-verify and repeated --commit-arg, whose values may start with dashes
+	cfg.ParseCommandLineArgs([]string{"--no-verify", "--commit-arg", "--author=A <a@example.com>", "--commit-arg", "--date=now"})
+	if !cfg.IsNoVerifyEnabled() {
+		t.Errorf("NoVerify should be enabled")
+	}
+	if commitArgs := cfg.GetCommitArgs(); len(commitArgs) != 2 || commitArgs[0] != "--author=A <a@example.com>" || commitArgs[1] != "--date=now" {
+		t.Errorf("Unexpected commit args %v", commitArgs)
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsNoVerifyEnabled() || len(cfg.GetCommitArgs()) != 0 {
+		t.Errorf("NoVerify and CommitArgs should be reset when the flags are not given")
+	}
+
+	// Test --notes
+	cfg.ParseCommandLineArgs([]string{"--notes"})
+	if !cfg.IsNotesEnabled() {
+		t.Errorf("Notes should be enabled")
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsNotesEnabled() {
+		t.Errorf("Notes should be reset when the flag is not given")
+	}
+
+	// Test --non-interactive
+	cfg.ParseCommandLineArgs([]string{"--non-interactive"})
+	if !cfg.IsNonInteractive() {
+		t.Errorf("NonInteractive should be enabled")
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if cfg.IsNonInteractive() {
+		t.Errorf("NonInteractive should be reset when the flag is not given")
+	}
+
+	// Test -L sets the commit message language
+	defer cfg.SetLanguage("")
+	args = []string{"program", "-L", "es"}
+	cfg.ParseCommandLineArgs(args[1:])
+
+	if cfg.GetLanguage() != "es" {
+		t.Errorf("Language should be es, got %v", cfg.GetLanguage())
+	}
+
+	// Test -i sets the GitLab issue reference alongside the Jira ID
+	cfg.ParseCommandLineArgs([]string{"-j", "GTN-1", "-i", "group/project#12"})
+
+	if cfg.GetIssueRef() != "group/project#12" || cfg.GetJiraID() != "GTN-1" {
+		t.Errorf("Expected both issue references, got %q and %q", cfg.GetIssueRef(), cfg.GetJiraID())
+	}
+
+	// Test --allow-unknown-model
+	defer cfg.SetAllowUnknownModel(false)
+	cfg.ParseCommandLineArgs([]string{"--allow-unknown-model"})
+
+	if !cfg.IsAllowUnknownModelEnabled() {
+		t.Errorf("--allow-unknown-model should allow unknown models")
+	}
+
+	// Test --body enables the bulleted body
+	defer cfg.SetBody(false)
+	cfg.ParseCommandLineArgs([]string{"--body"})
+
+	if !cfg.IsBodyEnabled() {
+		t.Errorf("--body should enable body generation")
+	}
+
+	// Test --include-untracked-context lists untracked files
+	defer cfg.SetUntrackedContext(false)
+	cfg.ParseCommandLineArgs([]string{"--include-untracked-context"})
+
+	if !cfg.IsUntrackedContextEnabled() {
+		t.Errorf("--include-untracked-context should enable the untracked files section")
+	}
+
+	// Test --include-diff-context-from-unstaged sends the working-tree content
+	defer cfg.SetUnstagedContext(false)
+	cfg.ParseCommandLineArgs([]string{"--include-diff-context-from-unstaged"})
+
+	if !cfg.IsUnstagedContextEnabled() {
+		t.Errorf("--include-diff-context-from-unstaged should enable the working-tree context")
+	}
+
+	// Test -S enables the Signed-off-by trailer
+	defer cfg.SetSignoff(false)
+	cfg.ParseCommandLineArgs([]string{"-S"})
+
+	if !cfg.IsSignoffEnabled() {
+		t.Errorf("-S should enable signoff")
+	}
+
+	// Test --attribution enables the model trailer
+	defer cfg.SetAttribution(false)
+	cfg.ParseCommandLineArgs([]string{"--attribution"})
+
+	if !cfg.IsAttributionEnabled() || cfg.GetAttributionTrailer() != DefaultAttributionTrailer {
+		t.Errorf("--attribution should enable the %s trailer", DefaultAttributionTrailer)
+	}
+
+	// Test --co-author adds to co_authors for one run, without repeats
+	cfg.CoAuthors = []string{"Jane Doe <jane@example.com>"}
+	defer func() { cfg.CoAuthors = nil }()
+	cfg.ParseCommandLineArgs([]string{"--co-author", "Sam Lee <sam@example.com>", "--co-author", "jane doe <JANE@example.com>"})
+	if coAuthors := cfg.GetCoAuthors(); strings.Join(coAuthors, ", ") != "Jane Doe <jane@example.com>, Sam Lee <sam@example.com>" {
+		t.Errorf("Unexpected co-authors %v", coAuthors)
+	}
+	cfg.ParseCommandLineArgs([]string{})
+	if coAuthors := cfg.GetCoAuthors(); len(coAuthors) != 1 {
+		t.Errorf("Expected only co_authors once --co-author is gone, got %v", coAuthors)
+	}
+
+	// Test --show-prompt-only implies --show-prompt
+	cfg.ParseCommandLineArgs([]string{"--show-prompt-only"})
+	if !cfg.IsShowPromptEnabled() || !cfg.IsShowPromptOnly() {
+		t.Errorf("--show-prompt-only should show the prompt and stop")
+	}
+	cfg.ParseCommandLineArgs([]string{"--show-prompt"})
+	if !cfg.IsShowPromptEnabled() || cfg.IsShowPromptOnly() {
+		t.Errorf("--show-prompt should show the prompt and continue")
+	}
+
+	// Test --edit always opens the editor, and can't be combined with --auto
+	defer cfg.SetEditAlways(false)
+	if _, err := cfg.ParseCommandLineArgs([]string{"--edit"}); err != nil {
+		t.Errorf("--edit should parse, got %v", err)
+	}
+	if !cfg.IsEditAlwaysEnabled() {
+		t.Errorf("--edit should enable edit-always")
+	}
+	if _, err := cfg.ParseCommandLineArgs([]string{"--edit", "-a"}); err == nil {
+		t.Errorf("--edit with --auto should be rejected")
+	}
+
+	// Test --structured asks for structured output
+	defer cfg.SetStructuredOutput(false)
+	cfg.ParseCommandLineArgs([]string{"--structured"})
+
+	if !cfg.IsStructuredOutputEnabled() {
+		t.Errorf("--structured should enable structured output")
+	}
+
+	// Test -N sets the number of candidates
+	defer cfg.SetCandidates(1)
+	cfg.ParseCommandLineArgs([]string{"-N", "3"})
+
+	if cfg.GetCandidates() != 3 {
+		t.Errorf("Candidates should be 3, got %v", cfg.GetCandidates())
+	}
+
+	// Test --log-file
+	defer cfg.SetLogFile("")
+	cfg.ParseCommandLineArgs([]string{"--log-file", "/tmp/ai-commit-msg.log"})
+
+	if cfg.GetLogFile() != "/tmp/ai-commit-msg.log" {
+		t.Errorf("LogFile should be /tmp/ai-commit-msg.log, got %v", cfg.GetLogFile())
+	}
+
+	// Test --jira-url
+	defer cfg.SetJiraBaseURL("")
+	cfg.ParseCommandLineArgs([]string{"--jira-url", "https://company.atlassian.net"})
+
+	if cfg.GetJiraBaseURL() != "https://company.atlassian.net" {
+		t.Errorf("JiraBaseURL should be https://company.atlassian.net, got %v", cfg.GetJiraBaseURL())
+	}
+
+	// Test --no-jira
+	defer cfg.SetJiraEnabled(true)
+	if !cfg.IsJiraEnabled() {
+		t.Error("Jira should be enabled by default")
+	}
+	cfg.ParseCommandLineArgs([]string{"--no-jira"})
+
+	if cfg.IsJiraEnabled() {
+		t.Error("Jira should be disabled with --no-jira")
+	}
+
+	// Test --max-prompt-tokens
+	defer cfg.SetMaxPromptTokens(0)
+	args = []string{"program", "--max-prompt-tokens", "8000"}
+	cfg.ParseCommandLineArgs(args[1:])
+
+	if cfg.GetMaxPromptTokens() != 8000 {
+		t.Errorf("MaxPromptTokens should be 8000, got %v", cfg.GetMaxPromptTokens())
+	}
+
+	// Test --max-diff-bytes
+	defer cfg.SetMaxDiffBytes(DefaultMaxDiffBytes)
+	cfg.ParseCommandLineArgs([]string{"--max-diff-bytes", "50000"})
+
+	if cfg.GetMaxDiffBytes() != 50000 {
+		t.Errorf("MaxDiffBytes should be 50000, got %v", cfg.GetMaxDiffBytes())
+	}
+
+	// Test --max-subject-length
+	defer cfg.SetMaxSubjectLength(72)
+	cfg.ParseCommandLineArgs([]string{"--max-subject-length", "50"})
+
+	if cfg.GetMaxSubjectLength() != 50 {
+		t.Errorf("MaxSubjectLength should be 50, got %v", cfg.GetMaxSubjectLength())
+	}
+}
+
+// TestParseVerbosityFlags tests counting the v's in verbosity flags, alone, repeated
+// and combined with other flags
+func TestParseVerbosityFlags(t *testing.T) {
+	cfg := GetInstance()
+	defer cfg.SetVerbosity(Silent)
+	defer cfg.ParseCommandLineArgs(nil)
+
+	testCases := []struct {
+		args      []string
+		verbosity VerbosityLevel
+		unknown   []string
+	}{
+		{args: []string{"-v"}, verbosity: Verbose},
+		{args: []string{"--verbose"}, verbosity: Verbose},
+		{args: []string{"-vv"}, verbosity: MoreVerbose},
+		{args: []string{"-vvv"}, verbosity: Debug},
+		{args: []string{"-vvvv"}, verbosity: Debug},
+		{args: []string{"-v", "-v"}, verbosity: MoreVerbose},
+		{args: []string{"--verbose", "-vv"}, verbosity: Debug},
+		{args: []string{"-va"}, verbosity: Verbose},
+		{args: []string{"-vvs"}, verbosity: MoreVerbose},
+		{args: []string{"-vav"}, verbosity: MoreVerbose},
+		{args: []string{"-avvv"}, verbosity: Debug},
+		{args: []string{"-vx"}, verbosity: Silent, unknown: []string{"-vx"}},
+		{args: []string{"-vj"}, verbosity: Silent, unknown: []string{"-vj"}},
+		{args: []string{"--vv"}, verbosity: Silent, unknown: []string{"--vv"}},
+		{args: []string{}, verbosity: Silent},
+	}
+
+	for _, tc := range testCases {
+		cfg.SetVerbosity(Silent)
+		unknown, err := cfg.ParseCommandLineArgs(tc.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.args, err)
+		}
+		if cfg.GetVerbosity() != tc.verbosity {
+			t.Errorf("%v: expected verbosity %v, got %v", tc.args, tc.verbosity, cfg.GetVerbosity())
+		}
+		if strings.Join(unknown, " ") != strings.Join(tc.unknown, " ") {
+			t.Errorf("%v: expected unknown flags %v, got %v", tc.args, tc.unknown, unknown)
+		}
+	}
+
+	// A combination that isn't valid sets none of its flags
+	cfg.ParseCommandLineArgs([]string{"-ax"})
+	if cfg.GetAutoCommit() {
+		t.Errorf("-ax should not enable auto-commit")
+	}
+}
+
+// TestParseCombinedFlags tests that every single-letter flag combined with others is
+// applied, e.g. -aS commits with a sign-off
+func TestParseCombinedFlags(t *testing.T) {
+	cfg := GetInstance()
+	defer cfg.ParseCommandLineArgs(nil)
+	defer cfg.SetSignoff(false)
+
+	cfg.SetSignoff(false)
+	cfg.ParseCommandLineArgs([]string{"-aS"})
+	if !cfg.GetAutoCommit() || !cfg.IsSignoffEnabled() {
+		t.Errorf("-aS should enable auto-commit and the sign-off, got %v and %v", cfg.GetAutoCommit(), cfg.IsSignoffEnabled())
+	}
+
+	cfg.ParseCommandLineArgs([]string{"-aA"})
+	if !cfg.GetAutoCommit() || !cfg.IsStageAllEnabled() {
+		t.Errorf("-aA should enable auto-commit and --all, got %v and %v", cfg.GetAutoCommit(), cfg.IsStageAllEnabled())
+	}
+
+	cfg.ParseCommandLineArgs([]string{"-aC"})
+	if !cfg.GetAutoCommit() || !cfg.IsClipboardEnabled() {
+		t.Errorf("-aC should enable auto-commit and --clipboard, got %v and %v", cfg.GetAutoCommit(), cfg.IsClipboardEnabled())
+	}
+
+	// A single-letter flag that can't be combined is reported rather than ignored
+	if unknown, _ := cfg.ParseCommandLineArgs([]string{"-ah"}); len(unknown) != 1 || unknown[0] != "-ah" {
+		t.Errorf("Expected -ah to be reported as unknown, got %v", unknown)
+	}
+
+	// Every other single-letter flag without a value can be combined
+	for _, flag := range SingleFlags() {
+		if len(flag) == 2 && flag[1] != '-' && flag != "-h" && !strings.Contains(combinedFlagLetters, flag[1:]) {
+			t.Errorf("%s can't be combined with other flags", flag)
+		}
+	}
+}
+
+func TestModelAliases(t *testing.T) {
+	cfg := GetInstance()
+	defer cfg.SetModelAliases(nil)
+	defer cfg.SetModelName(cfg.ModelName)
+
+	cfg.SetModelAliases(map[string]string{
+		"sonnet": "claude-3-5-sonnet-20240620",
+		"4o":     "gpt-4o",
+	})
+
+	if aliases := cfg.GetModelAliases(); len(aliases) != 2 || aliases["4o"] != "gpt-4o" {
+		t.Errorf("Unexpected model aliases: %v", aliases)
+	}
+
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "sonnet", expected: "claude-3-5-sonnet-20240620"},
+		{name: "Sonnet", expected: "claude-3-5-sonnet-20240620"},
+		{name: "4o", expected: "gpt-4o"},
+		{name: "claude-3-haiku-20240307", expected: "claude-3-haiku-20240307"},
+		{name: "", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		if resolved := cfg.ResolveModelAlias(tc.name); resolved != tc.expected {
+			t.Errorf("ResolveModelAlias(%q) = %q, expected %q", tc.name, resolved, tc.expected)
+		}
+	}
+
+	// --model accepts an alias and GetModelName expands it
+	cfg.ParseCommandLineArgs([]string{"--model", "sonnet"})
+	if cfg.GetModelName() != "claude-3-5-sonnet-20240620" {
+		t.Errorf("Expected --model sonnet to resolve, got %v", cfg.GetModelName())
+	}
 }
 
 func TestConfigSaveAndLoad(t *testing.T) {
@@ -184,3 +706,320 @@ func TestConfigSaveAndLoad(t *testing.T) {
 		t.Errorf("Loaded remember flags should be true")
 	}
 }
+
+// TestConfigDirectory tests the config directory lookup on each platform, and that
+// looking it up creates nothing
+func TestConfigDirectory(t *testing.T) {
+	tests := []struct {
+		name     string
+		goos     string
+		env      map[string]string
+		existing []string // Directories under the home directory that already exist
+		want     string   // Relative to the home directory, or absolute
+	}{
+		{name: "linux", goos: "linux", want: ".config/ai-commit-msg"},
+		{name: "linux XDG", goos: "linux", env: map[string]string{"XDG_CONFIG_HOME": "/xdg"}, want: "/xdg/ai-commit-msg"},
+		{name: "other unix", goos: "freebsd", want: ".config/ai-commit-msg"},
+		{name: "macOS new install", goos: "darwin", want: "Library/Application Support/ai-commit-msg"},
+		{name: "macOS earlier install", goos: "darwin", existing: []string{".config/ai-commit-msg"}, want: ".config/ai-commit-msg"},
+		{name: "macOS both", goos: "darwin", existing: []string{".config/ai-commit-msg", "Library/Application Support/ai-commit-msg"}, want: "Library/Application Support/ai-commit-msg"},
+		{name: "macOS XDG", goos: "darwin", env: map[string]string{"XDG_CONFIG_HOME": "/xdg"}, existing: []string{"Library/Application Support/ai-commit-msg"}, want: "/xdg/ai-commit-msg"},
+		{name: "windows APPDATA", goos: "windows", env: map[string]string{"APPDATA": "/appdata"}, existing: []string{".config/ai-commit-msg"}, want: "/appdata/ai-commit-msg"},
+		{name: "windows without APPDATA", goos: "windows", want: ".config/ai-commit-msg"},
+		{name: "windows AppData Local", goos: "windows", existing: []string{"AppData/Local/ai-commit-msg"}, want: "AppData/Local/ai-commit-msg"},
+		{name: "windows XDG", goos: "windows", env: map[string]string{"XDG_CONFIG_HOME": "/xdg", "APPDATA": "/appdata"}, want: "/xdg/ai-commit-msg"},
+	}
+
+	for _, tt := range tests {
+		home := t.TempDir()
+		for _, dir := range tt.existing {
+			if err := os.MkdirAll(filepath.Join(home, dir), 0755); err != nil {
+				t.Fatalf("Could not create %s: %v", dir, err)
+			}
+		}
+
+		locator := configLocator{
+			goos:   tt.goos,
+			getenv: func(key string) string { return tt.env[key] },
+			home:   func() (string, error) { return home, nil },
+			exists: dirExists,
+		}
+		got, err := locator.configDirectory()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		want := filepath.FromSlash(tt.want)
+		if !filepath.IsAbs(want) {
+			want = filepath.Join(home, want)
+		}
+		if got != want {
+			t.Errorf("%s: expected %s, got %s", tt.name, want, got)
+		}
+
+		// Only the directories made by the test are there
+		var found []string
+		filepath.Walk(home, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() && filepath.Base(path) == ConfigDirName {
+				rel, _ := filepath.Rel(home, path)
+				found = append(found, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if len(found) != len(tt.existing) {
+			t.Errorf("%s: expected the lookup to create nothing, found %v", tt.name, found)
+		}
+	}
+
+	// Without a home directory only XDG_CONFIG_HOME and APPDATA can be used
+	locator := configLocator{
+		goos:   "darwin",
+		getenv: func(string) string { return "" },
+		home:   func() (string, error) { return "", fmt.Errorf("no home") },
+		exists: dirExists,
+	}
+	if _, err := locator.configDirectory(); err == nil {
+		t.Error("Expected an error without a home directory")
+	}
+}
+
+func TestRepoConfigOverridesGlobal(t *testing.T) {
+	globalDir, err := os.MkdirTemp("", "config-global")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(globalDir)
+
+	repoDir, err := os.MkdirTemp("", "config-repo")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(repoDir)
+
+	oldXDG := os.Getenv("XDG_CONFIG_HOME")
+	defer os.Setenv("XDG_CONFIG_HOME", oldXDG)
+	os.Setenv("XDG_CONFIG_HOME", globalDir)
+
+	// Global config selects Gemini with 5 context lines
+	globalConfigDir := filepath.Join(globalDir, ConfigDirName)
+	os.MkdirAll(globalConfigDir, 0755)
+	globalConfig := "provider = \"gemini\"\ncontext_lines = 5\n"
+	if err := os.WriteFile(filepath.Join(globalConfigDir, ConfigFileName+".toml"), []byte(globalConfig), 0644); err != nil {
+		t.Fatalf("Could not write global config: %v", err)
+	}
+
+	// The repository overrides only the provider
+	if err := exec.Command("git", "init", repoDir).Run(); err != nil {
+		t.Fatalf("Failed to initialize git repository: %v", err)
+	}
+	repoConfig := "provider = \"openai\"\n"
+	if err := os.WriteFile(filepath.Join(repoDir, RepoConfigFileName), []byte(repoConfig), 0644); err != nil {
+		t.Fatalf("Could not write repository config: %v", err)
+	}
+
+	// Run from a subdirectory to check the file is found in the repository root
+	subDir := filepath.Join(repoDir, "sub")
+	os.MkdirAll(subDir, 0755)
+	oldWd, _ := os.Getwd()
+	defer os.Chdir(oldWd)
+	if err := os.Chdir(subDir); err != nil {
+		t.Fatalf("Could not change directory: %v", err)
+	}
+
+	cfg := &Config{
+		v:          viper.New(),
+		keyManager: key.NewKeyManager(false),
+	}
+	cfg.setDefaults()
+
+	if err := cfg.LoadConfig(); err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+
+	if cfg.GetProvider() != "openai" {
+		t.Errorf("Repository config should override the provider, got %v", cfg.GetProvider())
+	}
+	if cfg.GetContextLines() != 5 {
+		t.Errorf("Global config should still apply for other settings, got %v", cfg.GetContextLines())
+	}
+	if !strings.HasSuffix(cfg.GetRepoConfigFile(), RepoConfigFileName) {
+		t.Errorf("Expected the repository config file to be reported, got %q", cfg.GetRepoConfigFile())
+	}
+}
+
+// TestSaveConfigWithRepoConfig tests that saving inside a repository with its own config
+// only updates the settings the repository file overrides, and keeps personal and global
+// settings out of it
+func TestSaveConfigWithRepoConfig(t *testing.T) {
+	globalDir := t.TempDir()
+	repoDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", globalDir)
+
+	globalConfigDir := filepath.Join(globalDir, ConfigDirName)
+	os.MkdirAll(globalConfigDir, 0755)
+	globalFile := filepath.Join(globalConfigDir, ConfigFileName+".toml")
+	if err := os.WriteFile(globalFile, []byte("provider = \"gemini\"\ncontext_lines = 5\n"), 0644); err != nil {
+		t.Fatalf("Could not write global config: %v", err)
+	}
+	if err := exec.Command("git", "init", repoDir).Run(); err != nil {
+		t.Fatalf("Failed to initialize git repository: %v", err)
+	}
+	repoFile := filepath.Join(repoDir, RepoConfigFileName)
+	if err := os.WriteFile(repoFile, []byte("provider = \"openai\"\n"), 0644); err != nil {
+		t.Fatalf("Could not write repository config: %v", err)
+	}
+	t.Chdir(repoDir)
+
+	cfg := &Config{
+		v:          viper.New(),
+		keyManager: key.NewKeyManager(false),
+	}
+	cfg.setDefaults()
+	if err := cfg.LoadConfig(); err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+
+	// --remember with a new provider and personal settings
+	cfg.RememberFlags = true
+	cfg.SetProvider("anthropic")
+	cfg.SetSigningKey("ABCD1234")
+	cfg.CoAuthors = []string{"Pat <pat@example.com>"}
+	cfg.APIKeyCommands = map[string]string{"openai": "pass show openai"}
+	cfg.CredentialNamespace = "work"
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("Error saving config: %v", err)
+	}
+
+	repoContent, _ := os.ReadFile(repoFile)
+	if !strings.Contains(string(repoContent), "provider = 'anthropic'") {
+		t.Errorf("Expected the repository's provider to be updated, got:\n%s", repoContent)
+	}
+	for _, key := range []string{"signing_key", "co_authors", "api_key_command", "credential_namespace", "model_prices", "context_lines", "config_version"} {
+		if strings.Contains(string(repoContent), key) {
+			t.Errorf("Expected %s to stay out of the repository config, got:\n%s", key, repoContent)
+		}
+	}
+
+	globalContent, _ := os.ReadFile(globalFile)
+	for _, expected := range []string{"provider = 'gemini'", "ABCD1234", "pass show openai", "context_lines = 5"} {
+		if !strings.Contains(string(globalContent), expected) {
+			t.Errorf("Expected %q in the global config, got:\n%s", expected, globalContent)
+		}
+	}
+	if strings.Contains(string(globalContent), "provider = 'anthropic'") {
+		t.Errorf("Expected the repository's provider to stay out of the global config, got:\n%s", globalContent)
+	}
+}
+
+// v0Config is a config file written before config_version existed, with the removed
+// jira_prefix key and a GPT model left in the Claude-only model_name
+const v0Config = `verbosity = 1
+context_lines = 5
+model_name = "gpt-4o"
+provider = "openai"
+jira_prefix = "OPS"
+`
+
+func TestConfigMigrationFromV0(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tempDir)
+
+	configDir := filepath.Join(tempDir, ConfigDirName)
+	os.MkdirAll(configDir, 0755)
+	configFile := filepath.Join(configDir, ConfigFileName+".toml")
+	if err := os.WriteFile(configFile, []byte(v0Config), 0644); err != nil {
+		t.Fatalf("Could not write config: %v", err)
+	}
+
+	cfg := &Config{
+		v:          viper.New(),
+		keyManager: key.NewKeyManager(false),
+	}
+	cfg.setDefaults()
+	if err := cfg.LoadConfig(); err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+
+	if cfg.GetConfigVersion() != CurrentConfigVersion {
+		t.Errorf("Expected config version %d, got %d", CurrentConfigVersion, cfg.GetConfigVersion())
+	}
+	if prefixes := cfg.GetJiraPrefixes(); len(prefixes) != 1 || prefixes[0] != "OPS" {
+		t.Errorf("Expected jira_prefix to become jira_prefixes [OPS], got %v", prefixes)
+	}
+	if cfg.GetModelName() != "" {
+		t.Errorf("Expected the GPT model to be moved out of model_name, got %q", cfg.GetModelName())
+	}
+	if model := cfg.GetProviderModel("openai"); model != "gpt-4o" {
+		t.Errorf("Expected the openai model gpt-4o, got %q", model)
+	}
+	if cfg.GetContextLines() != 5 {
+		t.Errorf("Expected other settings to be kept, got context lines %d", cfg.GetContextLines())
+	}
+
+	// The file itself is rewritten so the migration only runs once
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatalf("Could not read migrated config: %v", err)
+	}
+	if strings.Contains(string(content), "jira_prefix =") {
+		t.Errorf("Expected the removed jira_prefix key to be dropped, got:\n%s", content)
+	}
+	if !strings.Contains(string(content), fmt.Sprintf("config_version = %d", CurrentConfigVersion)) {
+		t.Errorf("Expected config_version to be written, got:\n%s", content)
+	}
+}
+
+func TestMigrationsCoverCurrentVersion(t *testing.T) {
+	if len(migrations) != CurrentConfigVersion {
+		t.Errorf("Expected %d migrations for config version %d, got %d", CurrentConfigVersion, CurrentConfigVersion, len(migrations))
+	}
+}
+
+// TestStarterConfig tests that the starter config documents every setting and loads as the defaults
+func TestStarterConfig(t *testing.T) {
+	for _, setting := range settingKeys() {
+		if _, ok := settingDocs[setting]; !ok {
+			t.Errorf("Setting %s has no description in settingDocs", setting)
+		}
+	}
+
+	content, err := StarterConfig()
+	if err != nil {
+		t.Fatalf("StarterConfig returned error: %v", err)
+	}
+	for _, setting := range settingKeys() {
+		if !strings.Contains(content, "# "+setting+" = ") && !strings.Contains(content, "# ["+setting+"]") {
+			t.Errorf("Expected the starter config to include %s, got:\n%s", setting, content)
+		}
+	}
+
+	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tempDir)
+	cfg := &Config{
+		v:          viper.New(),
+		keyManager: key.NewKeyManager(false),
+	}
+	cfg.setDefaults()
+
+	path, err := cfg.WriteStarterConfig(false)
+	if err != nil {
+		t.Fatalf("WriteStarterConfig returned error: %v", err)
+	}
+	if _, err := cfg.WriteStarterConfig(false); err == nil {
+		t.Error("Expected an error when the config file already exists")
+	}
+	if _, err := cfg.WriteStarterConfig(true); err != nil {
+		t.Errorf("Expected overwrite to replace the file, got %v", err)
+	}
+
+	// The file is valid TOML and loads without a migration rewriting it
+	if err := cfg.LoadConfig(); err != nil {
+		t.Fatalf("Error loading starter config: %v", err)
+	}
+	if cfg.GetConfigVersion() != CurrentConfigVersion || cfg.GetContextLines() != 3 {
+		t.Errorf("Expected the defaults from the starter config, got version %d and %d context lines",
+			cfg.GetConfigVersion(), cfg.GetContextLines())
+	}
+	if written, _ := os.ReadFile(path); string(written) != content {
+		t.Error("Expected the starter config to be left as written")
+	}
+}
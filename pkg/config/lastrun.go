@@ -0,0 +1,84 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LastRunFileName is the file in the config directory holding the options of the last
+// run, which the rerun subcommand repeats
+const LastRunFileName = "last_run.json"
+
+// ErrNoLastRun is returned by LoadLastRun when no run has been saved yet
+var ErrNoLastRun = errors.New("no previous run to repeat")
+
+// lastRunFlags are the options rerun repeats: the provider, model, context lines and
+// Jira ID, description and site. Each short flag maps to its long form, which is what is saved.
+var lastRunFlags = map[string]string{
+	"-p": "--provider", "--provider": "--provider",
+	"-m": "--model", "--model": "--model",
+	"-c": "--context", "--context": "--context",
+	"-j": "--jira", "--jira": "--jira",
+	"-d": "--jira-desc", "--jira-desc": "--jira-desc",
+	"--jira-url": "--jira-url",
+}
+
+// lastRunContextFlags are the context levels and Jira options rerun repeats that take no value
+var lastRunContextFlags = map[string]bool{"-cc": true, "-ccc": true, "--no-jira": true}
+
+// lastRun is the content of the last run file
+type lastRun struct {
+	Args []string `json:"args"`
+}
+
+// LastRunArgs picks the options rerun repeats out of the command-line arguments, in
+// their long form, e.g. ["--provider", "openai", "--jira", "GTN-123"]. Everything else,
+// notably --key, is left out.
+func LastRunArgs(args []string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		if flag, ok := lastRunFlags[args[i]]; ok && i+1 < len(args) {
+			kept = append(kept, flag, args[i+1])
+			i++
+		} else if lastRunContextFlags[args[i]] {
+			kept = append(kept, args[i])
+		}
+	}
+	return kept
+}
+
+// SaveLastRun saves the options rerun repeats from the command-line arguments in the
+// last run file in dir, replacing the previous run
+func SaveLastRun(dir string, args []string) error {
+	data, err := json.MarshalIndent(lastRun{Args: LastRunArgs(args)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// Only the user needs to read the Jira ticket details
+	return os.WriteFile(filepath.Join(dir, LastRunFileName), data, 0600)
+}
+
+// LoadLastRun returns the options saved by SaveLastRun in dir, or ErrNoLastRun when
+// there are none. Command-line arguments given after them take precedence, since
+// later flags win.
+func LoadLastRun(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, LastRunFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoLastRun
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var run lastRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", LastRunFileName, err)
+	}
+	return LastRunArgs(run.Args), nil
+}
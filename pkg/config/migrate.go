@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// CurrentConfigVersion is the config_version written to new config files. Bump it
+// together with a new entry in migrations whenever a key is removed or changes meaning.
+const CurrentConfigVersion = 1
+
+// migrations upgrade the settings read from a config file by one version each:
+// migrations[i] turns version i into version i+1
+var migrations = []func(settings map[string]interface{}){
+	migrateV0,
+}
+
+// migrateV0 upgrades config files written before config_version existed. The single
+// jira_prefix was replaced by the jira_prefixes list, and model_name only holds the
+// Anthropic model, so a model left there for another provider moves to provider_models.
+func migrateV0(settings map[string]interface{}) {
+	if prefix, ok := settings["jira_prefix"].(string); ok && prefix != "" {
+		if _, exists := settings["jira_prefixes"]; !exists {
+			settings["jira_prefixes"] = []string{prefix}
+		}
+	}
+	delete(settings, "jira_prefix")
+
+	model, _ := settings["model_name"].(string)
+	if model == "" {
+		return
+	}
+	resolved := model
+	if aliases, ok := settings["model_aliases"].(map[string]interface{}); ok {
+		if target, ok := aliases[strings.ToLower(model)].(string); ok && target != "" {
+			resolved = target
+		}
+	}
+	if strings.HasPrefix(strings.ToLower(resolved), "claude") {
+		return
+	}
+
+	provider, _ := settings["provider"].(string)
+	provider = strings.ToLower(provider)
+	if provider != "" && provider != "anthropic" {
+		models, ok := settings["provider_models"].(map[string]interface{})
+		if !ok {
+			models = make(map[string]interface{})
+			settings["provider_models"] = models
+		}
+		if current, _ := models[provider].(string); current == "" {
+			models[provider] = model
+		}
+	}
+	settings["model_name"] = ""
+}
+
+// migrate upgrades the config file that was just read to CurrentConfigVersion and
+// rewrites it, then reads it again. The caller must hold c.mu.
+func (c *Config) migrate() error {
+	path := c.v.ConfigFileUsed()
+	if path == "" {
+		return nil
+	}
+
+	// Read the file on its own so defaults and environment variables aren't written back
+	file := viper.New()
+	file.SetConfigFile(path)
+	file.SetConfigType("toml")
+	if err := file.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading config file for migration: %w", err)
+	}
+
+	version := file.GetInt("config_version")
+	if version >= CurrentConfigVersion {
+		return nil
+	}
+
+	settings := file.AllSettings()
+	for ; version < CurrentConfigVersion; version++ {
+		migrations[version](settings)
+	}
+	settings["config_version"] = CurrentConfigVersion
+
+	migrated := viper.New()
+	for key, value := range settings {
+		migrated.Set(key, value)
+	}
+	if err := migrated.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("error writing migrated config file: %w", err)
+	}
+
+	return c.v.ReadInConfig()
+}
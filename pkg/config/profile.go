@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownProfile is returned by LoadConfig when the selected profile isn't defined
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// ProfileFromArgs returns the profile named with --profile NAME in the command-line
+// arguments, or "" if there is none. Profiles are applied by LoadConfig, before the
+// other flags are parsed, so main looks for the flag first.
+func ProfileFromArgs(args []string) string {
+	for i, arg := range args {
+		if arg == "--profile" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// SetProfile selects the profile that LoadConfig overlays on the base config
+func (c *Config) SetProfile(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Profile = strings.ToLower(strings.TrimSpace(name))
+}
+
+// GetProfile returns the profile in use, or "" if there is none
+func (c *Config) GetProfile() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Profile
+}
+
+// ListProfiles returns the names of the profiles defined in the config, sorted
+func (c *Config) ListProfiles() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.profileNames()
+}
+
+// profileNames returns the sorted profile names. The caller must hold the lock.
+func (c *Config) profileNames() []string {
+	var names []string
+	for name := range c.v.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile overlays the settings of the selected profile, from --profile or
+// AI_COMMIT_PROFILE, on the config already read into Viper. The caller must hold
+// the lock.
+func (c *Config) applyProfile() error {
+	name := c.Profile
+	if name == "" {
+		name = strings.ToLower(strings.TrimSpace(os.Getenv(EnvPrefix + "_PROFILE")))
+	}
+	if name == "" {
+		return nil
+	}
+
+	key := "profiles." + name
+	if !c.v.IsSet(key) {
+		c.Profile = ""
+		if names := c.profileNames(); len(names) > 0 {
+			return fmt.Errorf("%w %q; defined profiles: %s", ErrUnknownProfile, name, strings.Join(names, ", "))
+		}
+		return fmt.Errorf("%w %q; no profiles are defined in the config", ErrUnknownProfile, name)
+	}
+
+	if err := c.v.MergeConfigMap(c.v.GetStringMap(key)); err != nil {
+		return fmt.Errorf("error applying profile %q: %w", name, err)
+	}
+	c.Profile = name
+	return nil
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
@@ -23,13 +25,13 @@ func (c *Config) GetProviderModel(provider string) string {
 	
 	// If provider matches the legacy provider (anthropic), use the legacy model setting for backward compatibility
 	if provider == "anthropic" && c.ModelName != "" {
-		return c.ModelName
+		return c.resolveModelAlias(c.ModelName)
 	}
 	
 	// Check provider-specific model map
 	if c.ProviderModels != nil {
 		if model, ok := c.ProviderModels[provider]; ok && model != "" {
-			return model
+			return c.resolveModelAlias(model)
 		}
 	}
 	
@@ -39,17 +41,90 @@ func (c *Config) GetProviderModel(provider string) string {
 		return "gpt-4o"
 	case "gemini", "google":
 		return "gemini-1.5-pro"
+	case "custom":
+		return c.resolveModelAlias(c.CustomModel)
+	case "bedrock":
+		return "anthropic.claude-3-haiku-20240307-v1:0"
 	default:
 		return "claude-3-haiku-20240307" // Default to Anthropic model
 	}
 }
 
+// modelEnvProviders lists the providers whose model can be set with
+// AI_COMMIT_<PROVIDER>_MODEL
+var modelEnvProviders = []string{"anthropic", "openai", "gemini", "custom", "bedrock"}
+
+// ProviderModelEnvVar returns the environment variable that sets a provider's model,
+// e.g. AI_COMMIT_OPENAI_MODEL
+func ProviderModelEnvVar(provider string) string {
+	return EnvPrefix + "_" + strings.ToUpper(provider) + "_MODEL"
+}
+
+// applyProviderModelEnv overrides provider_models with the per-provider environment
+// variables. AI_COMMIT_ANTHROPIC_MODEL wins over AI_COMMIT_MODEL_NAME, and --model
+// still wins over both. The caller must hold c.mu.
+func (c *Config) applyProviderModelEnv() {
+	for _, provider := range modelEnvProviders {
+		if model := strings.TrimSpace(os.Getenv(ProviderModelEnvVar(provider))); model != "" {
+			c.setProviderModel(provider, model)
+		}
+	}
+}
+
+// GetCustomBaseURL returns the base URL of the OpenAI-compatible server used by the custom provider
+func (c *Config) GetCustomBaseURL() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CustomBaseURL
+}
+
+// GetCustomModel returns the default model for the custom provider
+func (c *Config) GetCustomModel() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CustomModel
+}
+
+// GetBedrockRegion returns the AWS region for the bedrock provider, or "" for the
+// region from the AWS configuration
+func (c *Config) GetBedrockRegion() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.BedrockRegion
+}
+
+// IsCustomRequireKeyEnabled returns whether the custom provider needs an API key
+func (c *Config) IsCustomRequireKeyEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CustomRequireKey
+}
+
+// GetFallbackProviders returns the providers to try, in order, when the selected
+// provider fails with a retryable error
+func (c *Config) GetFallbackProviders() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	providers := make([]string, len(c.FallbackProviders))
+	copy(providers, c.FallbackProviders)
+	return providers
+}
+
 // SetProviderModel sets the model for a specific provider
 func (c *Config) SetProviderModel(provider, model string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	
+	c.setProviderModel(provider, model)
+}
+
+// setProviderModel sets the model for a provider; the caller must hold c.mu.
+// An empty provider means the default, anthropic.
+func (c *Config) setProviderModel(provider, model string) {
 	provider = strings.ToLower(provider)
+	if provider == "" {
+		provider = "anthropic"
+	}
 	
 	// For anthropic, also set the legacy ModelName for backward compatibility
 	if provider == "anthropic" {
@@ -87,6 +162,80 @@ func (c *Config) GetProviderKey(provider string) string {
 	return ""
 }
 
+// GetAPIKeyCommand returns the api_key_command configured for a provider, or ""
+func (c *Config) GetAPIKeyCommand(provider string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.APIKeyCommands[strings.ToLower(provider)]
+}
+
+// LoadProviderKey reads the provider's API key from --key-file or its api_key_command
+// and keeps it as the provider's key, so it takes precedence over the environment and
+// the credential store. A key given with --key wins over both. --key-file only applies
+// to the selected provider. The key itself is never logged.
+func (c *Config) LoadProviderKey(provider string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	provider = strings.ToLower(provider)
+	if provider == "" {
+		provider = "anthropic"
+	}
+	if c.ProviderKeys[provider] != "" {
+		return nil
+	}
+
+	var key string
+	if selected := strings.ToLower(c.Provider); c.KeyFile != "" && (selected == provider || (selected == "" && provider == "anthropic")) {
+		content, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return fmt.Errorf("could not read key file: %w", err)
+		}
+		key = strings.TrimSpace(string(content))
+		if key == "" {
+			return fmt.Errorf("key file %s is empty", c.KeyFile)
+		}
+	} else if command := c.APIKeyCommands[provider]; command != "" {
+		var err error
+		if key, err = runKeyCommand(command); err != nil {
+			return fmt.Errorf("api_key_command for %s failed: %w", provider, err)
+		}
+	} else {
+		return nil
+	}
+
+	if c.ProviderKeys == nil {
+		c.ProviderKeys = make(map[string]string)
+	}
+	c.ProviderKeys[provider] = key
+	if provider == "anthropic" {
+		c.APIKey = key
+	}
+	return nil
+}
+
+// runKeyCommand runs an api_key_command through the shell and returns its trimmed
+// output. Its stderr is passed through so secret managers can prompt for a login.
+func runKeyCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(output))
+	if key == "" {
+		return "", fmt.Errorf("the command printed no key")
+	}
+	return key, nil
+}
+
 // SetProviderKey sets the API key for a specific provider
 func (c *Config) SetProviderKey(provider, key string) {
 	c.mu.Lock()
@@ -131,8 +280,9 @@ func (c *Config) GetProviderPromptPath(provider, promptType string) (string, err
 	return filepath.Join(providerDir, promptType), nil
 }
 
-// ReadProviderPrompt reads a prompt file for a specific provider
-func (c *Config) ReadProviderPrompt(provider, promptFile string) (string, error) {
+// ReadProviderPrompt reads a prompt file for a specific provider and returns its
+// content along with the path it was read from
+func (c *Config) ReadProviderPrompt(provider, promptFile string) (string, string, error) {
 	provider = strings.ToLower(provider)
 	
 	// Try custom prompt paths first (these override provider-specific paths)
@@ -146,46 +296,30 @@ func (c *Config) ReadProviderPrompt(provider, promptFile string) (string, error)
 	if customPath != "" {
 		content, err := os.ReadFile(customPath)
 		if err == nil {
-			return string(content), nil
+			return string(content), customPath, nil
 		}
 	}
 	
-	// Try provider-specific path in config directory
-	promptDir, err := c.GetPromptDirectory()
-	if err == nil {
-		providerPromptDir := filepath.Join(promptDir, provider)
-		promptPath := filepath.Join(providerPromptDir, promptFile)
-		
-		if _, err := os.Stat(promptPath); err == nil {
-			content, err := os.ReadFile(promptPath)
-			if err == nil {
-				return string(content), nil
-			}
+	// Try the prompt_dir override, then the config directory: the provider-specific
+	// path, the anthropic prompts for other providers and the root prompt directory
+	// (legacy location)
+	for _, promptDir := range c.GetPromptSearchDirectories() {
+		candidates := []string{filepath.Join(promptDir, provider, promptFile)}
+		if provider != "anthropic" {
+			candidates = append(candidates, filepath.Join(promptDir, "anthropic", promptFile))
 		}
-	}
-	
-	// If not found and provider isn't anthropic, fall back to anthropic prompts
-	if provider != "anthropic" {
-		anthropicPromptDir := filepath.Join(promptDir, "anthropic")
-		promptPath := filepath.Join(anthropicPromptDir, promptFile)
+		candidates = append(candidates, filepath.Join(promptDir, promptFile))
 		
-		if _, err := os.Stat(promptPath); err == nil {
-			content, err := os.ReadFile(promptPath)
-			if err == nil {
-				return string(content), nil
+		for _, promptPath := range candidates {
+			if _, err := os.Stat(promptPath); err == nil {
+				content, err := os.ReadFile(promptPath)
+				if err == nil {
+					return string(content), promptPath, nil
+				}
 			}
 		}
 	}
 	
-	// Try root prompt directory (legacy location)
-	promptPath := filepath.Join(promptDir, promptFile)
-	if _, err := os.Stat(promptPath); err == nil {
-		content, err := os.ReadFile(promptPath)
-		if err == nil {
-			return string(content), nil
-		}
-	}
-	
 	// Finally, try prompts directory relative to executable
 	// Note: This requires executableDir to be accessible
 	executableDir := c.GetExecutableDir()
@@ -195,13 +329,13 @@ func (c *Config) ReadProviderPrompt(provider, promptFile string) (string, error)
 		
 		content, err := os.ReadFile(promptPath)
 		if err != nil {
-			return "", fmt.Errorf("failed to read prompt file from any location: %v", err)
+			return "", "", fmt.Errorf("failed to read prompt file from any location: %v", err)
 		}
 		
-		return string(content), nil
+		return string(content), promptPath, nil
 	}
 	
-	return "", fmt.Errorf("could not find prompt file %s for provider %s", promptFile, provider)
+	return "", "", fmt.Errorf("could not find prompt file %s for provider %s", promptFile, provider)
 }
 
 // SetExecutableDir sets the executable directory for prompt loading
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nycjay/ai-commit-msg/pkg/git"
+)
+
+// RepoConfigFileName is the per-repository config file, looked up in the repository root
+const RepoConfigFileName = ".ai-commit-msg.toml"
+
+// RepoDirFromArgs returns the directory named with --repo DIR or -R DIR in the
+// command-line arguments, or "" if there is none. git has to run in that directory
+// before LoadConfig looks for the repository config, so main looks for the flag first.
+func RepoDirFromArgs(args []string) string {
+	for i, arg := range args {
+		if (arg == "--repo" || arg == "-R") && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// findRepoConfigFile returns the path of the repository config file, or "" if there
+// is none. The file is looked up in the git toplevel so it applies from any
+// subdirectory, falling back to the current directory outside a git repository.
+func findRepoConfigFile() string {
+	root, _ := git.CurrentRepo().Toplevel()
+	if root == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return ""
+		}
+		root = cwd
+	}
+
+	path := filepath.Join(root, RepoConfigFileName)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return ""
+	}
+	return path
+}
+
+// mergeRepoConfig merges the repository config file over the global config already
+// read into Viper. The caller must hold the lock.
+func (c *Config) mergeRepoConfig() error {
+	c.repoConfigFile = findRepoConfigFile()
+	if c.repoConfigFile == "" {
+		return nil
+	}
+
+	file, err := os.Open(c.repoConfigFile)
+	if err != nil {
+		return fmt.Errorf("error opening repository config file: %w", err)
+	}
+	defer file.Close()
+
+	if err := c.v.MergeConfig(file); err != nil {
+		return fmt.Errorf("error reading repository config file %s: %w", c.repoConfigFile, err)
+	}
+	return nil
+}
+
+// GetRepoConfigFile returns the repository config file in effect, or "" if there is none
+func (c *Config) GetRepoConfigFile() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.repoConfigFile
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/viper"
+)
+
+// settingDocs explains each setting in the config file. Every persisted Config field
+// needs an entry so the starter config written by init-config covers all settings.
+var settingDocs = map[string]string{
+	"verbosity":            "Log level: 0 silent, 1 normal, 2 verbose, 3 more verbose, 4 debug",
+	"context_lines":        "Lines of context around each change in the diff",
+	"remember_flags":       "Save command-line options to this file on every run",
+	"model_name":           "Anthropic model (other providers use provider_models)",
+	"system_prompt_path":   "Custom system prompt file",
+	"system_prompt_prefix": "Text added before the system prompt, e.g. \"Always use the imperative mood.\"",
+	"system_prompt_suffix": "Text added after the system prompt, before the language instruction",
+	"user_prompt_path":     "Custom user prompt file",
+	"prompt_dir":           "Directory with custom prompt files, instead of the prompts directory next to this file",
+	"enhanced_context":     "Send file contents, history and related files along with the diff",
+	"stream":               "Stream the message to the terminal as it is generated",
+	"body":                 "Ask for a subject line plus a bulleted body",
+	"signoff":              "Add a Signed-off-by trailer from git config user.name/user.email",
+	"attribution":          "Add a trailer such as AI-Generated-By: anthropic/claude-3-haiku-20240307 to commits",
+	"attribution_trailer":  "Key of the trailer added by attribution",
+	"co_authors":           "Co-authors credited on every commit, e.g. [\"Jane Doe <jane@example.com>\"]",
+	"edit_always":          "Open every message in the editor and commit what is saved, instead of asking",
+	"structured_output":    "Ask OpenAI and Gemini models for the message as JSON fields (type, scope, subject, body) instead of text",
+	"untracked_context":    "List untracked files next to the changed files, such as a new test not yet added",
+	"unstaged_context":     "Send the working-tree content of the staged files, unstaged edits included, as read-only context",
+	"scan_secrets":         "Check the diff for secrets such as API keys and private keys before sending it",
+	"secret_patterns":      "Regular expressions for more secrets to check for, e.g. [\"corp_[0-9]{6}\"]",
+	"jira_prefixes":        "Jira project prefixes detected in branch names",
+	"jira_enabled":         "Look for Jira IDs in branch names and pass them to the prompt; false for repositories without Jira",
+	"jira_base_url":        "Jira site such as https://company.atlassian.net; commits get a Jira trailer linking to each ticket",
+	"style_examples":       "Number of recent commit messages to include as style examples",
+	"timeout":              "Timeout for requests to the provider, e.g. 60s or 2m; with candidates, the deadline for the whole set, per batch of 4 requests",
+	"candidate_timeout":    "Time allowed for each candidate request in place of timeout (0s to use timeout)",
+	"candidates_deadline":  "Deadline for the whole set of candidates (0s for one candidate timeout per batch of 4)",
+	"language":             "Language to write messages in, e.g. es or de (empty for English)",
+	"max_prompt_tokens":    "Warn before sending a prompt larger than about this many tokens (0 for no limit)",
+	"max_diff_bytes":       "Truncate diffs larger than this many bytes, keeping the start of each file (0 for no limit)",
+	"candidates":           "Number of candidate messages to choose from",
+	"post_generate_hook":   "Command that receives the message on stdin and prints the message to use",
+	"log_file":             "Append log output to this file instead of printing it to the terminal",
+	"large_file_threshold": "Files larger than this many bytes are left out of enhanced context",
+	"issue_style":          "Issue references detected in branch names: jira, gitlab or both",
+	"allow_unknown_model":  "Accept models that aren't in the provider's list",
+	"notes_ref":            "Notes ref that --notes writes to (empty for git's refs/notes/commits)",
+	"max_subject_length":   "Warn about subject lines longer than this many characters (0 for no limit)",
+	"credential_namespace": "Store API keys under a separate credential store service, e.g. \"work\" for ai-commit-msg/work",
+	"context_file":         "File describing the project (architecture, conventions) to send instead of the README summary",
+	"sign_commits":         "Sign commits with GPG even if git's commit.gpgsign is off",
+	"signing_key":          "Key ID to sign commits with (empty for git's user.signingkey)",
+	"provider":             "Provider to generate messages with: anthropic, openai, gemini or custom",
+	"custom_base_url":      "OpenAI-compatible server for the custom provider; /chat/completions is appended",
+	"custom_model":         "Model to request from the custom server",
+	"custom_require_key":   "Send an API key to the custom server",
+	"bedrock_region":       "AWS region for the bedrock provider (empty for the region from the AWS configuration, or us-east-1)",
+	"fallback_providers":   "Providers to try, in order, when the provider is rate limited or unavailable",
+	"provider_models":      "Model to use for each provider",
+	"model_aliases":        "Short names for model IDs, e.g. sonnet = \"claude-3-5-sonnet-20240620\"",
+	"model_prices":         "Prices in US dollars per million tokens, e.g. \"gpt-4o\" = { input = 2.5, output = 10.0 }",
+	"templates":            "User prompt template for each likely change type (feat, docs, test, refactor or chore), e.g. docs = \"/home/me/prompts/docs.txt\"; default is used for the rest",
+	"key_bindings":         "Keys for the choices after a message is shown, e.g. regenerate = \"g\" (yes, edit, no, regenerate, copy, body, model)",
+	"api_key_command":      "Command that prints the API key for each provider, e.g. openai = \"op read op://dev/openai/key\"",
+}
+
+// settingKeys returns the keys of the persisted Config fields in declaration order
+func settingKeys() []string {
+	var keys []string
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		key := configType.Field(i).Tag.Get("mapstructure")
+		if key != "" && key != "-" && key != "config_version" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// StarterConfig returns a config file with every setting commented out at its default
+// value, each with a one-line explanation. Settings that are tables come last so that
+// uncommenting one doesn't pull the settings after it into the table.
+func StarterConfig() (string, error) {
+	defaults := &Config{v: viper.New()}
+	defaults.setDefaults()
+
+	var b strings.Builder
+	b.WriteString("# ai-commit-msg configuration\n")
+	b.WriteString("#\n")
+	b.WriteString("# Every setting is shown with its default value. Uncomment a line to change it.\n")
+	b.WriteString("# Settings in .ai-commit-msg.toml at the root of a repository override this file.\n\n")
+	b.WriteString("# Format version of this file; don't change it\n")
+	fmt.Fprintf(&b, "config_version = %d\n", CurrentConfigVersion)
+
+	var tables []string
+	for _, key := range settingKeys() {
+		value := defaults.v.Get(key)
+		if reflect.ValueOf(value).Kind() == reflect.Map {
+			tables = append(tables, key)
+			continue
+		}
+		if err := writeSetting(&b, key, value); err != nil {
+			return "", err
+		}
+	}
+	for _, key := range tables {
+		if err := writeSetting(&b, key, defaults.v.Get(key)); err != nil {
+			return "", err
+		}
+	}
+
+	return b.String(), nil
+}
+
+// writeSetting writes the explanation and the commented-out default for one setting
+func writeSetting(b *strings.Builder, key string, value interface{}) error {
+	doc, ok := settingDocs[key]
+	if !ok {
+		return fmt.Errorf("no description for setting %s", key)
+	}
+
+	encoded, err := toml.Marshal(map[string]interface{}{key: value})
+	if err != nil {
+		return fmt.Errorf("error encoding default for %s: %w", key, err)
+	}
+
+	fmt.Fprintf(b, "\n# %s\n", doc)
+	for _, line := range strings.Split(strings.TrimRight(string(encoded), "\n"), "\n") {
+		fmt.Fprintf(b, "# %s\n", line)
+	}
+	return nil
+}
+
+// WriteStarterConfig writes StarterConfig to the config file and returns its path. An
+// existing file is only replaced when overwrite is set.
+func (c *Config) WriteStarterConfig(overwrite bool) (string, error) {
+	configDir, err := c.GetConfigDirectory()
+	if err != nil {
+		return "", fmt.Errorf("could not determine config directory: %w", err)
+	}
+	path := filepath.Join(configDir, ConfigFileName+".toml")
+
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return path, fmt.Errorf("%s already exists; use --force to overwrite it", path)
+	}
+
+	content, err := StarterConfig()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write config file: %w", err)
+	}
+	return path, nil
+}
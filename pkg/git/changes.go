@@ -0,0 +1,237 @@
+package git
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// modeChangePattern matches a mode change in "git diff --summary" output
+var modeChangePattern = regexp.MustCompile(`^\s*mode change (\d+) => (\d+) (.+)$`)
+
+// FileChange describes how one file changed
+type FileChange struct {
+	Status  string // A (added), M (modified), D (deleted), R (renamed), C (copied) or T (type changed)
+	Path    string // Path after the change
+	OldPath string // Path before a rename or copy
+	Binary  bool   // Whether git treats the file as binary
+
+	OldMode        string // Mode before a mode change, e.g. 100644; empty if the mode didn't change
+	NewMode        string // Mode after a mode change, e.g. 100755
+	WhitespaceOnly bool   // Whether only whitespace and blank lines changed
+}
+
+// Note returns a human-readable description for changes whose diff says little on its
+// own, such as "Renamed a.go → b.go", "Updated binary image.png" or "Changed mode of
+// run.sh to 755 (made executable)", or "" otherwise
+func (fc FileChange) Note() string {
+	var notes []string
+	if note := fc.statusNote(); note != "" {
+		notes = append(notes, note)
+	}
+	if fc.OldMode != "" && fc.NewMode != "" {
+		notes = append(notes, fc.modeNote())
+	}
+	if fc.WhitespaceOnly {
+		notes = append(notes, "Changed only whitespace in "+fc.Path)
+	}
+	return strings.Join(notes, ". ")
+}
+
+// modeNote describes a mode change, e.g. "Changed mode of run.sh to 755 (made executable)"
+func (fc FileChange) modeNote() string {
+	permissions := func(mode string) string {
+		if len(mode) > 3 {
+			return mode[len(mode)-3:]
+		}
+		return mode
+	}
+
+	note := fmt.Sprintf("Changed mode of %s to %s", fc.Path, permissions(fc.NewMode))
+	switch {
+	case fc.OldMode == "100644" && fc.NewMode == "100755":
+		note += " (made executable)"
+	case fc.OldMode == "100755" && fc.NewMode == "100644":
+		note += " (no longer executable)"
+	}
+	return note
+}
+
+// statusNote describes renames, copies, type changes and binary files
+func (fc FileChange) statusNote() string {
+	if fc.Binary {
+		switch fc.Status {
+		case "A":
+			return "Added binary " + fc.Path
+		case "D":
+			return "Deleted binary " + fc.Path
+		case "R":
+			return fmt.Sprintf("Renamed binary %s → %s", fc.OldPath, fc.Path)
+		default:
+			return "Updated binary " + fc.Path
+		}
+	}
+
+	switch fc.Status {
+	case "R":
+		return fmt.Sprintf("Renamed %s → %s", fc.OldPath, fc.Path)
+	case "C":
+		return fmt.Sprintf("Copied %s → %s", fc.OldPath, fc.Path)
+	case "T":
+		return "Changed the file type of " + fc.Path
+	}
+	return ""
+}
+
+// GetFileChanges returns the status of each changed file for the given diff arguments,
+// e.g. GetFileChanges("--cached") for the staged changes. Renames, mode changes and
+// whitespace-only changes are detected.
+func GetFileChanges(args ...string) ([]FileChange, error) {
+	output, err := Command(append([]string{"diff", "--find-renames", "--name-status", "-z"}, args...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	changes := parseNameStatus(string(output))
+
+	// --numstat reports "-" instead of line counts for binary files
+	output, err = Command(append([]string{"diff", "--find-renames", "--numstat", "-z"}, args...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	counts := parseNumstat(string(output))
+
+	// Files whose changed lines all disappear when whitespace is ignored only changed whitespace
+	output, err = Command(append([]string{"diff", "--find-renames", "--numstat", "-z",
+		"--ignore-all-space", "--ignore-blank-lines"}, args...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	countsIgnoringSpace := parseNumstat(string(output))
+
+	// A chmod alone has no changed lines, so the summary is the only place it shows up
+	output, err = Command(append([]string{"diff", "--find-renames", "--summary", "-z"}, args...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	modes := parseModeChanges(string(output))
+
+	for i := range changes {
+		path := changes[i].Path
+		changes[i].Binary = counts[path] == "-\t-"
+		changes[i].WhitespaceOnly = !changes[i].Binary && hasLineCounts(counts[path]) && !hasLineCounts(countsIgnoringSpace[path])
+		if mode, ok := modes[path]; ok && changes[i].Status != "T" {
+			changes[i].OldMode, changes[i].NewMode = mode[0], mode[1]
+		}
+	}
+
+	return changes, nil
+}
+
+// HasLineChanges reports whether a diff has any hunks or binary changes. A diff of
+// mode changes alone only has file headers.
+func HasLineChanges(diff string) bool {
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "Binary files ") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNameStatus parses "git diff --name-status -z" output, where renames and copies
+// are followed by both the old and the new path
+func parseNameStatus(output string) []FileChange {
+	fields := strings.Split(output, "\x00")
+	var changes []FileChange
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "" {
+			continue
+		}
+		status := fields[i][:1]
+		if (status == "R" || status == "C") && i+2 < len(fields) {
+			changes = append(changes, FileChange{Status: status, OldPath: fields[i+1], Path: fields[i+2]})
+			i += 2
+		} else if i+1 < len(fields) {
+			changes = append(changes, FileChange{Status: status, Path: fields[i+1]})
+			i++
+		}
+	}
+	return changes
+}
+
+// parseNumstat returns the "added\tdeleted" line counts by new path in "git diff
+// --numstat -z" output, with "-\t-" for binary files. A rename has an empty path
+// followed by the old and the new path.
+func parseNumstat(output string) map[string]string {
+	counts := make(map[string]string)
+	fields := strings.Split(output, "\x00")
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := parts[2]
+		if path == "" && i+2 < len(fields) {
+			path = fields[i+2]
+			i += 2
+		}
+		counts[path] = parts[0] + "\t" + parts[1]
+	}
+	return counts
+}
+
+// hasLineCounts reports whether numstat counts show added or deleted text lines
+func hasLineCounts(counts string) bool {
+	return counts != "" && counts != "0\t0" && counts != "-\t-"
+}
+
+// parseModeChanges returns the old and new mode by path from "git diff --summary -z"
+// output, whose lines are " mode change 100644 => 100755 path". With -z the paths
+// aren't quoted.
+func parseModeChanges(output string) map[string][2]string {
+	modes := make(map[string][2]string)
+	for _, line := range strings.Split(output, "\n") {
+		if match := modeChangePattern.FindStringSubmatch(line); match != nil {
+			modes[match[3]] = [2]string{match[1], match[2]}
+		}
+	}
+	return modes
+}
+
+// GetUntrackedFiles returns up to limit untracked, non-ignored files that sit in the
+// same directories as the given files, which are relative to the repository root
+func GetUntrackedFiles(files []string, limit int) ([]string, error) {
+	dirs := make(map[string]bool)
+	var pathspecs []string
+	for _, file := range files {
+		dir := filepath.ToSlash(filepath.Dir(file))
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		pathspecs = append(pathspecs, ":(top)"+dir)
+	}
+	if len(pathspecs) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"ls-files", "--others", "--exclude-standard", "--full-name", "-z", "--"}, pathspecs...)
+	output, err := Command(args...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// ls-files lists whole subtrees, so keep only the files directly in the directories
+	var untracked []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path == "" || !dirs[filepath.ToSlash(filepath.Dir(path))] {
+			continue
+		}
+		untracked = append(untracked, path)
+		if len(untracked) == limit {
+			break
+		}
+	}
+	return untracked, nil
+}
@@ -0,0 +1,153 @@
+package git
+
+import (
+	"path"
+	"strings"
+)
+
+// testDirs and docDirs are directory names whose files count as tests or documentation
+var (
+	testDirs = map[string]bool{"test": true, "tests": true, "__tests__": true, "spec": true, "testdata": true}
+	docDirs  = map[string]bool{"doc": true, "docs": true, "documentation": true}
+)
+
+// docExtensions are the extensions of documentation files. Plain .txt files aren't
+// included since they are often data or prompts.
+var docExtensions = map[string]bool{".md": true, ".markdown": true, ".rst": true, ".adoc": true}
+
+// diffFileStat is what InferChangeType needs to know about one file of a diff
+type diffFileStat struct {
+	path    string
+	added   int // Added lines, as counted by git diff --numstat
+	deleted int // Deleted lines
+	isNew   bool
+	removed bool // Whether the whole file was deleted
+}
+
+// InferChangeType guesses the Conventional Commits type of a diff from its file paths
+// and line counts: "test" when only tests changed, "docs" when only documentation
+// did, "chore" when files were only deleted, "feat" when a new source file was added
+// and "refactor" when far more lines were deleted than added. It returns "" when
+// nothing stands out, e.g. for most fixes.
+func InferChangeType(diff string) string {
+	files := diffFileStats(diff)
+	if len(files) == 0 {
+		return ""
+	}
+
+	allTests, allDocs, allRemoved := true, true, true
+	newSource := false
+	added, deleted := 0, 0
+	for _, file := range files {
+		test, doc := isTestFile(file.path), isDocFile(file.path)
+		allTests = allTests && test
+		allDocs = allDocs && doc
+		allRemoved = allRemoved && file.removed
+		newSource = newSource || (file.isNew && !test && !doc)
+		added += file.added
+		deleted += file.deleted
+	}
+
+	switch {
+	case allTests:
+		return "test"
+	case allDocs:
+		return "docs"
+	case allRemoved:
+		return "chore"
+	case newSource:
+		return "feat"
+	case deleted > 0 && deleted >= 2*added:
+		return "refactor"
+	}
+	return ""
+}
+
+// diffFileStats reads the path, line counts and whether each file was added or
+// deleted from a git diff or a plain unified diff
+func diffFileStats(diff string) []*diffFileStat {
+	var files []*diffFileStat
+	var current *diffFileStat
+	inHunk := false
+
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			current = &diffFileStat{}
+			files = append(files, current)
+			inHunk = false
+			if idx := strings.LastIndex(line, " b/"); idx >= 0 {
+				current.path = line[idx+3:]
+			}
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") &&
+			(current == nil || inHunk || current.path == ""):
+			// A plain unified diff starts each file with its "---"/"+++" headers
+			if current == nil || inHunk {
+				current = &diffFileStat{}
+				files = append(files, current)
+			}
+			inHunk = false
+			oldPath, newPath := diffHeaderPath(line, "a/"), diffHeaderPath(lines[i+1], "b/")
+			current.isNew = current.isNew || oldPath == "/dev/null"
+			current.removed = current.removed || newPath == "/dev/null"
+			if newPath == "/dev/null" {
+				current.path = oldPath
+			} else {
+				current.path = newPath
+			}
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case inHunk && strings.HasPrefix(line, "+"):
+			current.added++
+		case inHunk && strings.HasPrefix(line, "-"):
+			current.deleted++
+		case inHunk:
+			continue
+		case strings.HasPrefix(line, "new file mode"):
+			current.isNew = true
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.removed = true
+		}
+	}
+	return files
+}
+
+// isTestFile reports whether a path looks like a test, e.g. parser_test.go,
+// app.spec.ts, test_parser.py or anything under a tests directory
+func isTestFile(file string) bool {
+	base := path.Base(file)
+	if strings.Contains(base, "_test.") || strings.Contains(base, ".test.") ||
+		strings.Contains(base, ".spec.") || strings.HasPrefix(base, "test_") {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(file), "/") {
+		if testDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// isDocFile reports whether a path looks like documentation, e.g. README.md,
+// CHANGELOG, LICENSE or anything under a docs directory
+func isDocFile(file string) bool {
+	base := path.Base(file)
+	if docExtensions[strings.ToLower(path.Ext(base))] {
+		return true
+	}
+	upper := strings.ToUpper(base)
+	for _, name := range []string{"README", "CHANGELOG", "LICENSE", "CONTRIBUTING"} {
+		if strings.HasPrefix(upper, name) {
+			return true
+		}
+	}
+	for _, dir := range strings.Split(path.Dir(file), "/") {
+		if docDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
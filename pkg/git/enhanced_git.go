@@ -2,11 +2,31 @@ package git
 
 import (
 	"fmt"
-	"os/exec"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// DefaultLargeFileThreshold is the size in bytes above which file contents are left out
+const DefaultLargeFileThreshold int64 = 100 * 1024
+
+// largeFileThreshold is the size in bytes above which a file counts as large
+var largeFileThreshold = DefaultLargeFileThreshold
+
+// omittedContent stands in for the content of binary and large files
+const omittedContent = "[Binary or large file, content not included]"
+
+// SetLargeFileThreshold sets the size in bytes above which file contents are left out
+// of the enhanced context. A non-positive threshold restores the default.
+func SetLargeFileThreshold(bytes int64) {
+	if bytes <= 0 {
+		bytes = DefaultLargeFileThreshold
+	}
+	largeFileThreshold = bytes
+}
+
 // EnhancedGitDiff extends GitDiff with additional context information
 type EnhancedGitDiff struct {
 	GitDiff
@@ -19,6 +39,19 @@ type EnhancedGitDiff struct {
 	ProjectContext string                       // Brief description of the project context
 }
 
+// ToGitDiff returns the GitDiff passed to the providers, carrying over the enhanced
+// context fields that the enhanced user prompt template reads
+func (e EnhancedGitDiff) ToGitDiff() GitDiff {
+	diffInfo := e.GitDiff
+	diffInfo.ProjectContext = e.ProjectContext
+	diffInfo.FileContents = e.FileContents
+	diffInfo.FileTypes = e.FileTypes
+	diffInfo.CommitHistory = e.CommitHistory
+	diffInfo.FileSummaries = e.FileSummaries
+	diffInfo.RelatedFiles = e.RelatedFiles
+	return diffInfo
+}
+
 // GetEnhancedGitDiff retrieves detailed information about staged changes
 func GetEnhancedGitDiff(jiraID, jiraDesc string, contextLines int) (EnhancedGitDiff, error) {
 	// Initialize the enhanced diff
@@ -35,13 +68,13 @@ func GetEnhancedGitDiff(jiraID, jiraDesc string, contextLines int) (EnhancedGitD
 	}
 
 	// Check if we're in a git repository
-	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	cmd := Command("rev-parse", "--is-inside-work-tree")
 	if err := cmd.Run(); err != nil {
 		return enhancedDiff, fmt.Errorf("not in a git repository")
 	}
 
 	// Get list of staged files
-	cmd = exec.Command("git", "diff", "--name-only", "--cached")
+	cmd = Command("diff", "--name-only", "--cached")
 	output, err := cmd.Output()
 	if err != nil {
 		return enhancedDiff, fmt.Errorf("error getting staged files: %v", err)
@@ -54,13 +87,13 @@ func GetEnhancedGitDiff(jiraID, jiraDesc string, contextLines int) (EnhancedGitD
 	}
 	
 	enhancedDiff.StagedFiles = strings.Split(stagingOutput, "\n")
-	fmt.Printf("Found %d staged files\n", len(enhancedDiff.StagedFiles))
+	logf("Found %d staged files", len(enhancedDiff.StagedFiles))
 	for _, file := range enhancedDiff.StagedFiles {
-		fmt.Printf("  %s\n", file)
+		logf("  %s", file)
 	}
 
 	// Get the standard diff with context
-	cmd = exec.Command("git", "diff", "--cached", fmt.Sprintf("--unified=%d", contextLines))
+	cmd = Command("diff", "--cached", fmt.Sprintf("--unified=%d", contextLines))
 	output, err = cmd.Output()
 	if err != nil {
 		return enhancedDiff, fmt.Errorf("error getting diff: %v", err)
@@ -68,17 +101,18 @@ func GetEnhancedGitDiff(jiraID, jiraDesc string, contextLines int) (EnhancedGitD
 	enhancedDiff.Diff = string(output)
 
 	// Get branch information
-	cmd = exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	output, err = cmd.Output()
-	if err != nil {
-		fmt.Printf("Warning: Failed to get branch name: %v\n", err)
+	if branch, err := CurrentRepo().Branch(); err != nil {
+		logf("Warning: Failed to get branch name: %v", err)
 	} else {
-		enhancedDiff.Branch = strings.TrimSpace(string(output))
-		fmt.Printf("Branch: %s\n", enhancedDiff.Branch)
+		enhancedDiff.Branch = branch
+		logf("Branch: %s", enhancedDiff.Branch)
 
 		// Try to extract Jira ID from branch name if not provided
 		if enhancedDiff.JiraID == "" && enhancedDiff.Branch != "" {
-			enhancedDiff.JiraID = extractJiraIDFromBranchName(enhancedDiff.Branch)
+			enhancedDiff.JiraIDs = ExtractJiraIDsFromBranchName(enhancedDiff.Branch)
+			if len(enhancedDiff.JiraIDs) > 0 {
+				enhancedDiff.JiraID = enhancedDiff.JiraIDs[0]
+			}
 		}
 	}
 
@@ -95,10 +129,10 @@ func GetEnhancedGitDiff(jiraID, jiraDesc string, contextLines int) (EnhancedGitD
 		// Get full file content for important context
 		if isBinaryFile(file) || isLargeFile(file) {
 			// Skip binary or very large files
-			enhancedDiff.FileContents[file] = "[Binary or large file, content not included]"
+			enhancedDiff.FileContents[file] = omittedContent
 		} else {
 			// Get the staged version of the file
-			cmd = exec.Command("git", "show", fmt.Sprintf(":%s", file))
+			cmd = Command("show", fmt.Sprintf(":%s", file))
 			output, err := cmd.Output()
 			if err == nil {
 				enhancedDiff.FileContents[file] = string(output)
@@ -106,7 +140,7 @@ func GetEnhancedGitDiff(jiraID, jiraDesc string, contextLines int) (EnhancedGitD
 		}
 
 		// Get commit history for the file (last 3 commits)
-		cmd = exec.Command("git", "log", "-n", "3", "--pretty=format:%h %s", "--", file)
+		cmd = Command("log", "-n", "3", "--pretty=format:%h %s", "--", file)
 		output, err = cmd.Output()
 		if err == nil && len(output) > 0 {
 			history := strings.Split(strings.TrimSpace(string(output)), "\n")
@@ -131,9 +165,71 @@ func GetEnhancedGitDiff(jiraID, jiraDesc string, contextLines int) (EnhancedGitD
 	return enhancedDiff, nil
 }
 
+// WorkingTreeFile is the current content of a staged file in the working tree, sent
+// with --include-diff-context-from-unstaged as read-only context around the change
+type WorkingTreeFile struct {
+	Path     string
+	Content  string
+	Unstaged bool // Whether the file has edits that aren't staged, so it differs from the commit
+}
+
+// GetWorkingTreeFiles reads the working-tree content of the staged files, which are
+// relative to the repository root, as FileContents does for their staged content.
+// Files deleted from the working tree are left out.
+func GetWorkingTreeFiles(files []string) ([]WorkingTreeFile, error) {
+	top, err := CurrentRepo().Toplevel()
+	if err != nil {
+		return nil, err
+	}
+
+	var pathspecs []string
+	for _, file := range files {
+		if file != "" {
+			pathspecs = append(pathspecs, ":(top)"+file)
+		}
+	}
+	if len(pathspecs) == 0 {
+		return nil, nil
+	}
+
+	// Files with unstaged edits, whose working-tree content isn't what is committed
+	args := append([]string{"diff", "--name-only", "-z", "--"}, pathspecs...)
+	output, err := Command(args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error getting unstaged files: %v", err)
+	}
+	unstaged := make(map[string]bool)
+	for _, file := range strings.Split(string(output), "\x00") {
+		unstaged[file] = file != ""
+	}
+
+	var contents []WorkingTreeFile
+	for _, file := range files {
+		path := filepath.Join(top, filepath.FromSlash(file))
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		content := omittedContent
+		if !isBinaryFile(file) && info.Size() <= largeFileThreshold {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			// Binary files without a gitattributes entry still contain a NUL byte
+			if !strings.ContainsRune(string(data), 0) {
+				content = string(data)
+			}
+		}
+		contents = append(contents, WorkingTreeFile{Path: file, Content: content, Unstaged: unstaged[file]})
+	}
+	return contents, nil
+}
+
 // isBinaryFile checks if a file is binary based on git attributes
 func isBinaryFile(file string) bool {
-	cmd := exec.Command("git", "check-attr", "binary", "--", file)
+	cmd := Command("check-attr", "binary", "--", file)
 	output, err := cmd.Output()
 	if err == nil && strings.Contains(string(output), "binary: set") {
 		return true
@@ -143,16 +239,26 @@ func isBinaryFile(file string) bool {
 
 // isLargeFile checks if a file is too large to include in full
 func isLargeFile(file string) bool {
-	// Get file size
-	cmd := exec.Command("git", "ls-files", "-s", "--", file)
-	output, err := cmd.Output()
+	size, err := fileSize(file)
 	if err != nil {
 		return false
 	}
+	return size > largeFileThreshold
+}
+
+// fileSize returns the size of the staged version of a file, falling back to
+// the working tree copy when the file is not in the index
+func fileSize(file string) (int64, error) {
+	output, err := Command("cat-file", "-s", ":"+file).Output()
+	if err == nil {
+		return strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	}
 
-	// Very crude size estimation - would be better to parse the actual size
-	// For now, just check if the output contains a large file marker
-	return len(output) > 0 && strings.Contains(string(output), "100755") // Executable files are often binary
+	info, statErr := os.Stat(runner.Path(file))
+	if statErr != nil {
+		return 0, statErr
+	}
+	return info.Size(), nil
 }
 
 // getFileType returns a description of the file type based on extension
@@ -199,6 +305,48 @@ func getFileType(extension string) string {
 	}
 }
 
+// fileTypeSuffixes are cut from getFileType's descriptions to name a kind of file in
+// DescribeFileTypes, e.g. "Go source code" becomes "Go"
+var fileTypeSuffixes = []string{" source code", " configuration file", " documentation", " data file",
+	" database script", " stylesheet", " script", " file"}
+
+// DescribeFileTypes summarizes the kinds of files changed, most common first, e.g.
+// "3 Go files, 1 YAML file". Files without an extension are counted as other files.
+func DescribeFileTypes(files []string) string {
+	counts := make(map[string]int)
+	var kinds []string
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		kind := "other"
+		if ext := filepath.Ext(file); ext != "" {
+			kind = getFileType(ext)
+			if strings.HasPrefix(kind, "File with ") {
+				kind = strings.ToLower(ext)
+			}
+			for _, suffix := range fileTypeSuffixes {
+				kind = strings.TrimSuffix(kind, suffix)
+			}
+		}
+		if counts[kind] == 0 {
+			kinds = append(kinds, kind)
+		}
+		counts[kind]++
+	}
+
+	sort.SliceStable(kinds, func(i, j int) bool { return counts[kinds[i]] > counts[kinds[j]] })
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		noun := "files"
+		if counts[kind] == 1 {
+			noun = "file"
+		}
+		parts[i] = fmt.Sprintf("%d %s %s", counts[kind], kind, noun)
+	}
+	return strings.Join(parts, ", ")
+}
+
 // isCodeFile checks if a file is a source code file
 func isCodeFile(file string) bool {
 	ext := filepath.Ext(file)
@@ -309,7 +457,7 @@ func findRelatedFiles(stagedFiles []string) []string {
 		// Get directory and suggest other files in the same directory
 		dir := filepath.Dir(file)
 		if dir != "." {
-			cmd := exec.Command("git", "ls-files", dir)
+			cmd := Command("ls-files", dir)
 			output, err := cmd.Output()
 			if err == nil {
 				dirFiles := strings.Split(strings.TrimSpace(string(output)), "\n")
@@ -335,30 +483,25 @@ func getProjectContext() string {
 	var context strings.Builder
 	
 	// Get the repository name
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	output, err := cmd.Output()
-	if err == nil {
-		repoPath := strings.TrimSpace(string(output))
+	repo := CurrentRepo()
+	if repoPath, err := repo.Toplevel(); err == nil {
 		repoName := filepath.Base(repoPath)
 		context.WriteString(fmt.Sprintf("Repository: %s\n", repoName))
 	}
 	
 	// Add branch information
-	cmd = exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	output, err = cmd.Output()
-	if err == nil {
-		branch := strings.TrimSpace(string(output))
+	if branch, err := repo.Branch(); err == nil {
 		context.WriteString(fmt.Sprintf("Branch: %s\n", branch))
 	}
 	
 	// Look for README to extract project description
-	cmd = exec.Command("git", "ls-files", "*README*")
-	output, err = cmd.Output()
+	cmd := Command("ls-files", "*README*")
+	output, err := cmd.Output()
 	if err == nil && len(output) > 0 {
 		readmeFiles := strings.Split(strings.TrimSpace(string(output)), "\n")
 		if len(readmeFiles) > 0 {
 			readmeFile := readmeFiles[0]
-			cmd = exec.Command("git", "show", fmt.Sprintf(":%s", readmeFile))
+			cmd = Command("show", fmt.Sprintf(":%s", readmeFile))
 			readme, err := cmd.Output()
 			if err == nil {
 				// Extract the first paragraph from the README
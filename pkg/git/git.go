@@ -1,16 +1,28 @@
 package git
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"os/exec"
+	"strings"
 )
 
+// EmptyTreeHash is the hash of git's empty tree, used as the diff base when
+// amending a root commit that has no parent
+const EmptyTreeHash = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
 // GitDiff contains information about staged changes
 type GitDiff struct {
 	StagedFiles     []string
 	Diff            string
 	Branch          string
 	JiraID          string
+	JiraIDs         []string // All Jira IDs found in the branch name; JiraID is the first
+	JiraURLs        []string // Links to the Jira tickets on the jira_base_url site, one per ID
 	JiraDescription string
+	IssueRef        string // GitLab issue reference such as #123 or group/project#123
+	NoJira          bool   // The repository doesn't use Jira, with --no-jira: no IDs and no placeholders
 	SystemPrompt    string // Prompt for LLM system context
 	UserPrompt      string // Template for user prompt
 	
@@ -21,6 +33,50 @@ type GitDiff struct {
 	CommitHistory   map[string][]string // Recent commit history for changed files
 	FileSummaries   map[string]string // Summarized information about each file
 	RelatedFiles    []string         // Related files that might provide context
+	StyleExamples   []string         // Recent commit messages used as style examples
+	Language        string           // Language to write the message in (empty for English)
+	Body            bool             // Ask for a subject line plus a bulleted body
+	CommitSubjects  []string         // Subjects of the commits being summarized with --since, oldest first
+	Template        string           // Commit message template whose sections the model fills in
+	Draft           string           // Message prepared by an in-progress merge, rebase or cherry-pick
+	DiffStat        string           // "git diff --stat" summary of the changes
+	FileChanges     []FileChange     // Status of each changed file, including renames and binary files
+	UntrackedFiles  []string         // Untracked files next to the changed files, with --include-untracked-context
+	WorkingTreeFiles []WorkingTreeFile // Working-tree content of the staged files, with --include-diff-context-from-unstaged
+	Notes           bool             // Ask for a rationale after the message to store as a git note
+	Structured      bool             // Ask for the message as JSON fields, with --structured, where the model supports it
+	Subject         string           // Subject line written by the user; the model only writes the body
+	SubjectLimit    int              // Longest subject line allowed, set when asking again for a shorter one
+	ChangeType      string           // Likely Conventional Commits type guessed by InferChangeType, e.g. feat
+	Turns           []Turn           // Earlier messages and the user's feedback on them, sent after the prompt when regenerating
+}
+
+// Turn is a message in the conversation with the model after the prompt: a commit
+// message it wrote, or the user's feedback on one
+type Turn struct {
+	Role    string // TurnAssistant or TurnUser
+	Content string
+}
+
+// Roles of the turns in a conversation
+const (
+	TurnUser      = "user"
+	TurnAssistant = "assistant"
+)
+
+// ErrNotInstalled is returned by EnsureAvailable when git isn't on the PATH
+var ErrNotInstalled = errors.New("git is not installed or not on your PATH")
+
+// EnsureAvailable checks that git can be run and returns its version, e.g. "2.43.0"
+func EnsureAvailable() (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", ErrNotInstalled
+	}
+	output, err := Command("--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("git is installed but could not be run: %w", err)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "git version "), nil
 }
 
 // GetGitDiff retrieves information about staged changes
@@ -34,7 +90,323 @@ func GetGitDiff(jiraID, jiraDesc string) (GitDiff, error) {
 
 // CommitWithMessage commits staged changes with the provided message
 func CommitWithMessage(message string) error {
-	// This is a stub function to be implemented later
-	cmd := exec.Command("git", "commit", "-m", message)
+	messageFile, err := WriteMessageFile(message)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(messageFile)
+
+	cmd := Command("commit", "-F", messageFile)
 	return cmd.Run()
 }
+
+// WriteMessageFile writes a commit message to a temporary file for use with
+// "git commit -F", so multi-line bodies are passed through exactly as written.
+// The caller is responsible for removing the file.
+func WriteMessageFile(message string) (string, error) {
+	file, err := os.CreateTemp("", "ai-commit-msg-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create commit message file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(message); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write commit message file: %v", err)
+	}
+
+	return file.Name(), nil
+}
+
+// AddNote attaches text as a git note to HEAD under the notes ref, e.g. "refs/notes/ai"
+// or "ai". An empty ref uses git's default, refs/notes/commits.
+func AddNote(ref, text string) error {
+	args := []string{"notes"}
+	if ref != "" {
+		args = append(args, "--ref", ref)
+	}
+	args = append(args, "add", "-m", text, "HEAD")
+
+	output, err := Command(args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git notes add failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// GetStagedFileNames returns the paths of the files staged for commit
+func GetStagedFileNames() ([]string, error) {
+	return gitFileList("diff", "--cached", "--name-only")
+}
+
+// GetUnstagedFiles returns the paths of tracked files with changes that are not staged
+func GetUnstagedFiles() ([]string, error) {
+	return gitFileList("diff", "--name-only")
+}
+
+// StageAll stages every change in the working tree, including new and deleted files
+func StageAll() error {
+	output, err := Command("add", "-A").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git add -A failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// gitFileList runs a git command that prints one path per line and returns the paths
+func gitFileList(args ...string) ([]string, error) {
+	output, err := Command(args...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// GetAmendBase returns the revision to diff the index against when amending the
+// last commit: its parent, or the empty tree for a root commit. It returns an
+// error if the repository has no commits yet.
+func GetAmendBase() (string, error) {
+	if err := Command("rev-parse", "--verify", "--quiet", "HEAD").Run(); err != nil {
+		return "", fmt.Errorf("no commits to amend yet")
+	}
+
+	if err := Command("rev-parse", "--verify", "--quiet", "HEAD~1").Run(); err != nil {
+		return EmptyTreeHash, nil
+	}
+
+	return "HEAD~1", nil
+}
+
+// AmendCommitWithMessage replaces the last commit with the staged changes and the provided message
+func AmendCommitWithMessage(message string) error {
+	messageFile, err := WriteMessageFile(message)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(messageFile)
+
+	cmd := Command("commit", "--amend", "-F", messageFile)
+	return cmd.Run()
+}
+
+// GetRecentCommitMessages returns the full messages of the last n commits, newest first.
+// It returns nil when n is not positive or the repository has no commits.
+func GetRecentCommitMessages(n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	if err := Command("rev-parse", "--verify", "--quiet", "HEAD").Run(); err != nil {
+		return nil, nil
+	}
+
+	// Separate messages with a NUL byte since bodies can contain blank lines
+	output, err := Command("log", "-n", fmt.Sprintf("%d", n), "--pretty=format:%B%x00").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []string
+	for _, message := range strings.Split(string(output), "\x00") {
+		message = strings.TrimSpace(message)
+		if message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// GetRangeDiff returns the changes made on HEAD since it diverged from ref
+// ("git diff ref...HEAD") and the paths of the changed files. A negative
+// contextLines keeps git's default amount of context.
+func GetRangeDiff(ref string, contextLines int) (string, []string, error) {
+	if err := Command("rev-parse", "--verify", "--quiet", ref+"^{commit}").Run(); err != nil {
+		return "", nil, fmt.Errorf("unknown revision %q", ref)
+	}
+
+	rangeSpec := ref + "...HEAD"
+	files, err := gitFileList("diff", "--name-only", rangeSpec)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(files) == 0 {
+		return "", nil, fmt.Errorf("no changes between %s and HEAD", ref)
+	}
+
+	args := []string{"diff"}
+	if contextLines >= 0 {
+		args = append(args, fmt.Sprintf("--unified=%d", contextLines))
+	}
+	output, err := Command(append(args, rangeSpec)...).Output()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return string(output), files, nil
+}
+
+// GetDiffStat returns the "git diff --stat" summary for the given diff arguments,
+// e.g. GetDiffStat("--cached") for the staged changes
+func GetDiffStat(args ...string) (string, error) {
+	output, err := Command(append([]string{"diff", "--stat"}, args...)...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// BuildFullContextDiff returns the staged content of each file followed by the diff
+// for the given diff arguments, which default to "--cached". It is used for maximum
+// context (-ccc). Files without staged content, such as deleted ones, only show up
+// in the diff.
+func BuildFullContextDiff(files []string, diffArgs ...string) (string, error) {
+	if len(diffArgs) == 0 {
+		diffArgs = []string{"--cached"}
+	}
+
+	var fullDiff strings.Builder
+	fullDiff.WriteString("# Showing full files for maximum context\n\n")
+
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		content, err := Command("show", ":"+file).Output()
+		if err != nil {
+			logf("Leaving %s out of the full files: it has no staged content", file)
+			continue
+		}
+		fmt.Fprintf(&fullDiff, "=== %s ===\n", file)
+		fullDiff.Write(content)
+		fullDiff.WriteString("\n\n")
+	}
+
+	// The diff shows what actually changed within the full files
+	changes, err := Command(append([]string{"diff"}, diffArgs...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the diff: %w", err)
+	}
+	fullDiff.WriteString("=== CHANGES ===\n")
+	fullDiff.Write(changes)
+
+	return fullDiff.String(), nil
+}
+
+// GetCommitSubjects returns the subject lines of the commits reachable from HEAD
+// but not from ref ("git log ref..HEAD"), oldest first
+func GetCommitSubjects(ref string) ([]string, error) {
+	return gitFileList("log", "--reverse", "--pretty=format:%s", ref+"..HEAD")
+}
+
+// GetDefaultBranch returns the branch pull requests are usually opened against: the
+// remote's default branch (e.g. "origin/main") if known, otherwise a local main or master
+func GetDefaultBranch() (string, error) {
+	if output, err := Command("symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD").Output(); err == nil {
+		if branch := strings.TrimSpace(string(output)); branch != "" {
+			return branch, nil
+		}
+	}
+	for _, branch := range []string{"main", "master"} {
+		if Command("rev-parse", "--verify", "--quiet", "refs/heads/"+branch).Run() == nil {
+			return branch, nil
+		}
+	}
+	return "", fmt.Errorf("could not determine the default branch")
+}
+
+// GetRefBranchName returns the branch name of a local or remote-tracking branch ref,
+// e.g. "main" for "origin/main", or "" if ref isn't a branch
+func GetRefBranchName(ref string) string {
+	output, err := Command("rev-parse", "--symbolic-full-name", ref).Output()
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimSpace(string(output))
+	if branch, ok := strings.CutPrefix(name, "refs/heads/"); ok {
+		return branch
+	}
+	if remoteBranch, ok := strings.CutPrefix(name, "refs/remotes/"); ok {
+		if _, branch, found := strings.Cut(remoteBranch, "/"); found {
+			return branch
+		}
+	}
+	return ""
+}
+
+// ParseDiffFiles returns the paths of the files changed in a unified diff, in the
+// order they appear. It understands both git's "diff --git" headers and the
+// "---"/"+++" file headers of plain unified diffs.
+func ParseDiffFiles(diff string) []string {
+	var files []string
+	seen := make(map[string]bool)
+	addFile := func(path string) {
+		if path != "" && path != "/dev/null" && !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	oldPath := ""
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			rest := strings.TrimPrefix(line, "diff --git ")
+			if idx := strings.LastIndex(rest, " b/"); idx >= 0 {
+				addFile(rest[idx+3:])
+			}
+		case strings.HasPrefix(line, "--- "):
+			oldPath = diffHeaderPath(line, "a/")
+		case strings.HasPrefix(line, "+++ "):
+			newPath := diffHeaderPath(line, "b/")
+			if newPath == "/dev/null" {
+				// Deleted files only carry their name in the "---" header
+				newPath = oldPath
+			}
+			addFile(newPath)
+		}
+	}
+
+	return files
+}
+
+// FileDiff is the part of a diff that changes one file
+type FileDiff struct {
+	Path string
+	Diff string
+}
+
+// SplitDiff splits a diff into one FileDiff per file, in the order they appear, at
+// each "diff --git" header. A plain unified diff without those headers stays in one
+// piece whose Path lists all its files. Anything before the first file is dropped.
+func SplitDiff(diff string) []FileDiff {
+	if diff == "" {
+		return nil
+	}
+
+	var files []FileDiff
+	for _, section := range splitDiffFiles(diff) {
+		paths := ParseDiffFiles(section)
+		if len(paths) == 0 {
+			continue
+		}
+		files = append(files, FileDiff{Path: strings.Join(paths, ", "), Diff: section})
+	}
+	return files
+}
+
+// diffHeaderPath extracts the path from a "---" or "+++" diff header line,
+// dropping the a/ or b/ prefix and any trailing timestamp
+func diffHeaderPath(line, prefix string) string {
+	path := strings.TrimSpace(line[4:])
+	if idx := strings.Index(path, "\t"); idx >= 0 {
+		path = path[:idx]
+	}
+	return strings.TrimPrefix(path, prefix)
+}
@@ -0,0 +1,53 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Issue reference styles that can be detected from branch names
+const (
+	// IssueStyleJira detects Jira IDs such as GTN-123 (the default)
+	IssueStyleJira = "jira"
+
+	// IssueStyleGitLab detects GitLab issue references such as #123 or group/project#123
+	IssueStyleGitLab = "gitlab"
+
+	// IssueStyleBoth detects Jira IDs and GitLab issue references independently
+	IssueStyleBoth = "both"
+)
+
+// gitLabIssuePattern matches "#123" with an optional "group/project" in front
+var gitLabIssuePattern = regexp.MustCompile(`(?:([A-Za-z0-9_.-]+/[A-Za-z0-9_./-]+))?#(\d+)`)
+
+// DetectsJira reports whether Jira IDs should be extracted for the given style
+func DetectsJira(style string) bool {
+	style = strings.ToLower(strings.TrimSpace(style))
+	return style == "" || style == IssueStyleJira || style == IssueStyleBoth
+}
+
+// DetectsGitLabIssues reports whether GitLab issue references should be extracted for the given style
+func DetectsGitLabIssues(style string) bool {
+	style = strings.ToLower(strings.TrimSpace(style))
+	return style == IssueStyleGitLab || style == IssueStyleBoth
+}
+
+// ExtractIssueRefFromBranchName extracts a GitLab issue reference from a branch name.
+// It supports formats like:
+// - feature/#123-description
+// - group/project#123
+// - fix-#42
+func ExtractIssueRefFromBranchName(branchName string) string {
+	matches := gitLabIssuePattern.FindStringSubmatch(branchName)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	issueRef := "#" + matches[2]
+	if matches[1] != "" {
+		issueRef = matches[1] + issueRef
+	}
+
+	logf("Extracted issue reference from branch name: %s", issueRef)
+	return issueRef
+}
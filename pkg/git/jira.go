@@ -1,57 +1,113 @@
 package git
 
 import (
-	"fmt"
 	"regexp"
+	"strings"
 )
 
-// JiraPrefixes contains the list of all known Jira project prefixes
-// used by the organization. These are used to extract Jira IDs from
-// branch names and commit messages.
-var JiraPrefixes = []string{
+// DefaultJiraPrefixes contains the Jira project prefixes used when none are configured
+var DefaultJiraPrefixes = []string{
 	"GTN",
 	"GTBUG",
 	"TOOLS",
 	"TASK",
 }
 
+// JiraPrefixes contains the list of known Jira project prefixes. These are
+// used to extract Jira IDs from branch names and commit messages. The list
+// can be replaced from the jira_prefixes config option via SetJiraPrefixes.
+var JiraPrefixes = DefaultJiraPrefixes
+
+// SetJiraPrefixes replaces the known Jira prefixes with the configured list.
+// Blank entries are ignored; an empty list restores the defaults.
+func SetJiraPrefixes(prefixes []string) {
+	var cleaned []string
+	for _, prefix := range prefixes {
+		prefix = strings.ToUpper(strings.TrimSpace(prefix))
+		if prefix != "" {
+			cleaned = append(cleaned, prefix)
+		}
+	}
+
+	if len(cleaned) == 0 {
+		JiraPrefixes = DefaultJiraPrefixes
+		return
+	}
+	JiraPrefixes = cleaned
+}
+
 // IsJiraPrefix checks if the given string is a known Jira prefix
 func IsJiraPrefix(prefix string) bool {
 	for _, knownPrefix := range JiraPrefixes {
-		if prefix == knownPrefix {
+		if strings.EqualFold(prefix, knownPrefix) {
 			return true
 		}
 	}
 	return false
 }
 
+// jiraIDPattern matches anything shaped like a Jira ID: uppercase letters, a dash and a number
+var jiraIDPattern = regexp.MustCompile(`[A-Z]+-\d+`)
+
 // ExtractJiraIDFromBranchName attempts to extract a Jira ID from a branch name
 // It supports formats like:
 // - feature/GTBUG-123-description
 // - bugfix/GTN-456-fix-memory-leak
 // - TOOLS-789_update_config
 // - TASK-101-improve-docs
-func extractJiraIDFromBranchName(branchName string) string {
-	// Create patterns based on the defined JiraPrefixes
-	var patterns []string
-	
-	// Add specific patterns for known prefixes
-	for _, prefix := range JiraPrefixes {
-		patterns = append(patterns, prefix+"-\\d+")
-	}
-	
-	// Add a generic pattern for any uppercase followed by dash and numbers
-	patterns = append(patterns, "[A-Z]+-\\d+")
-	
-	// Try each pattern
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		matches := re.FindStringSubmatch(branchName)
-		if len(matches) > 0 {
-			fmt.Printf("Extracted Jira ID from branch name: %s\n", matches[0])
-			return matches[0]
+// IDs with a known prefix win over other IDs, wherever they appear.
+func ExtractJiraIDFromBranchName(branchName string) string {
+	ids := extractAllJiraIDs(branchName)
+	if len(ids) == 0 {
+		return ""
+	}
+	logf("Extracted Jira ID from branch name: %s", ids[0])
+	return ids[0]
+}
+
+// extractAllJiraIDs returns every Jira ID in a branch name such as
+// feature/GTN-1-GTBUG-2-thing, without duplicates. IDs with a known prefix
+// come first, each group in the order they appear in the branch name.
+func extractAllJiraIDs(branchName string) []string {
+	var known, other []string
+	seen := make(map[string]bool)
+	for _, id := range jiraIDPattern.FindAllString(branchName, -1) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if IsJiraPrefix(id[:strings.LastIndex(id, "-")]) {
+			known = append(known, id)
+		} else {
+			other = append(other, id)
 		}
 	}
+	return append(known, other...)
+}
 
-	return ""
+// ExtractJiraIDsFromBranchName returns every Jira ID referenced in a branch name,
+// most relevant first, or nil if there are none
+func ExtractJiraIDsFromBranchName(branchName string) []string {
+	ids := extractAllJiraIDs(branchName)
+	if len(ids) > 0 {
+		logf("Extracted Jira IDs from branch name: %s", strings.Join(ids, ", "))
+	}
+	return ids
+}
+
+// JiraURL returns the link to a ticket on a Jira site, e.g.
+// https://company.atlassian.net/browse/GTN-123 for the base URL
+// https://company.atlassian.net. A base URL already ending in /browse is used as it is.
+// It returns an empty string when either the base URL or the ID is empty.
+func JiraURL(baseURL, id string) string {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	id = strings.TrimSpace(id)
+	if baseURL == "" || id == "" {
+		return ""
+	}
+	if !strings.HasSuffix(baseURL, "/browse") {
+		baseURL += "/browse"
+	}
+	return baseURL + "/" + id
 }
@@ -0,0 +1,16 @@
+package git
+
+// Logger receives the progress messages of the git package. The package never
+// writes to stdout itself, so machine-readable output such as --json stays clean.
+type Logger func(format string, args ...interface{})
+
+// logf is the active logger; it discards messages until SetLogger is called
+var logf Logger = func(format string, args ...interface{}) {}
+
+// SetLogger sets the logger used for progress messages. A nil logger discards them.
+func SetLogger(logger Logger) {
+	if logger == nil {
+		logger = func(format string, args ...interface{}) {}
+	}
+	logf = logger
+}
@@ -0,0 +1,98 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RepoInfo caches facts about a repository that don't change during a run: its
+// toplevel directory, the current branch and that branch's upstream. Each is read
+// with git the first time it is needed. RepoInfo is safe for concurrent use.
+type RepoInfo struct {
+	dir string // Directory git runs in; "" for the current directory
+
+	toplevel cachedValue
+	branch   cachedValue
+	upstream cachedValue
+}
+
+// cachedValue holds the result of a git command that runs at most once
+type cachedValue struct {
+	once  sync.Once
+	value string
+	err   error
+}
+
+// get returns the cached result, running load the first time
+func (c *cachedValue) get(load func() (string, error)) (string, error) {
+	c.once.Do(func() {
+		c.value, c.err = load()
+	})
+	return c.value, c.err
+}
+
+// NewRepoInfo returns a RepoInfo for the repository containing dir
+func NewRepoInfo(dir string) *RepoInfo {
+	return &RepoInfo{dir: dir}
+}
+
+var (
+	reposMu sync.Mutex
+	repos   = make(map[string]*RepoInfo)
+)
+
+// CurrentRepo returns the shared RepoInfo for the directory set with SetDir, or the
+// current directory, so the git calls behind it run once per run however many times
+// the information is needed
+func CurrentRepo() *RepoInfo {
+	dir := Dir()
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return NewRepoInfo("")
+		}
+	}
+
+	reposMu.Lock()
+	defer reposMu.Unlock()
+	if repo, ok := repos[dir]; ok {
+		return repo
+	}
+	repo := NewRepoInfo(dir)
+	repos[dir] = repo
+	return repo
+}
+
+// Toplevel returns the root directory of the work tree
+func (r *RepoInfo) Toplevel() (string, error) {
+	return r.toplevel.get(func() (string, error) {
+		top, err := r.git("rev-parse", "--show-toplevel")
+		return filepath.FromSlash(top), err
+	})
+}
+
+// Branch returns the name of the current branch, or "HEAD" when it is detached
+func (r *RepoInfo) Branch() (string, error) {
+	return r.branch.get(func() (string, error) {
+		return r.git("rev-parse", "--abbrev-ref", "HEAD")
+	})
+}
+
+// Upstream returns the branch the current branch tracks, such as "origin/main", or an
+// error if it has none
+func (r *RepoInfo) Upstream() (string, error) {
+	return r.upstream.get(func() (string, error) {
+		return r.git("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	})
+}
+
+// git runs a git command in the repository directory and returns its trimmed output
+func (r *RepoInfo) git(args ...string) (string, error) {
+	output, err := Runner{Dir: r.dir}.Command(args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
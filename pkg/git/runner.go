@@ -0,0 +1,70 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Runner runs git commands in a directory, or in the current directory when Dir is
+// empty. Every git command of the package goes through the active runner, so --repo
+// applies to all of them.
+type Runner struct {
+	Dir string
+}
+
+// Command returns a git command with the given arguments that runs in r.Dir
+func (r Runner) Command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	return cmd
+}
+
+// Path returns file, a path relative to the runner's directory, as a path the process
+// can open. Absolute paths are returned unchanged.
+func (r Runner) Path(file string) string {
+	if r.Dir == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(r.Dir, file)
+}
+
+// runner is the active runner; the zero value runs git in the current directory
+var runner Runner
+
+// Command returns a git command that runs in the directory set with SetDir
+func Command(args ...string) *exec.Cmd {
+	return runner.Command(args...)
+}
+
+// Dir returns the directory git commands run in, or "" for the current directory
+func Dir() string {
+	return runner.Dir
+}
+
+// SetDir makes every git command run in dir, after checking that dir is inside a git
+// work tree. An empty dir restores the current directory.
+func SetDir(dir string) error {
+	if dir == "" {
+		runner = Runner{}
+		return nil
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid repository directory %s: %v", dir, err)
+	}
+	if info, err := os.Stat(absDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("repository directory %s does not exist", dir)
+	}
+
+	candidate := Runner{Dir: absDir}
+	output, err := candidate.Command("rev-parse", "--is-inside-work-tree").Output()
+	if err != nil || strings.TrimSpace(string(output)) != "true" {
+		return fmt.Errorf("%s is not inside a git work tree", dir)
+	}
+	runner = candidate
+	return nil
+}
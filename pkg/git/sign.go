@@ -0,0 +1,40 @@
+package git
+
+import (
+	"strings"
+)
+
+// signingErrorMarkers are printed by git commit when the commit couldn't be signed
+var signingErrorMarkers = []string{
+	"gpg failed to sign the data",
+	"error: gpg",
+	"cannot run gpg",
+	"secret key not available",
+	"no secret key",
+	"ssh-keygen",
+}
+
+// IsGPGSignEnabled reports whether git signs commits by default (commit.gpgsign)
+func IsGPGSignEnabled() bool {
+	output, err := Command("config", "--type=bool", "--get", "commit.gpgsign").Output()
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(output)) == "true"
+}
+
+// SignArgs returns the git commit option that signs a commit, "-S" or "-S<keyID>"
+func SignArgs(keyID string) []string {
+	return []string{"-S" + strings.TrimSpace(keyID)}
+}
+
+// IsSigningError reports whether git commit's error output shows that signing failed
+func IsSigningError(output string) bool {
+	output = strings.ToLower(output)
+	for _, marker := range signingErrorMarkers {
+		if strings.Contains(output, marker) {
+			return true
+		}
+	}
+	return false
+}
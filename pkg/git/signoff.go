@@ -0,0 +1,117 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// trailerPattern matches a single git trailer line such as "Signed-off-by: Name <email>"
+var trailerPattern = regexp.MustCompile(`^[A-Za-z0-9-]+: .+$`)
+
+// trailerKeyPattern matches a trailer key such as "Signed-off-by"
+var trailerKeyPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// coAuthorPattern matches a co-author such as "Jane Doe <jane@example.com>"
+var coAuthorPattern = regexp.MustCompile(`^([^<>]+?)\s*<\s*([^<>\s]+@[^<>\s]+)\s*>$`)
+
+// GetUserIdentity returns the committer name and email from git config
+func GetUserIdentity() (string, string, error) {
+	name, err := gitConfigValue("user.name")
+	if err != nil {
+		return "", "", fmt.Errorf("git user.name is not set: %v", err)
+	}
+
+	email, err := gitConfigValue("user.email")
+	if err != nil {
+		return "", "", fmt.Errorf("git user.email is not set: %v", err)
+	}
+
+	return name, email, nil
+}
+
+// gitConfigValue reads a single value from git config
+func gitConfigValue(key string) (string, error) {
+	output, err := Command("config", "--get", key).Output()
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSpace(string(output))
+	if value == "" {
+		return "", fmt.Errorf("empty value")
+	}
+	return value, nil
+}
+
+// AppendSignoff adds a "Signed-off-by" trailer for the given identity to the message
+func AppendSignoff(message, name, email string) string {
+	return AppendTrailer(message, "Signed-off-by", fmt.Sprintf("%s <%s>", name, email))
+}
+
+// FormatCoAuthor checks that a co-author is written as "Name <email>" and returns it
+// with the spacing GitHub needs to match the email to an account, e.g.
+// "Jane  Doe<jane@example.com>" becomes "Jane Doe <jane@example.com>"
+func FormatCoAuthor(coAuthor string) (string, error) {
+	match := coAuthorPattern.FindStringSubmatch(strings.TrimSpace(coAuthor))
+	if match == nil {
+		return "", fmt.Errorf("invalid co-author %q: use \"Name <email>\"", coAuthor)
+	}
+	name := strings.Join(strings.Fields(match[1]), " ")
+	return fmt.Sprintf("%s <%s>", name, match[2]), nil
+}
+
+// AppendCoAuthors adds a "Co-authored-by" trailer for each co-author to the message,
+// in order. Each co-author must be written as "Name <email>".
+func AppendCoAuthors(message string, coAuthors []string) (string, error) {
+	for _, coAuthor := range coAuthors {
+		formatted, err := FormatCoAuthor(coAuthor)
+		if err != nil {
+			return "", err
+		}
+		message = AppendTrailer(message, "Co-authored-by", formatted)
+	}
+	return message, nil
+}
+
+// IsTrailerKey reports whether key can be used as a git trailer key: letters,
+// digits and dashes, such as "AI-Generated-By"
+func IsTrailerKey(key string) bool {
+	return trailerKeyPattern.MatchString(key)
+}
+
+// AppendTrailer adds a "key: value" trailer to the message. The trailer joins an
+// existing trailer block at the end of the body, or starts a new one after a blank
+// line. A message that already carries the trailer is returned as is.
+func AppendTrailer(message, key, value string) string {
+	trailer := fmt.Sprintf("%s: %s", key, value)
+	message = strings.TrimRight(message, " \t\r\n")
+
+	lines := strings.Split(message, "\n")
+	for _, line := range lines {
+		if strings.TrimSpace(line) == trailer {
+			return message
+		}
+	}
+
+	if endsWithTrailerBlock(message) {
+		return message + "\n" + trailer
+	}
+	return message + "\n\n" + trailer
+}
+
+// endsWithTrailerBlock reports whether the last paragraph of a message consists of
+// trailers only. The subject line is never treated as a trailer block.
+func endsWithTrailerBlock(message string) bool {
+	paragraphs := strings.Split(message, "\n\n")
+	if len(paragraphs) < 2 {
+		return false
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(paragraphs[len(paragraphs)-1]), "\n") {
+		if !trailerPattern.MatchString(strings.TrimSpace(line)) {
+			return false
+		}
+	}
+	return true
+}
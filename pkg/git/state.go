@@ -0,0 +1,80 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GetGitDir returns the absolute path of the current repository's git directory,
+// usually .git at the top of the working tree
+func GetGitDir() (string, error) {
+	output, err := Command("rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Operation describes a merge, rebase or cherry-pick that is in progress
+type Operation struct {
+	Name    string // "merge", "rebase" or "cherry-pick"
+	Message string // The message git prepared for the next commit, without comment lines
+}
+
+// operationMarkers maps files in the git directory to the operation they indicate,
+// along with the files git writes the prepared commit message to. Rebases are
+// checked first because a conflicted rebase step can also leave the other markers.
+var operationMarkers = []struct {
+	marker   string
+	name     string
+	messages []string
+}{
+	{marker: "rebase-merge", name: "rebase", messages: []string{"rebase-merge/message", "MERGE_MSG"}},
+	{marker: "rebase-apply", name: "rebase", messages: []string{"rebase-apply/msg", "MERGE_MSG"}},
+	{marker: "MERGE_HEAD", name: "merge", messages: []string{"MERGE_MSG"}},
+	{marker: "CHERRY_PICK_HEAD", name: "cherry-pick", messages: []string{"MERGE_MSG"}},
+}
+
+// GetInProgressOperation returns the merge, rebase or cherry-pick in progress in the
+// current repository, or nil if there is none
+func GetInProgressOperation() (*Operation, error) {
+	gitDir, err := GetGitDir()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range operationMarkers {
+		if _, err := os.Stat(filepath.Join(gitDir, op.marker)); err != nil {
+			continue
+		}
+
+		operation := &Operation{Name: op.name}
+		for _, file := range op.messages {
+			content, err := os.ReadFile(filepath.Join(gitDir, file))
+			if err != nil {
+				continue
+			}
+			if message := StripCommentLines(string(content)); message != "" {
+				operation.Message = message
+				break
+			}
+		}
+		logf("Detected %s in progress", op.name)
+		return operation, nil
+	}
+
+	return nil, nil
+}
+
+// StripCommentLines removes the "#" lines git adds to prepared messages, such as the
+// list of conflicted files, since "git commit -F" keeps them
+func StripCommentLines(message string) string {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
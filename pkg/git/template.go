@@ -0,0 +1,26 @@
+package git
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// GetCommitTemplatePath returns the file configured with "git config commit.template",
+// or "" if none is set. A relative path is resolved against the top of the work tree,
+// as git does when it reads the template.
+func GetCommitTemplatePath() string {
+	output, err := Command("config", "--path", "--get", "commit.template").Output()
+	if err != nil {
+		return ""
+	}
+
+	path := strings.TrimSpace(string(output))
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	if top, err := CurrentRepo().Toplevel(); err == nil {
+		path = filepath.Join(top, path)
+	}
+	return path
+}
@@ -0,0 +1,121 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TruncateDiff shortens a diff to about maxBytes by sharing the budget between the
+// files: files smaller than their share are kept whole and each larger file keeps
+// the start of its hunks, cut at a line boundary. When the shares are too small to
+// show any of a file's hunks, the last files are left out so the others get more,
+// and a "[diff truncated: X of Y files shown]" marker is appended. The diff is
+// returned unchanged, with false, when it fits or maxBytes is 0.
+func TruncateDiff(diff string, maxBytes int) (string, bool) {
+	if maxBytes <= 0 || len(diff) <= maxBytes {
+		return diff, false
+	}
+
+	files := splitDiffFiles(diff)
+	shown := files
+	var kept []string
+	for len(shown) > 0 {
+		kept = keptParts(shown, shareBudget(shown, maxBytes))
+		if kept != nil {
+			break
+		}
+		shown = shown[:len(shown)-1]
+	}
+
+	var builder strings.Builder
+	for i, part := range kept {
+		builder.WriteString(part)
+		if len(part) < len(shown[i]) {
+			fmt.Fprintf(&builder, "[... %d more bytes of this file's diff left out]\n", len(shown[i])-len(part))
+		}
+	}
+	fmt.Fprintf(&builder, "[diff truncated: %d of %d files shown]\n", len(shown), len(files))
+	return builder.String(), true
+}
+
+// keptParts cuts each file to its budget, or returns nil if a budget is too small to
+// keep anything past the file's header
+func keptParts(files []string, budgets []int) []string {
+	parts := make([]string, len(files))
+	for i, file := range files {
+		parts[i] = cutAtLine(file, budgets[i])
+		if len(parts[i]) < len(file) && len(parts[i]) <= diffHeaderLength(file) {
+			return nil
+		}
+	}
+	return parts
+}
+
+// splitDiffFiles splits a diff into one section per file, each starting with its
+// "diff --git" line. Anything before the first file is its own section.
+func splitDiffFiles(diff string) []string {
+	var files []string
+	start := 0
+	for {
+		next := strings.Index(diff[start+1:], "\ndiff --git ")
+		if next < 0 {
+			break
+		}
+		end := start + 1 + next + 1
+		files = append(files, diff[start:end])
+		start = end
+	}
+	return append(files, diff[start:])
+}
+
+// shareBudget splits maxBytes between the files so that small files are kept whole
+// and what they don't use is shared between the larger ones
+func shareBudget(files []string, maxBytes int) []int {
+	budgets := make([]int, len(files))
+	whole := make([]bool, len(files))
+	remaining := maxBytes
+	open := len(files)
+	for open > 0 {
+		share := remaining / open
+		settled := false
+		for i, file := range files {
+			if !whole[i] && len(file) <= share {
+				whole[i] = true
+				budgets[i] = len(file)
+				remaining -= len(file)
+				open--
+				settled = true
+			}
+		}
+		if !settled {
+			for i := range files {
+				if !whole[i] {
+					budgets[i] = share
+				}
+			}
+			break
+		}
+	}
+	return budgets
+}
+
+// cutAtLine returns the longest prefix of text up to maxBytes that ends with a newline
+func cutAtLine(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	return text[:strings.LastIndex(text[:maxBytes], "\n")+1]
+}
+
+// diffHeaderLength returns the length of a file section's header: the lines up to and
+// including its first hunk's @@ line
+func diffHeaderLength(file string) int {
+	i := strings.Index(file, "\n@@")
+	if i < 0 {
+		return len(file)
+	}
+	if end := strings.Index(file[i+1:], "\n"); end >= 0 {
+		return i + 1 + end + 1
+	}
+	return len(file)
+}
@@ -130,6 +130,12 @@ func (k *KeyManager) platformGetFromCredentialStore() (string, error) {
 		// On Windows, we can't directly use the Windows Credential Manager here
 		// because of build constraints - Windows support is in the Windows-specific file
 		return "", fmt.Errorf("Windows Credential Manager is only available on Windows")
+	case PlatformLinux:
+		// On Linux, use GNOME Keyring through secret-tool when it is installed
+		if linuxSecretServiceAvailable() {
+			return k.linuxGetFromSecretService()
+		}
+		return "", fmt.Errorf("no credential store available: install secret-tool (libsecret) to use GNOME Keyring")
 	default:
 		return "", fmt.Errorf("no credential store available for platform: %s", k.platform)
 	}
@@ -167,6 +173,12 @@ func (k *KeyManager) platformStoreInCredentialStore(apiKey string) error {
 		// On Windows, we can't directly use the Windows Credential Manager here
 		// because of build constraints - Windows support is in the Windows-specific file
 		return fmt.Errorf("Windows Credential Manager is only available on Windows")
+	case PlatformLinux:
+		// On Linux, use GNOME Keyring through secret-tool when it is installed
+		if linuxSecretServiceAvailable() {
+			return k.linuxStoreInSecretService(apiKey)
+		}
+		return fmt.Errorf("no credential store available: install secret-tool (libsecret) to use GNOME Keyring")
 	default:
 		return fmt.Errorf("no credential store available for platform: %s", k.platform)
 	}
@@ -227,8 +239,10 @@ func (k *KeyManager) GetPlatform() Platform {
 
 // CredentialStoreAvailable returns whether a credential store is available for the current platform
 func (k *KeyManager) CredentialStoreAvailable() bool {
-	// Both macOS and Windows now have fully implemented credential stores
-	return k.platform == PlatformMac || k.platform == PlatformWindows
+	// Both macOS and Windows now have fully implemented credential stores.
+	// Linux needs secret-tool to reach GNOME Keyring.
+	return k.platform == PlatformMac || k.platform == PlatformWindows ||
+		(k.platform == PlatformLinux && linuxSecretServiceAvailable())
 }
 
 // GetCredentialStoreName returns a user-friendly name for the current credential store
@@ -238,6 +252,11 @@ func (k *KeyManager) GetCredentialStoreName() string {
 		return "macOS Keychain"
 	case PlatformWindows:
 		return "Windows Credential Manager"
+	case PlatformLinux:
+		if linuxSecretServiceAvailable() {
+			return "GNOME Keyring"
+		}
+		return "none"
 	default:
 		return "none"
 	}
@@ -248,6 +267,27 @@ func (k *KeyManager) SetVerbose(verbose bool) {
 	k.verbose = verbose
 }
 
+// ServiceName returns the credential store service name for a namespace, e.g.
+// "ai-commit-msg/work" for "work", or KeychainService when the namespace is empty
+func ServiceName(namespace string) string {
+	namespace = strings.TrimSpace(namespace)
+	if namespace == "" {
+		return KeychainService
+	}
+	return KeychainService + "/" + namespace
+}
+
+// SetNamespace stores and looks up keys under the service name for the namespace, so
+// separate keys (e.g. for work and personal accounts) can coexist in the credential store
+func (k *KeyManager) SetNamespace(namespace string) {
+	k.keychainService = ServiceName(namespace)
+}
+
+// GetServiceName returns the service name keys are stored under in the credential store
+func (k *KeyManager) GetServiceName() string {
+	return k.keychainService
+}
+
 // This is defined in provider_keys.go - in key.go we just define GetKeyLegacy
 
 // This is used internally by the provider_keys.go implementation
\ No newline at end of file
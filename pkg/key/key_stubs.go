@@ -1,3 +1,21 @@
+//go:build !linux
+// +build !linux
+
 package key
 
-// This file is intentionally empty
+import "fmt"
+
+// linuxSecretServiceAvailable is always false outside Linux
+func linuxSecretServiceAvailable() bool {
+	return false
+}
+
+// linuxGetFromSecretService is only implemented on Linux
+func (k *KeyManager) linuxGetFromSecretService() (string, error) {
+	return "", fmt.Errorf("GNOME Keyring is only available on Linux")
+}
+
+// linuxStoreInSecretService is only implemented on Linux
+func (k *KeyManager) linuxStoreInSecretService(apiKey string) error {
+	return fmt.Errorf("GNOME Keyring is only available on Linux")
+}
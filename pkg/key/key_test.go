@@ -294,6 +294,52 @@ func TestStoreInKeychain(t *testing.T) {
 	}
 }
 
+// Test keys are stored and looked up under the namespaced service name
+func TestSetNamespace(t *testing.T) {
+	if name := ServiceName(""); name != KeychainService {
+		t.Errorf("Expected %s without a namespace, got %s", KeychainService, name)
+	}
+	if name := ServiceName(" work "); name != KeychainService+"/work" {
+		t.Errorf("Expected %s/work, got %s", KeychainService, name)
+	}
+
+	km := NewTestKeyManager(false)
+	t.Setenv(OpenAIEnvVar, "")
+	var service, account string
+	km.storeInKeychainFn = func(key string) error {
+		service, account = km.keychainService, km.keychainAccount
+		return nil
+	}
+	km.getFromKeychainFn = func() (string, error) {
+		service, account = km.keychainService, km.keychainAccount
+		return "sk-work-key", nil
+	}
+
+	km.SetNamespace("work")
+	if km.GetServiceName() != "ai-commit-msg/work" {
+		t.Errorf("Expected service ai-commit-msg/work, got %s", km.GetServiceName())
+	}
+	if err := km.StoreProviderKey("openai", "sk-work-key"); err != nil {
+		t.Fatalf("Unexpected error storing key: %v", err)
+	}
+	if service != "ai-commit-msg/work" || account != OpenAIAccount {
+		t.Errorf("Expected the key stored under ai-commit-msg/work/%s, got %s/%s", OpenAIAccount, service, account)
+	}
+
+	service = ""
+	if _, err := km.GetProviderKey("openai", ""); err != nil {
+		t.Fatalf("Unexpected error getting key: %v", err)
+	}
+	if service != "ai-commit-msg/work" {
+		t.Errorf("Expected the key looked up under ai-commit-msg/work, got %s", service)
+	}
+
+	km.SetNamespace("")
+	if km.GetServiceName() != KeychainService {
+		t.Errorf("Expected an empty namespace to restore %s, got %s", KeychainService, km.GetServiceName())
+	}
+}
+
 // Test platform detection
 func TestPlatformDetection(t *testing.T) {
 	km := NewTestKeyManager(false)
@@ -323,7 +369,8 @@ func TestCredentialStoreAvailable(t *testing.T) {
 	available := km.CredentialStoreAvailable()
 	
 	// Verify that credential store availability matches the platform
-	expected := runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+	expected := runtime.GOOS == "darwin" || runtime.GOOS == "windows" ||
+		(runtime.GOOS == "linux" && linuxSecretServiceAvailable())
 	
 	if available != expected {
 		t.Errorf("Expected credential store availability '%v', got '%v'", expected, available)
@@ -342,6 +389,11 @@ func TestGetCredentialStoreName(t *testing.T) {
 		expected = "macOS Keychain"
 	case "windows":
 		expected = "Windows Credential Manager"
+	case "linux":
+		expected = "none"
+		if linuxSecretServiceAvailable() {
+			expected = "GNOME Keyring"
+		}
 	default:
 		expected = "none"
 	}
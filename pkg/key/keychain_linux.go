@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+package key
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretToolCommand is the libsecret command-line client used to talk to GNOME Keyring
+const secretToolCommand = "secret-tool"
+
+// linuxSecretServiceAvailable reports whether secret-tool is installed
+func linuxSecretServiceAvailable() bool {
+	_, err := exec.LookPath(secretToolCommand)
+	return err == nil
+}
+
+// linuxGetFromSecretService retrieves the API key from GNOME Keyring via secret-tool
+func (k *KeyManager) linuxGetFromSecretService() (string, error) {
+	k.log("Executing secret-tool command to retrieve API key...")
+	cmd := exec.Command(secretToolCommand, "lookup", "service", k.keychainService, "account", k.keychainAccount)
+	output, err := cmd.Output()
+	if err != nil {
+		// secret-tool exits with an error when no matching item exists
+		return "", fmt.Errorf("failed to retrieve API key from GNOME Keyring")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// linuxStoreInSecretService stores the API key in GNOME Keyring via secret-tool.
+// Storing replaces any existing item with the same attributes.
+func (k *KeyManager) linuxStoreInSecretService(apiKey string) error {
+	k.log("Adding GNOME Keyring entry (service='%s', account='%s')...", k.keychainService, k.keychainAccount)
+	label := fmt.Sprintf("AI Commit Message Generator (%s)", k.keychainAccount)
+	cmd := exec.Command(secretToolCommand, "store", "--label="+label, "service", k.keychainService, "account", k.keychainAccount)
+	// secret-tool reads the secret from stdin so it never appears in the process list
+	cmd.Stdin = strings.NewReader(apiKey)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to store API key in GNOME Keyring")
+	}
+	return nil
+}
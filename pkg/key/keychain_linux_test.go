@@ -0,0 +1,93 @@
+//go:build linux
+// +build linux
+
+package key
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSecretTool stores secrets in files named after the account so the tests
+// can run without a D-Bus session or GNOME Keyring
+const fakeSecretTool = `#!/bin/sh
+store="$FAKE_SECRET_STORE"
+case "$1" in
+store)
+	cat > "$store/$6"
+	;;
+lookup)
+	[ -f "$store/$5" ] || exit 1
+	cat "$store/$5"
+	;;
+esac
+`
+
+func TestLinuxSecretService(t *testing.T) {
+	binDir := t.TempDir()
+	storeDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(binDir, secretToolCommand), []byte(fakeSecretTool), 0755); err != nil {
+		t.Fatalf("Failed to create fake secret-tool: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_SECRET_STORE", storeDir)
+
+	km := NewKeyManager(false)
+
+	if !km.CredentialStoreAvailable() {
+		t.Fatalf("Expected GNOME Keyring to be available when secret-tool is installed")
+	}
+	if km.GetCredentialStoreName() != "GNOME Keyring" {
+		t.Errorf("Expected credential store name 'GNOME Keyring', got '%s'", km.GetCredentialStoreName())
+	}
+
+	// Missing entries are reported as errors
+	if _, err := km.GetFromKeychain(); err == nil {
+		t.Errorf("Expected an error when no key is stored")
+	}
+
+	testKey := "sk-ant-REDACTED"
+	if err := km.StoreInKeychain(testKey); err != nil {
+		t.Fatalf("Error storing key in GNOME Keyring: %v", err)
+	}
+
+	// The key is stored under the Anthropic account name
+	if _, err := os.Stat(filepath.Join(storeDir, KeychainAccount)); err != nil {
+		t.Errorf("Expected the key to be stored under account %s: %v", KeychainAccount, err)
+	}
+
+	key, err := km.GetFromKeychain()
+	if err != nil {
+		t.Fatalf("Error retrieving key from GNOME Keyring: %v", err)
+	}
+	if key != testKey {
+		t.Errorf("Expected '%s', got '%s'", testKey, key)
+	}
+
+	// Provider keys use their own account
+	if err := km.StoreProviderKey("openai", "sk-openai-test-key-12345"); err != nil {
+		t.Fatalf("Error storing provider key: %v", err)
+	}
+	if key, _ := km.getFromCredentialStore(OpenAIAccount); key != "sk-openai-test-key-12345" {
+		t.Errorf("Expected OpenAI key from its own account, got '%s'", key)
+	}
+}
+
+func TestLinuxSecretServiceMissing(t *testing.T) {
+	// An empty PATH means secret-tool cannot be found
+	t.Setenv("PATH", t.TempDir())
+
+	km := NewKeyManager(false)
+
+	if km.CredentialStoreAvailable() {
+		t.Errorf("Expected no credential store without secret-tool")
+	}
+	if km.GetCredentialStoreName() != "none" {
+		t.Errorf("Expected credential store name 'none', got '%s'", km.GetCredentialStoreName())
+	}
+	if err := km.StoreInKeychain("sk-ant-REDACTED"); err == nil {
+		t.Errorf("Expected an error storing a key without secret-tool")
+	}
+}
@@ -50,7 +50,7 @@ func (k *KeyManager) GetProviderKey(provider string, cmdLineKey string) (string,
 	}
 
 	// Try credential store
-	k.log("No API key found in environment, checking credential store for account: %s", account)
+	k.log("No API key found in environment, checking credential store for service: %s, account: %s", k.keychainService, account)
 	credStoreKey, err := k.getFromCredentialStore(account)
 	if err != nil {
 		k.log("Error retrieving API key from credential store: %v", err)
@@ -68,7 +68,7 @@ func (k *KeyManager) GetProviderKey(provider string, cmdLineKey string) (string,
 // StoreProviderKey stores the API key for a specific provider
 func (k *KeyManager) StoreProviderKey(provider string, apiKey string) error {
 	account, _ := k.GetProviderKeyInfo(provider)
-	k.log("Storing API key for provider: %s with account: %s", provider, account)
+	k.log("Storing API key for provider: %s with service: %s, account: %s", provider, k.keychainService, account)
 	return k.storeInCredentialStore(account, apiKey)
 }
 
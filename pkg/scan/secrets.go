@@ -0,0 +1,214 @@
+// Package scan looks for secrets in a diff before it is sent to a provider
+package scan
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule is a kind of secret and the pattern that finds it
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRules find the secrets most often committed by mistake
+var DefaultRules = []Rule{
+	{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret access key", regexp.MustCompile(`(?i)aws_?secret_?access_?key\W{1,4}[A-Za-z0-9/+=]{40}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY( BLOCK)?-----`)},
+	{"API key", regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{20,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9\-]{10,}`)},
+}
+
+// Finding is a likely secret in a diff
+type Finding struct {
+	Rule  string // Name of the rule that found it, e.g. "AWS access key ID"
+	File  string // File the line belongs to, if known
+	Line  int    // Line number in the file, or 0 if unknown
+	Match string // The secret with all but its first characters masked
+}
+
+// String describes the finding, e.g. "config/aws.go:12: AWS access key ID (AKIA****)"
+func (f Finding) String() string {
+	location := f.File
+	if location == "" {
+		location = "diff"
+	}
+	if f.Line > 0 {
+		location += ":" + strconv.Itoa(f.Line)
+	}
+	return fmt.Sprintf("%s: %s (%s)", location, f.Rule, f.Match)
+}
+
+// highEntropyRule names findings of random-looking strings, which no pattern describes
+const highEntropyRule = "high-entropy string"
+
+// entropyToken matches the strings checked for entropy: long runs of the characters
+// used by base64 and hex encoded keys
+var entropyToken = regexp.MustCompile(`[A-Za-z0-9+/=_\-]{32,}`)
+
+// minEntropy is the Shannon entropy, in bits per character, above which a token looks
+// random. Words, identifiers and hex hashes stay below it; base64 keys don't.
+const minEntropy = 4.5
+
+// lockFiles list checksums and hashes that would all look like secrets
+var lockFiles = map[string]bool{
+	"go.sum": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"Cargo.lock": true, "poetry.lock": true, "Gemfile.lock": true, "composer.lock": true,
+}
+
+// hunkHeader captures the first line numbers of a hunk, e.g. "@@ -10,4 +12,6 @@"
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)`)
+
+// CompileRules turns regular expressions from the config into rules named after them
+func CompileRules(patterns []string) ([]Rule, error) {
+	var rules []Rule
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, Rule{Name: "pattern " + pattern, Pattern: re})
+	}
+	return rules, nil
+}
+
+// FindSecrets returns the likely secrets on the lines of a diff, found by DefaultRules,
+// the extra rules and the entropy check. Removed lines are scanned too, since they are
+// sent as well. Lock files are skipped.
+func FindSecrets(diff string, extra ...Rule) []Finding {
+	rules := append(append([]Rule{}, DefaultRules...), extra...)
+
+	var findings []Finding
+	file := ""
+	oldLine, newLine := 0, 0
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			if idx := strings.LastIndex(line, " b/"); idx >= 0 {
+				file = line[idx+3:]
+			}
+			oldLine, newLine = 0, 0
+			continue
+		case isFileHeader(lines, i):
+			// Plain diffs may follow the name with a tab and a timestamp
+			name := strings.SplitN(line[4:], "\t", 2)[0]
+			if name = strings.TrimPrefix(strings.TrimPrefix(name, "b/"), "a/"); name != "/dev/null" {
+				file = name
+			}
+			continue
+		case strings.HasPrefix(line, "@@"):
+			if match := hunkHeader.FindStringSubmatch(line); match != nil {
+				oldLine, _ = strconv.Atoi(match[1])
+				newLine, _ = strconv.Atoi(match[2])
+			}
+			continue
+		}
+		if lockFiles[path.Base(file)] {
+			continue
+		}
+
+		number := 0
+		switch {
+		case strings.HasPrefix(line, "-") && oldLine > 0:
+			number = oldLine
+			oldLine++
+		case strings.HasPrefix(line, "+") && newLine > 0:
+			number = newLine
+			newLine++
+		case strings.HasPrefix(line, " ") && newLine > 0:
+			number = newLine
+			oldLine++
+			newLine++
+		}
+		findings = append(findings, scanLine(line, file, number, rules)...)
+	}
+	return findings
+}
+
+// isFileHeader reports whether the i-th line is one of the "---"/"+++" lines naming
+// a file, rather than a removed or added line that happens to start the same way
+func isFileHeader(lines []string, i int) bool {
+	switch {
+	case strings.HasPrefix(lines[i], "--- "):
+		return i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+	case strings.HasPrefix(lines[i], "+++ "):
+		return i > 0 && strings.HasPrefix(lines[i-1], "--- ")
+	}
+	return false
+}
+
+// FindSecretsInFile returns the likely secrets in the whole content of a file, as sent
+// for enhanced context
+func FindSecretsInFile(file, content string, extra ...Rule) []Finding {
+	if lockFiles[path.Base(file)] {
+		return nil
+	}
+	rules := append(append([]Rule{}, DefaultRules...), extra...)
+
+	var findings []Finding
+	for i, line := range strings.Split(content, "\n") {
+		findings = append(findings, scanLine(line, file, i+1, rules)...)
+	}
+	return findings
+}
+
+// scanLine applies the rules and the entropy check to one line. A string found by a
+// rule isn't reported again as high-entropy.
+func scanLine(line, file string, number int, rules []Rule) []Finding {
+	var findings []Finding
+	for _, rule := range rules {
+		for _, match := range rule.Pattern.FindAllString(line, -1) {
+			findings = append(findings, Finding{Rule: rule.Name, File: file, Line: number, Match: mask(match)})
+		}
+	}
+	if len(findings) > 0 {
+		return findings
+	}
+
+	for _, token := range entropyToken.FindAllString(line, -1) {
+		if looksRandom(token) {
+			findings = append(findings, Finding{Rule: highEntropyRule, File: file, Line: number, Match: mask(token)})
+		}
+	}
+	return findings
+}
+
+// looksRandom reports whether a token mixes letters and digits with high entropy, as
+// generated keys do
+func looksRandom(token string) bool {
+	hasLetter := strings.IndexFunc(token, func(r rune) bool { return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') }) >= 0
+	hasDigit := strings.IndexFunc(token, func(r rune) bool { return r >= '0' && r <= '9' }) >= 0
+	return hasLetter && hasDigit && entropy(token) >= minEntropy
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var bits float64
+	for _, count := range counts {
+		p := float64(count) / float64(len(s))
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// mask keeps the first four characters of a secret so it can be recognized, e.g.
+// "AKIA****"
+func mask(secret string) string {
+	if len(secret) <= 4 {
+		return "****"
+	}
+	return secret[:4] + "****"
+}
@@ -0,0 +1,104 @@
+package setup
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MessageID names one of the texts shown during setup
+type MessageID string
+
+const (
+	MsgWelcome          MessageID = "welcome"
+	MsgFirstTime        MessageID = "first_time"
+	MsgChooseProvider   MessageID = "choose_provider"
+	MsgProviderOption   MessageID = "provider_option"
+	MsgProviderPrompt   MessageID = "provider_prompt"
+	MsgInvalidChoice    MessageID = "invalid_choice"
+	MsgProviderSelected MessageID = "provider_selected"
+	MsgKeyNeeded        MessageID = "key_needed"
+	MsgKeySteps         MessageID = "key_steps"
+	MsgKeyPrompt        MessageID = "key_prompt"
+	MsgNoKey            MessageID = "no_key"
+	MsgNoKeyHelp        MessageID = "no_key_help"
+	MsgKeyFormat        MessageID = "key_format"
+	MsgKeyLength        MessageID = "key_length"
+	MsgKeyContinue      MessageID = "key_continue"
+	MsgCancelled        MessageID = "cancelled"
+	MsgStorageTitle     MessageID = "storage_title"
+	MsgStorageIntro     MessageID = "storage_intro"
+	MsgStorePrompt      MessageID = "store_prompt"
+	MsgStoreFailed      MessageID = "store_failed"
+	MsgEnterEachTime    MessageID = "enter_each_time"
+	MsgStored           MessageID = "stored"
+	MsgNotStored        MessageID = "not_stored"
+	MsgEnvTip           MessageID = "env_tip"
+	MsgEnvExample       MessageID = "env_example"
+	MsgNoStore          MessageID = "no_store"
+	MsgInstallSecret    MessageID = "install_secret_tool"
+	MsgEnvAlternative   MessageID = "env_alternative"
+	MsgKeyStored        MessageID = "key_stored"
+)
+
+// Messages is the catalog of texts shown during setup and when a key is stored with
+// --store-key. Each is a fmt format; replace entries to change or translate them.
+var Messages = map[MessageID]string{
+	MsgWelcome:          "===== Welcome to AI Commit Message Generator =====",
+	MsgFirstTime:        "It looks like this is the first time you're using this tool.",
+	MsgChooseProvider:   "Let's start by choosing your default AI provider:",
+	MsgProviderOption:   "%d. %s",
+	MsgProviderPrompt:   "Enter the number of your preferred provider (1-%d): ",
+	MsgInvalidChoice:    "Invalid choice. Please enter a number between 1 and %d.",
+	MsgProviderSelected: "You've selected %s as your default provider.",
+	MsgKeyNeeded:        "To use this tool, you'll need an API key from %s.",
+	MsgKeySteps:         "Getting an API key is quick and easy:\n1. Visit: %s\n2. Sign up or log in to your account\n3. Navigate to the API keys section\n4. Create a new API key",
+	MsgKeyPrompt:        "Paste your API key here: ",
+	MsgNoKey:            "No API key provided. The tool cannot proceed without an API key.",
+	MsgNoKeyHelp:        "If you're having trouble, visit %s to obtain a key.",
+	MsgKeyFormat:        "Warning: The provided %s API key format looks incorrect.",
+	MsgKeyLength:        "- Should be at least 20 characters long",
+	MsgKeyContinue:      "Are you sure you want to continue with this key? (y/n): ",
+	MsgCancelled:        "API key setup cancelled. Exiting.",
+	MsgStorageTitle:     "🔐 API Key Storage",
+	MsgStorageIntro:     "You can securely store your API key in your system's credential manager.\nThis allows you to use the tool without re-entering the key each time.",
+	MsgStorePrompt:      "Would you like to store your %s API key in %s? (y/n): ",
+	MsgStoreFailed:      "Error storing API key: %v",
+	MsgEnterEachTime:    "You'll need to enter the API key manually each time you use the tool.",
+	MsgStored:           "✅ Success!\nAPI key stored securely in %s.\nYou won't need to enter it again on this machine.",
+	MsgNotStored:        "⚠️  API key will not be stored.",
+	MsgEnvTip:           "Tip: You can also set the %s environment variable to avoid manual entry.",
+	MsgEnvExample:       "Example: export %s=your_%s_api_key_here",
+	MsgNoStore:          "⚠️  No secure credential store available on your platform.",
+	MsgInstallSecret:    "Install secret-tool (libsecret) to store keys in GNOME Keyring.",
+	MsgEnvAlternative:   "Recommended alternative: Set the %s environment variable.",
+	MsgKeyStored:        "API key stored successfully in %s.",
+}
+
+// Text formats the message with args, leaving out emoji when noEmoji is set
+func Text(id MessageID, noEmoji bool, args ...interface{}) string {
+	format, ok := Messages[id]
+	if !ok {
+		format = string(id)
+	}
+	text := fmt.Sprintf(format, args...)
+	if noEmoji {
+		text = stripEmoji(text)
+	}
+	return text
+}
+
+// stripEmoji removes emoji, and the spaces after them, from the start of each line.
+// Emoji are symbols such as ✅ and 🔐, possibly followed by a variation selector as in ⚠️.
+func stripEmoji(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		stripped := strings.TrimLeftFunc(line, func(r rune) bool {
+			return unicode.Is(unicode.So, r) || unicode.Is(unicode.Variation_Selector, r)
+		})
+		if stripped != line {
+			lines[i] = strings.TrimLeft(stripped, " ")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,238 @@
+// Package setup runs the first-time setup, which asks for the default provider and its
+// API key and offers to keep the key in the system's credential store
+package setup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nycjay/ai-commit-msg/pkg/key"
+)
+
+// ErrCancelled is returned when the user gives no key or rejects a key that looks wrong.
+// The reason has already been shown.
+var ErrCancelled = errors.New("API key setup cancelled")
+
+// Provider is a provider the setup offers
+type Provider struct {
+	Name           string // As shown, e.g. "OpenAI"
+	ID             string // As configured, e.g. "openai"
+	KeyURL         string // Where to create an API key
+	KeyPattern     string // Regular expression a key is expected to match
+	KeyDescription string // Shown when the key doesn't match KeyPattern
+}
+
+// Providers are the providers offered, in order
+var Providers = []Provider{
+	{"Anthropic", "anthropic", "https://console.anthropic.com/", `^sk-ant-|^sk-`, "Anthropic API keys typically start with 'sk-ant-' or 'sk-'"},
+	{"OpenAI", "openai", "https://platform.openai.com/account/api-keys", `^sk-`, "OpenAI API keys typically start with 'sk-'"},
+	{"Gemini", "gemini", "https://makersuite.google.com/app/apikey", `.+`, "Gemini API keys"}, // Gemini keys have less strict format requirements
+}
+
+// KeyStore is the credential store the key can be kept in, such as a *key.KeyManager
+type KeyStore interface {
+	CredentialStoreAvailable() bool
+	GetCredentialStoreName() string
+	GetPlatform() key.Platform
+}
+
+// Result is what the user chose during setup
+type Result struct {
+	Provider string // ID of the chosen provider
+	APIKey   string
+	Stored   bool // Whether the key was saved in the credential store
+}
+
+// Wizard asks the setup questions on In and Out
+type Wizard struct {
+	In      io.Reader
+	Out     io.Writer
+	NoEmoji bool // Leave emoji out of the messages
+	Quiet   bool // Only show questions, warnings and errors
+
+	Store    KeyStore
+	StoreKey func(provider, apiKey string) error // Saves the key in Store
+
+	// ReadSecret shows the prompt and reads the API key without echoing it. When nil
+	// the key is read as a line from In.
+	ReadSecret func(prompt string) (string, error)
+
+	reader *bufio.Reader
+}
+
+// Run asks for the provider and API key, offers to store the key and returns the
+// choices. It returns ErrCancelled when the user gives up.
+func (w *Wizard) Run() (Result, error) {
+	w.reader = bufio.NewReader(w.In)
+
+	w.info("")
+	w.info(w.text(MsgWelcome))
+	w.info(w.text(MsgFirstTime))
+
+	provider, err := w.chooseProvider()
+	if err != nil {
+		return Result{}, err
+	}
+
+	apiKey, err := w.readKey(provider)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Provider: provider.ID, APIKey: apiKey}
+	result.Stored = w.offerToStore(provider, apiKey)
+	return result, nil
+}
+
+// chooseProvider lists the providers and asks until one is picked
+func (w *Wizard) chooseProvider() (Provider, error) {
+	w.say("")
+	w.say(w.text(MsgChooseProvider))
+	for i, provider := range Providers {
+		w.say(w.text(MsgProviderOption, i+1, provider.Name))
+	}
+
+	for {
+		w.say("")
+		w.ask(w.text(MsgProviderPrompt, len(Providers)))
+		line, err := w.readLine()
+		if err != nil {
+			return Provider{}, err
+		}
+		if choice, err := strconv.Atoi(line); err == nil && choice >= 1 && choice <= len(Providers) {
+			return Providers[choice-1], nil
+		}
+		w.say(w.text(MsgInvalidChoice, len(Providers)))
+	}
+}
+
+// readKey explains how to get a key, reads it and checks its format
+func (w *Wizard) readKey(provider Provider) (string, error) {
+	w.info("")
+	w.info(w.text(MsgProviderSelected, provider.Name))
+	w.info("")
+	w.info(w.text(MsgKeyNeeded, provider.Name))
+	w.info(w.text(MsgKeySteps, provider.KeyURL))
+	w.info("")
+
+	var entered string
+	var err error
+	if w.ReadSecret != nil {
+		entered, err = w.ReadSecret(w.text(MsgKeyPrompt))
+	} else {
+		w.ask(w.text(MsgKeyPrompt))
+		entered, err = w.readLine()
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("error reading API key: %w", err)
+	}
+
+	apiKey := strings.TrimSpace(entered)
+	if apiKey == "" {
+		w.say("")
+		w.say(w.text(MsgNoKey))
+		w.say(w.text(MsgNoKeyHelp, provider.KeyURL))
+		return "", ErrCancelled
+	}
+
+	if matched, _ := regexp.MatchString(provider.KeyPattern, apiKey); !matched {
+		w.say("")
+		w.say(w.text(MsgKeyFormat, provider.Name))
+		w.say(provider.KeyDescription)
+		w.say(w.text(MsgKeyLength))
+		w.say("")
+		w.ask(w.text(MsgKeyContinue))
+		if !w.confirm() {
+			w.say(w.text(MsgCancelled))
+			return "", ErrCancelled
+		}
+	}
+	return apiKey, nil
+}
+
+// offerToStore asks whether to keep the key in the credential store, if there is one,
+// and otherwise explains the alternatives. It returns whether the key was stored.
+func (w *Wizard) offerToStore(provider Provider, apiKey string) bool {
+	envVarName := strings.ToUpper(provider.ID) + "_API_KEY"
+	if w.Store == nil || !w.Store.CredentialStoreAvailable() {
+		w.info("")
+		w.info(w.text(MsgNoStore))
+		if w.Store != nil && w.Store.GetPlatform() == key.PlatformLinux {
+			w.info(w.text(MsgInstallSecret))
+		}
+		w.info(w.text(MsgEnvAlternative, envVarName))
+		w.info(w.text(MsgEnvExample, envVarName, provider.ID))
+		return false
+	}
+
+	storeName := w.Store.GetCredentialStoreName()
+	w.info("")
+	w.info(w.text(MsgStorageTitle))
+	w.info(w.text(MsgStorageIntro))
+	w.info("")
+	w.ask(w.text(MsgStorePrompt, provider.Name, storeName))
+	if !w.confirm() {
+		w.info("")
+		w.info(w.text(MsgNotStored))
+		w.info(w.text(MsgEnterEachTime))
+		w.info("")
+		w.info(w.text(MsgEnvTip, envVarName))
+		w.info(w.text(MsgEnvExample, envVarName, provider.ID))
+		return false
+	}
+
+	if err := w.StoreKey(provider.ID, apiKey); err != nil {
+		w.say(w.text(MsgStoreFailed, err))
+		w.say("")
+		w.say(w.text(MsgEnterEachTime))
+		return false
+	}
+	w.info("")
+	w.info(w.text(MsgStored, storeName))
+	return true
+}
+
+// confirm reads a line and reports whether it is y or yes
+func (w *Wizard) confirm() bool {
+	response, _ := w.readLine()
+	response = strings.ToLower(response)
+	return response == "y" || response == "yes"
+}
+
+// readLine reads a line of input without its line ending and surrounding spaces. At
+// the end of the input it returns what was read along with io.EOF, so that scripted
+// input that runs out doesn't make the setup ask forever.
+func (w *Wizard) readLine() (string, error) {
+	line, err := w.reader.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if err != nil && line != "" {
+		err = nil
+	}
+	return line, err
+}
+
+func (w *Wizard) text(id MessageID, args ...interface{}) string {
+	return Text(id, w.NoEmoji, args...)
+}
+
+// say shows a line the user needs to answer a question or to know what went wrong
+func (w *Wizard) say(line string) {
+	fmt.Fprintln(w.Out, line)
+}
+
+// info shows a line of guidance, unless Quiet is set
+func (w *Wizard) info(line string) {
+	if !w.Quiet {
+		fmt.Fprintln(w.Out, line)
+	}
+}
+
+// ask shows a question, leaving the cursor after it
+func (w *Wizard) ask(question string) {
+	fmt.Fprint(w.Out, question)
+}
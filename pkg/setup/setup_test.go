@@ -0,0 +1,181 @@
+package setup
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nycjay/ai-commit-msg/pkg/key"
+)
+
+// fakeStore is a credential store that records the keys stored in it
+type fakeStore struct {
+	available bool
+	platform  key.Platform
+	fail      bool
+	stored    map[string]string
+}
+
+func (s *fakeStore) CredentialStoreAvailable() bool { return s.available }
+func (s *fakeStore) GetCredentialStoreName() string { return "Test Keyring" }
+func (s *fakeStore) GetPlatform() key.Platform      { return s.platform }
+
+func (s *fakeStore) storeKey(provider, apiKey string) error {
+	if s.fail {
+		return errors.New("keyring locked")
+	}
+	if s.stored == nil {
+		s.stored = make(map[string]string)
+	}
+	s.stored[provider] = apiKey
+	return nil
+}
+
+// runWizard runs the setup with input as the user's answers and returns its output
+func runWizard(t *testing.T, input string, store *fakeStore, noEmoji, quiet bool) (Result, string, error) {
+	t.Helper()
+	var out strings.Builder
+	wizard := Wizard{
+		In:       strings.NewReader(input),
+		Out:      &out,
+		NoEmoji:  noEmoji,
+		Quiet:    quiet,
+		Store:    store,
+		StoreKey: store.storeKey,
+	}
+	result, err := wizard.Run()
+	return result, out.String(), err
+}
+
+// TestWizardStoresKey tests choosing a provider, entering a key and storing it
+func TestWizardStoresKey(t *testing.T) {
+	store := &fakeStore{available: true}
+	result, out, err := runWizard(t, "5\nabc\n2\nsk-test-key\ny\n", store, false, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != (Result{Provider: "openai", APIKey: "sk-test-key", Stored: true}) {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if store.stored["openai"] != "sk-test-key" {
+		t.Errorf("Expected the key to be stored for openai, got %v", store.stored)
+	}
+
+	// Invalid choices are asked again
+	if strings.Count(out, "Invalid choice. Please enter a number between 1 and 3.") != 2 {
+		t.Errorf("Expected two invalid choices, got:\n%s", out)
+	}
+	for _, want := range []string{"Welcome to AI Commit Message Generator", "2. OpenAI", "https://platform.openai.com/account/api-keys", "🔐 API Key Storage", "✅ Success!\nAPI key stored securely in Test Keyring."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in the output:\n%s", want, out)
+		}
+	}
+}
+
+// TestWizardDeclinesStorage tests keeping the key out of the credential store, and
+// setup without a credential store
+func TestWizardDeclinesStorage(t *testing.T) {
+	store := &fakeStore{available: true}
+	result, out, err := runWizard(t, "3\nAIza-key\nn\n", store, false, false)
+	if err != nil || result.Stored || len(store.stored) != 0 {
+		t.Errorf("Expected the key not to be stored, got %+v (err: %v)", result, err)
+	}
+	if !strings.Contains(out, "⚠️  API key will not be stored.") || !strings.Contains(out, "export GEMINI_API_KEY=your_gemini_api_key_here") {
+		t.Errorf("Expected the environment variable tip, got:\n%s", out)
+	}
+
+	store = &fakeStore{platform: key.PlatformLinux}
+	result, out, err = runWizard(t, "1\nsk-ant-key\n", store, false, false)
+	if err != nil || result.Stored || result.Provider != "anthropic" {
+		t.Errorf("Unexpected result %+v (err: %v)", result, err)
+	}
+	if !strings.Contains(out, "No secure credential store") || !strings.Contains(out, "Install secret-tool") {
+		t.Errorf("Expected the credential store alternatives, got:\n%s", out)
+	}
+
+	// A failure to store the key is reported, and the key is still used
+	store = &fakeStore{available: true, fail: true}
+	result, out, err = runWizard(t, "1\nsk-ant-key\nyes\n", store, false, false)
+	if err != nil || result.Stored || result.APIKey != "sk-ant-key" {
+		t.Errorf("Unexpected result %+v (err: %v)", result, err)
+	}
+	if !strings.Contains(out, "Error storing API key: keyring locked") {
+		t.Errorf("Expected the storage error, got:\n%s", out)
+	}
+}
+
+// TestWizardCancelled tests giving up without a usable key
+func TestWizardCancelled(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		shown string
+	}{
+		{"no key", "1\n\n", "No API key provided."},
+		{"rejected key format", "1\nnot-a-key\nn\n", "API key setup cancelled."},
+		{"input runs out", "1\n", "No API key provided."},
+	}
+
+	for _, tt := range tests {
+		_, out, err := runWizard(t, tt.input, &fakeStore{}, false, false)
+		if !errors.Is(err, ErrCancelled) {
+			t.Errorf("%s: expected ErrCancelled, got %v", tt.name, err)
+		}
+		if !strings.Contains(out, tt.shown) {
+			t.Errorf("%s: expected %q in the output:\n%s", tt.name, tt.shown, out)
+		}
+	}
+
+	// A key in an unexpected format can still be accepted
+	result, _, err := runWizard(t, "1\nnot-a-key\ny\n", &fakeStore{}, false, false)
+	if err != nil || result.APIKey != "not-a-key" {
+		t.Errorf("Expected the key to be accepted, got %+v (err: %v)", result, err)
+	}
+
+	// Input that runs out before a provider is picked isn't asked for forever
+	if _, _, err := runWizard(t, "9\n", &fakeStore{}, false, false); err == nil {
+		t.Error("Expected an error when the input runs out")
+	}
+}
+
+// TestWizardNoEmojiQuiet tests leaving out emoji, and the guidance under --quiet
+func TestWizardNoEmojiQuiet(t *testing.T) {
+	_, out, err := runWizard(t, "1\nsk-ant-key\ny\n", &fakeStore{available: true}, true, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.ContainsAny(out, "🔐✅⚠") {
+		t.Errorf("Expected no emoji, got:\n%s", out)
+	}
+	if !strings.Contains(out, "\nAPI Key Storage\n") || !strings.Contains(out, "\nSuccess!\n") {
+		t.Errorf("Expected the messages without their emoji, got:\n%s", out)
+	}
+
+	_, out, err = runWizard(t, "1\nsk-ant-key\ny\n", &fakeStore{available: true}, false, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, hidden := range []string{"Welcome", "Visit:", "API Key Storage", "Success!"} {
+		if strings.Contains(out, hidden) {
+			t.Errorf("Expected %q to be left out under --quiet, got:\n%s", hidden, out)
+		}
+	}
+	for _, shown := range []string{"1. Anthropic", "Enter the number of your preferred provider (1-3): ", "Paste your API key here: ", "Would you like to store your Anthropic API key in Test Keyring? (y/n): "} {
+		if !strings.Contains(out, shown) {
+			t.Errorf("Expected %q to be shown under --quiet, got:\n%s", shown, out)
+		}
+	}
+}
+
+// TestText tests formatting catalog messages with and without emoji
+func TestText(t *testing.T) {
+	if got := Text(MsgKeyStored, false, "macOS Keychain"); got != "API key stored successfully in macOS Keychain." {
+		t.Errorf("Unexpected text %q", got)
+	}
+	if got := Text(MsgNotStored, true); got != "API key will not be stored." {
+		t.Errorf("Expected the emoji and its spaces to be removed, got %q", got)
+	}
+	if got := Text(MsgStored, true, "GNOME Keyring"); !strings.HasPrefix(got, "Success!\nAPI key stored securely in GNOME Keyring.") {
+		t.Errorf("Unexpected text %q", got)
+	}
+}
@@ -0,0 +1,231 @@
+// Package ui asks the user to choose between actions such as committing, editing or
+// regenerating a message. On a terminal a single keypress picks a choice; otherwise a
+// line of input is read.
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Action is what a choice does
+type Action string
+
+const (
+	ActionYes             Action = "yes"
+	ActionEdit            Action = "edit"
+	ActionNo              Action = "no"
+	ActionRegenerate      Action = "regenerate"
+	ActionCopy            Action = "copy"
+	ActionSelectCandidate Action = "select-candidate"
+	ActionBody            Action = "body"
+	ActionModel           Action = "model"
+)
+
+// Actions lists the actions whose keys can be changed with Rebind
+var Actions = []Action{ActionYes, ActionEdit, ActionNo, ActionRegenerate, ActionCopy, ActionBody, ActionModel}
+
+// Choice is one of the options offered by Confirm
+type Choice struct {
+	Action Action
+	Key    string // Key that picks the choice, e.g. "y"
+	Label  string // Shown with the key marked, e.g. "yes" is shown as "(y)es"
+	Index  int    // Candidate number, from 1, for ActionSelectCandidate
+}
+
+// NewChoice returns a choice labelled with the action's name, picked with key
+func NewChoice(action Action, key string) Choice {
+	return Choice{Action: action, Key: key, Label: string(action)}
+}
+
+// CandidateChoices returns a choice for each of count candidate messages, picked with
+// its number
+func CandidateChoices(count int) []Choice {
+	choices := make([]Choice, count)
+	for i := range choices {
+		number := strconv.Itoa(i + 1)
+		choices[i] = Choice{Action: ActionSelectCandidate, Key: number, Label: number, Index: i + 1}
+	}
+	return choices
+}
+
+// String shows the choice with its key in parentheses: "(y)es", "re(g)enerate", or
+// "copy (x)" when the label doesn't contain the key
+func (c Choice) String() string {
+	if c.Label == c.Key {
+		return "(" + c.Key + ")"
+	}
+	if i := strings.Index(strings.ToLower(c.Label), c.Key); i >= 0 {
+		return c.Label[:i] + "(" + c.Label[i:i+len(c.Key)] + ")" + c.Label[i+len(c.Key):]
+	}
+	return fmt.Sprintf("%s (%s)", c.Label, c.Key)
+}
+
+// Prompt lists the choices, e.g. "(y)es/(e)dit/(n)o"
+func Prompt(options []Choice) string {
+	shown := make([]string, len(options))
+	for i, option := range options {
+		shown[i] = option.String()
+	}
+	return strings.Join(shown, "/")
+}
+
+// Rebind changes the keys of the choices to the ones in keys, which maps action names
+// to keys, e.g. {"regenerate": "g"}. Keys must be single characters and no two choices
+// may end up with the same key.
+func Rebind(options []Choice, keys map[string]string) ([]Choice, error) {
+	for name, key := range keys {
+		if !isAction(Action(name)) {
+			return nil, fmt.Errorf("unknown action %q; use one of %s", name, actionNames())
+		}
+		if len(key) != 1 || key[0] <= ' ' || key[0] > '~' {
+			return nil, fmt.Errorf("the key for %s must be a single character, not %q", name, key)
+		}
+	}
+
+	bound := make([]Choice, len(options))
+	used := make(map[string]Action)
+	for i, option := range options {
+		if key, ok := keys[string(option.Action)]; ok {
+			option.Key = strings.ToLower(key)
+		}
+		if other, ok := used[option.Key]; ok {
+			return nil, fmt.Errorf("%s and %s both use the key %q", other, option.Action, option.Key)
+		}
+		used[option.Key] = option.Action
+		bound[i] = option
+	}
+	return bound, nil
+}
+
+// Confirm shows the choices and returns the one picked. Anything that isn't one of
+// the keys or labels, such as an empty line, Escape or Ctrl-C, picks the last choice,
+// so it should be the safe one, such as (n)o.
+func Confirm(options []Choice) Choice {
+	fmt.Fprint(output, Prompt(options)+": ")
+
+	fd := int(os.Stdin.Fd())
+	if singleKeys(options) && term.IsTerminal(fd) {
+		if choice, ok := readKey(fd, options); ok {
+			return choice
+		}
+	}
+	return readLine(input, options)
+}
+
+// Ask shows a question and returns the line typed in answer, trimmed, or "" when
+// nothing was typed. It reads one byte at a time so that input after the line is left
+// for the next question.
+func Ask(question string) string {
+	fmt.Fprint(output, question)
+
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		if n, err := input.Read(buf); err != nil || n == 0 || buf[0] == '\n' {
+			break
+		}
+		line = append(line, buf[0])
+	}
+	return strings.TrimSpace(string(line))
+}
+
+var (
+	// input is read when stdin isn't a terminal
+	input io.Reader = os.Stdin
+
+	// output receives the prompt and the echoed keypress, on stderr like the rest of
+	// the questions so stdout can be piped
+	output io.Writer = os.Stderr
+)
+
+// readKey reads a single keypress in raw mode, ignoring keys that pick nothing. It
+// returns false if the terminal can't be put in raw mode.
+func readKey(fd int, options []Choice) (Choice, bool) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return Choice{}, false
+	}
+
+	var choice Choice
+	buf := make([]byte, 1)
+	for {
+		if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+			choice = options[len(options)-1]
+			break
+		}
+		// Enter, Escape, Ctrl-C and Ctrl-D pick the last choice as an empty line would
+		if key := buf[0]; key == '\r' || key == '\n' || key == 27 || key == 3 || key == 4 {
+			choice = options[len(options)-1]
+			break
+		}
+		if match, ok := find(options, string(buf[0])); ok {
+			choice = match
+			break
+		}
+	}
+
+	term.Restore(fd, state)
+	fmt.Fprintln(output, choice.Key)
+	return choice, true
+}
+
+// readLine reads a line and returns the choice whose key or label it names
+func readLine(r io.Reader, options []Choice) Choice {
+	var response string
+	fmt.Fscanln(r, &response)
+	if choice, ok := find(options, response); ok {
+		return choice
+	}
+	return options[len(options)-1]
+}
+
+// find returns the choice whose key or label is response, ignoring case
+func find(options []Choice, response string) (Choice, bool) {
+	response = strings.ToLower(strings.TrimSpace(response))
+	if response == "" {
+		return Choice{}, false
+	}
+	for _, option := range options {
+		if response == option.Key || response == strings.ToLower(option.Label) {
+			return option, true
+		}
+	}
+	return Choice{}, false
+}
+
+// singleKeys reports whether every choice is picked with one character, so a single
+// keypress is enough. Ten or more candidates need a line of input.
+func singleKeys(options []Choice) bool {
+	for _, option := range options {
+		if len(option.Key) != 1 {
+			return false
+		}
+	}
+	return len(options) > 0
+}
+
+func isAction(action Action) bool {
+	for _, known := range Actions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
+// actionNames returns the names of the actions that can be rebound, sorted
+func actionNames() string {
+	names := make([]string, len(Actions))
+	for i, action := range Actions {
+		names[i] = string(action)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
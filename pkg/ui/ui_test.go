@@ -0,0 +1,120 @@
+package ui
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func confirmChoices() []Choice {
+	return []Choice{
+		NewChoice(ActionYes, "y"),
+		NewChoice(ActionEdit, "e"),
+		NewChoice(ActionRegenerate, "r"),
+		NewChoice(ActionCopy, "c"),
+		NewChoice(ActionNo, "n"),
+	}
+}
+
+// TestPrompt tests showing the choices with their keys marked
+func TestPrompt(t *testing.T) {
+	if prompt := Prompt(confirmChoices()); prompt != "(y)es/(e)dit/(r)egenerate/(c)opy/(n)o" {
+		t.Errorf("Unexpected prompt %q", prompt)
+	}
+
+	choices := append(CandidateChoices(2), NewChoice(ActionRegenerate, "g"), NewChoice(ActionCopy, "x"))
+	if prompt := Prompt(choices); prompt != "(1)/(2)/re(g)enerate/copy (x)" {
+		t.Errorf("Unexpected prompt %q", prompt)
+	}
+}
+
+// TestConfirmLineInput tests picking a choice from a line of input, as when stdin
+// isn't a terminal
+func TestConfirmLineInput(t *testing.T) {
+	defer func(in io.Reader, out io.Writer) { input, output = in, out }(input, output)
+
+	tests := []struct {
+		line   string
+		action Action
+	}{
+		{"y\n", ActionYes},
+		{"Edit\n", ActionEdit},
+		{"r\n", ActionRegenerate},
+		{"copy\n", ActionCopy},
+		{"\n", ActionNo},
+		{"whatever\n", ActionNo},
+		{"", ActionNo},
+	}
+	for _, tt := range tests {
+		var prompt strings.Builder
+		input, output = strings.NewReader(tt.line), &prompt
+		if choice := Confirm(confirmChoices()); choice.Action != tt.action {
+			t.Errorf("Confirm with %q = %s, want %s", tt.line, choice.Action, tt.action)
+		}
+		if prompt.String() != "(y)es/(e)dit/(r)egenerate/(c)opy/(n)o: " {
+			t.Errorf("Unexpected prompt %q", prompt.String())
+		}
+	}
+
+	// Candidates are picked by number, including ones past 9
+	input, output = strings.NewReader("12\n"), io.Discard
+	choice := Confirm(append(CandidateChoices(12), NewChoice(ActionNo, "n")))
+	if choice.Action != ActionSelectCandidate || choice.Index != 12 {
+		t.Errorf("Expected candidate 12, got %+v", choice)
+	}
+}
+
+// TestRebind tests changing the keys of the choices from key_bindings
+// TestAsk tests reading a freeform answer without consuming the input after it
+func TestAsk(t *testing.T) {
+	defer func(in io.Reader, out io.Writer) { input, output = in, out }(input, output)
+
+	var prompt strings.Builder
+	input, output = strings.NewReader("  too vague, mention the parser \nr\n"), &prompt
+	if answer := Ask("Why? "); answer != "too vague, mention the parser" {
+		t.Errorf("Unexpected answer %q", answer)
+	}
+	if prompt.String() != "Why? " {
+		t.Errorf("Unexpected prompt %q", prompt.String())
+	}
+
+	// The next line is still there for the following question
+	if choice := Confirm(confirmChoices()); choice.Action != ActionRegenerate {
+		t.Errorf("Expected the next line to be read by Confirm, got %s", choice.Action)
+	}
+
+	// An empty line or the end of the input is no answer
+	input = strings.NewReader("\n")
+	if answer := Ask("Why? "); answer != "" {
+		t.Errorf("Expected no answer, got %q", answer)
+	}
+	input = strings.NewReader("")
+	if answer := Ask("Why? "); answer != "" {
+		t.Errorf("Expected no answer at the end of the input, got %q", answer)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	bound, err := Rebind(confirmChoices(), map[string]string{"regenerate": "G", "copy": "x"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if prompt := Prompt(bound); prompt != "(y)es/(e)dit/re(g)enerate/copy (x)/(n)o" {
+		t.Errorf("Unexpected prompt %q", prompt)
+	}
+	if choice, ok := find(bound, "g"); !ok || choice.Action != ActionRegenerate {
+		t.Errorf("Expected g to regenerate, got %+v", choice)
+	}
+
+	invalid := []map[string]string{
+		{"regenerate": "y"},       // Same key as yes
+		{"regenerate": "gg"},      // More than one character
+		{"rewrite": "w"},          // Unknown action
+		{"select-candidate": "s"}, // Candidates are always picked by number
+	}
+	for _, keys := range invalid {
+		if _, err := Rebind(confirmChoices(), keys); err == nil {
+			t.Errorf("Expected an error for %v", keys)
+		}
+	}
+}
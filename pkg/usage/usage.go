@@ -0,0 +1,163 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogFileName is the name of the usage log kept in the config directory
+const LogFileName = "usage.jsonl"
+
+// Price is the cost of a model in US dollars per million input and output tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// DefaultPrices holds the list prices of the built-in models. Prices change, so
+// they can be overridden or extended with the model_prices config option.
+var DefaultPrices = map[string]Price{
+	"claude-3-haiku-20240307":    {Input: 0.25, Output: 1.25},
+	"claude-3-haiku-20231221":    {Input: 0.25, Output: 1.25},
+	"claude-3-sonnet-20240229":   {Input: 3, Output: 15},
+	"claude-3-5-sonnet-20240620": {Input: 3, Output: 15},
+	"claude-3-opus-20240229":     {Input: 15, Output: 75},
+	"gpt-4o":                     {Input: 2.5, Output: 10},
+	"gpt-4-turbo":                {Input: 10, Output: 30},
+	"gpt-4":                      {Input: 30, Output: 60},
+	"gpt-3.5-turbo":              {Input: 0.5, Output: 1.5},
+	"o1":                         {Input: 15, Output: 60},
+	"o1-mini":                    {Input: 1.1, Output: 4.4},
+	"o3":                         {Input: 2, Output: 8},
+	"o3-mini":                    {Input: 1.1, Output: 4.4},
+	"gemini-1.5-pro":             {Input: 1.25, Output: 5},
+	"gemini-1.5-flash":           {Input: 0.075, Output: 0.3},
+	"gemini-1.0-pro":             {Input: 0.5, Output: 1.5},
+}
+
+// Record is a single generation in the usage log
+type Record struct {
+	Time         time.Time `json:"time"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	Cost         float64   `json:"cost"`
+	Priced       bool      `json:"priced"` // False when the model has no known price
+}
+
+// Totals sums up the records in the usage log
+type Totals struct {
+	Calls        int
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+	Unpriced     int               // Calls to models without a known price
+	Models       map[string]Totals // Totals per model; nil within a per-model entry
+}
+
+// MergePrices returns the default prices with the configured prices applied on top.
+// Configured prices use "input" and "output" keys in dollars per million tokens.
+func MergePrices(configured map[string]map[string]float64) map[string]Price {
+	prices := make(map[string]Price, len(DefaultPrices)+len(configured))
+	for model, price := range DefaultPrices {
+		prices[model] = price
+	}
+	for model, price := range configured {
+		prices[strings.ToLower(model)] = Price{Input: price["input"], Output: price["output"]}
+	}
+	return prices
+}
+
+// Cost returns the cost in US dollars of the tokens for the model, and whether
+// the model has a known price
+func Cost(prices map[string]Price, model string, inputTokens, outputTokens int) (float64, bool) {
+	price, ok := prices[strings.ToLower(model)]
+	if !ok {
+		return 0, false
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1e6, true
+}
+
+// Append adds a record to the usage log at path, creating the file and its directory
+// if needed
+func Append(path string, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create usage log directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage log: %v", err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// ReadTotals sums up the usage log at path. A missing log has zero totals.
+func ReadTotals(path string) (Totals, error) {
+	totals := Totals{Models: make(map[string]Totals)}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return totals, nil
+	}
+	if err != nil {
+		return totals, fmt.Errorf("failed to open usage log: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			// Skip lines that were cut off or edited by hand
+			continue
+		}
+
+		totals.add(record)
+		model := totals.Models[record.Model]
+		model.add(record)
+		totals.Models[record.Model] = model
+	}
+
+	return totals, scanner.Err()
+}
+
+// ModelNames returns the models in the totals, sorted by name
+func (t Totals) ModelNames() []string {
+	names := make([]string, 0, len(t.Models))
+	for name := range t.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// add counts a record in the totals
+func (t *Totals) add(record Record) {
+	t.Calls++
+	t.InputTokens += record.InputTokens
+	t.OutputTokens += record.OutputTokens
+	t.Cost += record.Cost
+	if !record.Priced {
+		t.Unpriced++
+	}
+}
@@ -0,0 +1,79 @@
+package usage
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCost(t *testing.T) {
+	prices := MergePrices(map[string]map[string]float64{
+		"Local-Model": {"input": 1, "output": 2},
+		"gpt-4o":      {"input": 5, "output": 20},
+	})
+
+	cost, ok := Cost(prices, "claude-3-haiku-20240307", 1000000, 1000000)
+	if !ok || math.Abs(cost-1.5) > 1e-9 {
+		t.Errorf("Expected $1.50 for haiku, got %v (priced: %v)", cost, ok)
+	}
+
+	// Configured prices override the defaults and model names are case-insensitive
+	if cost, ok = Cost(prices, "gpt-4o", 1000, 500); !ok || math.Abs(cost-0.015) > 1e-9 {
+		t.Errorf("Expected the configured gpt-4o price, got %v", cost)
+	}
+	if _, ok = Cost(prices, "local-model", 1, 1); !ok {
+		t.Errorf("Expected a configured model to be priced")
+	}
+
+	if cost, ok = Cost(prices, "unknown", 1000, 1000); ok || cost != 0 {
+		t.Errorf("Expected no price for an unknown model, got %v (priced: %v)", cost, ok)
+	}
+}
+
+func TestAppendAndReadTotals(t *testing.T) {
+	// The config directory the log lives in may not have been created yet
+	path := filepath.Join(t.TempDir(), "ai-commit-msg", LogFileName)
+
+	// A missing log has zero totals
+	totals, err := ReadTotals(path)
+	if err != nil || totals.Calls != 0 {
+		t.Fatalf("Expected empty totals for a missing log, got %+v (err: %v)", totals, err)
+	}
+
+	records := []Record{
+		{Time: time.Now(), Provider: "openai", Model: "gpt-4o", InputTokens: 100, OutputTokens: 10, Cost: 0.5, Priced: true},
+		{Time: time.Now(), Provider: "openai", Model: "gpt-4o", InputTokens: 200, OutputTokens: 20, Cost: 1, Priced: true},
+		{Time: time.Now(), Provider: "custom", Model: "llama3", InputTokens: 50, OutputTokens: 5},
+	}
+	for _, record := range records {
+		if err := Append(path, record); err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+
+	// Broken lines are skipped
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	file.WriteString("{not json\n")
+	file.Close()
+
+	totals, err = ReadTotals(path)
+	if err != nil {
+		t.Fatalf("ReadTotals returned error: %v", err)
+	}
+	if totals.Calls != 3 || totals.InputTokens != 350 || totals.OutputTokens != 35 || totals.Unpriced != 1 {
+		t.Errorf("Unexpected totals: %+v", totals)
+	}
+	if math.Abs(totals.Cost-1.5) > 1e-9 {
+		t.Errorf("Expected a total cost of 1.5, got %v", totals.Cost)
+	}
+
+	names := totals.ModelNames()
+	if len(names) != 2 || names[0] != "gpt-4o" || names[1] != "llama3" {
+		t.Errorf("Unexpected models: %v", names)
+	}
+	if gpt := totals.Models["gpt-4o"]; gpt.Calls != 2 || gpt.InputTokens != 300 {
+		t.Errorf("Unexpected gpt-4o totals: %+v", gpt)
+	}
+}